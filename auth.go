@@ -0,0 +1,29 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+)
+
+// apiKeyHeader 是管理類端點驗證用的 HTTP 標頭
+const apiKeyHeader = "X-API-Key"
+
+// requireAPIKey 包裝需要 API key 的端點，請求必須在 X-API-Key 帶上與 API_KEY 相同的值
+// 未設定 API_KEY 時一律拒絕，避免管理類端點在未設定時被開放
+func requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expected := os.Getenv("API_KEY")
+		if expected == "" {
+			http.Error(w, "endpoint requires API_KEY to be configured", http.StatusForbidden)
+			return
+		}
+
+		provided := r.Header.Get(apiKeyHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) != 1 {
+			http.Error(w, "invalid or missing API key", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
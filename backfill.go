@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/sirupsen/logrus"
+)
+
+// backfill 補抓斷線期間產生的區塊 (上次處理到的區塊之後到目前最新區塊)
+// 第一次連線時尚未處理過任何區塊，不補抓；缺口超過 backfillMax 時只補抓最近的 backfillMax 個區塊
+func (w *blockWatcher) backfill(ctx context.Context, client replayBlockClient) {
+	if w.backfillMax == 0 || w.lastProcessed == 0 {
+		return
+	}
+
+	headCtx, cancel := context.WithTimeout(ctx, w.fetchTimeout)
+	head, err := client.BlockNumber(headCtx)
+	cancel()
+	if err != nil {
+		logrus.WithError(err).Warn("⚠️ 取得最新區塊號失敗，略過補抓")
+		return
+	}
+	if head <= w.lastProcessed {
+		return
+	}
+
+	from := w.lastProcessed + 1
+	if head-from+1 > w.backfillMax {
+		skipped := head - w.backfillMax + 1 - from
+		logrus.WithFields(logrus.Fields{
+			"from":    from,
+			"to":      from + skipped - 1,
+			"skipped": skipped,
+		}).Warn("⚠️ 斷線期間的區塊過多，較舊的區塊不會補抓，可用 /replay/block 手動重放")
+		from = head - w.backfillMax + 1
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"from": from,
+		"to":   head,
+	}).Info("⏪ 補抓斷線期間的區塊")
+	if err := w.backfillBlocks(ctx, client, from, head); err != nil {
+		logrus.WithError(err).Warn("⚠️ 補抓區塊中斷")
+	}
+}
+
+// backfillBlocks 依序抓取 [from, to] 區間的區塊，並如同訂閱到的區塊一樣處理
+// 抓取失敗時停止並返回錯誤；已抓取但推送失敗的區塊會保留到 retry，在下次重新連線後重試
+func (w *blockWatcher) backfillBlocks(ctx context.Context, client replayBlockClient, from, to uint64) error {
+	receipts := w.receiptsFor(client)
+	for n := from; n <= to; n++ {
+		fetchCtx, cancel := context.WithTimeout(ctx, w.fetchTimeout)
+		block, err := client.BlockByNumber(fetchCtx, new(big.Int).SetUint64(n))
+		if err != nil {
+			cancel()
+			return fmt.Errorf("failed to fetch block %d: %w", n, err)
+		}
+
+		header := block.Header()
+		observeHeader(header)
+		w.record(header, w.push(fetchCtx, receipts, block))
+		cancel()
+	}
+	return nil
+}
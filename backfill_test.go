@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// mockRangeClient 是測試用的節點，提供 [1, head] 區間的區塊並記錄依區塊號抓取的順序
+type mockRangeClient struct {
+	*mockBlockFetcher
+	head     uint64
+	byNumber map[uint64]*types.Block
+	fetched  []uint64
+	failAt   uint64
+}
+
+func newMockRangeClient(head uint64) *mockRangeClient {
+	c := &mockRangeClient{
+		mockBlockFetcher: newMockBlockFetcher(),
+		head:             head,
+		byNumber:         make(map[uint64]*types.Block),
+	}
+	for n := uint64(1); n <= head; n++ {
+		h := newTestHeader(int64(n))
+		block := types.NewBlockWithHeader(h).WithBody(types.Body{
+			Transactions: []*types.Transaction{newTestTx(n, targetAddress)},
+		})
+		c.blocks[h.Hash()] = block
+		c.byNumber[n] = block
+	}
+	return c
+}
+
+func (c *mockRangeClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.head, nil
+}
+
+func (c *mockRangeClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	n := number.Uint64()
+	c.fetched = append(c.fetched, n)
+	if n == c.failAt {
+		return nil, errors.New("connection reset")
+	}
+	return c.byNumber[n], nil
+}
+
+func (c *mockRangeClient) header(n uint64) *types.Header {
+	return c.byNumber[n].Header()
+}
+
+func newBackfillWatcher(max uint64) *blockWatcher {
+	return &blockWatcher{clock: broker.RealClock{}, grace: time.Second, fetchTimeout: time.Second, backfillMax: max}
+}
+
+func TestBackfillBlocksProcessesRange(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	client := newMockRangeClient(10)
+	w := newBackfillWatcher(defaultBackfillMaxBlocks)
+
+	if err := w.backfillBlocks(context.Background(), client, 3, 6); err != nil {
+		t.Fatalf("backfillBlocks failed: %v", err)
+	}
+
+	if got := pulledBlockNumbers(t); !reflect.DeepEqual(got, []string{"3", "4", "5", "6"}) {
+		t.Errorf("Expected blocks 3-6 to be pushed in order, got %v", got)
+	}
+	if w.lastProcessed != 6 {
+		t.Errorf("Expected lastProcessed 6, got %d", w.lastProcessed)
+	}
+}
+
+func TestBackfillBlocksStopsOnFetchError(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	client := newMockRangeClient(10)
+	client.failAt = 5
+	w := newBackfillWatcher(defaultBackfillMaxBlocks)
+
+	if err := w.backfillBlocks(context.Background(), client, 3, 8); err == nil {
+		t.Fatal("Expected error when a block cannot be fetched")
+	}
+	if got := pulledBlockNumbers(t); !reflect.DeepEqual(got, []string{"3", "4"}) {
+		t.Errorf("Expected only blocks before the failure, got %v", got)
+	}
+}
+
+func TestBlockWatcherBackfillsReconnectGap(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	client := newMockRangeClient(8)
+	w := newBackfillWatcher(defaultBackfillMaxBlocks)
+
+	// 第一次連線處理到區塊 3 後中斷
+	headers := make(chan *types.Header, 3)
+	for n := uint64(1); n <= 3; n++ {
+		headers <- client.header(n)
+	}
+	errs := make(chan error, 1)
+	errs <- errors.New("subscription dropped")
+	w.run(context.Background(), client, headers, errs)
+	pulledBlockNumbers(t)
+
+	// 斷線期間產生了區塊 4-8，重新連線後應在處理新區塊前補抓
+	errs <- errors.New("subscription dropped")
+	w.run(context.Background(), client, make(chan *types.Header), errs)
+
+	if got := pulledBlockNumbers(t); !reflect.DeepEqual(got, []string{"4", "5", "6", "7", "8"}) {
+		t.Errorf("Expected gap 4-8 to be backfilled, got %v", got)
+	}
+	if w.lastProcessed != 8 {
+		t.Errorf("Expected lastProcessed 8, got %d", w.lastProcessed)
+	}
+}
+
+func TestBackfillBoundedToMostRecentBlocks(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	client := newMockRangeClient(50)
+	w := newBackfillWatcher(5)
+	w.lastProcessed = 10
+
+	w.backfill(context.Background(), client)
+
+	if !reflect.DeepEqual(client.fetched, []uint64{46, 47, 48, 49, 50}) {
+		t.Errorf("Expected only the 5 most recent blocks to be fetched, got %v", client.fetched)
+	}
+}
+
+func TestBackfillSkippedOnFirstConnection(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	client := newMockRangeClient(5)
+	w := newBackfillWatcher(defaultBackfillMaxBlocks)
+
+	w.backfill(context.Background(), client)
+	if len(client.fetched) != 0 {
+		t.Errorf("Expected no backfill before any block was processed, got %v", client.fetched)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultBackpressureThreshold 是區塊隊列使用率的預設警戒值，超過時視為 worker 跟不上
+const defaultBackpressureThreshold = 0.8
+
+// blockBackpressure 表示區塊隊列目前是否處於背壓狀態，輸出為 queue_backpressure 指標
+var blockBackpressure atomic.Bool
+
+// underPressure 檢查區塊隊列的使用率是否超過門檻，跨越門檻時記錄日誌並更新 queue_backpressure
+// 門檻 <= 0 表示不檢查
+func (w *blockWatcher) underPressure() bool {
+	if w.pressureThreshold <= 0 {
+		return false
+	}
+
+	pressure := brokerFor(brokerPurposeBlocks).QueuePressure(blockQueueName)
+	pressured := pressure >= w.pressureThreshold
+	if pressured != blockBackpressure.Swap(pressured) {
+		fields := logrus.Fields{"queue": blockQueueName, "pressure": pressure, "threshold": w.pressureThreshold}
+		if pressured {
+			logrus.WithFields(fields).Warn("⚠️ 區塊隊列壓力過高，worker 跟不上，暫停廣播區塊摘要")
+		} else {
+			logrus.WithFields(fields).Info("✅ 區塊隊列壓力已恢復")
+		}
+	}
+	return pressured
+}
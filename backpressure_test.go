@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestBlockWatcherBackpressure(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+	t.Cleanup(func() { blockBackpressure.Store(false) })
+
+	blocks.DeclareQueue(blockQueueName, 4)
+	summaries, _ := blocks.Subscribe(blocksTopicName)
+	w := &blockWatcher{clock: broker.RealClock{}, pressureThreshold: 0.75}
+
+	// 使用率低於門檻時照常廣播區塊摘要
+	for n := int64(1); n <= 3; n++ {
+		if err := w.push(context.Background(), nil, types.NewBlockWithHeader(newTestHeader(n))); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+	}
+	if got := len(summaries); got != 3 {
+		t.Errorf("Expected 3 block summaries, got %d", got)
+	}
+	if got := scrapeGauge(t, "queue_backpressure"); got != 0 {
+		t.Errorf("Expected queue_backpressure 0, got %v", got)
+	}
+
+	// 隊列已有 3 條 (0.75)，達到門檻：區塊仍入隊，但跳過廣播
+	if err := w.push(context.Background(), nil, types.NewBlockWithHeader(newTestHeader(4))); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if got := len(summaries); got != 3 {
+		t.Errorf("Expected broadcast to be skipped under pressure, got %d summaries", got)
+	}
+	if stats, _ := blocks.GetQueueStats(blockQueueName); stats.MessageCount != 4 {
+		t.Errorf("Expected block to be queued under pressure, got %d", stats.MessageCount)
+	}
+	if got := scrapeGauge(t, "queue_backpressure"); got != 1 {
+		t.Errorf("Expected queue_backpressure 1, got %v", got)
+	}
+
+	// worker 追上後壓力恢復
+	for i := 0; i < 4; i++ {
+		blocks.PullWithTimeout(blockQueueName, time.Second)
+	}
+	if w.underPressure() {
+		t.Error("Expected pressure to recover after the queue drained")
+	}
+	if got := scrapeGauge(t, "queue_backpressure"); got != 0 {
+		t.Errorf("Expected queue_backpressure 0 after recovery, got %v", got)
+	}
+}
+
+func TestBlockWatcherBackpressureDisabled(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+	blocks.DeclareQueue(blockQueueName, 1)
+	blocks.Push(blockQueueName, broker.NewMessage("full", nil, blockQueueName))
+
+	// 門檻為 0 時不檢查
+	w := &blockWatcher{clock: broker.RealClock{}}
+	if w.underPressure() {
+		t.Error("Expected no backpressure when threshold is 0")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+)
+
+// missedBlocksQueueName 記錄重試後仍抓取失敗的區塊，供之後補抓
+const missedBlocksQueueName = "missed_blocks"
+
+// 區塊抓取重試的預設值
+const (
+	defaultBlockFetchAttempts = 3                      // 單一區塊最多抓取次數 (含第一次)
+	defaultBlockFetchBackoff  = 200 * time.Millisecond // 第一次重試前的等待時間，之後每次加倍
+)
+
+// missedBlock 是推送到 missed_blocks 隊列的消息內容
+type missedBlock struct {
+	BlockNumber string `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	Error       string `json:"error"`
+}
+
+// fetchBlock 抓取區塊詳情，失敗時以指數退避重試，最多嘗試 fetchAttempts 次
+// 每次嘗試各自套用 fetchTimeout；ctx 取消時立即放棄
+func (w *blockWatcher) fetchBlock(ctx context.Context, fetcher blockFetcher, header *types.Header) (*types.Block, error) {
+	attempts := max(w.fetchAttempts, 1)
+	backoff := w.fetchBackoff
+	for attempt := 1; ; attempt++ {
+		fetchCtx, cancel := context.WithTimeout(ctx, w.fetchTimeout)
+		block, err := fetcher.BlockByHash(fetchCtx, header.Hash())
+		cancel()
+		if err == nil {
+			return block, nil
+		}
+		if attempt >= attempts {
+			return nil, fmt.Errorf("failed to fetch block %s after %d attempts: %w", header.Number, attempt, err)
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"blockNumber": header.Number.String(),
+			"attempt":     attempt,
+			"backoff":     backoff,
+		}).WithError(err).Debug("🔁 抓取區塊失敗，稍後重試")
+		if err := w.sleep(ctx, backoff); err != nil {
+			return nil, fmt.Errorf("failed to fetch block %s: %w", header.Number, err)
+		}
+		backoff *= 2
+	}
+}
+
+// sleep 等待 d 或直到 ctx 取消
+func (w *blockWatcher) sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := w.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recordMissed 將重試後仍抓取失敗的區塊推送到 missed_blocks 隊列
+// 以區塊雜湊作為消息 ID，開啟 ID 去重時重新連線後再次失敗不會重複記錄同一區塊
+func recordMissed(header *types.Header, fetchErr error) {
+	body, err := json.Marshal(missedBlock{
+		BlockNumber: header.Number.String(),
+		BlockHash:   header.Hash().Hex(),
+		Error:       fetchErr.Error(),
+	})
+	if err != nil {
+		logrus.WithError(err).Error("❌ 序列化遺漏區塊失敗")
+		return
+	}
+
+	msg := broker.NewMessage(header.Hash().Hex(), body, missedBlocksQueueName)
+	if err := brokerFor(brokerPurposeBlocks).Push(missedBlocksQueueName, msg); err != nil {
+		logrus.WithField("blockNumber", header.Number.String()).WithError(err).Error("❌ 記錄遺漏區塊失敗")
+	}
+}
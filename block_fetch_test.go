@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+func TestFetchBlockRetriesUntilSuccess(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	clock := broker.NewFakeClock(time.Unix(1700000000, 0))
+	h1 := newTestHeader(1)
+	fetcher := newMockBlockFetcher(h1)
+	fetcher.failures[1] = 2 // 前兩次抓取失敗，第三次成功
+	w := &blockWatcher{clock: clock, fetchTimeout: time.Second, fetchAttempts: 3, fetchBackoff: 100 * time.Millisecond}
+
+	done := make(chan error, 1)
+	go func() { done <- w.process(context.Background(), fetcher, h1) }()
+
+	// 退避時間每次加倍：100ms 後第二次抓取，再 200ms 後第三次抓取
+	clock.BlockUntil(1)
+	clock.Advance(100 * time.Millisecond)
+	clock.BlockUntil(1)
+	clock.Advance(200 * time.Millisecond)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Expected block to be fetched after retries, got %v", err)
+	}
+	if len(fetcher.fetched) != 3 {
+		t.Errorf("Expected 3 fetch attempts, got %d", len(fetcher.fetched))
+	}
+	if got := pulledBlockNumbers(t); len(got) != 1 || got[0] != "1" {
+		t.Errorf("Expected block 1 to be enqueued, got %v", got)
+	}
+	if msg, _ := messageBroker.Pull(missedBlocksQueueName); msg != nil {
+		t.Errorf("Expected no missed block, got %s", msg.Body)
+	}
+}
+
+func TestFetchBlockRecordsMissedBlockAfterRetries(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	h1 := newTestHeader(1)
+	fetcher := newMockBlockFetcher(h1)
+	fetcher.failures[1] = 3
+	w := &blockWatcher{clock: broker.RealClock{}, fetchTimeout: time.Second, fetchAttempts: 3}
+
+	// 重試次數用盡：區塊記錄到 missed_blocks，並保留到 retry 待重新連線後處理
+	w.handle(context.Background(), fetcher, h1)
+	if len(fetcher.fetched) != 3 {
+		t.Errorf("Expected 3 fetch attempts, got %d", len(fetcher.fetched))
+	}
+	if len(w.retry) != 1 {
+		t.Errorf("Expected block 1 pending retry, got %d", len(w.retry))
+	}
+
+	msg, err := messageBroker.Pull(missedBlocksQueueName)
+	if err != nil {
+		t.Fatalf("Expected missed block message: %v", err)
+	}
+	var missed missedBlock
+	if err := json.Unmarshal(msg.Body, &missed); err != nil {
+		t.Fatalf("Failed to decode missed block: %v", err)
+	}
+	if missed.BlockNumber != "1" || missed.BlockHash != h1.Hash().Hex() || missed.Error == "" {
+		t.Errorf("Unexpected missed block %+v", missed)
+	}
+}
+
+func TestFetchBlockStopsOnContextCancel(t *testing.T) {
+	h1 := newTestHeader(1)
+	fetcher := newMockBlockFetcher(h1)
+	fetcher.failures[1] = 1
+	w := &blockWatcher{clock: broker.RealClock{}, fetchTimeout: time.Second, fetchAttempts: 3, fetchBackoff: time.Hour}
+
+	// 等待重試期間 ctx 取消時立即放棄
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := w.fetchBlock(ctx, fetcher, h1); err == nil {
+		t.Error("Expected error when ctx is cancelled during backoff")
+	}
+	if len(fetcher.fetched) != 1 {
+		t.Errorf("Expected a single fetch attempt, got %d", len(fetcher.fetched))
+	}
+}
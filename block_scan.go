@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+)
+
+// 區塊交易數超過上限時的掃描策略
+const (
+	scanStrategyFull   = "full"   // 仍掃描全部交易，只記錄警告 (預設，保證不漏掉匹配)
+	scanStrategySample = "sample" // 以固定間隔取樣至多 limit 筆交易
+)
+
+// scanLimitHits 是區塊交易數超過掃描上限的次數
+var scanLimitHits atomic.Int64
+
+// scanPolicy 限制單一區塊最多掃描的交易數，用於控制最壞情況下的每區塊處理量
+type scanPolicy struct {
+	Limit    int    // 0 表示不限制
+	Strategy string // scanStrategyFull 或 scanStrategySample
+}
+
+// scanPolicyFromEnv 讀取 BLOCK_SCAN_LIMIT 與 BLOCK_SCAN_STRATEGY，預設全量掃描
+func scanPolicyFromEnv() scanPolicy {
+	policy := scanPolicy{
+		Limit:    envInt("BLOCK_SCAN_LIMIT", 0),
+		Strategy: strings.ToLower(strings.TrimSpace(os.Getenv("BLOCK_SCAN_STRATEGY"))),
+	}
+	switch policy.Strategy {
+	case "":
+		policy.Strategy = scanStrategyFull
+	case scanStrategyFull, scanStrategySample:
+	default:
+		logrus.WithField("strategy", policy.Strategy).Warn("⚠️ 未知的區塊掃描策略，改用全量掃描")
+		policy.Strategy = scanStrategyFull
+	}
+	return policy
+}
+
+// selectTransactions 依策略返回要掃描的交易，以及交易數是否超過上限
+// 取樣策略以 ceil(n / limit) 為間隔取樣，讓取樣結果平均分布在整個區塊
+func (p scanPolicy) selectTransactions(txs types.Transactions) (types.Transactions, bool) {
+	if p.Limit <= 0 || len(txs) <= p.Limit {
+		return txs, false
+	}
+	if p.Strategy != scanStrategySample {
+		return txs, true
+	}
+
+	stride := (len(txs) + p.Limit - 1) / p.Limit
+	sampled := make(types.Transactions, 0, p.Limit)
+	for i := 0; i < len(txs); i += stride {
+		sampled = append(sampled, txs[i])
+	}
+	return sampled, true
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+)
+
+// 區塊處理的預設時限
+const (
+	defaultReconnectGrace    = 5 * time.Second  // 訂閱中斷後完成進行中區塊的寬限時間
+	defaultBlockFetchTimeout = 10 * time.Second // 單一區塊抓取的時限
+	defaultBackfillMaxBlocks = 100              // 重新連線後最多補抓的區塊數
+	defaultBlockTimeout      = 90 * time.Second // 超過此時間未收到新區塊即視為訂閱停滯
+)
+
+// errBlockTimeout 表示訂閱沒有報錯但已超過 BLOCK_TIMEOUT 未收到新區塊
+var errBlockTimeout = errors.New("no new block received within block timeout")
+
+// blockFetcher 是抓取區塊詳情所需的節點操作 (ethclient.Client 即實作了此介面)
+type blockFetcher interface {
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error)
+}
+
+// blockWatcher 將訂閱到的區塊抓取後推送到區塊隊列
+// 處理失敗的區塊會被保留，在重新連線後優先重新抓取，避免每次斷線都漏掉一個區塊
+type blockWatcher struct {
+	clock             broker.Clock
+	grace             time.Duration
+	fetchTimeout      time.Duration
+	fetchAttempts     int           // 單一區塊最多抓取次數，0 表示只抓取一次
+	fetchBackoff      time.Duration // 抓取失敗後第一次重試前的等待時間，之後每次加倍
+	scan              scanPolicy
+	tokens            bool          // 同時掃描收據中的 ERC-20 Transfer 事件
+	ttl               time.Duration // 區塊消息在隊列中的有效期限，0 表示不過期
+	backfillMax       uint64        // 重新連線後最多補抓的區塊數，0 表示不補抓
+	blockTimeout      time.Duration // 超過此時間未收到新區塊時中斷訂閱以重新連線，0 表示不檢查
+	codec             Codec         // 區塊消息的編碼，nil 時使用 JSON
+	pressureThreshold float64       // 區塊隊列使用率超過此值時跳過非必要的工作，0 表示不檢查
+
+	retry         []*types.Header // 尚未完整處理的區塊
+	lastProcessed uint64          // 已完整處理的最高區塊號
+}
+
+// newBlockWatcher 創建區塊監聽器，同一個實例應跨重新連線重複使用
+func newBlockWatcher() *blockWatcher {
+	return &blockWatcher{
+		clock:             clock,
+		grace:             envDuration("RECONNECT_GRACE", defaultReconnectGrace),
+		fetchTimeout:      envDuration("BLOCK_FETCH_TIMEOUT", defaultBlockFetchTimeout),
+		fetchAttempts:     envInt("BLOCK_FETCH_ATTEMPTS", defaultBlockFetchAttempts),
+		fetchBackoff:      envDuration("BLOCK_FETCH_BACKOFF", defaultBlockFetchBackoff),
+		scan:              scanPolicyFromEnv(),
+		tokens:            os.Getenv("TOKEN_TRANSFER_WATCH") == "true",
+		ttl:               envDuration("BLOCK_MESSAGE_TTL", 0),
+		backfillMax:       uint64(max(envInt("BACKFILL_MAX_BLOCKS", defaultBackfillMaxBlocks), 0)),
+		blockTimeout:      envDuration("BLOCK_TIMEOUT", defaultBlockTimeout),
+		codec:             codecFromEnv(),
+		pressureThreshold: envFloat("QUEUE_PRESSURE_THRESHOLD", defaultBackpressureThreshold),
+	}
+}
+
+// watch 訂閱節點的新區塊並處理，直到訂閱中斷或 ctx 取消，返回中斷的原因
+func (w *blockWatcher) watch(ctx context.Context, client EthClient) error {
+	if chainID, err := client.ChainID(ctx); err != nil {
+		logrus.WithError(err).Warn("⚠️ 查詢鏈 ID 失敗")
+	} else {
+		logrus.WithField("chainID", chainID.String()).Info("🔗 已連線到節點")
+	}
+
+	// 保留少量緩衝，讓訂閱中斷時已送達的區塊仍能在寬限時間內處理
+	headers := make(chan *types.Header, 16)
+	sub, err := client.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to new heads: %w", err)
+	}
+	defer sub.Unsubscribe()
+	logrus.Info("✅ 訂閱成功！正在等待新的區塊...")
+
+	// 訂閱有效期間 /ready 回報就緒，連線結束時回到未就緒；連線次數與狀態輸出到 /metrics
+	upstream.connected()
+	defer upstream.disconnected()
+
+	return w.run(ctx, client, headers, sub.Err())
+}
+
+// run 處理訂閱到的區塊直到訂閱中斷，返回中斷的錯誤
+// 中斷時會在寬限時間內處理完已經收到的區塊再返回；超過 blockTimeout 未收到新區塊時返回 errBlockTimeout
+func (w *blockWatcher) run(ctx context.Context, fetcher blockFetcher, headers <-chan *types.Header, errs <-chan error) error {
+	// 先補上次連線未完成的區塊，再補斷線期間產生的區塊
+	w.retryPending(ctx, fetcher)
+	if client, ok := fetcher.(replayBlockClient); ok {
+		w.backfill(ctx, client)
+	}
+
+	// 看門狗：供應商停止推送新區塊卻沒有報錯時訂閱會一直停在這裡，逾時後返回錯誤讓外層重新連線
+	var stalled <-chan time.Time
+	var watchdog broker.Timer
+	if w.blockTimeout > 0 {
+		watchdog = w.clock.NewTimer(w.blockTimeout)
+		defer watchdog.Stop()
+		stalled = watchdog.C()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case err := <-errs:
+			w.drain(fetcher, headers)
+			return err
+
+		case <-stalled:
+			return fmt.Errorf("%w (%s)", errBlockTimeout, w.blockTimeout)
+
+		case header := <-headers:
+			if watchdog != nil {
+				resetWatchdog(watchdog, w.blockTimeout)
+			}
+			upstream.blockReceived(w.clock.Now())
+			w.handle(ctx, fetcher, header)
+		}
+	}
+}
+
+// resetWatchdog 重新計時看門狗
+// 計時器可能在收到區塊的同時到期，先丟棄已送出的到期時間，避免下一輪誤判為停滯
+func resetWatchdog(watchdog broker.Timer, timeout time.Duration) {
+	if !watchdog.Stop() {
+		select {
+		case <-watchdog.C():
+		default:
+		}
+	}
+	watchdog.Reset(timeout)
+}
+
+// drain 在寬限時間內處理已經送達但尚未處理的區塊
+func (w *blockWatcher) drain(fetcher blockFetcher, headers <-chan *types.Header) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.grace)
+	defer cancel()
+
+	for {
+		select {
+		case header := <-headers:
+			w.handle(ctx, fetcher, header)
+		default:
+			return
+		}
+	}
+}
+
+// retryPending 重新處理上次未完成的區塊
+func (w *blockWatcher) retryPending(ctx context.Context, fetcher blockFetcher) {
+	pending := w.retry
+	w.retry = nil
+	for _, header := range pending {
+		logrus.WithField("blockNumber", header.Number.String()).Info("🔁 重新抓取上次未完成的區塊")
+		w.handle(ctx, fetcher, header)
+	}
+}
+
+// handle 處理單一區塊，失敗時保留到 retry 並暫停推進 cursor
+func (w *blockWatcher) handle(ctx context.Context, fetcher blockFetcher, header *types.Header) {
+	observeHeader(header)
+	w.record(header, w.process(ctx, fetcher, header))
+}
+
+// observeHeader 將新的區塊頭交給重組保護、撤回追蹤與確認數追蹤
+func observeHeader(header *types.Header) {
+	if reorgs != nil {
+		reorgs.observe(header)
+	}
+	if retractions != nil {
+		retractions.observe(header)
+	}
+	if confirmations != nil {
+		confirmations.observe(header.Number.Uint64())
+	}
+}
+
+// record 記錄區塊的處理結果：失敗時保留到 retry，成功時推進 cursor
+func (w *blockWatcher) record(header *types.Header, err error) {
+	if err != nil {
+		logrus.WithField("blockNumber", header.Number.String()).WithError(err).Warn("⚠️ 處理區塊失敗，將在重新連線後重試")
+		w.retry = append(w.retry, header)
+		return
+	}
+
+	if n := header.Number.Uint64(); n > w.lastProcessed {
+		w.lastProcessed = n
+	}
+
+	// 只有在沒有未完成的區塊時才推進 cursor，確保 cursor 代表已完整處理的區塊
+	if blockCursor != nil && len(w.retry) == 0 {
+		if err := blockCursor.Set(w.lastProcessed); err != nil {
+			logrus.WithError(err).Warn("⚠️ 更新區塊 cursor 失敗")
+		}
+	}
+}
+
+// process 抓取區塊詳情並推送到區塊隊列
+// 抓取在重試後仍失敗時，區塊同時記錄到 missed_blocks 隊列
+func (w *blockWatcher) process(ctx context.Context, fetcher blockFetcher, header *types.Header) error {
+	block, err := w.fetchBlock(ctx, fetcher, header)
+	if err != nil {
+		recordMissed(header, err)
+		return err
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, w.fetchTimeout)
+	defer cancel()
+	return w.push(fetchCtx, w.receiptsFor(fetcher), block)
+}
+
+// blockPushResults 依推送結果累計區塊推送次數
+var blockPushResults = map[broker.PushResult]*atomic.Int64{
+	broker.PushEnqueued:     {},
+	broker.PushDeadLettered: {},
+	broker.PushDeduplicated: {},
+	broker.PushRateLimited:  {},
+	broker.PushFailed:       {},
+}
+
+// recordBlockPush 累計一次區塊推送的結果
+func recordBlockPush(result broker.PushResult) {
+	if c, ok := blockPushResults[result]; ok {
+		c.Add(1)
+	}
+}
+
+// push 掃描已抓取的區塊並推送到區塊隊列
+func (w *blockWatcher) push(ctx context.Context, receipts receiptFetcher, block *types.Block) error {
+	header := block.Header()
+	msg, err := w.blockMessage(ctx, receipts, block)
+	if err != nil {
+		return fmt.Errorf("failed to scan block %s: %w", header.Number, err)
+	}
+	msg.ContentID = header.Hash().Hex() // 同一區塊重新推送時得到相同 ID，供 effectively-once 去重
+	msg.TTL = w.ttl                     // 積壓過久的區塊已無處理價值，過期後不再投遞
+	pressured := w.underPressure()
+	result, err := brokerFor(brokerPurposeBlocks).PushWithResult(ctx, blockQueueName, msg)
+	recordBlockPush(result)
+	if err != nil {
+		return fmt.Errorf("failed to push block %s (%s): %w", header.Number, result, err)
+	}
+	switch result {
+	case broker.PushDeadLettered:
+		logrus.WithField("block", header.Number.String()).Warn("⚠️ 區塊隊列已滿，區塊已移入死信隊列")
+	case broker.PushDeduplicated:
+		logrus.WithField("block", header.Number.String()).Debug("🔁 區塊已推送過，略過重複推送")
+	}
+
+	// 同時廣播區塊摘要，供 /ws/blocks 即時顯示；沒有訂閱者時不會保留
+	// 區塊隊列壓力過高時跳過廣播，把資源留給區塊處理
+	if pressured {
+		return nil
+	}
+	if err := brokerFor(brokerPurposeBlocks).Publish(blocksTopicName, msg); err != nil {
+		logrus.WithError(err).Debug("⚠️ 廣播區塊摘要失敗")
+	}
+	return nil
+}
+
+// blockMessage 掃描區塊中發往目標地址的交易，建立推送到區塊隊列的消息
+// receipts 不為 nil 時同時掃描收據中發往目標地址的 ERC-20 轉帳
+func (w *blockWatcher) blockMessage(ctx context.Context, receipts receiptFetcher, block *types.Block) (broker.Message, error) {
+	header := block.Header()
+	scanned, capped := w.scan.selectTransactions(block.Transactions())
+	if capped {
+		scanLimitHits.Add(1)
+		logrus.WithFields(logrus.Fields{
+			"blockNumber": header.Number.String(),
+			"txCount":     len(block.Transactions()),
+			"limit":       w.scan.Limit,
+			"strategy":    w.scan.Strategy,
+			"scanned":     len(scanned),
+		}).Warn("⚠️ 區塊交易數超過掃描上限")
+	}
+
+	var transactions []TransactionInfo
+	for _, tx := range scanned {
+		if tx.To() != nil && watched.Contains(tx.To().Hex()) {
+			// 只包含監聽地址的交易 (包含暫時停用的地址，供 suppressed 指標統計)
+			txInfo := TransactionInfo{
+				Hash:     tx.Hash().Hex(),
+				To:       tx.To().Hex(),
+				Value:    tx.Value().String(),
+				GasPrice: tx.GasPrice().String(),
+			}
+			// 簡化處理，不獲取 from 地址（需要簽名信息）
+			txInfo.From = "unknown"
+			transactions = append(transactions, txInfo)
+		}
+	}
+
+	if receipts != nil {
+		transfers, err := tokenTransfers(ctx, receipts, scanned)
+		if err != nil {
+			return broker.Message{}, err
+		}
+		transactions = append(transactions, transfers...)
+	}
+
+	blockMessage := BlockMessage{
+		BlockNumber:  header.Number.String(),
+		BlockHash:    header.Hash().Hex(),
+		Timestamp:    w.clock.Now(),
+		TxCount:      len(block.Transactions()),
+		Transactions: transactions,
+	}
+
+	codec := w.codec
+	if codec == nil {
+		codec = jsonCodec{}
+	}
+	return encodeMessage(codec, generateMessageID(), blockMessage, blockQueueName)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// mockBlockFetcher 是測試用的區塊來源，failures 中的區塊號第一次抓取時會失敗
+type mockBlockFetcher struct {
+	mu       sync.Mutex
+	blocks   map[common.Hash]*types.Block
+	failures map[uint64]int // 區塊號對應的剩餘失敗次數
+	fetched  []uint64
+}
+
+func newMockBlockFetcher(headers ...*types.Header) *mockBlockFetcher {
+	f := &mockBlockFetcher{
+		blocks:   make(map[common.Hash]*types.Block),
+		failures: make(map[uint64]int),
+	}
+	for _, h := range headers {
+		f.blocks[h.Hash()] = types.NewBlockWithHeader(h).WithBody(types.Body{
+			Transactions: []*types.Transaction{newTestTx(h.Number.Uint64(), targetAddress)},
+		})
+	}
+	return f
+}
+
+func (f *mockBlockFetcher) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	block := f.blocks[hash]
+	n := block.NumberU64()
+	f.fetched = append(f.fetched, n)
+	if f.failures[n] > 0 {
+		f.failures[n]--
+		return nil, errors.New("connection reset")
+	}
+	return block, nil
+}
+
+func newTestHeader(n int64) *types.Header {
+	return &types.Header{Number: big.NewInt(n), Difficulty: big.NewInt(1)}
+}
+
+// pulledBlockNumbers 取出區塊隊列中所有區塊號
+func pulledBlockNumbers(t *testing.T) []string {
+	t.Helper()
+	var numbers []string
+	for {
+		msg, _ := messageBroker.Pull(blockQueueName)
+		if msg == nil {
+			return numbers
+		}
+		var blockMessage BlockMessage
+		json.Unmarshal(msg.Body, &blockMessage)
+		numbers = append(numbers, blockMessage.BlockNumber)
+	}
+}
+
+func TestBlockWatcherDrainsInFlightHeadersOnDisconnect(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	h1, h2 := newTestHeader(1), newTestHeader(2)
+	fetcher := newMockBlockFetcher(h1, h2)
+	w := &blockWatcher{clock: broker.RealClock{}, grace: time.Second, fetchTimeout: time.Second}
+
+	// 中斷時已有兩個區塊送達但尚未處理
+	headers := make(chan *types.Header, 2)
+	headers <- h1
+	headers <- h2
+	errs := make(chan error, 1)
+	errs <- errors.New("subscription dropped")
+
+	if err := w.run(context.Background(), fetcher, headers, errs); err == nil {
+		t.Error("Expected subscription error to be returned")
+	}
+
+	if got := pulledBlockNumbers(t); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("Expected in-flight blocks 1 and 2 to be enqueued, got %v", got)
+	}
+	if w.lastProcessed != 2 || len(w.retry) != 0 {
+		t.Errorf("Expected block 2 fully processed with nothing to retry, got last=%d retry=%d", w.lastProcessed, len(w.retry))
+	}
+}
+
+func TestBlockWatcherRefetchesFailedBlockOnReconnect(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	blockCursor = newThrottledCursor(newFileCursor(filepath.Join(t.TempDir(), "cursor")), 0)
+	defer func() { blockCursor = nil }()
+
+	h1, h2, h3 := newTestHeader(1), newTestHeader(2), newTestHeader(3)
+	fetcher := newMockBlockFetcher(h1, h2, h3)
+	fetcher.failures[2] = 1 // 斷線發生在抓取區塊 2 的途中
+	w := &blockWatcher{clock: broker.RealClock{}, grace: time.Second, fetchTimeout: time.Second}
+
+	// 第一次連線：區塊 1 成功、區塊 2 抓取失敗、區塊 3 成功，隨後訂閱中斷
+	headers := make(chan *types.Header, 3)
+	headers <- h1
+	headers <- h2
+	headers <- h3
+	errs := make(chan error, 1)
+	errs <- errors.New("subscription dropped")
+	w.run(context.Background(), fetcher, headers, errs)
+
+	// cursor 停在區塊 1，不會跳過未完成的區塊 2
+	if last, _ := blockCursor.Get(); last != 1 {
+		t.Errorf("Expected cursor to stay at last fully processed block 1, got %d", last)
+	}
+	if len(w.retry) != 1 || w.retry[0].Number.Uint64() != 2 {
+		t.Fatalf("Expected block 2 pending retry, got %v", w.retry)
+	}
+
+	// 重新連線：先補抓區塊 2
+	errs <- errors.New("stop")
+	w.run(context.Background(), fetcher, make(chan *types.Header), errs)
+
+	got := pulledBlockNumbers(t)
+	if len(got) != 3 || got[2] != "2" {
+		t.Errorf("Expected blocks 1, 3 then re-fetched 2, got %v", got)
+	}
+	if last, _ := blockCursor.Get(); last != 3 {
+		t.Errorf("Expected cursor to advance to 3 once block 2 is processed, got %d", last)
+	}
+}
+
+// newLargeBlockFetcher 創建一個含 n 筆交易的區塊，只有 matches 中的索引是發往目標地址的交易
+func newLargeBlockFetcher(header *types.Header, n int, matches ...int) *mockBlockFetcher {
+	isMatch := make(map[int]bool)
+	for _, i := range matches {
+		isMatch[i] = true
+	}
+
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		to := "0x000000000000000000000000000000000000dEaD"
+		if isMatch[i] {
+			to = targetAddress
+		}
+		txs[i] = newTestTx(uint64(i), to)
+	}
+
+	f := newMockBlockFetcher()
+	f.blocks[header.Hash()] = types.NewBlockWithHeader(header).WithBody(types.Body{Transactions: txs})
+	return f
+}
+
+func TestBlockWatcherScanLimitSample(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	header := newTestHeader(1)
+	// 10 筆交易、上限 5：取樣索引 0,2,4,6,8，索引 3 的匹配會被略過
+	fetcher := newLargeBlockFetcher(header, 10, 2, 3)
+	w := &blockWatcher{
+		clock:        broker.RealClock{},
+		fetchTimeout: time.Second,
+		scan:         scanPolicy{Limit: 5, Strategy: scanStrategySample},
+	}
+
+	before := scanLimitHits.Load()
+	if err := w.process(context.Background(), fetcher, header); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+	if got := scanLimitHits.Load() - before; got != 1 {
+		t.Errorf("Expected scan limit hit metric to increase by 1, got %d", got)
+	}
+
+	msg, _ := messageBroker.Pull(blockQueueName)
+	var blockMessage BlockMessage
+	json.Unmarshal(msg.Body, &blockMessage)
+	if blockMessage.TxCount != 10 {
+		t.Errorf("Expected tx count 10, got %d", blockMessage.TxCount)
+	}
+	if len(blockMessage.Transactions) != 1 {
+		t.Errorf("Expected only the sampled match, got %d transactions", len(blockMessage.Transactions))
+	}
+}
+
+func TestBlockWatcherScanLimitFullScan(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	header := newTestHeader(1)
+	fetcher := newLargeBlockFetcher(header, 10, 2, 3)
+	w := &blockWatcher{
+		clock:        broker.RealClock{},
+		fetchTimeout: time.Second,
+		scan:         scanPolicy{Limit: 5, Strategy: scanStrategyFull},
+	}
+
+	before := scanLimitHits.Load()
+	w.process(context.Background(), fetcher, header)
+	if got := scanLimitHits.Load() - before; got != 1 {
+		t.Errorf("Expected scan limit hit metric to increase by 1, got %d", got)
+	}
+
+	msg, _ := messageBroker.Pull(blockQueueName)
+	var blockMessage BlockMessage
+	json.Unmarshal(msg.Body, &blockMessage)
+	if len(blockMessage.Transactions) != 2 {
+		t.Errorf("Expected full scan to find both matches, got %d", len(blockMessage.Transactions))
+	}
+}
+
+func TestScanPolicyFromEnv(t *testing.T) {
+	t.Setenv("BLOCK_SCAN_LIMIT", "")
+	t.Setenv("BLOCK_SCAN_STRATEGY", "")
+	if p := scanPolicyFromEnv(); p.Limit != 0 || p.Strategy != scanStrategyFull {
+		t.Errorf("Expected unlimited full scan by default, got %+v", p)
+	}
+
+	t.Setenv("BLOCK_SCAN_LIMIT", "500")
+	t.Setenv("BLOCK_SCAN_STRATEGY", "Sample")
+	if p := scanPolicyFromEnv(); p.Limit != 500 || p.Strategy != scanStrategySample {
+		t.Errorf("Expected sample strategy with limit 500, got %+v", p)
+	}
+}
+
+func TestBlockWatcherWatchdogFiresOnSilence(t *testing.T) {
+	withBrokerRegistry(t)
+	resetUpstream(t)
+
+	clk := broker.NewFakeClock(time.Unix(1_700_000_000, 0))
+	h1 := newTestHeader(1)
+	w := &blockWatcher{clock: clk, grace: time.Second, fetchTimeout: time.Second, blockTimeout: 90 * time.Second}
+	headers := make(chan *types.Header)
+	result := make(chan error, 1)
+	go func() {
+		result <- w.run(context.Background(), newMockBlockFetcher(h1), headers, make(chan error))
+	}()
+
+	// expectRunning 確認監聽器仍在等待新區塊
+	expectRunning := func(when string) {
+		t.Helper()
+		select {
+		case err := <-result:
+			t.Fatalf("Expected watcher to keep running %s, got %v", when, err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	clk.BlockUntil(1)
+	clk.Advance(60 * time.Second)
+	expectRunning("before the timeout")
+
+	// 收到區塊後看門狗重新計時
+	headers <- h1
+	for upstream.lastBlock.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clk.Advance(60 * time.Second)
+	expectRunning("after a block reset the watchdog")
+
+	// 自上一個區塊起超過 BLOCK_TIMEOUT 仍無新區塊
+	clk.Advance(30 * time.Second)
+	select {
+	case err := <-result:
+		if !errors.Is(err, errBlockTimeout) {
+			t.Errorf("Expected errBlockTimeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected watchdog to stop the watcher after a silent timeout")
+	}
+}
+
+func TestBlockWatcherCountsPushResults(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+	blocks.DeclareQueue(blockQueueName, 1)
+	w := &blockWatcher{clock: broker.RealClock{}}
+
+	enqueued := blockPushResults[broker.PushEnqueued].Load()
+	deadLettered := blockPushResults[broker.PushDeadLettered].Load()
+
+	// 第二個區塊遇到已滿的隊列，移入死信隊列但不返回錯誤
+	for n := int64(1); n <= 2; n++ {
+		if err := w.push(context.Background(), nil, types.NewBlockWithHeader(newTestHeader(n))); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+	}
+	if got := blockPushResults[broker.PushEnqueued].Load() - enqueued; got != 1 {
+		t.Errorf("Expected 1 enqueued block push, got %d", got)
+	}
+	if got := blockPushResults[broker.PushDeadLettered].Load() - deadLettered; got != 1 {
+		t.Errorf("Expected 1 dead-lettered block push, got %d", got)
+	}
+	if _, exists := scrapeMetrics(t)["block_push_results_total"]; !exists {
+		t.Error("Expected block_push_results_total metric")
+	}
+}
+
+func TestBlockWatcherPushHonorsContext(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+	w := &blockWatcher{clock: broker.RealClock{}}
+	failed := blockPushResults[broker.PushFailed].Load()
+
+	// 連線已結束 (ctx 已取消) 時不再推送區塊
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.push(ctx, nil, types.NewBlockWithHeader(newTestHeader(1))); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if got := blockPushResults[broker.PushFailed].Load() - failed; got != 1 {
+		t.Errorf("Expected 1 failed block push, got %d", got)
+	}
+	if stats, _ := blocks.GetQueueStats(blockQueueName); stats != nil && stats.MessageCount != 0 {
+		t.Errorf("Expected no block queued after cancellation, got %d", stats.MessageCount)
+	}
+}
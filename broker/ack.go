@@ -0,0 +1,141 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnknownDeliveryTag 表示投遞標籤不存在 (已被確認、已逾時重新投遞，或不屬於該隊列)
+var ErrUnknownDeliveryTag = errors.New("unknown delivery tag")
+
+// inflightEntry 是已投遞但尚未確認的消息
+type inflightEntry struct {
+	queue string
+	msg   Message
+	timer Timer
+}
+
+// EnableAcks 開啟 at-least-once 投遞模式
+//
+// 開啟後 Pull 取出的消息會帶有 DeliveryTag 並移入 in-flight 集合，消費者必須呼叫 Ack 確認處理完成，
+// 或呼叫 Nack 表示處理失敗。超過 visibilityTimeout 仍未確認的消息 (例如消費者崩潰) 會被重新投遞，
+// 重新投遞與 Nack 重新入隊一樣會增加 Attempts，超過 MaxRetry 時移入死信隊列。
+func (b *SimpleBroker) EnableAcks(visibilityTimeout time.Duration) error {
+	if visibilityTimeout <= 0 {
+		return fmt.Errorf("invalid visibility timeout %v", visibilityTimeout)
+	}
+	if !atomic.CompareAndSwapInt64(&b.visibilityTimeout, 0, int64(visibilityTimeout)) {
+		return fmt.Errorf("acknowledgements are already enabled")
+	}
+	return nil
+}
+
+// acksEnabled 判斷是否開啟了 at-least-once 投遞模式
+func (b *SimpleBroker) acksEnabled() bool {
+	return atomic.LoadInt64(&b.visibilityTimeout) > 0
+}
+
+// deliver 更新取出消息的統計，並在開啟確認模式時將消息移入 in-flight 集合
+// 已過期或已處理過的重複消息 (effectively-once) 會被丟棄並返回 false
+func (b *SimpleBroker) deliver(mq *messageQueue, op string, msg Message) (Message, bool) {
+	if b.expire(mq, msg) {
+		return Message{}, false
+	}
+	b.dequeued(mq, op, msg)
+	if b.isProcessed(mq, msg) {
+		b.journalConsume(msg)
+		return Message{}, false
+	}
+	if !b.acksEnabled() {
+		b.journalConsume(msg)
+		return msg, true
+	}
+
+	msg.DeliveryTag = strconv.FormatUint(atomic.AddUint64(&b.deliverySeq, 1), 10)
+	entry := &inflightEntry{queue: mq.name, msg: msg}
+
+	b.inflightMu.Lock()
+	b.inflight[msg.DeliveryTag] = entry
+	entry.timer = b.clock.AfterFunc(time.Duration(atomic.LoadInt64(&b.visibilityTimeout)), func() {
+		b.redeliver(msg.DeliveryTag)
+	})
+	b.inflightMu.Unlock()
+
+	atomic.AddInt64(&mq.stats.InFlightCount, 1)
+	return msg, true
+}
+
+// takeInflight 從 in-flight 集合中移除並返回消息
+func (b *SimpleBroker) takeInflight(queue, deliveryTag string) (*inflightEntry, error) {
+	b.inflightMu.Lock()
+	defer b.inflightMu.Unlock()
+
+	entry, exists := b.inflight[deliveryTag]
+	if !exists || (queue != "" && entry.queue != queue) {
+		return nil, fmt.Errorf("%w: %s on queue %s", ErrUnknownDeliveryTag, deliveryTag, queue)
+	}
+	delete(b.inflight, deliveryTag)
+	entry.timer.Stop()
+
+	if queueInterface, exists := b.queues.Load(entry.queue); exists {
+		atomic.AddInt64(&queueInterface.(*messageQueue).stats.InFlightCount, -1)
+	}
+	entry.msg.DeliveryTag = ""
+	return entry, nil
+}
+
+// Ack 確認消息已處理完成，將其從 in-flight 集合移除
+// 開啟 effectively-once 模式時，同時將其 ContentID 記為已處理
+func (b *SimpleBroker) Ack(queue, deliveryTag string) error {
+	entry, err := b.takeInflight(queue, deliveryTag)
+	if err != nil {
+		b.logOp("ack", queue, "", opResult(err))
+		return err
+	}
+
+	if processed := b.processed.Load(); processed != nil && entry.msg.ContentID != "" {
+		processed.add(entry.msg.ContentID)
+	}
+	b.journalConsume(entry.msg)
+	b.logOp("ack", queue, entry.msg.ID, OpResultOK)
+	return nil
+}
+
+// Nack 表示消息處理失敗：requeue 為 true 時重新入隊 (受 MaxRetry 限制)，否則直接移入死信隊列
+// 設定了 BrokerConfig.RetryPolicy 時，重新入隊會依重試次數延遲投遞
+func (b *SimpleBroker) Nack(queue, deliveryTag string, requeue bool) error {
+	entry, err := b.takeInflight(queue, deliveryTag)
+	if err != nil {
+		b.logOp("nack", queue, "", opResult(err))
+		return err
+	}
+
+	b.logOp("nack", queue, entry.msg.ID, OpResultOK)
+	if requeue {
+		return b.retry(queue, entry.msg, "requeue", nil, b.config.RetryPolicy)
+	}
+	return b.MoveToDLQ(queue, entry.msg)
+}
+
+// redeliver 在可見性逾時後重新投遞仍未確認的消息
+func (b *SimpleBroker) redeliver(deliveryTag string) {
+	entry, err := b.takeInflight("", deliveryTag)
+	if err != nil || b.ctx.Err() != nil {
+		return // 已被確認，或 Broker 已關閉
+	}
+	b.Retry(entry.queue, entry.msg, "visibility_timeout", nil)
+}
+
+// stopInflight 停止所有 in-flight 消息的逾時計時器 (於 Close 時呼叫)
+func (b *SimpleBroker) stopInflight() {
+	b.inflightMu.Lock()
+	defer b.inflightMu.Unlock()
+
+	for tag, entry := range b.inflight {
+		entry.timer.Stop()
+		delete(b.inflight, tag)
+	}
+}
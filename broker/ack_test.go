@@ -0,0 +1,189 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newAckBroker 創建開啟確認模式、使用虛擬時鐘的 Broker
+func newAckBroker(t *testing.T, timeout time.Duration) (*SimpleBroker, *FakeClock) {
+	t.Helper()
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	if err := broker.EnableAcks(timeout); err != nil {
+		t.Fatalf("EnableAcks failed: %v", err)
+	}
+	return broker, clock
+}
+
+func TestAckRemovesInflight(t *testing.T) {
+	broker, clock := newAckBroker(t, time.Minute)
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", []byte("data"), "test"))
+	msg, err := broker.Pull("test")
+	if err != nil || msg == nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if msg.DeliveryTag == "" {
+		t.Fatal("Expected delivery tag on pulled message")
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.InFlightCount != 1 {
+		t.Errorf("Expected 1 in-flight message, got %d", stats.InFlightCount)
+	}
+
+	if err := broker.Ack("test", msg.DeliveryTag); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	if err := broker.Ack("test", msg.DeliveryTag); !errors.Is(err, ErrUnknownDeliveryTag) {
+		t.Errorf("Expected ErrUnknownDeliveryTag on double ack, got %v", err)
+	}
+
+	// 已確認的消息不會在逾時後重新投遞
+	clock.Advance(time.Minute)
+	if msg, _ := broker.Pull("test"); msg != nil {
+		t.Errorf("Expected no redelivery after ack, got %s", msg.ID)
+	}
+	stats, _ = broker.GetQueueStats("test")
+	if stats.InFlightCount != 0 {
+		t.Errorf("Expected 0 in-flight messages, got %d", stats.InFlightCount)
+	}
+}
+
+func TestAckWrongQueue(t *testing.T) {
+	broker, _ := newAckBroker(t, time.Minute)
+	defer broker.Close()
+
+	broker.Push("a", NewMessage("msg-1", []byte("data"), "a"))
+	msg, _ := broker.Pull("a")
+	if err := broker.Ack("b", msg.DeliveryTag); !errors.Is(err, ErrUnknownDeliveryTag) {
+		t.Errorf("Expected ErrUnknownDeliveryTag for wrong queue, got %v", err)
+	}
+	if err := broker.Ack("a", msg.DeliveryTag); err != nil {
+		t.Errorf("Expected ack on the right queue to succeed, got %v", err)
+	}
+}
+
+func TestNackRequeue(t *testing.T) {
+	broker, _ := newAckBroker(t, time.Minute)
+	defer broker.Close()
+
+	msg := NewMessage("msg-1", []byte("data"), "test")
+	msg.MaxRetry = 1
+	broker.Push("test", msg)
+
+	pulled, _ := broker.Pull("test")
+	if err := broker.Nack("test", pulled.DeliveryTag, true); err != nil {
+		t.Fatalf("Nack failed: %v", err)
+	}
+
+	redelivered, _ := broker.Pull("test")
+	if redelivered == nil || redelivered.ID != "msg-1" {
+		t.Fatalf("Expected nacked message to be requeued, got %v", redelivered)
+	}
+	if redelivered.Attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", redelivered.Attempts)
+	}
+	if redelivered.DeliveryTag == pulled.DeliveryTag {
+		t.Error("Expected a new delivery tag on redelivery")
+	}
+
+	// 超過 MaxRetry 後移入死信隊列
+	if err := broker.Nack("test", redelivered.DeliveryTag, true); !errors.Is(err, ErrMaxRetryExceeded) {
+		t.Errorf("Expected ErrMaxRetryExceeded, got %v", err)
+	}
+	if dlq := broker.GetDLQ("test"); len(dlq) != 1 {
+		t.Errorf("Expected 1 DLQ message, got %d", len(dlq))
+	}
+}
+
+func TestNackWithoutRequeue(t *testing.T) {
+	broker, _ := newAckBroker(t, time.Minute)
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", []byte("data"), "test"))
+	pulled, _ := broker.Pull("test")
+	if err := broker.Nack("test", pulled.DeliveryTag, false); err != nil {
+		t.Fatalf("Nack failed: %v", err)
+	}
+
+	if msg, _ := broker.Pull("test"); msg != nil {
+		t.Errorf("Expected message not to be requeued, got %s", msg.ID)
+	}
+	dlq := broker.GetDLQ("test")
+	if len(dlq) != 1 || dlq[0].DeliveryTag != "" {
+		t.Errorf("Expected 1 DLQ message without delivery tag, got %v", dlq)
+	}
+}
+
+func TestVisibilityTimeoutRedelivery(t *testing.T) {
+	broker, clock := newAckBroker(t, 30*time.Second)
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", []byte("data"), "test"))
+	pulled, _ := broker.Pull("test")
+
+	// 逾時前不會重新投遞
+	clock.Advance(29 * time.Second)
+	if msg, _ := broker.Pull("test"); msg != nil {
+		t.Fatalf("Expected no redelivery before the visibility timeout, got %s", msg.ID)
+	}
+
+	clock.Advance(time.Second)
+	redelivered, _ := broker.Pull("test")
+	if redelivered == nil || redelivered.ID != "msg-1" {
+		t.Fatalf("Expected redelivery after the visibility timeout, got %v", redelivered)
+	}
+	if redelivered.Attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", redelivered.Attempts)
+	}
+
+	// 逾時的投遞標籤已失效
+	if err := broker.Ack("test", pulled.DeliveryTag); !errors.Is(err, ErrUnknownDeliveryTag) {
+		t.Errorf("Expected stale delivery tag to be rejected, got %v", err)
+	}
+	if err := broker.Ack("test", redelivered.DeliveryTag); err != nil {
+		t.Errorf("Expected ack of redelivery to succeed, got %v", err)
+	}
+}
+
+func TestAckMarksProcessed(t *testing.T) {
+	broker, _ := newAckBroker(t, time.Minute)
+	defer broker.Close()
+	broker.EnableEffectivelyOnce(time.Minute, 100)
+
+	broker.Push("test", NewMessage("msg-1", []byte("block-100"), "test"))
+	pulled, _ := broker.Pull("test")
+	broker.Ack("test", pulled.DeliveryTag)
+
+	// 已確認內容的重新推送會被丟棄
+	broker.Push("test", NewMessage("msg-1-retry", []byte("block-100"), "test"))
+	if msg, _ := broker.Pull("test"); msg != nil {
+		t.Errorf("Expected acked content to be dropped, got %s", msg.ID)
+	}
+}
+
+func TestAcksDisabled(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", []byte("data"), "test"))
+	msg, _ := broker.Pull("test")
+	if msg.DeliveryTag != "" {
+		t.Errorf("Expected no delivery tag when disabled, got %q", msg.DeliveryTag)
+	}
+	if err := broker.Ack("test", "1"); !errors.Is(err, ErrUnknownDeliveryTag) {
+		t.Errorf("Expected ErrUnknownDeliveryTag when disabled, got %v", err)
+	}
+
+	if err := broker.EnableAcks(0); err == nil {
+		t.Error("Expected error for zero visibility timeout")
+	}
+	broker.EnableAcks(time.Second)
+	if err := broker.EnableAcks(time.Second); err == nil {
+		t.Error("Expected error enabling twice")
+	}
+}
@@ -0,0 +1,177 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// BatchOverflowError 表示批次推送中有部分消息因隊列已滿而被移入死信隊列
+// Err 為 nil 時其餘消息都已成功入隊；否則批次在之後因 Err 提前停止，可用 errors.Is 判斷原因
+type BatchOverflowError struct {
+	Queue        string
+	DeadLettered []string // 被移入死信隊列的消息 ID，依批次中的順序
+	Err          error    // 批次提前停止的原因，整批推送完成時為 nil
+}
+
+// Error 實作 error 介面
+func (e *BatchOverflowError) Error() string {
+	msg := fmt.Sprintf("queue %s is full, %d messages dead-lettered: %s", e.Queue, len(e.DeadLettered), strings.Join(e.DeadLettered, ", "))
+	if e.Err != nil {
+		msg += "; " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap 返回批次提前停止的原因
+func (e *BatchOverflowError) Unwrap() error {
+	return e.Err
+}
+
+// PushBatch 依序推送一批消息，隊列統計在整批完成後一次更新
+// 放不下的消息會被移入死信隊列而不是讓整批失敗，此時返回列出這些消息 ID 的 *BatchOverflowError
+// 開啟 ID 去重時，窗口內重複的消息與 Push 一樣被丟棄
+// 有序隊列 (BrokerConfig.OrderedQueues) 已滿時等待消費者騰出空間，逾時返回 ErrQueueFull 並停止推送其餘消息
+// 超過隊列的速率限制時，已入隊的消息保留，其餘消息不再推送並返回包裝 ErrRateLimited 的錯誤
+// 提前停止前已有消息被移入死信隊列時，錯誤包裝在 *BatchOverflowError.Err 中，這些消息 ID 不會遺失
+func (b *SimpleBroker) PushBatch(queue string, msgs []Message) error {
+	if err := b.acceptingPushes(); err != nil {
+		return err
+	}
+
+	mq := b.getOrCreateQueue(queue)
+	now := b.clock.Now()
+	accepted := int64(0)
+	var overflow []string
+	// stop 在批次提前停止時更新統計，並連同已移入死信隊列的消息 ID 一起返回 err
+	stop := func(err error) error {
+		b.recordBatch(mq, accepted)
+		if len(overflow) > 0 {
+			return &BatchOverflowError{Queue: queue, DeadLettered: overflow, Err: err}
+		}
+		return err
+	}
+
+	for _, msg := range msgs {
+		assignID(&msg)
+		if b.dropDuplicate("push_batch", queue, msg) {
+			continue
+		}
+		if err := b.rateLimit("push_batch", queue, msg); err != nil {
+			// 超過速率限制時停止推送其餘消息，由呼叫者決定是否稍後重送
+			b.releaseDuplicate(queue, msg)
+			return stop(fmt.Errorf("%w after %d of %d messages", err, accepted, len(msgs)))
+		}
+		span := b.startPushSpan(context.Background(), "push_batch", queue, &msg)
+		msg.Queue = queue
+		msg.Timestamp = now
+		b.assignContentID(&msg)
+
+		if err := b.journal(walOpPush, queue, &msg); err != nil {
+			b.logOp("push_batch", queue, msg.ID, opResult(err))
+			b.releaseDuplicate(queue, msg)
+			err = fmt.Errorf("failed to persist message %s: %w", msg.ID, err)
+			endSpan(span, err)
+			return stop(err)
+		}
+
+		if !b.offer(mq, msg) {
+			if mq.isOrdered() {
+				// 有序隊列等待消費者騰出空間，逾時則停止推送其餘消息以保持順序
+				if err := b.offerOrdered(mq, msg); err != nil {
+					b.journalConsume(msg)
+					b.logOp("push_batch", queue, msg.ID, opResult(err))
+					b.releaseDuplicate(queue, msg)
+					endSpan(span, err)
+					return stop(err)
+				}
+			} else {
+				b.logOp("push_batch", queue, msg.ID, OpResultDeadLettered)
+				if err := b.MoveToDLQ(queue, msg); err != nil {
+					b.releaseDuplicate(queue, msg)
+					endSpan(span, err)
+					return stop(err)
+				}
+				overflow = append(overflow, msg.ID)
+				span.AddEvent("dead_lettered")
+				endSpan(span, nil)
+				continue
+			}
+		}
+		accepted++
+		b.metrics.RecordOp()
+		b.logOp("push_batch", queue, msg.ID, OpResultOK)
+		endSpan(span, nil)
+	}
+
+	b.recordBatch(mq, accepted)
+	if len(overflow) > 0 {
+		return &BatchOverflowError{Queue: queue, DeadLettered: overflow}
+	}
+	return nil
+}
+
+// recordBatch 一次更新批次推送成功入隊的統計
+func (b *SimpleBroker) recordBatch(mq *messageQueue, accepted int64) {
+	atomic.AddInt64(&mq.stats.MessageCount, accepted)
+	atomic.AddInt64(&mq.stats.EnqueuedTotal, accepted)
+	atomic.AddInt64(&b.metrics.TotalMessages, accepted)
+}
+
+// PullBatch 一次拉取最多 max 條消息，減少逐條拉取的開銷
+// 隊列為空時最多等待 timeout 取得第一條消息 (timeout 為 0 時不等待，為負數時一直等待)，之後只取出已在隊列中的消息，
+// 隊列取空即提前返回。逾時或沒有消息時返回空切片。每條消息的統計與單獨 Pull 相同
+func (b *SimpleBroker) PullBatch(queue string, max int, timeout time.Duration) ([]*Message, error) {
+	return b.PullBatchContext(context.Background(), queue, max, timeout)
+}
+
+// PullBatchContext 與 PullBatch 相同，但等待第一條消息期間 ctx 被取消時立即返回 ctx.Err()
+func (b *SimpleBroker) PullBatchContext(ctx context.Context, queue string, max int, timeout time.Duration) ([]*Message, error) {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return nil, ErrBrokerClosed
+	}
+	if max <= 0 {
+		return nil, fmt.Errorf("invalid batch size %d", max)
+	}
+
+	queueInterface, exists := b.queues.Load(queue)
+	if !exists {
+		err := queueNotFound(queue)
+		b.logOp("pull_batch", queue, "", opResult(err))
+		return nil, err
+	}
+	mq := queueInterface.(*messageQueue)
+
+	batch := b.drainBatch(mq, make([]*Message, 0, max), max)
+
+	// 隊列一開始就是空的，等待第一條消息後再取出其餘已到達的消息
+	if len(batch) == 0 && timeout != 0 {
+		first, err := b.pullContext(ctx, queue, timeout)
+		if errors.Is(err, ErrBrokerClosed) || (err != nil && ctx.Err() != nil) {
+			return batch, err
+		}
+		if err != nil || first == nil {
+			return batch, nil // 逾時
+		}
+		batch = b.drainBatch(mq, append(batch, first), max)
+	}
+
+	return batch, nil
+}
+
+// drainBatch 非阻塞地從隊列取出消息加入 batch，直到 batch 達到 max 條或隊列為空
+func (b *SimpleBroker) drainBatch(mq *messageQueue, batch []*Message, max int) []*Message {
+	for len(batch) < max {
+		msg, ok := mq.poll()
+		if !ok {
+			break
+		}
+		if msg, ok := b.deliver(mq, "pull_batch", msg); ok {
+			batch = append(batch, &msg)
+		}
+	}
+	return batch
+}
@@ -0,0 +1,166 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPullBatch(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	for i := 0; i < 10; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "test"))
+	}
+
+	next := 0
+	for _, want := range []int{4, 4, 2} {
+		batch, err := broker.PullBatch("test", 4, 0)
+		if err != nil {
+			t.Fatalf("PullBatch failed: %v", err)
+		}
+		if len(batch) != want {
+			t.Fatalf("Expected batch of %d, got %d", want, len(batch))
+		}
+		for _, msg := range batch {
+			if expected := fmt.Sprintf("msg-%d", next); msg.ID != expected {
+				t.Errorf("Expected %s, got %s", expected, msg.ID)
+			}
+			next++
+		}
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.MessageCount != 0 || stats.DequeuedTotal != 10 {
+		t.Errorf("Expected count 0 and dequeued 10, got %d and %d", stats.MessageCount, stats.DequeuedTotal)
+	}
+	if processed := broker.GetMetrics().ProcessedMessages; processed != 10 {
+		t.Errorf("Expected 10 processed messages, got %d", processed)
+	}
+
+	if batch, err := broker.PullBatch("test", 4, 0); err != nil || len(batch) != 0 {
+		t.Errorf("Expected empty batch, got %d (err=%v)", len(batch), err)
+	}
+}
+
+func TestPullBatchWaitsForFirstMessage(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+	broker.DeclareQueue("test", 10)
+
+	result := make(chan []*Message, 1)
+	go func() {
+		batch, _ := broker.PullBatch("test", 4, time.Second)
+		result <- batch
+	}()
+
+	clock.BlockUntil(1)
+	broker.Push("test", NewMessage("msg-1", []byte("data"), "test"))
+	if batch := <-result; len(batch) != 1 || batch[0].ID != "msg-1" {
+		t.Errorf("Expected batch with msg-1, got %v", batch)
+	}
+
+	// 逾時返回空切片而非錯誤
+	go func() {
+		batch, _ := broker.PullBatch("test", 4, time.Second)
+		result <- batch
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if batch := <-result; len(batch) != 0 {
+		t.Errorf("Expected empty batch after timeout, got %d", len(batch))
+	}
+}
+
+func TestPullBatchContextCancelled(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+	broker.DeclareQueue("test", 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := broker.PullBatchContext(ctx, "test", 4, time.Hour)
+		result <- err
+	}()
+
+	// 取消 ctx 後不必等到逾時即返回
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPullBatchValidation(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	if _, err := broker.PullBatch("missing", 4, 0); err == nil {
+		t.Error("Expected error for missing queue")
+	}
+	broker.DeclareQueue("test", 10)
+	if _, err := broker.PullBatch("test", 0, 0); err == nil {
+		t.Error("Expected error for zero batch size")
+	}
+}
+
+func TestPushBatchAccepted(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	msgs := make([]Message, 5)
+	for i := range msgs {
+		msgs[i] = NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "test")
+	}
+	if err := broker.PushBatch("test", msgs); err != nil {
+		t.Fatalf("PushBatch failed: %v", err)
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.MessageCount != 5 || stats.EnqueuedTotal != 5 {
+		t.Errorf("Expected count and enqueued 5, got %d and %d", stats.MessageCount, stats.EnqueuedTotal)
+	}
+	if total := broker.GetMetrics().TotalMessages; total != 5 {
+		t.Errorf("Expected 5 total messages, got %d", total)
+	}
+	batch, _ := broker.PullBatch("test", 10, 0)
+	for i, msg := range batch {
+		if expected := fmt.Sprintf("msg-%d", i); msg.ID != expected || msg.Queue != "test" {
+			t.Errorf("Expected %s on queue test, got %s on %s", expected, msg.ID, msg.Queue)
+		}
+	}
+}
+
+func TestPushBatchOverflow(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{QueueBufferSize: 3})
+	defer broker.Close()
+
+	msgs := make([]Message, 5)
+	for i := range msgs {
+		msgs[i] = NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "test")
+	}
+	err := broker.PushBatch("test", msgs)
+
+	var overflow *BatchOverflowError
+	if !errors.As(err, &overflow) {
+		t.Fatalf("Expected BatchOverflowError, got %v", err)
+	}
+	if overflow.Queue != "test" || len(overflow.DeadLettered) != 2 ||
+		overflow.DeadLettered[0] != "msg-3" || overflow.DeadLettered[1] != "msg-4" {
+		t.Errorf("Unexpected overflow error: %+v", overflow)
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.MessageCount != 3 || stats.DeadLetterCount != 2 {
+		t.Errorf("Expected 3 queued and 2 dead-lettered, got %d and %d", stats.MessageCount, stats.DeadLetterCount)
+	}
+	if dlq := broker.GetDLQ("test"); len(dlq) != 2 {
+		t.Errorf("Expected 2 DLQ messages, got %d", len(dlq))
+	}
+}
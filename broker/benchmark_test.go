@@ -2,6 +2,8 @@ package broker
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -227,4 +229,87 @@ func BenchmarkBrokerTPS(b *testing.B) {
 			ops++
 		}
 	}
-}
\ No newline at end of file
+}
+// defaultMinOpsPerSecond 是吞吐量基準測試的預設下限，遠低於正常值 (包含 -race)，只攔截明顯的效能退化
+const defaultMinOpsPerSecond = 10000
+
+// minOpsPerSecond 從 BROKER_BENCH_MIN_OPS 讀取吞吐量下限
+func minOpsPerSecond(b *testing.B) float64 {
+	value := os.Getenv("BROKER_BENCH_MIN_OPS")
+	if value == "" {
+		return defaultMinOpsPerSecond
+	}
+	floor, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		b.Fatalf("invalid BROKER_BENCH_MIN_OPS %q: %v", value, err)
+	}
+	return floor
+}
+
+// requireThroughput 在實際吞吐量低於下限時讓基準測試失敗
+func requireThroughput(b *testing.B, ops int, elapsed time.Duration) float64 {
+	b.Helper()
+	actual := float64(ops) / elapsed.Seconds()
+	if floor := minOpsPerSecond(b); actual < floor {
+		b.Fatalf("push/pull throughput regressed: %.0f ops/sec is below the floor of %.0f", actual, floor)
+	}
+	return actual
+}
+
+// 吞吐量下限檢查，並確認 Broker 自我回報的 ops/sec 與實際操作數相符
+func BenchmarkBrokerThroughputFloor(b *testing.B) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	queueName := "throughput-floor-queue"
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		broker.Push(queueName, NewMessage("msg", []byte("benchmark message"), queueName))
+		broker.Pull(queueName)
+	}
+	elapsed := time.Since(start)
+
+	actual := requireThroughput(b, 2*b.N, elapsed)
+	reported := broker.GetMetrics().OpsPerSecond()
+	b.ReportMetric(reported, "ops/sec")
+
+	// 自我回報以 Broker 建立時間為起點計算，與量測區間幾乎相同；窗口較短時容許較大誤差
+	if elapsed >= 100*time.Millisecond && (reported < actual*0.5 || reported > actual*1.5) {
+		b.Errorf("self-reported %.0f ops/sec is not within range of actual %.0f ops/sec", reported, actual)
+	}
+}
+
+// 多隊列統計快照：逐一查詢 vs 一次遍歷
+func benchmarkQueueStatsBroker(queues int) *SimpleBroker {
+	broker := NewSimpleBroker()
+	for i := 0; i < queues; i++ {
+		queueName := fmt.Sprintf("stats-queue-%d", i)
+		broker.Push(queueName, NewMessage(fmt.Sprintf("msg-%d", i), []byte("stats"), queueName))
+	}
+	return broker
+}
+
+func BenchmarkQueueStatsLoop(b *testing.B) {
+	broker := benchmarkQueueStatsBroker(500)
+	defer broker.Close()
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stats := make(map[string]*QueueStats)
+		for _, queueName := range broker.GetAllQueues() {
+			if s, err := broker.GetQueueStats(queueName); err == nil {
+				stats[queueName] = s
+			}
+		}
+	}
+}
+
+func BenchmarkGetAllQueueStats(b *testing.B) {
+	broker := benchmarkQueueStatsBroker(500)
+	defer broker.Close()
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		broker.GetAllQueueStats()
+	}
+}
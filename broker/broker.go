@@ -2,173 +2,489 @@ package broker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SimpleBroker 是一個高性能的內存消息代理實現
 type SimpleBroker struct {
+	config BrokerConfig
+
 	// 使用 sync.Map 來實現無鎖的並發安全 map
 	queues      sync.Map // map[string]*messageQueue
 	subscribers sync.Map // map[string]*subscriberManager
-	deadLetters sync.Map // map[string][]Message
+	deadLetters sync.Map // map[string]*deadLetterQueue
 	
 	metrics *Metrics
 	closed  int32
+	draining int32 // Shutdown 進行中：不再接受新的 Push，但仍可 Pull
 	ctx     context.Context
 	cancel  context.CancelFunc
+	clock   Clock
+	tracer  trace.Tracer // 推送與拉取的 span，未設定 TracerProvider 時為 no-op
+
+	// pullAnyCursor 是 PullAny 輪詢的起始位置
+	pullAnyCursor uint64
+
+	// consumerSeq 用於產生 RegisterConsumer 的消費者 ID
+	consumerSeq uint64
+
+	// agingRate 是優先級老化速率 (float64 bits)，見 SetPriorityAging
+	agingRate uint64
+
+	// retryBudget 是每個邏輯事件跨階段的重試上限，0 表示不限制，見 SetRetryBudget
+	retryBudget int64
+
+	// oplog 是可選的操作日誌，nil 表示未開啟
+	oplog atomic.Pointer[opLog]
+
+	// depthSampler 是可選的隊列深度直方圖取樣器，nil 表示未開啟
+	depthSampler atomic.Pointer[depthSampler]
+
+	// processed 是 effectively-once 模式下已處理的 ContentID，nil 表示未開啟
+	processed atomic.Pointer[idWindow]
+
+	// 延遲投遞的消息，依隊列與消息 ID 索引
+	scheduleMu sync.Mutex
+	scheduled  map[string]map[string]*scheduledEntry
+
+	// at-least-once 模式：可見性逾時 (0 表示未開啟) 與已投遞未確認的消息，依投遞標籤索引
+	visibilityTimeout int64
+	deliverySeq       uint64
+	inflightMu        sync.Mutex
+	inflight          map[string]*inflightEntry
+
+	// 持久化的預寫日誌，只有 PersistentBroker 會設定
+	wal *wal
+
+	// Push 時的 ID 去重窗口，未設定 DedupeWindow 時為 nil
+	pushed *idWindow
+
+	// 每個隊列的推送速率限制，依 QueueRateLimits 創建後只讀
+	limiters map[string]*tokenBucket
 }
 
 // messageQueue 表示一個消息隊列的實現
 type messageQueue struct {
 	name     string
 	messages chan Message
+	priority *priorityQueue // 非 nil 時為優先級隊列，不使用 messages
+	ordered  bool           // 已滿時 Push 阻塞等待而不是移入死信隊列，見 BrokerConfig.OrderedQueues
 	stats    *QueueStats
 	mu       sync.RWMutex
+
+	// Peek 從通道取出但尚未被 Pull 的隊首消息；所有從通道或 head 取出消息的操作都持有 mu，保持 FIFO 順序
+	head    atomic.Pointer[Message]
+	ready   chan struct{} // 等待中的消費者有消息可取時關閉並替換 (由 mu 保護)
+	waiters int32         // 已取得 ready 並可能正在等待的消費者數，為 0 時推送不必通知
+}
+
+// capacity 返回隊列的緩衝大小，超過時新消息會進入死信隊列
+func (mq *messageQueue) capacity() int {
+	if mq.priority != nil {
+		return mq.priority.capacity
+	}
+	return cap(mq.messages)
+}
+
+// ErrQueueNotFound 表示隊列不存在 (尚未有消息推送或宣告過)
+var ErrQueueNotFound = errors.New("queue not found")
+
+// ErrQueueEmpty 表示隊列目前沒有可取出的消息 (非阻塞拉取，或等待逾時)
+var ErrQueueEmpty = errors.New("queue is empty")
+
+// queueNotFound 返回包裝 ErrQueueNotFound 的錯誤
+func queueNotFound(queue string) error {
+	return fmt.Errorf("%w: %s", ErrQueueNotFound, queue)
+}
+
+// ErrBrokerClosed 表示 Broker 已關閉，包括阻塞中的 Pull 因 Close 而被喚醒
+var ErrBrokerClosed = errors.New("broker is closed")
+
+// ErrDLQMessageNotFound 表示要重新處理的消息不在該隊列的死信隊列中
+var ErrDLQMessageNotFound = errors.New("message not found in dead letter queue")
+
+// ErrTopicNotFound 表示主題不存在 (從未有人訂閱過)
+var ErrTopicNotFound = errors.New("topic not found")
+
+// ErrSubscriberNotFound 表示通道不是該主題的訂閱者，或已經退訂過
+var ErrSubscriberNotFound = errors.New("subscriber not found")
+
+// deadLetterQueue 是一個隊列的死信消息，所有修改都必須持有 mu
+type deadLetterQueue struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// getOrCreateDLQ 獲取隊列的死信隊列，不存在時創建
+func (b *SimpleBroker) getOrCreateDLQ(queue string) *deadLetterQueue {
+	if dlqInterface, exists := b.deadLetters.Load(queue); exists {
+		return dlqInterface.(*deadLetterQueue)
+	}
+	dlqInterface, _ := b.deadLetters.LoadOrStore(queue, &deadLetterQueue{})
+	return dlqInterface.(*deadLetterQueue)
 }
 
 // subscriberManager 管理一個主題的所有訂閱者
 type subscriberManager struct {
 	topic       string
 	subscribers []chan Message
+	groups      map[string]*consumerGroup // 具名消費者組，見 SubscribeGroup
+	filtered    []*filteredSubscriber     // 帶 predicate 的訂閱者，見 SubscribeFiltered
+	replay      *replayBuffer             // 最近發布的消息，nil 表示不保留，見 SubscribeWithReplay
 	mu          sync.RWMutex
 }
 
 // NewSimpleBroker 創建一個新的 SimpleBroker 實例
 func NewSimpleBroker() *SimpleBroker {
+	return NewSimpleBrokerWithConfig(DefaultBrokerConfig())
+}
+
+// NewSimpleBrokerWithClock 創建一個使用指定時間來源的 SimpleBroker，測試中可傳入 FakeClock
+func NewSimpleBrokerWithClock(clock Clock) *SimpleBroker {
+	return NewSimpleBrokerWithConfig(BrokerConfig{Clock: clock})
+}
+
+// NewSimpleBrokerWithConfig 依設定創建 SimpleBroker，未設定的欄位使用預設值
+func NewSimpleBrokerWithConfig(cfg BrokerConfig) *SimpleBroker {
+	cfg = cfg.withDefaults()
 	ctx, cancel := context.WithCancel(context.Background())
 	
-	return &SimpleBroker{
-		metrics: NewMetrics(),
-		ctx:     ctx,
-		cancel:  cancel,
+	var pushed *idWindow
+	if cfg.DedupeWindow > 0 {
+		pushed = newIDWindow(cfg.DedupeWindow, cfg.DedupeMaxIDs, cfg.Clock)
+	}
+
+	b := &SimpleBroker{
+		pushed:    pushed,
+		limiters:  newRateLimiters(cfg.QueueRateLimits, cfg.Clock.Now()),
+		config:    cfg,
+		metrics:   newMetricsWithClock(cfg.Clock),
+		ctx:       ctx,
+		cancel:    cancel,
+		clock:     cfg.Clock,
+		tracer:    cfg.TracerProvider.Tracer(tracerName),
+		scheduled: make(map[string]map[string]*scheduledEntry),
+		inflight:  make(map[string]*inflightEntry),
+	}
+
+	// 設定死信保留期限時，背景定期清除過期的死信消息，Close 時停止
+	if cfg.DLQRetention > 0 {
+		go b.runDLQJanitor()
 	}
+	return b
 }
 
 // Push 將消息推送到指定隊列 (Queue 模式 - 點對點)
+// 開啟 ID 去重 (BrokerConfig.DedupeWindow) 時，窗口內已推送過的 ID 會被丟棄；
+// 超過隊列的速率限制 (BrokerConfig.QueueRateLimits) 時返回 ErrRateLimited
+// 消息沒有 ID 時以 NewMessageID 生成，入隊的消息帶有生成的 ID
+// 隊列已滿時消息移入死信隊列且不返回錯誤；需要區分入隊與其他結果時使用 PushWithResult
 func (b *SimpleBroker) Push(queue string, msg Message) error {
+	return b.PushContext(context.Background(), queue, msg)
+}
+
+// PushContext 與 Push 相同，但 ctx 已取消或逾時時不推送並返回 ctx.Err()
+// 消息本身沒有追蹤上下文時，推送 span 以 ctx 中的 span 為父 span
+func (b *SimpleBroker) PushContext(ctx context.Context, queue string, msg Message) error {
+	_, err := b.PushWithResult(ctx, queue, msg)
+	return err
+}
+
+// push 將消息放入隊列，不做 ID 去重 (重試與重新處理的消息沿用原本的 ID)
+func (b *SimpleBroker) push(queue string, msg Message) error {
+	_, err := b.pushResult(queue, msg)
+	return err
+}
+
+// pushResult 與 push 相同，但同時返回消息是入隊還是因隊列已滿而移入死信隊列
+func (b *SimpleBroker) pushResult(queue string, msg Message) (PushResult, error) {
 	if atomic.LoadInt32(&b.closed) == 1 {
-		return fmt.Errorf("broker is closed")
+		return PushFailed, ErrBrokerClosed
 	}
 	
 	msg.Queue = queue
-	msg.Timestamp = time.Now()
+	msg.Timestamp = b.clock.Now()
+	b.assignContentID(&msg)
 	
 	// 獲取或創建隊列
-	queueInterface, _ := b.queues.LoadOrStore(queue, b.createMessageQueue(queue))
-	mq := queueInterface.(*messageQueue)
+	mq := b.getOrCreateQueue(queue)
+
+	// 先寫入 WAL 再入隊，確保可被消費的消息都已持久化
+	if err := b.journal(walOpPush, queue, &msg); err != nil {
+		b.logOp("push", queue, msg.ID, opResult(err))
+		return PushFailed, fmt.Errorf("failed to persist message %s: %w", msg.ID, err)
+	}
+	
+	if !b.offer(mq, msg) {
+		if !mq.isOrdered() {
+			// 隊列已滿，移動到死信隊列
+			b.logOp("push", queue, msg.ID, OpResultDeadLettered)
+			if err := b.MoveToDLQ(queue, msg); err != nil {
+				return PushFailed, err
+			}
+			return PushDeadLettered, nil
+		}
+		// 有序隊列不以死信打亂順序，而是等待消費者騰出空間
+		if err := b.offerOrdered(mq, msg); err != nil {
+			b.journalConsume(msg)
+			b.logOp("push", queue, msg.ID, opResult(err))
+			return PushFailed, err
+		}
+	}
+	
+	// 成功發送，更新統計
+	atomic.AddInt64(&mq.stats.MessageCount, 1)
+	atomic.AddInt64(&mq.stats.EnqueuedTotal, 1)
+	b.metrics.IncrementTotalMessages()
+	b.metrics.RecordOp()
+	b.logOp("push", queue, msg.ID, OpResultOK)
+	return PushEnqueued, nil
+}
+
+// offer 非阻塞地將消息放入隊列，隊列已滿時返回 false
+func (b *SimpleBroker) offer(mq *messageQueue, msg Message) bool {
+	if mq.priority != nil {
+		return mq.priority.offer(msg, b.agingKey(msg.Priority, msg.Timestamp))
+	}
 	
 	// 使用 select 實現非阻塞發送，避免死鎖
 	select {
 	case mq.messages <- msg:
-		// 成功發送，更新統計
-		atomic.AddInt64(&mq.stats.MessageCount, 1)
-		atomic.AddInt64(&mq.stats.EnqueuedTotal, 1)
-		b.metrics.IncrementTotalMessages()
-		return nil
+		mq.notify()
+		return true
 	default:
-		// 隊列已滿，移動到死信隊列
-		return b.MoveToDLQ(queue, msg)
+		return false
 	}
 }
 
+// poll 非阻塞地從隊列取出一條消息
+func (mq *messageQueue) poll() (Message, bool) {
+	if mq.priority != nil {
+		return mq.priority.poll()
+	}
+	
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	return mq.receive()
+}
+
+// dequeued 更新取出一條消息後的統計
+func (b *SimpleBroker) dequeued(mq *messageQueue, op string, msg Message) {
+	atomic.AddInt64(&mq.stats.MessageCount, -1)
+	atomic.AddInt64(&mq.stats.DequeuedTotal, 1)
+	mq.stats.latency.record(b.clock.Now().Sub(msg.Timestamp))
+	b.metrics.IncrementProcessedMessages()
+	b.metrics.RecordOp()
+	b.logOp(op, mq.name, msg.ID, OpResultOK)
+	b.traceDequeue(op, mq.name, msg)
+}
+
 // Pull 從指定隊列拉取消息 (Queue 模式 - 點對點)
 func (b *SimpleBroker) Pull(queue string) (*Message, error) {
 	return b.PullWithTimeout(queue, 0)
 }
 
 // PullWithTimeout 從指定隊列拉取消息，支持超時
+// timeout 為 0 時不等待，隊列為空返回 ErrQueueEmpty；timeout 為負數時一直等待直到有消息或 Broker 關閉。
+// 逾時返回 ErrQueueEmpty，等待期間 Broker 被關閉時返回 ErrBrokerClosed，隊列不存在時返回 ErrQueueNotFound
 func (b *SimpleBroker) PullWithTimeout(queue string, timeout time.Duration) (*Message, error) {
+	return b.pullContext(context.Background(), queue, timeout)
+}
+
+// PullContext 等待並拉取一條消息，直到有消息、ctx 被取消 (返回 ctx.Err()) 或 Broker 關閉 (返回 ErrBrokerClosed)
+// 需要逾時時以 context.WithTimeout 設定 ctx 的期限
+func (b *SimpleBroker) PullContext(ctx context.Context, queue string) (*Message, error) {
+	return b.pullContext(ctx, queue, -1)
+}
+
+// pullContext 是 PullWithTimeout 與 PullContext 的實作，ctx 被取消時停止等待並返回 ctx.Err()
+func (b *SimpleBroker) pullContext(ctx context.Context, queue string, timeout time.Duration) (*Message, error) {
 	if atomic.LoadInt32(&b.closed) == 1 {
-		return nil, fmt.Errorf("broker is closed")
+		return nil, ErrBrokerClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 	
 	queueInterface, exists := b.queues.Load(queue)
 	if !exists {
-		return nil, fmt.Errorf("queue %s does not exist", queue)
+		err := queueNotFound(queue)
+		b.logOp("pull", queue, "", opResult(err))
+		return nil, err
 	}
 	
 	mq := queueInterface.(*messageQueue)
 	
 	if timeout == 0 {
-		// 非阻塞模式
-		select {
-		case msg := <-mq.messages:
-			atomic.AddInt64(&mq.stats.MessageCount, -1)
-			atomic.AddInt64(&mq.stats.DequeuedTotal, 1)
-			b.metrics.IncrementProcessedMessages()
-			return &msg, nil
-		default:
-			return nil, nil // 沒有消息
+		// 非阻塞模式，已處理過的重複消息會被丟棄並繼續取下一條
+		for {
+			msg, ok := mq.poll()
+			if !ok {
+				b.logOp("pull", queue, "", OpResultEmpty)
+				return nil, fmt.Errorf("%w: %s", ErrQueueEmpty, queue)
+			}
+			if msg, ok := b.deliver(mq, "pull", msg); ok {
+				return &msg, nil
+			}
 		}
 	}
 	
-	// 阻塞模式，支持超時
-	ctx, cancel := context.WithTimeout(b.ctx, timeout)
-	defer cancel()
+	// 阻塞模式，支持超時 (timeout < 0 時沒有計時器，只會被消息或 Close 喚醒)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := b.clock.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C()
+	}
 	
-	select {
-	case msg := <-mq.messages:
-		atomic.AddInt64(&mq.stats.MessageCount, -1)
-		atomic.AddInt64(&mq.stats.DequeuedTotal, 1)
-		b.metrics.IncrementProcessedMessages()
-		return &msg, nil
-	case <-ctx.Done():
-		return nil, fmt.Errorf("timeout waiting for message from queue %s", queue)
+	for {
+		// 不直接 select 隊列通道：與 Peek 並行時可能越過被 Peek 移到 head 的隊首消息，
+		// 因此隊列為空時等待入隊的通知，再與 Peek 一樣持有鎖取出
+		msg, ok, ready := mq.pollOrWait()
+		if ok {
+			if msg, ok := b.deliver(mq, "pull", msg); ok {
+				return &msg, nil
+			}
+			continue
+		}
+		
+		select {
+		case <-ready:
+			continue // 有新消息，重新嘗試取出 (可能已被其他消費者取走)
+		case <-expired:
+		case <-ctx.Done():
+			b.logOp("pull", queue, "", opResult(ctx.Err()))
+			return nil, ctx.Err()
+		case <-b.ctx.Done():
+			b.logOp("pull", queue, "", opResult(ErrBrokerClosed))
+			return nil, ErrBrokerClosed
+		}
+		
+		err := fmt.Errorf("%w: timeout waiting for message from queue %s", ErrQueueEmpty, queue)
+		b.logOp("pull", queue, "", opResult(err))
+		return nil, err
+	}
+}
+
+// PullAny 以輪詢 (round-robin) 方式從多個隊列中非阻塞地拉取一條消息
+// 每次呼叫都會從下一個隊列開始檢查，因此任何非空隊列最多等待 len(queues) 次呼叫就會被服務，
+// 不會因為其他熱門隊列而被無限期略過。返回消息及其來源隊列，所有隊列都為空 (或不存在) 時返回 ErrQueueEmpty
+func (b *SimpleBroker) PullAny(queues []string) (*Message, string, error) {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return nil, "", ErrBrokerClosed
+	}
+
+	if len(queues) == 0 {
+		return nil, "", fmt.Errorf("no queues specified")
+	}
+
+	start := atomic.AddUint64(&b.pullAnyCursor, 1) - 1
+	for i := 0; i < len(queues); i++ {
+		name := queues[(start+uint64(i))%uint64(len(queues))]
+		queueInterface, exists := b.queues.Load(name)
+		if !exists {
+			continue
+		}
+
+		mq := queueInterface.(*messageQueue)
+		for {
+			msg, ok := mq.poll()
+			if !ok {
+				break
+			}
+			if msg, ok := b.deliver(mq, "pull_any", msg); ok {
+				return &msg, name, nil
+			}
+		}
+		// 此隊列為空，檢查下一個
 	}
+
+	return nil, "", ErrQueueEmpty // 所有隊列都沒有消息
 }
 
 // Publish 發布消息到指定主題 (Pub/Sub 模式 - 廣播)
 func (b *SimpleBroker) Publish(topic string, msg Message) error {
 	if atomic.LoadInt32(&b.closed) == 1 {
-		return fmt.Errorf("broker is closed")
+		return ErrBrokerClosed
 	}
 	
-	msg.Timestamp = time.Now()
+	msg.Timestamp = b.clock.Now()
 	b.metrics.IncrementTotalMessages()
+	b.logOp("publish", topic, msg.ID, OpResultOK)
 	
 	subMgrInterface, exists := b.subscribers.Load(topic)
 	if !exists {
-		// 沒有訂閱者，直接返回
-		return nil
+		if b.config.TopicReplaySizes[topic] <= 0 {
+			// 沒有訂閱者，也不需要保留消息，直接返回
+			return nil
+		}
+		subMgrInterface = b.getOrCreateSubscriberManager(topic)
 	}
 	
 	subMgr := subMgrInterface.(*subscriberManager)
+	// 先在鎖外評估過濾訂閱者的 predicate，避免慢的 predicate 長時間持有訂閱鎖
+	matched := subMgr.matchFiltered(msg)
 	subMgr.mu.RLock()
 	defer subMgr.mu.RUnlock()
+
+	// 在訂閱鎖內保留消息，與 SubscribeWithReplay 的補發互斥
+	subMgr.replay.add(msg)
 	
-	// 向所有訂閱者廣播消息
+	// 向所有訂閱者廣播消息，緩衝區已滿時依 SubscriberOverflow 丟棄、寫入死信隊列或等待
 	for _, subscriber := range subMgr.subscribers {
-		select {
-		case subscriber <- msg:
-			// 成功發送
-		default:
-			// 訂閱者的緩衝區已滿，跳過
+		b.sendToSubscriber(topic, subscriber, msg)
+	}
+
+	// 每個消費者組只有一個成員會收到消息，所有成員都已滿時視為溢出 (Block 策略不等待)
+	for _, group := range subMgr.groups {
+		if !group.dispatch(msg) && len(group.members) > 0 {
+			b.subscriberOverflow(topic, msg)
+		}
+	}
+
+	// 評估 predicate 期間已取消訂閱的過濾訂閱者不再發送
+	for _, sub := range matched {
+		if sub.removed {
+			continue
 		}
+		b.sendToSubscriber(topic, sub.ch, msg)
 	}
 	
 	return nil
 }
 
-// Subscribe 訂閱指定主題
+// Subscribe 訂閱指定主題，通道緩衝大小為 subscriberBufferSize
 func (b *SimpleBroker) Subscribe(topic string) (<-chan Message, error) {
+	return b.SubscribeWithBuffer(topic, subscriberBufferSize)
+}
+
+// SubscribeWithBuffer 以指定的通道緩衝大小訂閱主題
+// 緩衝區滿時的處理方式見 BrokerConfig.SubscriberOverflow
+func (b *SimpleBroker) SubscribeWithBuffer(topic string, size int) (<-chan Message, error) {
 	if atomic.LoadInt32(&b.closed) == 1 {
-		return nil, fmt.Errorf("broker is closed")
+		return nil, ErrBrokerClosed
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("subscriber buffer size must be positive, got %d", size)
 	}
 	
 	// 創建一個有緩衝的通道給訂閱者
-	subscriberChan := make(chan Message, 100)
+	subscriberChan := make(chan Message, size)
 	
 	// 獲取或創建訂閱管理器
-	subMgrInterface, _ := b.subscribers.LoadOrStore(topic, &subscriberManager{
-		topic:       topic,
-		subscribers: make([]chan Message, 0),
-	})
-	
-	subMgr := subMgrInterface.(*subscriberManager)
+	subMgr := b.getOrCreateSubscriberManager(topic)
 	subMgr.mu.Lock()
+	// 在鎖內再次檢查，避免與 Close 並行時加入一個不會被關閉的訂閱者
+	if atomic.LoadInt32(&b.closed) == 1 {
+		subMgr.mu.Unlock()
+		return nil, ErrBrokerClosed
+	}
 	subMgr.subscribers = append(subMgr.subscribers, subscriberChan)
 	subMgr.mu.Unlock()
 	
@@ -177,11 +493,12 @@ func (b *SimpleBroker) Subscribe(topic string) (<-chan Message, error) {
 	return subscriberChan, nil
 }
 
-// Unsubscribe 取消訂閱
+// Unsubscribe 取消訂閱並關閉通道
+// 主題不存在時返回 ErrTopicNotFound，通道不是訂閱者或已退訂過時返回 ErrSubscriberNotFound
 func (b *SimpleBroker) Unsubscribe(topic string, subscriber <-chan Message) error {
 	subMgrInterface, exists := b.subscribers.Load(topic)
 	if !exists {
-		return fmt.Errorf("topic %s does not exist", topic)
+		return fmt.Errorf("%w: %s", ErrTopicNotFound, topic)
 	}
 	
 	subMgr := subMgrInterface.(*subscriberManager)
@@ -194,11 +511,24 @@ func (b *SimpleBroker) Unsubscribe(topic string, subscriber <-chan Message) erro
 			subMgr.subscribers = append(subMgr.subscribers[:i], subMgr.subscribers[i+1:]...)
 			close(sub)
 			atomic.AddInt32(&b.metrics.ActiveConsumers, -1)
-			break
+			return nil
 		}
 	}
-	
-	return nil
+
+	// 也可能是過濾訂閱者或消費者組的成員
+	if subMgr.removeFiltered(subscriber) {
+		atomic.AddInt32(&b.metrics.ActiveConsumers, -1)
+		return nil
+	}
+	for _, group := range subMgr.groups {
+		if group.remove(subscriber) {
+			atomic.AddInt32(&b.metrics.ActiveConsumers, -1)
+			return nil
+		}
+	}
+
+	// 找不到通道：已經退訂過 (通道已關閉，不可再次關閉) 或從未訂閱此主題
+	return fmt.Errorf("%w: %s", ErrSubscriberNotFound, topic)
 }
 
 // GetDLQ 獲取指定隊列的死信消息
@@ -208,17 +538,54 @@ func (b *SimpleBroker) GetDLQ(queue string) []Message {
 		return []Message{}
 	}
 	
-	return dlqInterface.([]Message)
+	// 返回副本，避免呼叫者與後續的修改互相影響
+	dlq := dlqInterface.(*deadLetterQueue)
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	return append([]Message{}, dlq.messages...)
+}
+
+// GetDLQPage 返回指定隊列死信消息中從 offset 開始最多 limit 條的副本，以及死信消息總數
+// offset 超出範圍時返回空頁
+func (b *SimpleBroker) GetDLQPage(queue string, offset, limit int) ([]Message, int, error) {
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("invalid offset %d", offset)
+	}
+	if limit <= 0 {
+		return nil, 0, fmt.Errorf("invalid limit %d", limit)
+	}
+
+	dlqInterface, exists := b.deadLetters.Load(queue)
+	if !exists {
+		return []Message{}, 0, nil
+	}
+
+	dlq := dlqInterface.(*deadLetterQueue)
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+
+	total := len(dlq.messages)
+	if offset >= total {
+		return []Message{}, total, nil
+	}
+	end := min(offset+limit, total)
+	return append([]Message{}, dlq.messages[offset:end]...), total, nil
 }
 
 // MoveToDLQ 將消息移動到死信隊列
 func (b *SimpleBroker) MoveToDLQ(queue string, msg Message) error {
 	msg.Attempts++
-	
-	dlqInterface, _ := b.deadLetters.LoadOrStore(queue, []Message{})
-	dlq := dlqInterface.([]Message)
-	dlq = append(dlq, msg)
-	b.deadLetters.Store(queue, dlq)
+	return b.deadLetter(queue, msg)
+}
+
+// deadLetter 將消息原樣加入死信隊列，不改變 Attempts
+func (b *SimpleBroker) deadLetter(queue string, msg Message) error {
+	if err := b.journal(walOpDLQ, queue, &msg); err != nil {
+		b.logOp("move_to_dlq", queue, msg.ID, opResult(err))
+		return fmt.Errorf("failed to persist dead letter %s: %w", msg.ID, err)
+	}
+
+	b.addDeadLetter(queue, msg)
 	
 	// 更新統計
 	queueInterface, exists := b.queues.Load(queue)
@@ -228,6 +595,7 @@ func (b *SimpleBroker) MoveToDLQ(queue string, msg Message) error {
 	}
 	
 	b.metrics.IncrementFailedMessages()
+	b.logOp("move_to_dlq", queue, msg.ID, OpResultOK)
 	return nil
 }
 
@@ -235,43 +603,60 @@ func (b *SimpleBroker) MoveToDLQ(queue string, msg Message) error {
 func (b *SimpleBroker) ReprocessDLQ(queue string, msgID string) error {
 	dlqInterface, exists := b.deadLetters.Load(queue)
 	if !exists {
-		return fmt.Errorf("no dead letters for queue %s", queue)
+		return fmt.Errorf("%w: no dead letters for queue %s", ErrDLQMessageNotFound, queue)
 	}
 	
-	dlq := dlqInterface.([]Message)
-	for i, msg := range dlq {
+	dlq := dlqInterface.(*deadLetterQueue)
+	dlq.mu.Lock()
+	for i, msg := range dlq.messages {
 		if msg.ID == msgID {
+			// 重新處理也是一次重試，預算耗盡的消息留在死信隊列
+			if isRetryTerminal(msg) || !b.ChargeRetry(&msg, "reprocess_dlq", nil) {
+				b.journal(walOpDLQ, queue, &msg) // 保存更新後的重試記錄
+				dlq.messages[i] = msg
+				dlq.mu.Unlock()
+				err := fmt.Errorf("%w: message %s", ErrRetryBudgetExhausted, msgID)
+				b.logOp("reprocess_dlq", queue, msgID, opResult(err))
+				return err
+			}
+
 			// 重置嘗試次數
 			msg.Attempts = 0
 			
-			// 從死信隊列中移除
-			dlq = append(dlq[:i], dlq[i+1:]...)
-			b.deadLetters.Store(queue, dlq)
+			// 從死信隊列中移除，釋放鎖後再推送 (隊列已滿時 Push 會再寫入死信隊列)
+			dlq.messages = append(dlq.messages[:i], dlq.messages[i+1:]...)
+			dlq.mu.Unlock()
 			
 			// 重新推送到隊列
-			return b.Push(queue, msg)
+			b.logOp("reprocess_dlq", queue, msgID, OpResultOK)
+			return b.push(queue, msg)
 		}
 	}
+	dlq.mu.Unlock()
 	
-	return fmt.Errorf("message %s not found in dead letter queue", msgID)
+	err := fmt.Errorf("%w: message %s", ErrDLQMessageNotFound, msgID)
+	b.logOp("reprocess_dlq", queue, msgID, opResult(err))
+	return err
 }
 
 // GetQueueStats 獲取指定隊列的統計信息
 func (b *SimpleBroker) GetQueueStats(queue string) (*QueueStats, error) {
 	queueInterface, exists := b.queues.Load(queue)
 	if !exists {
-		return nil, fmt.Errorf("queue %s does not exist", queue)
+		return nil, queueNotFound(queue)
 	}
 	
-	mq := queueInterface.(*messageQueue)
-	return &QueueStats{
-		Name:            mq.stats.Name,
-		MessageCount:    atomic.LoadInt64(&mq.stats.MessageCount),
-		ConsumerCount:   atomic.LoadInt32(&mq.stats.ConsumerCount),
-		EnqueuedTotal:   atomic.LoadInt64(&mq.stats.EnqueuedTotal),
-		DequeuedTotal:   atomic.LoadInt64(&mq.stats.DequeuedTotal),
-		DeadLetterCount: atomic.LoadInt64(&mq.stats.DeadLetterCount),
-	}, nil
+	return queueInterface.(*messageQueue).snapshot(), nil
+}
+
+// GetAllQueueStats 遍歷一次隊列表，返回所有隊列統計信息的副本
+func (b *SimpleBroker) GetAllQueueStats() map[string]*QueueStats {
+	result := make(map[string]*QueueStats)
+	b.queues.Range(func(key, value interface{}) bool {
+		result[key.(string)] = value.(*messageQueue).snapshot()
+		return true
+	})
+	return result
 }
 
 // GetMetrics 獲取 Broker 的整體指標
@@ -293,19 +678,20 @@ func (b *SimpleBroker) GetAllQueues() []string {
 func (b *SimpleBroker) PurgeQueue(queue string) error {
 	queueInterface, exists := b.queues.Load(queue)
 	if !exists {
-		return fmt.Errorf("queue %s does not exist", queue)
+		return queueNotFound(queue)
 	}
 	
 	mq := queueInterface.(*messageQueue)
 	
 	// 清空隊列中的所有消息
 	for {
-		select {
-		case <-mq.messages:
-			atomic.AddInt64(&mq.stats.MessageCount, -1)
-		default:
+		msg, ok := mq.poll()
+		if !ok {
+			b.logOp("purge", queue, "", OpResultOK)
 			return nil // 隊列已空
 		}
+		b.journalConsume(msg)
+		atomic.AddInt64(&mq.stats.MessageCount, -1)
 	}
 }
 
@@ -321,36 +707,103 @@ func (b *SimpleBroker) Close() error {
 	}
 	
 	b.cancel()
+	b.stopScheduled()
+	b.stopInflight()
 	
-	// 關閉所有訂閱者通道
+	// 關閉所有訂閱者通道，每關閉一個就減少一個活躍消費者
 	b.subscribers.Range(func(key, value interface{}) bool {
 		subMgr := value.(*subscriberManager)
 		subMgr.mu.Lock()
+		closed := len(subMgr.subscribers)
 		for _, subscriber := range subMgr.subscribers {
 			close(subscriber)
 		}
+		subMgr.subscribers = nil // 之後的 Unsubscribe 不會重複關閉通道，也不會重複減少計數
+		closed += subMgr.closeFiltered()
+		for _, group := range subMgr.groups {
+			closed += len(group.members)
+			for _, member := range group.members {
+				close(member)
+			}
+			group.members = nil
+		}
 		subMgr.mu.Unlock()
+		atomic.AddInt32(&b.metrics.ActiveConsumers, -int32(closed))
 		return true
 	})
 	
 	return nil
 }
 
+// getOrCreateQueue 獲取隊列，不存在時創建
+func (b *SimpleBroker) getOrCreateQueue(name string) *messageQueue {
+	if queueInterface, exists := b.queues.Load(name); exists {
+		return queueInterface.(*messageQueue)
+	}
+
+	return b.storeQueue(name, b.createMessageQueue(name, b.config.bufferSize(name)))
+}
+
+// DeclareQueue 以指定的緩衝大小預先創建隊列，避免第一次 Push 時以預設大小創建
+// 隊列已存在且緩衝大小不同時返回錯誤；大小相同時視為成功
+func (b *SimpleBroker) DeclareQueue(name string, bufferSize int) error {
+	if bufferSize <= 0 {
+		return fmt.Errorf("invalid buffer size %d for queue %s", bufferSize, name)
+	}
+
+	mq := b.storeQueue(name, b.createMessageQueue(name, bufferSize))
+	if existing := mq.capacity(); existing != bufferSize {
+		return fmt.Errorf("queue %s already exists with buffer size %d", name, existing)
+	}
+	return nil
+}
+
+// storeQueue 存入新建的隊列，若其他 goroutine 已先存入則返回既有的隊列
+// 只有實際存入的隊列才會登記到 metrics，避免重複計數；DeleteQueue 刪除隊列時一併移除登記
+func (b *SimpleBroker) storeQueue(name string, created *messageQueue) *messageQueue {
+	created.stats.Capacity = int64(created.capacity()) // 隊列模式與大小在存入前已確定
+	queueInterface, loaded := b.queues.LoadOrStore(name, created)
+	mq := queueInterface.(*messageQueue)
+	if !loaded {
+		b.metrics.registerQueue(name, mq.stats)
+	}
+	return mq
+}
+
 // createMessageQueue 創建一個新的消息隊列
-func (b *SimpleBroker) createMessageQueue(name string) *messageQueue {
+func (b *SimpleBroker) createMessageQueue(name string, bufferSize int) *messageQueue {
 	stats := &QueueStats{
-		Name: name,
+		Name:    name,
+		latency: newLatencyRecorder(),
 	}
 	
-	// 更新 metrics 中的隊列統計
-	b.metrics.mu.Lock()
-	b.metrics.QueueMetrics[name] = stats
-	b.metrics.mu.Unlock()
-	atomic.AddInt32(&b.metrics.ActiveQueues, 1)
-	
 	return &messageQueue{
-		name:     name,
-		messages: make(chan Message, 1000), // 1000 緩衝大小
-		stats:    stats,
+		name:      name,
+		messages:  make(chan Message, bufferSize),
+		ready:     make(chan struct{}),
+		ordered:   b.config.OrderedQueues[name],
+		stats:     stats,
+	}
+}
+
+// snapshot 返回隊列統計信息的副本
+func (mq *messageQueue) snapshot() *QueueStats {
+	return &QueueStats{
+		Name:            mq.stats.Name,
+		MessageCount:    atomic.LoadInt64(&mq.stats.MessageCount),
+		ConsumerCount:   atomic.LoadInt32(&mq.stats.ConsumerCount),
+		EnqueuedTotal:   atomic.LoadInt64(&mq.stats.EnqueuedTotal),
+		DequeuedTotal:   atomic.LoadInt64(&mq.stats.DequeuedTotal),
+		DeadLetterCount: atomic.LoadInt64(&mq.stats.DeadLetterCount),
+		DuplicateCount:  atomic.LoadInt64(&mq.stats.DuplicateCount),
+		InFlightCount:   atomic.LoadInt64(&mq.stats.InFlightCount),
+		ExpiredCount:    atomic.LoadInt64(&mq.stats.ExpiredCount),
+		DedupedCount:    atomic.LoadInt64(&mq.stats.DedupedCount),
+		RateLimitedCount: atomic.LoadInt64(&mq.stats.RateLimitedCount),
+		DLQExpiredCount: atomic.LoadInt64(&mq.stats.DLQExpiredCount),
+		DLQEvictedCount: atomic.LoadInt64(&mq.stats.DLQEvictedCount),
+		Capacity:        mq.stats.Capacity,
+		Utilization:     utilization(atomic.LoadInt64(&mq.stats.MessageCount), mq.stats.Capacity),
+		Latency:         mq.stats.latency.snapshot(),
 	}
 }
\ No newline at end of file
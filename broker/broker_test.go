@@ -1,7 +1,10 @@
 package broker
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -96,6 +99,40 @@ func TestPullEmptyQueue(t *testing.T) {
 	}
 }
 
+func TestPullSentinelErrors(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	if _, err := broker.Pull("missing"); !errors.Is(err, ErrQueueNotFound) {
+		t.Errorf("Expected ErrQueueNotFound from Pull, got %v", err)
+	}
+	if _, err := broker.PullWithTimeout("missing", 10*time.Millisecond); !errors.Is(err, ErrQueueNotFound) {
+		t.Errorf("Expected ErrQueueNotFound from PullWithTimeout, got %v", err)
+	}
+	if _, err := broker.PullBatch("missing", 10, 0); !errors.Is(err, ErrQueueNotFound) {
+		t.Errorf("Expected ErrQueueNotFound from PullBatch, got %v", err)
+	}
+	if _, err := broker.Peek("missing"); !errors.Is(err, ErrQueueNotFound) {
+		t.Errorf("Expected ErrQueueNotFound from Peek, got %v", err)
+	}
+
+	broker.DeclareQueue("empty", 10)
+	if msg, err := broker.Pull("empty"); !errors.Is(err, ErrQueueEmpty) || msg != nil {
+		t.Errorf("Expected ErrQueueEmpty from Pull, got msg=%v err=%v", msg, err)
+	}
+	if _, err := broker.PullWithTimeout("empty", 10*time.Millisecond); !errors.Is(err, ErrQueueEmpty) {
+		t.Errorf("Expected ErrQueueEmpty after timeout, got %v", err)
+	}
+	if _, err := broker.Peek("empty"); !errors.Is(err, ErrQueueEmpty) {
+		t.Errorf("Expected ErrQueueEmpty from Peek, got %v", err)
+	}
+
+	broker.Close()
+	if _, err := broker.Pull("empty"); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Expected ErrBrokerClosed after close, got %v", err)
+	}
+}
+
 func TestPullWithTimeout(t *testing.T) {
 	broker := NewSimpleBroker()
 	defer broker.Close()
@@ -127,6 +164,138 @@ func TestPullWithTimeout(t *testing.T) {
 	}
 }
 
+// pullResult 是在背景執行的 Pull 的結果
+type pullResult struct {
+	msg *Message
+	err error
+}
+
+func TestPullBlockingWakesOnPush(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	broker.DeclareQueue("blocking", 10)
+
+	results := make(chan pullResult, 1)
+	go func() {
+		msg, err := broker.PullWithTimeout("blocking", -1)
+		results <- pullResult{msg, err}
+	}()
+
+	// 沒有消息時一直等待，不會逾時返回
+	select {
+	case r := <-results:
+		t.Fatalf("Expected pull to block, got %v (err %v)", r.msg, r.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	broker.Push("blocking", NewMessage("msg-1", []byte("data"), "blocking"))
+	select {
+	case r := <-results:
+		if r.err != nil || r.msg == nil || r.msg.ID != "msg-1" {
+			t.Errorf("Expected msg-1, got %v (err %v)", r.msg, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected blocked pull to wake on push")
+	}
+}
+
+func TestPullBlockingWakesOnClose(t *testing.T) {
+	broker := NewSimpleBroker()
+	broker.DeclareQueue("blocking", 10)
+
+	results := make(chan pullResult, 2)
+	go func() {
+		msg, err := broker.PullWithTimeout("blocking", -1)
+		results <- pullResult{msg, err}
+	}()
+	go func() {
+		batch, err := broker.PullBatch("blocking", 10, -1)
+		results <- pullResult{err: err}
+		if len(batch) != 0 {
+			t.Errorf("Expected empty batch on close, got %d messages", len(batch))
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	broker.Close()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-results:
+			if !errors.Is(r.err, ErrBrokerClosed) {
+				t.Errorf("Expected ErrBrokerClosed, got %v", r.err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected blocked pulls to wake on close")
+		}
+	}
+
+	if _, err := broker.PullWithTimeout("blocking", -1); !errors.Is(err, ErrBrokerClosed) {
+		t.Errorf("Expected ErrBrokerClosed after close, got %v", err)
+	}
+}
+
+func TestPullContextCancelledMidWait(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	broker.DeclareQueue("ctx", 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan pullResult, 1)
+	go func() {
+		msg, err := broker.PullContext(ctx, "ctx")
+		results <- pullResult{msg, err}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case r := <-results:
+		if !errors.Is(r.err, context.Canceled) || r.msg != nil {
+			t.Errorf("Expected context.Canceled, got msg=%v err=%v", r.msg, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected PullContext to return after cancellation")
+	}
+
+	// 取消不影響之後的消息
+	broker.Push("ctx", NewMessage("msg-1", []byte("data"), "ctx"))
+	if msg, err := broker.PullContext(context.Background(), "ctx"); err != nil || msg.ID != "msg-1" {
+		t.Errorf("Expected msg-1, got %v (err %v)", msg, err)
+	}
+}
+
+func TestPullContextDeadline(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	broker.DeclareQueue("ctx", 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := broker.PullContext(ctx, "ctx"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestPushContextCancelled(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := broker.PushContext(ctx, "ctx", NewMessage("msg-1", []byte("data"), "ctx")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if _, err := broker.Pull("ctx"); !errors.Is(err, ErrQueueNotFound) {
+		t.Errorf("Expected nothing pushed after cancellation, got %v", err)
+	}
+
+	if err := broker.PushContext(context.Background(), "ctx", NewMessage("msg-2", []byte("data"), "ctx")); err != nil {
+		t.Errorf("Expected push with live context to succeed, got %v", err)
+	}
+}
+
 func TestPubSub(t *testing.T) {
 	broker := NewSimpleBroker()
 	defer broker.Close()
@@ -236,6 +405,53 @@ func TestDeadLetterQueue(t *testing.T) {
 	}
 }
 
+func TestRequeueEnforcesMaxRetry(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	queueName := "test-retry-queue"
+	msg := NewMessage("retry-msg-1", []byte("retry test"), queueName)
+	msg.MaxRetry = 3
+	broker.Push(queueName, msg)
+
+	// 每次投遞都處理失敗：前 3 次失敗重新入隊，第 4 次失敗才進入死信隊列
+	deliveries := 0
+	for {
+		pulled, _ := broker.Pull(queueName)
+		if pulled == nil {
+			break
+		}
+		deliveries++
+		if pulled.Attempts != deliveries-1 {
+			t.Errorf("Delivery %d: expected attempts %d, got %d", deliveries, deliveries-1, pulled.Attempts)
+		}
+
+		err := broker.Requeue(queueName, *pulled)
+		if deliveries <= 3 {
+			if err != nil {
+				t.Fatalf("Requeue %d failed: %v", deliveries, err)
+			}
+			if dlq := broker.GetDLQ(queueName); len(dlq) != 0 {
+				t.Fatalf("Expected empty DLQ before retries are exhausted, got %d after delivery %d", len(dlq), deliveries)
+			}
+		} else if !errors.Is(err, ErrMaxRetryExceeded) {
+			t.Errorf("Expected ErrMaxRetryExceeded, got %v", err)
+		}
+	}
+
+	if deliveries != 4 {
+		t.Errorf("Expected 4 total delivery attempts, got %d", deliveries)
+	}
+
+	dlqMessages := broker.GetDLQ(queueName)
+	if len(dlqMessages) != 1 {
+		t.Fatalf("Expected 1 message in DLQ, got %d", len(dlqMessages))
+	}
+	if dlqMessages[0].Attempts != 4 {
+		t.Errorf("Expected attempts 4 in DLQ, got %d", dlqMessages[0].Attempts)
+	}
+}
+
 func TestConcurrentAccess(t *testing.T) {
 	broker := NewSimpleBroker()
 	defer broker.Close()
@@ -344,6 +560,88 @@ func TestBrokerClose(t *testing.T) {
 	}
 }
 
+func TestCloseResetsActiveConsumers(t *testing.T) {
+	broker := NewSimpleBroker()
+
+	// 普通訂閱者、過濾訂閱者與消費者組成員都計入活躍消費者
+	for i := 0; i < 3; i++ {
+		if _, err := broker.Subscribe("events"); err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+	}
+	if _, err := broker.SubscribeFiltered("events", func(Message) bool { return true }); err != nil {
+		t.Fatalf("SubscribeFiltered failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := broker.SubscribeGroup("other", "workers"); err != nil {
+			t.Fatalf("SubscribeGroup failed: %v", err)
+		}
+	}
+	if got := broker.GetMetrics().ActiveConsumers; got != 6 {
+		t.Fatalf("Expected 6 active consumers, got %d", got)
+	}
+
+	broker.Close()
+
+	if got := broker.GetMetrics().ActiveConsumers; got != 0 {
+		t.Errorf("Expected 0 active consumers after close, got %d", got)
+	}
+}
+
+func TestUnsubscribeTwiceKeepsActiveConsumersNonNegative(t *testing.T) {
+	broker := NewSimpleBroker()
+
+	first, _ := broker.Subscribe("events")
+	second, _ := broker.Subscribe("events")
+
+	// 重複退訂同一個通道只減少一次
+	broker.Unsubscribe("events", first)
+	broker.Unsubscribe("events", first)
+	if got := broker.GetMetrics().ActiveConsumers; got != 1 {
+		t.Errorf("Expected 1 active consumer after double unsubscribe, got %d", got)
+	}
+
+	// Close 之後再退訂不會讓計數變成負數
+	broker.Close()
+	broker.Unsubscribe("events", second)
+	if got := broker.GetMetrics().ActiveConsumers; got != 0 {
+		t.Errorf("Expected 0 active consumers, got %d", got)
+	}
+}
+
+func TestUnsubscribeUnknownChannel(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	if err := broker.Unsubscribe("missing", make(chan Message)); !errors.Is(err, ErrTopicNotFound) {
+		t.Errorf("Expected ErrTopicNotFound, got %v", err)
+	}
+
+	// 主題存在但通道不是它的訂閱者
+	broker.Subscribe("events")
+	if err := broker.Unsubscribe("events", make(chan Message)); !errors.Is(err, ErrSubscriberNotFound) {
+		t.Errorf("Expected ErrSubscriberNotFound, got %v", err)
+	}
+}
+
+func TestUnsubscribeTwiceReturnsSubscriberNotFound(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	sub, _ := broker.Subscribe("events")
+	if err := broker.Unsubscribe("events", sub); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+
+	// 第二次退訂不會再關閉通道 (重複關閉會 panic)
+	if err := broker.Unsubscribe("events", sub); !errors.Is(err, ErrSubscriberNotFound) {
+		t.Errorf("Expected ErrSubscriberNotFound on second unsubscribe, got %v", err)
+	}
+	if _, ok := <-sub; ok {
+		t.Error("Expected unsubscribed channel to be closed")
+	}
+}
+
 func TestGetAllQueues(t *testing.T) {
 	broker := NewSimpleBroker()
 	defer broker.Close()
@@ -408,4 +706,176 @@ func TestPurgeQueue(t *testing.T) {
 	if stats.MessageCount != 0 {
 		t.Errorf("Expected 0 messages after purge, got %d", stats.MessageCount)
 	}
-}
\ No newline at end of file
+}
+func TestPullAnyFairness(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	
+	// 熱門隊列遠比冷門隊列深
+	for i := 0; i < 100; i++ {
+		broker.Push("hot", NewMessage(fmt.Sprintf("hot-%d", i), []byte("hot"), "hot"))
+	}
+	for i := 0; i < 10; i++ {
+		broker.Push("cold", NewMessage(fmt.Sprintf("cold-%d", i), []byte("cold"), "cold"))
+	}
+	
+	counts := make(map[string]int)
+	for i := 0; i < 20; i++ {
+		msg, queue, err := broker.PullAny([]string{"hot", "cold"})
+		if err != nil || msg == nil {
+			t.Fatalf("PullAny failed: msg=%v err=%v", msg, err)
+		}
+		if msg.Queue != queue {
+			t.Errorf("Expected message from %s, got message for %s", queue, msg.Queue)
+		}
+		counts[queue]++
+	}
+	
+	// 冷門隊列不應被熱門隊列餓死
+	if counts["cold"] != 10 || counts["hot"] != 10 {
+		t.Errorf("Expected fair 10/10 split over 20 pulls, got %v", counts)
+	}
+	
+	// 冷門隊列清空後，剩下的都來自熱門隊列
+	for i := 0; i < 10; i++ {
+		_, queue, _ := broker.PullAny([]string{"hot", "cold"})
+		if queue != "hot" {
+			t.Errorf("Expected hot queue once cold is empty, got %q", queue)
+		}
+	}
+}
+
+func TestPullAnyEmptyAndMissingQueues(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	
+	msg, queue, err := broker.PullAny([]string{"missing-a", "missing-b"})
+	if !errors.Is(err, ErrQueueEmpty) || msg != nil || queue != "" {
+		t.Errorf("Expected no message from missing queues, got msg=%v queue=%q err=%v", msg, queue, err)
+	}
+	
+	if _, _, err := broker.PullAny(nil); err == nil {
+		t.Error("Expected error when no queues are given")
+	}
+	
+	broker.Push("only", NewMessage("m-1", []byte("x"), "only"))
+	msg, queue, _ = broker.PullAny([]string{"missing-a", "only"})
+	if msg == nil || queue != "only" {
+		t.Errorf("Expected message from the only existing queue, got queue=%q", queue)
+	}
+	
+	stats, _ := broker.GetQueueStats("only")
+	if stats.MessageCount != 0 || stats.DequeuedTotal != 1 {
+		t.Errorf("Expected stats updated after PullAny, got %+v", stats)
+	}
+}
+
+func TestGetAllQueueStats(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	for i := 0; i < 5; i++ {
+		queueName := fmt.Sprintf("queue-%d", i)
+		for j := 0; j <= i; j++ {
+			broker.Push(queueName, NewMessage(fmt.Sprintf("msg-%d-%d", i, j), []byte("data"), queueName))
+		}
+	}
+	broker.Pull("queue-4")
+
+	all := broker.GetAllQueueStats()
+	if len(all) != 5 {
+		t.Fatalf("Expected stats for 5 queues, got %d", len(all))
+	}
+	for _, queueName := range broker.GetAllQueues() {
+		individual, err := broker.GetQueueStats(queueName)
+		if err != nil {
+			t.Fatalf("GetQueueStats(%s) failed: %v", queueName, err)
+		}
+		if !reflect.DeepEqual(*all[queueName], *individual) {
+			t.Errorf("Queue %s: snapshot %+v does not match %+v", queueName, *all[queueName], *individual)
+		}
+	}
+
+	// 同一隊列多次推送只計為一個活躍隊列
+	if active := broker.GetMetrics().GetStats()["active_queues"].(int32); active != 5 {
+		t.Errorf("Expected 5 active queues, got %d", active)
+	}
+}
+
+func TestConcurrentMoveToDLQ(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	const goroutines = 10
+	const perGoroutine = 100
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				msg := NewMessage(fmt.Sprintf("msg-%d-%d", g, i), []byte("data"), "test")
+				broker.MoveToDLQ("test", msg)
+			}
+		}(g)
+	}
+
+	// 同時讀取死信隊列，不應影響寫入
+	go func() {
+		for i := 0; i < perGoroutine; i++ {
+			broker.GetDLQ("test")
+		}
+	}()
+	wg.Wait()
+
+	if dlq := broker.GetDLQ("test"); len(dlq) != goroutines*perGoroutine {
+		t.Errorf("Expected %d DLQ messages, got %d", goroutines*perGoroutine, len(dlq))
+	}
+}
+
+func TestGetDLQPage(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	for i := 0; i < 5; i++ {
+		broker.MoveToDLQ("work", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "work"))
+	}
+
+	pageIDs := func(msgs []Message) string {
+		ids := make([]string, len(msgs))
+		for i, msg := range msgs {
+			ids[i] = msg.ID
+		}
+		return fmt.Sprint(ids)
+	}
+
+	// 第一頁
+	page, total, err := broker.GetDLQPage("work", 0, 2)
+	if err != nil || total != 5 || pageIDs(page) != "[msg-0 msg-1]" {
+		t.Errorf("Expected first page [msg-0 msg-1] of 5, got %s of %d (err %v)", pageIDs(page), total, err)
+	}
+
+	// 最後一頁只有部分消息
+	page, total, _ = broker.GetDLQPage("work", 4, 2)
+	if total != 5 || pageIDs(page) != "[msg-4]" {
+		t.Errorf("Expected last page [msg-4] of 5, got %s of %d", pageIDs(page), total)
+	}
+
+	// offset 超出範圍返回空頁
+	page, total, err = broker.GetDLQPage("work", 10, 2)
+	if err != nil || total != 5 || len(page) != 0 {
+		t.Errorf("Expected empty page of 5, got %d messages of %d (err %v)", len(page), total, err)
+	}
+
+	if page, total, err := broker.GetDLQPage("missing", 0, 10); err != nil || total != 0 || len(page) != 0 {
+		t.Errorf("Expected empty page for missing DLQ, got %v of %d (err %v)", page, total, err)
+	}
+
+	if _, _, err := broker.GetDLQPage("work", -1, 2); err == nil {
+		t.Error("Expected error for negative offset")
+	}
+	if _, _, err := broker.GetDLQPage("work", 0, 0); err == nil {
+		t.Error("Expected error for zero limit")
+	}
+}
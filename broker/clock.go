@@ -0,0 +1,176 @@
+package broker
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock 抽象時間來源，讓依賴時間的邏輯 (逾時、延遲投遞、運行時間) 可以在測試中以虛擬時間驅動
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer 是 Clock 產生的計時器
+type Timer interface {
+	// C 返回到期時接收時間的通道 (AfterFunc 產生的計時器返回 nil)
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// RealClock 是使用系統時間的 Clock
+type RealClock struct{}
+
+// Now 返回目前的系統時間
+func (RealClock) Now() time.Time { return time.Now() }
+
+// After 等同於 time.After
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// NewTimer 等同於 time.NewTimer
+func (RealClock) NewTimer(d time.Duration) Timer { return &realTimer{t: time.NewTimer(d)} }
+
+// AfterFunc 等同於 time.AfterFunc
+func (RealClock) AfterFunc(d time.Duration, f func()) Timer {
+	return &realTimer{t: time.AfterFunc(d, f)}
+}
+
+// realTimer 包裝 *time.Timer
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r *realTimer) C() <-chan time.Time        { return r.t.C }
+func (r *realTimer) Stop() bool                 { return r.t.Stop() }
+func (r *realTimer) Reset(d time.Duration) bool { return r.t.Reset(d) }
+
+// FakeClock 是測試用的虛擬時鐘，只有呼叫 Advance 時時間才會前進
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock 創建一個從 start 開始的虛擬時鐘
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now 返回目前的虛擬時間
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After 返回在虛擬時間經過 d 後接收時間的通道
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer 創建一個在虛擬時間經過 d 後到期的計時器
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	c.schedule(t, d)
+	return t
+}
+
+// AfterFunc 創建一個在虛擬時間經過 d 後呼叫 f 的計時器
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{clock: c, fn: f}
+	c.schedule(t, d)
+	return t
+}
+
+// Advance 將虛擬時間前進 d，並依到期順序觸發所有到期的計時器
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+
+	var due []*fakeTimer
+	remaining := c.timers[:0]
+	for _, t := range c.timers {
+		if !t.fireAt.After(now) {
+			due = append(due, t)
+		} else {
+			remaining = append(remaining, t)
+		}
+	}
+	c.timers = remaining
+	sort.SliceStable(due, func(i, j int) bool { return due[i].fireAt.Before(due[j].fireAt) })
+	c.mu.Unlock()
+
+	for _, t := range due {
+		if t.fn != nil {
+			t.fn()
+			continue
+		}
+		select {
+		case t.ch <- now:
+		default:
+		}
+	}
+}
+
+// BlockUntil 等待直到至少有 n 個計時器在等待到期，用於確保其他 goroutine 已開始等待
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// schedule 登記一個計時器，d <= 0 時立即到期
+func (c *FakeClock) schedule(t *fakeTimer, d time.Duration) {
+	c.mu.Lock()
+	t.fireAt = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	c.mu.Unlock()
+
+	if d <= 0 {
+		c.Advance(0)
+	}
+}
+
+// remove 移除一個計時器，返回計時器是否仍在等待中
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer 是 FakeClock 產生的計時器
+type fakeTimer struct {
+	clock  *FakeClock
+	fireAt time.Time
+	ch     chan time.Time
+	fn     func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.clock.remove(t)
+	t.clock.schedule(t, d)
+	return active
+}
@@ -0,0 +1,107 @@
+package broker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockTimers(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+
+	timer := clock.NewTimer(time.Second)
+	var fired []string
+	clock.AfterFunc(2*time.Second, func() { fired = append(fired, "func") })
+	stopped := clock.NewTimer(time.Second)
+	stopped.Stop()
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("Timer fired before its deadline")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case at := <-timer.C():
+		if !at.Equal(time.Unix(1, 0)) {
+			t.Errorf("Expected timer to fire at 1s, got %v", at)
+		}
+	default:
+		t.Fatal("Expected timer to fire at its deadline")
+	}
+	select {
+	case <-stopped.C():
+		t.Error("Stopped timer should not fire")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if len(fired) != 1 {
+		t.Errorf("Expected AfterFunc to run once, got %v", fired)
+	}
+}
+
+func TestPullWithTimeoutFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	queueName := "fake-timeout-queue"
+	broker.Push(queueName, NewMessage("msg-1", []byte("test"), queueName))
+	broker.Pull(queueName)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := broker.PullWithTimeout(queueName, time.Hour)
+		done <- err
+	}()
+
+	// 等待 PullWithTimeout 開始計時後再前進虛擬時間
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected timeout error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("PullWithTimeout did not time out after advancing the fake clock")
+	}
+}
+
+func TestMetricsUptimeFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	clock.Advance(90 * time.Second)
+
+	uptime := broker.GetMetrics().GetStats()["uptime_seconds"].(float64)
+	if uptime != 90 {
+		t.Errorf("Expected uptime of exactly 90s, got %f", uptime)
+	}
+}
+
+func TestPushDelayedFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	broker.PushDelayed("delayed", NewMessage("msg-1", []byte("later"), "delayed"), time.Minute)
+
+	clock.Advance(59 * time.Second)
+	if len(broker.GetScheduled("delayed")) != 1 {
+		t.Fatal("Expected message to still be scheduled before its delay")
+	}
+
+	clock.Advance(time.Second)
+	msg, err := broker.Pull("delayed")
+	if err != nil || msg == nil || msg.ID != "msg-1" {
+		t.Fatalf("Expected delayed message to be delivered, got %v (err=%v)", msg, err)
+	}
+	if !msg.Timestamp.Equal(clock.Now()) {
+		t.Errorf("Expected message timestamp from fake clock, got %v", msg.Timestamp)
+	}
+}
@@ -0,0 +1,90 @@
+package broker
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// 未設定時的預設值
+const (
+	DefaultQueueBufferSize    = 1000            // 每個隊列的緩衝大小，隊列滿時新消息會進入死信隊列
+	DefaultDedupeMaxIDs       = 10000           // ID 去重最多記住的 ID 數
+	DefaultOrderedPushTimeout = 5 * time.Second // 有序隊列已滿時 Push 等待消費者騰出空間的時間上限
+)
+
+// BrokerConfig 是 SimpleBroker 的建構設定，零值欄位使用預設值
+type BrokerConfig struct {
+	QueueBufferSize  int            // 每個隊列的緩衝大小，<= 0 時使用 DefaultQueueBufferSize
+	QueueBufferSizes map[string]int // 個別隊列的緩衝大小，優先於 QueueBufferSize (效果同 DeclareQueue)
+
+	OrderedQueues      map[string]bool // 有序隊列：已滿時 Push 阻塞等待而不是移入死信隊列，單一生產者的順序不會被打亂
+	OrderedPushTimeout time.Duration   // 有序隊列 Push 等待的時間上限，逾時返回 ErrQueueFull；<= 0 時使用 DefaultOrderedPushTimeout
+	Clock              Clock           // 時間來源，nil 時使用 RealClock
+
+	DeadLetterExpired bool // 超過 TTL 的消息移入死信隊列，而不是直接丟棄
+
+	MaxDLQSize  int               // 每個死信隊列最多保存的消息數，<= 0 表示不限制
+	DLQOverflow DLQOverflowPolicy // 死信隊列已滿時的處理方式，預設淘汰最舊的消息
+
+	DLQRetention       time.Duration // > 0 時背景定期清除 Timestamp 早於此期限的死信消息
+	DLQJanitorInterval time.Duration // 清除過期死信的間隔，<= 0 時使用 DefaultDLQJanitorInterval
+
+	DedupeWindow time.Duration // > 0 時開啟 ID 去重：窗口內重複推送的相同 ID 會被丟棄
+	DedupeMaxIDs int           // ID 去重最多記住的 ID 數 (LRU 淘汰)，<= 0 時使用 DefaultDedupeMaxIDs
+
+	RetryPolicy RetryPolicy // Nack 重新入隊的退避策略，零值表示立即重新入隊
+
+	QueueRateLimits     map[string]RateLimit // 個別隊列的推送速率限制，超過時 Push 返回 ErrRateLimited；未設定的隊列不限制
+	RateLimitDeadLetter bool                 // 超過速率限制的消息同時移入死信隊列，而不是只拒絕
+
+	SubscriberOverflow     SubscriberOverflowPolicy // Publish 時訂閱者通道已滿的處理方式，預設丟棄
+	SubscriberBlockTimeout time.Duration            // SubscriberOverflowBlock 策略下等待訂閱者的時間上限
+
+	TopicReplaySizes map[string]int // 個別主題保留最近發布的消息數，供 SubscribeWithReplay 補發；未設定的主題不保留
+
+	// TracerProvider 用於建立推送與拉取的 span，nil 時不追蹤 (no-op)
+	TracerProvider trace.TracerProvider
+
+	// 以下只用於 PersistentBroker
+	WALPath string // 預寫日誌路徑
+	WALSync bool   // 每次寫入後 fsync，可在主機斷電時不遺失消息，但會降低吞吐量
+}
+
+// DefaultBrokerConfig 返回預設設定
+func DefaultBrokerConfig() BrokerConfig {
+	return BrokerConfig{QueueBufferSize: DefaultQueueBufferSize, Clock: RealClock{}, TracerProvider: noop.NewTracerProvider()}
+}
+
+// withDefaults 以預設值補齊未設定的欄位
+func (c BrokerConfig) withDefaults() BrokerConfig {
+	defaults := DefaultBrokerConfig()
+	if c.QueueBufferSize <= 0 {
+		c.QueueBufferSize = defaults.QueueBufferSize
+	}
+	if c.Clock == nil {
+		c.Clock = defaults.Clock
+	}
+	if c.TracerProvider == nil {
+		c.TracerProvider = defaults.TracerProvider
+	}
+	if c.DedupeMaxIDs <= 0 {
+		c.DedupeMaxIDs = DefaultDedupeMaxIDs
+	}
+	if c.OrderedPushTimeout <= 0 {
+		c.OrderedPushTimeout = DefaultOrderedPushTimeout
+	}
+	if c.DLQJanitorInterval <= 0 {
+		c.DLQJanitorInterval = DefaultDLQJanitorInterval
+	}
+	return c
+}
+
+// bufferSize 返回隊列應使用的緩衝大小
+func (c BrokerConfig) bufferSize(queue string) int {
+	if size := c.QueueBufferSizes[queue]; size > 0 {
+		return size
+	}
+	return c.QueueBufferSize
+}
@@ -0,0 +1,170 @@
+package broker
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQueueBufferSizeOverflowToDLQ(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{QueueBufferSize: 2})
+	defer broker.Close()
+
+	for _, id := range []string{"msg-1", "msg-2", "msg-3"} {
+		broker.Push("test", NewMessage(id, []byte(id), "test"))
+	}
+
+	dlq := broker.GetDLQ("test")
+	if len(dlq) != 1 || dlq[0].ID != "msg-3" {
+		t.Fatalf("Expected msg-3 in DLQ, got %v", dlq)
+	}
+	for _, want := range []string{"msg-1", "msg-2"} {
+		msg, _ := broker.Pull("test")
+		if msg == nil || msg.ID != want {
+			t.Errorf("Expected %s, got %v", want, msg)
+		}
+	}
+}
+
+func TestBrokerConfigDefaults(t *testing.T) {
+	cfg := BrokerConfig{}.withDefaults()
+	if cfg.QueueBufferSize != DefaultQueueBufferSize {
+		t.Errorf("Expected default buffer size %d, got %d", DefaultQueueBufferSize, cfg.QueueBufferSize)
+	}
+	if cfg.Clock == nil {
+		t.Error("Expected default clock")
+	}
+
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	if broker.config.QueueBufferSize != DefaultQueueBufferSize {
+		t.Errorf("Expected NewSimpleBroker to use default buffer size, got %d", broker.config.QueueBufferSize)
+	}
+}
+
+func TestDeclareQueueIndependentCapacity(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{QueueBufferSize: 10})
+	defer broker.Close()
+
+	if err := broker.DeclareQueue("blocks", 3); err != nil {
+		t.Fatalf("DeclareQueue blocks failed: %v", err)
+	}
+	if err := broker.DeclareQueue("transactions", 1); err != nil {
+		t.Fatalf("DeclareQueue transactions failed: %v", err)
+	}
+
+	for i := 0; i < 4; i++ {
+		broker.Push("blocks", NewMessage("b", []byte("b"), "blocks"))
+		broker.Push("transactions", NewMessage("t", []byte("t"), "transactions"))
+	}
+
+	if dlq := broker.GetDLQ("blocks"); len(dlq) != 1 {
+		t.Errorf("Expected 1 blocks message in DLQ, got %d", len(dlq))
+	}
+	if dlq := broker.GetDLQ("transactions"); len(dlq) != 3 {
+		t.Errorf("Expected 3 transactions messages in DLQ, got %d", len(dlq))
+	}
+
+	// 未宣告的隊列使用 Broker 的預設大小
+	for i := 0; i < 10; i++ {
+		broker.Push("other", NewMessage("o", []byte("o"), "other"))
+	}
+	if dlq := broker.GetDLQ("other"); len(dlq) != 0 {
+		t.Errorf("Expected undeclared queue to use the broker default, got %d in DLQ", len(dlq))
+	}
+}
+
+func TestDeclareQueueConflict(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	if err := broker.DeclareQueue("blocks", 5); err != nil {
+		t.Fatalf("DeclareQueue failed: %v", err)
+	}
+	if err := broker.DeclareQueue("blocks", 5); err != nil {
+		t.Errorf("Expected redeclaring with the same size to succeed, got %v", err)
+	}
+	if err := broker.DeclareQueue("blocks", 6); err == nil {
+		t.Error("Expected error redeclaring with a different size")
+	}
+
+	// 已被 Push 以預設大小創建的隊列
+	broker.Push("lazy", NewMessage("msg-1", []byte("data"), "lazy"))
+	if err := broker.DeclareQueue("lazy", 5); err == nil {
+		t.Error("Expected error declaring a lazily created queue with a different size")
+	}
+	if err := broker.DeclareQueue("bad", 0); err == nil {
+		t.Error("Expected error for zero buffer size")
+	}
+}
+
+func TestQueueBufferSizesOverride(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		QueueBufferSize:  1,
+		QueueBufferSizes: map[string]int{"blocks": 3},
+	})
+	defer broker.Close()
+
+	for i := 0; i < 3; i++ {
+		broker.Push("blocks", NewMessage("b", []byte("b"), "blocks"))
+		broker.Push("transactions", NewMessage("t", []byte("t"), "transactions"))
+	}
+
+	if dlq := broker.GetDLQ("blocks"); len(dlq) != 0 {
+		t.Errorf("Expected per-queue override to hold 3 messages, got %d in DLQ", len(dlq))
+	}
+	if dlq := broker.GetDLQ("transactions"); len(dlq) != 2 {
+		t.Errorf("Expected 2 transactions messages in DLQ, got %d", len(dlq))
+	}
+	if err := broker.DeclareQueue("blocks", 3); err != nil {
+		t.Errorf("Expected declaring the configured size to succeed, got %v", err)
+	}
+}
+
+func TestQueueStatsUtilization(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{QueueBufferSize: 4})
+	defer broker.Close()
+
+	var last float64
+	for i := 1; i <= 4; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+		stats, _ := broker.GetQueueStats("test")
+		if stats.Capacity != 4 {
+			t.Fatalf("Expected capacity 4, got %d", stats.Capacity)
+		}
+		if stats.Utilization <= last {
+			t.Errorf("Expected utilization to rise after push %d, got %v", i, stats.Utilization)
+		}
+		last = stats.Utilization
+	}
+	if last != 1.0 {
+		t.Errorf("Expected a full queue to report utilization 1.0, got %v", last)
+	}
+
+	// 溢出的消息進入死信隊列，使用率維持在 1.0
+	broker.Push("test", NewMessage("overflow", nil, "test"))
+	if stats, _ := broker.GetQueueStats("test"); stats.Utilization != 1.0 || stats.DeadLetterCount != 1 {
+		t.Errorf("Expected utilization 1.0 with 1 dead letter, got %v and %d", stats.Utilization, stats.DeadLetterCount)
+	}
+
+	broker.Pull("test")
+	broker.Pull("test")
+	queueMetrics := broker.GetMetrics().GetStats()["queue_metrics"].(map[string]*QueueStats)
+	if got := queueMetrics["test"]; got.Capacity != 4 || got.Utilization != 0.5 {
+		t.Errorf("Expected metrics to report capacity 4 and utilization 0.5, got %d and %v", got.Capacity, got.Utilization)
+	}
+}
+
+func TestQueueStatsCapacityForPriorityAndDeclaredQueues(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{QueueBufferSize: 10})
+	defer broker.Close()
+
+	broker.PushWithPriority("prio", NewMessage("msg-1", nil, "prio"))
+	if stats, _ := broker.GetQueueStats("prio"); stats.Capacity != 10 || stats.Utilization != 0.1 {
+		t.Errorf("Expected priority queue capacity 10 and utilization 0.1, got %d and %v", stats.Capacity, stats.Utilization)
+	}
+
+	broker.DeclareQueue("big", 200)
+	if stats, _ := broker.GetQueueStats("big"); stats.Capacity != 200 || stats.Utilization != 0 {
+		t.Errorf("Expected declared capacity 200 and utilization 0, got %d and %v", stats.Capacity, stats.Utilization)
+	}
+}
@@ -0,0 +1,22 @@
+package broker
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// RegisterConsumer 登記一個消費指定隊列的消費者，使 QueueStats.ConsumerCount 反映目前活躍的消費者數
+// 返回消費者 ID 與釋放函數，消費者停止時呼叫 release 減少計數 (重複呼叫無效)。隊列不存在時會被創建
+func (b *SimpleBroker) RegisterConsumer(queue string) (string, func()) {
+	mq := b.getOrCreateQueue(queue)
+	consumerID := queue + "-consumer-" + strconv.FormatUint(atomic.AddUint64(&b.consumerSeq, 1), 10)
+	atomic.AddInt32(&mq.stats.ConsumerCount, 1)
+
+	var once sync.Once
+	return consumerID, func() {
+		once.Do(func() {
+			atomic.AddInt32(&mq.stats.ConsumerCount, -1)
+		})
+	}
+}
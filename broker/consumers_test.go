@@ -0,0 +1,36 @@
+package broker
+
+import "testing"
+
+func TestRegisterConsumerTracksCount(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	consumerCount := func() int32 {
+		stats, err := broker.GetQueueStats("work")
+		if err != nil {
+			t.Fatalf("GetQueueStats failed: %v", err)
+		}
+		return stats.ConsumerCount
+	}
+
+	firstID, releaseFirst := broker.RegisterConsumer("work")
+	secondID, releaseSecond := broker.RegisterConsumer("work")
+	if firstID == secondID {
+		t.Errorf("Expected distinct consumer IDs, got %s twice", firstID)
+	}
+	if got := consumerCount(); got != 2 {
+		t.Errorf("Expected 2 consumers, got %d", got)
+	}
+
+	releaseFirst()
+	releaseFirst() // 重複釋放不會再減少
+	if got := consumerCount(); got != 1 {
+		t.Errorf("Expected 1 consumer after release, got %d", got)
+	}
+
+	releaseSecond()
+	if got := consumerCount(); got != 0 {
+		t.Errorf("Expected 0 consumers after releasing all, got %d", got)
+	}
+}
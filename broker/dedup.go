@@ -0,0 +1,207 @@
+package broker
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OpResultDuplicate 表示消息已被處理過，投遞時被自動確認並丟棄
+const OpResultDuplicate = "duplicate"
+
+// ContentID 由消息內容計算出穩定的 ID (SHA-256 十六進位)，相同內容必得到相同 ID
+func ContentID(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// idWindow 是有大小上限、依時間過期的已處理 ID 集合
+// 記錄依加入順序保存在 order 中，最舊的記錄在前，過期或超過上限時從前端淘汰
+type idWindow struct {
+	window  time.Duration
+	maxSize int
+	clock   Clock
+
+	mu    sync.Mutex
+	order *list.List               // *idEntry，依加入時間排序
+	ids   map[string]*list.Element // ID -> order 中的節點
+}
+
+// idEntry 是 idWindow 中的一筆記錄
+type idEntry struct {
+	id     string
+	seenAt time.Time
+}
+
+// newIDWindow 創建一個保存 window 時間內、最多 maxSize 個 ID 的集合
+func newIDWindow(window time.Duration, maxSize int, clock Clock) *idWindow {
+	return &idWindow{
+		window:  window,
+		maxSize: maxSize,
+		clock:   clock,
+		order:   list.New(),
+		ids:     make(map[string]*list.Element),
+	}
+}
+
+// add 記錄一個 ID，已存在時更新其時間
+func (w *idWindow) add(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.clock.Now()
+	w.expire(now)
+
+	if elem, exists := w.ids[id]; exists {
+		elem.Value.(*idEntry).seenAt = now
+		w.order.MoveToBack(elem)
+		return
+	}
+
+	w.ids[id] = w.order.PushBack(&idEntry{id: id, seenAt: now})
+	for w.order.Len() > w.maxSize {
+		w.remove(w.order.Front())
+	}
+}
+
+// addIfAbsent 在 ID 不在時間窗口內時記錄它並返回 true；已存在時返回 false 且不更新時間
+// 窗口從第一次記錄起算，持續重複的 ID 不會無限延長自己的窗口
+func (w *idWindow) addIfAbsent(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.clock.Now()
+	w.expire(now)
+	if _, exists := w.ids[id]; exists {
+		return false
+	}
+
+	w.ids[id] = w.order.PushBack(&idEntry{id: id, seenAt: now})
+	for w.order.Len() > w.maxSize {
+		w.remove(w.order.Front())
+	}
+	return true
+}
+
+// contains 判斷 ID 是否在時間窗口內被記錄過
+func (w *idWindow) contains(id string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.expire(w.clock.Now())
+	_, exists := w.ids[id]
+	return exists
+}
+
+// forget 移除一個 ID 的記錄，ID 不存在時不做任何事
+func (w *idWindow) forget(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if elem, exists := w.ids[id]; exists {
+		w.remove(elem)
+	}
+}
+
+// size 返回目前保存的 ID 數量
+func (w *idWindow) size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.order.Len()
+}
+
+// expire 淘汰超過時間窗口的記錄，呼叫者需持有鎖
+func (w *idWindow) expire(now time.Time) {
+	for elem := w.order.Front(); elem != nil; elem = w.order.Front() {
+		if now.Sub(elem.Value.(*idEntry).seenAt) < w.window {
+			return
+		}
+		w.remove(elem)
+	}
+}
+
+// remove 移除一筆記錄，呼叫者需持有鎖
+func (w *idWindow) remove(elem *list.Element) {
+	w.order.Remove(elem)
+	delete(w.ids, elem.Value.(*idEntry).id)
+}
+
+// EnableEffectivelyOnce 開啟「時間窗口內只處理一次」的投遞模式
+//
+// 開啟後，Push 會為沒有 ContentID 的消息以內容計算 ContentID，消費者處理完成後呼叫
+// MarkProcessed 確認。之後同一個 ContentID 的消息再被投遞時 (例如重新連線後重複推送、
+// 從 DLQ 重新處理)，Pull 會自動確認並丟棄它，不會交給消費者重複處理。
+//
+// 這只是「在去重窗口內有效地只處理一次」，並非真正的 exactly-once：
+// 已處理的 ID 只保留 window 時間、最多 maxSize 個，超出後的重複投遞仍會被處理；
+// 消費者處理完成但在 MarkProcessed 之前崩潰，消息仍可能被處理兩次。
+func (b *SimpleBroker) EnableEffectivelyOnce(window time.Duration, maxSize int) error {
+	if window <= 0 {
+		return fmt.Errorf("invalid dedup window %v", window)
+	}
+	if maxSize <= 0 {
+		return fmt.Errorf("invalid dedup max size %d", maxSize)
+	}
+	if !b.processed.CompareAndSwap(nil, newIDWindow(window, maxSize, b.clock)) {
+		return fmt.Errorf("effectively-once delivery is already enabled")
+	}
+	return nil
+}
+
+// MarkProcessed 確認消息已處理完成，之後相同 ContentID 的投遞會被自動丟棄
+func (b *SimpleBroker) MarkProcessed(queue string, msg Message) error {
+	processed := b.processed.Load()
+	if processed == nil {
+		return fmt.Errorf("effectively-once delivery is not enabled")
+	}
+	if msg.ContentID == "" {
+		return fmt.Errorf("message %s has no content id", msg.ID)
+	}
+
+	processed.add(msg.ContentID)
+	b.logOp("mark_processed", queue, msg.ID, OpResultOK)
+	return nil
+}
+
+// assignContentID 在開啟 effectively-once 模式時為消息補上 ContentID
+func (b *SimpleBroker) assignContentID(msg *Message) {
+	if msg.ContentID == "" && b.processed.Load() != nil {
+		msg.ContentID = ContentID(msg.Body)
+	}
+}
+
+// isProcessed 判斷取出的消息是否已經處理過，是的話記錄為重複並返回 true
+func (b *SimpleBroker) isProcessed(mq *messageQueue, msg Message) bool {
+	processed := b.processed.Load()
+	if processed == nil || msg.ContentID == "" || !processed.contains(msg.ContentID) {
+		return false
+	}
+
+	atomic.AddInt64(&mq.stats.DuplicateCount, 1)
+	b.logOp("auto_ack", mq.name, msg.ID, OpResultDuplicate)
+	return true
+}
+
+// dropDuplicate 在開啟 ID 去重且消息 ID 已在窗口內推送過時返回 true，並計入 DedupedCount
+// 去重以隊列與 ID 為鍵，不同隊列可以使用相同的 ID
+func (b *SimpleBroker) dropDuplicate(op, queue string, msg Message) bool {
+	if b.pushed == nil || b.pushed.addIfAbsent(queue+"\x00"+msg.ID) {
+		return false
+	}
+
+	atomic.AddInt64(&b.getOrCreateQueue(queue).stats.DedupedCount, 1)
+	b.logOp(op, queue, msg.ID, OpResultDuplicate)
+	return true
+}
+
+// releaseDuplicate 撤銷 dropDuplicate 對消息 ID 的記錄
+// 推送因速率限制或寫入 WAL 失敗等原因沒有完成時呼叫，讓生產者稍後重送同一 ID 時不會被當成重複而丟棄
+func (b *SimpleBroker) releaseDuplicate(queue string, msg Message) {
+	if b.pushed != nil {
+		b.pushed.forget(queue + "\x00" + msg.ID)
+	}
+}
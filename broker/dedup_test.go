@@ -0,0 +1,229 @@
+package broker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEffectivelyOnceRedeliveryDropped(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	if err := broker.EnableEffectivelyOnce(time.Minute, 100); err != nil {
+		t.Fatalf("EnableEffectivelyOnce failed: %v", err)
+	}
+
+	broker.Push("test", NewMessage("msg-1", []byte("block-100"), "test"))
+	msg, err := broker.Pull("test")
+	if err != nil || msg == nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if msg.ContentID != ContentID([]byte("block-100")) {
+		t.Errorf("Expected content-derived ID, got %q", msg.ContentID)
+	}
+	if err := broker.MarkProcessed("test", *msg); err != nil {
+		t.Fatalf("MarkProcessed failed: %v", err)
+	}
+
+	// 重新投遞相同內容的消息 (不同的消息 ID)，應被自動確認並丟棄
+	broker.Push("test", NewMessage("msg-1-retry", []byte("block-100"), "test"))
+	broker.Push("test", NewMessage("msg-2", []byte("block-101"), "test"))
+
+	msg, err = broker.Pull("test")
+	if err != nil || msg == nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if msg.ID != "msg-2" {
+		t.Errorf("Expected redelivered message to be skipped, got %s", msg.ID)
+	}
+	if msg, _ := broker.Pull("test"); msg != nil {
+		t.Errorf("Expected empty queue, got %s", msg.ID)
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.DuplicateCount != 1 {
+		t.Errorf("Expected 1 duplicate, got %d", stats.DuplicateCount)
+	}
+	if stats.MessageCount != 0 {
+		t.Errorf("Expected message count 0, got %d", stats.MessageCount)
+	}
+
+	// 未確認的消息重新投遞時仍會被處理
+	broker.Push("test", NewMessage("msg-2-retry", []byte("block-101"), "test"))
+	if msg, _ := broker.Pull("test"); msg == nil || msg.ID != "msg-2-retry" {
+		t.Errorf("Expected unacked message to be redelivered, got %v", msg)
+	}
+}
+
+func TestEffectivelyOnceWindowExpiry(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	broker.EnableEffectivelyOnce(time.Minute, 100)
+
+	msg := NewMessage("msg-1", []byte("payload"), "test")
+	msg.ContentID = "block-hash"
+	broker.Push("test", msg)
+	pulled, _ := broker.Pull("test")
+	broker.MarkProcessed("test", *pulled)
+
+	// 超過去重窗口後，同一 ID 會再被處理
+	clock.Advance(time.Minute)
+	broker.Push("test", msg)
+	if pulled, _ := broker.Pull("test"); pulled == nil {
+		t.Error("Expected redelivery after the dedup window to be processed")
+	}
+}
+
+func TestEffectivelyOnceBlockingAndPullAny(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.EnableEffectivelyOnce(time.Minute, 100)
+
+	processed := NewMessage("done", []byte("done"), "a")
+	processed.ContentID = "done"
+	broker.MarkProcessed("a", processed)
+
+	broker.Push("a", processed)
+	broker.Push("a", NewMessage("fresh", []byte("fresh"), "a"))
+	msg, err := broker.PullWithTimeout("a", 100*time.Millisecond)
+	if err != nil || msg == nil || msg.ID != "fresh" {
+		t.Errorf("Expected blocking pull to skip processed message, got %v (err=%v)", msg, err)
+	}
+
+	broker.Push("b", processed)
+	if msg, _, _ := broker.PullAny([]string{"b"}); msg != nil {
+		t.Errorf("Expected PullAny to drop processed message, got %s", msg.ID)
+	}
+}
+
+func TestEffectivelyOnceDisabled(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", []byte("data"), "test"))
+	msg, _ := broker.Pull("test")
+	if msg.ContentID != "" {
+		t.Errorf("Expected no content ID when disabled, got %q", msg.ContentID)
+	}
+	if err := broker.MarkProcessed("test", *msg); err == nil {
+		t.Error("Expected error marking processed when disabled")
+	}
+
+	broker.EnableEffectivelyOnce(time.Minute, 100)
+	if err := broker.EnableEffectivelyOnce(time.Minute, 100); err == nil {
+		t.Error("Expected error enabling twice")
+	}
+}
+
+func TestIDWindowMaxSize(t *testing.T) {
+	w := newIDWindow(time.Hour, 3, NewFakeClock(time.Unix(0, 0)))
+	for i := 0; i < 5; i++ {
+		w.add(fmt.Sprintf("id-%d", i))
+	}
+
+	if w.size() != 3 {
+		t.Errorf("Expected size 3, got %d", w.size())
+	}
+	if w.contains("id-0") || w.contains("id-1") {
+		t.Error("Expected oldest IDs to be evicted")
+	}
+	if !w.contains("id-4") {
+		t.Error("Expected newest ID to be kept")
+	}
+}
+
+func TestDedupeWindowDropsRepeatedID(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{Clock: clock, DedupeWindow: time.Minute})
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", []byte("first"), "test"))
+
+	// 窗口內再次推送相同 ID，應被丟棄
+	clock.Advance(30 * time.Second)
+	if err := broker.Push("test", NewMessage("msg-1", []byte("second"), "test")); err != nil {
+		t.Fatalf("Push of duplicate should not fail: %v", err)
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.MessageCount != 1 {
+		t.Errorf("Expected message count 1, got %d", stats.MessageCount)
+	}
+	if stats.DedupedCount != 1 {
+		t.Errorf("Expected 1 deduped message, got %d", stats.DedupedCount)
+	}
+
+	// 窗口從第一次推送起算，過了窗口後相同 ID 應被接受
+	clock.Advance(31 * time.Second)
+	broker.Push("test", NewMessage("msg-1", []byte("third"), "test"))
+
+	var bodies []string
+	for {
+		msg, _ := broker.Pull("test")
+		if msg == nil {
+			break
+		}
+		bodies = append(bodies, string(msg.Body))
+	}
+	if len(bodies) != 2 || bodies[0] != "first" || bodies[1] != "third" {
+		t.Errorf("Expected [first third], got %v", bodies)
+	}
+
+	stats, _ = broker.GetQueueStats("test")
+	if stats.DedupedCount != 1 {
+		t.Errorf("Expected deduped count to stay 1, got %d", stats.DedupedCount)
+	}
+}
+
+func TestDedupeWindowScopedPerQueueAndSparesRetries(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{Clock: clock, DedupeWindow: time.Minute})
+	defer broker.Close()
+
+	broker.Push("a", NewMessage("msg-1", nil, "a"))
+	broker.Push("b", NewMessage("msg-1", nil, "b"))
+	if stats, _ := broker.GetQueueStats("b"); stats.MessageCount != 1 {
+		t.Errorf("Expected same ID on another queue to be accepted, got count %d", stats.MessageCount)
+	}
+
+	// 重新入隊的消息沿用原本的 ID，不應被去重
+	msg, _ := broker.Pull("a")
+	if err := broker.Requeue("a", *msg); err != nil {
+		t.Fatalf("Requeue failed: %v", err)
+	}
+	if stats, _ := broker.GetQueueStats("a"); stats.MessageCount != 1 || stats.DedupedCount != 0 {
+		t.Errorf("Expected requeued message to be kept, got count %d deduped %d", stats.MessageCount, stats.DedupedCount)
+	}
+
+	// 批次推送同樣去重
+	err := broker.PushBatch("a", []Message{NewMessage("msg-1", nil, "a"), NewMessage("msg-2", nil, "a")})
+	if err != nil {
+		t.Fatalf("PushBatch failed: %v", err)
+	}
+	if stats, _ := broker.GetQueueStats("a"); stats.MessageCount != 2 || stats.DedupedCount != 1 {
+		t.Errorf("Expected count 2 deduped 1, got count %d deduped %d", stats.MessageCount, stats.DedupedCount)
+	}
+}
+
+func TestDedupeWindowBoundedByMaxIDs(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{Clock: clock, DedupeWindow: time.Minute, DedupeMaxIDs: 2})
+	defer broker.Close()
+
+	for i := 0; i < 3; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+	}
+	// msg-0 已被淘汰，可再次推送；msg-2 仍在窗口內
+	broker.Push("test", NewMessage("msg-0", nil, "test"))
+	broker.Push("test", NewMessage("msg-2", nil, "test"))
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.MessageCount != 4 || stats.DedupedCount != 1 {
+		t.Errorf("Expected count 4 deduped 1, got count %d deduped %d", stats.MessageCount, stats.DedupedCount)
+	}
+}
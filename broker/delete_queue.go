@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrQueueNotEmpty 表示隊列還有待處理或已投遞未確認的消息，不能刪除
+var ErrQueueNotEmpty = errors.New("queue is not empty")
+
+// OpResultNotEmpty 表示刪除隊列因隊列不是空的而被拒絕
+const OpResultNotEmpty = "not_empty"
+
+// DeleteQueue 刪除已清空的隊列，釋放隊列本身與其統計，ActiveQueues 減一
+//
+// 隊列還有消息 (包括 Peek 暫存的隊首消息) 或已投遞未確認的消息時返回 ErrQueueNotEmpty；
+// 死信隊列不受影響，仍可以 GetDLQ 查詢。之後再推送到同名隊列時會重新創建。
+// 應先停止該隊列的消費者：刪除時仍在阻塞等待的 Pull 不會收到之後推送到新隊列的消息。
+func (b *SimpleBroker) DeleteQueue(queue string) error {
+	queueInterface, exists := b.queues.Load(queue)
+	if !exists {
+		return queueNotFound(queue)
+	}
+	mq := queueInterface.(*messageQueue)
+	if !mq.empty() {
+		b.logOp("delete_queue", queue, "", OpResultNotEmpty)
+		return fmt.Errorf("%w: %s has %d messages", ErrQueueNotEmpty, queue, atomic.LoadInt64(&mq.stats.MessageCount))
+	}
+	if !b.queues.CompareAndDelete(queue, mq) {
+		return nil // 其他 goroutine 已先刪除
+	}
+
+	b.metrics.unregisterQueue(queue, mq.stats)
+
+	// 檢查與刪除之間並行推送進來的消息轉移到重新創建的同名隊列，不會遺失
+	for {
+		msg, ok := mq.poll()
+		if !ok {
+			break
+		}
+		atomic.AddInt64(&mq.stats.MessageCount, -1)
+		b.journalConsume(msg)
+		if err := b.push(queue, msg); err != nil {
+			b.logOp("delete_queue", queue, msg.ID, opResult(err))
+		}
+	}
+
+	b.logOp("delete_queue", queue, "", OpResultOK)
+	return nil
+}
+
+// empty 返回隊列是否沒有待處理與未確認的消息
+func (mq *messageQueue) empty() bool {
+	return atomic.LoadInt64(&mq.stats.MessageCount) == 0 &&
+		atomic.LoadInt64(&mq.stats.InFlightCount) == 0 &&
+		mq.head.Load() == nil
+}
+
+// registerQueue 登記新建隊列的統計，供 GetStats 的 queue_metrics 與 ActiveQueues 使用
+func (m *Metrics) registerQueue(name string, stats *QueueStats) {
+	m.mu.Lock()
+	m.QueueMetrics[name] = stats
+	m.mu.Unlock()
+	atomic.AddInt32(&m.ActiveQueues, 1)
+}
+
+// unregisterQueue 移除已刪除隊列的統計登記，與 registerQueue 成對使用，避免短暫隊列的統計無限累積
+// 同名隊列已重新創建並登記了新的統計時不移除
+func (m *Metrics) unregisterQueue(name string, stats *QueueStats) {
+	m.mu.Lock()
+	if m.QueueMetrics[name] == stats {
+		delete(m.QueueMetrics, name)
+	}
+	m.mu.Unlock()
+	atomic.AddInt32(&m.ActiveQueues, -1)
+}
@@ -0,0 +1,115 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeleteEmptyQueue(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("transient", NewMessage("msg-1", nil, "transient"))
+	broker.Push("kept", NewMessage("msg-2", nil, "kept"))
+	broker.Pull("transient")
+
+	if err := broker.DeleteQueue("transient"); err != nil {
+		t.Fatalf("DeleteQueue failed: %v", err)
+	}
+
+	// 隊列與其統計都被移除，ActiveQueues 減一
+	if queues := broker.GetAllQueues(); len(queues) != 1 || queues[0] != "kept" {
+		t.Errorf("Expected only kept queue to remain, got %v", queues)
+	}
+	if got := broker.GetMetrics().ActiveQueues; got != 1 {
+		t.Errorf("Expected 1 active queue, got %d", got)
+	}
+	if _, exists := broker.GetMetrics().GetStats()["queue_metrics"].(map[string]*QueueStats)["transient"]; exists {
+		t.Error("Expected metrics entry to be removed")
+	}
+	if _, err := broker.GetQueueStats("transient"); !errors.Is(err, ErrQueueNotFound) {
+		t.Errorf("Expected ErrQueueNotFound after delete, got %v", err)
+	}
+
+	// 再次推送時重新創建
+	broker.Push("transient", NewMessage("msg-3", nil, "transient"))
+	if stats, err := broker.GetQueueStats("transient"); err != nil || stats.MessageCount != 1 || stats.EnqueuedTotal != 1 {
+		t.Errorf("Expected recreated queue with fresh stats, got %+v (%v)", stats, err)
+	}
+	if got := broker.GetMetrics().ActiveQueues; got != 2 {
+		t.Errorf("Expected 2 active queues after recreate, got %d", got)
+	}
+}
+
+func TestDeleteQueueRejectsNonEmpty(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", nil, "test"))
+	if err := broker.DeleteQueue("test"); !errors.Is(err, ErrQueueNotEmpty) {
+		t.Errorf("Expected ErrQueueNotEmpty, got %v", err)
+	}
+	if len(broker.GetAllQueues()) != 1 || broker.GetMetrics().ActiveQueues != 1 {
+		t.Error("Expected non-empty queue to be kept")
+	}
+
+	// Peek 暫存的隊首消息也算在內
+	broker.Peek("test")
+	if err := broker.DeleteQueue("test"); !errors.Is(err, ErrQueueNotEmpty) {
+		t.Errorf("Expected ErrQueueNotEmpty with a peeked message, got %v", err)
+	}
+}
+
+func TestDeleteQueueWithUnackedMessages(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	broker.EnableAcks(time.Minute)
+
+	broker.Push("test", NewMessage("msg-1", nil, "test"))
+	msg, err := broker.Pull("test")
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if err := broker.DeleteQueue("test"); !errors.Is(err, ErrQueueNotEmpty) {
+		t.Errorf("Expected ErrQueueNotEmpty with an in-flight message, got %v", err)
+	}
+
+	broker.Ack("test", msg.DeliveryTag)
+	if err := broker.DeleteQueue("test"); err != nil {
+		t.Errorf("Expected delete after ack to succeed, got %v", err)
+	}
+}
+
+func TestDeleteMissingQueue(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	if err := broker.DeleteQueue("missing"); !errors.Is(err, ErrQueueNotFound) {
+		t.Errorf("Expected ErrQueueNotFound, got %v", err)
+	}
+}
+
+func TestDeleteQueueRemovesMetricsEntry(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	// 短暫隊列清空並刪除後不再出現在 GetStats 中，統計不會無限累積
+	for i := 0; i < 100; i++ {
+		queue := fmt.Sprintf("ephemeral-%d", i)
+		broker.Push(queue, NewMessage("msg", nil, queue))
+		broker.PurgeQueue(queue)
+		if err := broker.DeleteQueue(queue); err != nil {
+			t.Fatalf("DeleteQueue %s failed: %v", queue, err)
+		}
+	}
+
+	stats := broker.GetMetrics().GetStats()
+	if queues := stats["queue_metrics"].(map[string]*QueueStats); len(queues) != 0 {
+		t.Errorf("Expected no queue metrics after delete, got %d entries", len(queues))
+	}
+	if stats["active_queues"] != int32(0) {
+		t.Errorf("Expected 0 active queues, got %v", stats["active_queues"])
+	}
+}
@@ -0,0 +1,36 @@
+package broker
+
+import "sync/atomic"
+
+// DrainDLQ 將 srcQueue 死信隊列中的所有消息移到 dstQueue 重新處理，重置 Attempts，返回移動的消息數
+// 死信隊列在同一次加鎖中被整批取出並清空，同時被移入的死信消息不是被這次移走就是留在死信隊列中，
+// 不會遺失或重複。推送失敗時，尚未推送的消息放回死信隊列並返回錯誤
+func (b *SimpleBroker) DrainDLQ(srcQueue, dstQueue string) (int, error) {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return 0, ErrBrokerClosed
+	}
+
+	dlqInterface, exists := b.deadLetters.Load(srcQueue)
+	if !exists {
+		return 0, nil
+	}
+	dlq := dlqInterface.(*deadLetterQueue)
+
+	dlq.mu.Lock()
+	drained := dlq.messages
+	dlq.messages = nil
+	dlq.mu.Unlock()
+
+	for i, msg := range drained {
+		msg.Attempts = 0
+		if err := b.push(dstQueue, msg); err != nil {
+			dlq.mu.Lock()
+			dlq.messages = append(drained[i:], dlq.messages...)
+			dlq.mu.Unlock()
+			b.logOp("drain_dlq", srcQueue, msg.ID, opResult(err))
+			return i, err
+		}
+		b.logOp("drain_dlq", srcQueue, msg.ID, OpResultOK)
+	}
+	return len(drained), nil
+}
@@ -0,0 +1,80 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestDrainDLQIntoFreshQueue(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	for i := 0; i < 5; i++ {
+		msg := NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "work")
+		msg.Attempts = 3
+		broker.MoveToDLQ("work", msg)
+	}
+
+	moved, err := broker.DrainDLQ("work", "recovery")
+	if err != nil || moved != 5 {
+		t.Fatalf("Expected 5 messages drained, got %d (err %v)", moved, err)
+	}
+
+	if dlq := broker.GetDLQ("work"); len(dlq) != 0 {
+		t.Errorf("Expected source DLQ to be empty, got %d messages", len(dlq))
+	}
+	stats, err := broker.GetQueueStats("recovery")
+	if err != nil || stats.MessageCount != 5 {
+		t.Fatalf("Expected 5 messages in recovery queue, got %+v (err %v)", stats, err)
+	}
+
+	// 依死信順序投遞，嘗試次數已重置
+	for i := 0; i < 5; i++ {
+		msg, err := broker.Pull("recovery")
+		if err != nil {
+			t.Fatalf("Pull failed: %v", err)
+		}
+		if msg.ID != fmt.Sprintf("msg-%d", i) || msg.Attempts != 0 || msg.Queue != "recovery" {
+			t.Errorf("Expected msg-%d with 0 attempts on recovery, got %s with %d attempts on %s", i, msg.ID, msg.Attempts, msg.Queue)
+		}
+	}
+
+	if moved, err := broker.DrainDLQ("work", "recovery"); err != nil || moved != 0 {
+		t.Errorf("Expected nothing to drain, got %d (err %v)", moved, err)
+	}
+}
+
+func TestDrainDLQConcurrentWithDeadLettering(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	broker.DeclareQueue("recovery", 10000)
+
+	const total = 1000
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < total; i++ {
+			broker.MoveToDLQ("work", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "work"))
+		}
+	}()
+
+	drained := 0
+	for i := 0; i < 20; i++ {
+		moved, err := broker.DrainDLQ("work", "recovery")
+		if err != nil {
+			t.Fatalf("DrainDLQ failed: %v", err)
+		}
+		drained += moved
+	}
+	wg.Wait()
+	moved, _ := broker.DrainDLQ("work", "recovery")
+	drained += moved
+
+	// 每條死信消息恰好被移動一次
+	stats, _ := broker.GetQueueStats("recovery")
+	if drained != total || stats.MessageCount != total {
+		t.Errorf("Expected %d messages drained and queued, got %d drained and %d queued", total, drained, stats.MessageCount)
+	}
+}
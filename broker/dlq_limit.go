@@ -0,0 +1,46 @@
+package broker
+
+import "sync/atomic"
+
+// DLQOverflowPolicy 決定死信隊列達到 BrokerConfig.MaxDLQSize 時如何處理新的死信消息
+type DLQOverflowPolicy int
+
+const (
+	DLQDropOldest   DLQOverflowPolicy = iota // 淘汰最舊的死信消息以保留新的 (預設)
+	DLQRejectNewest                          // 保留既有的死信消息，丟棄新的
+)
+
+// add 加入一條死信消息；max > 0 且已滿時依 policy 淘汰最舊的消息或拒絕新消息
+// 返回被淘汰或拒絕的消息
+func (d *deadLetterQueue) add(msg Message, max int, policy DLQOverflowPolicy) (Message, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if max <= 0 || len(d.messages) < max {
+		d.messages = append(d.messages, msg)
+		return Message{}, false
+	}
+	if policy == DLQRejectNewest {
+		return msg, true
+	}
+
+	evicted := d.messages[0]
+	copy(d.messages, d.messages[1:])
+	d.messages[len(d.messages)-1] = msg
+	return evicted, true
+}
+
+// addDeadLetter 將消息加入隊列的死信隊列，受 MaxDLQSize 限制
+// 被淘汰的消息計入 DLQEvictedCount，並從 WAL 中標記為已消費，重啟後不會再恢復
+func (b *SimpleBroker) addDeadLetter(queue string, msg Message) {
+	evicted, ok := b.getOrCreateDLQ(queue).add(msg, b.config.MaxDLQSize, b.config.DLQOverflow)
+	if !ok {
+		return
+	}
+
+	b.journalConsume(evicted)
+	if queueInterface, exists := b.queues.Load(queue); exists {
+		atomic.AddInt64(&queueInterface.(*messageQueue).stats.DLQEvictedCount, 1)
+	}
+	b.logOp("dlq_evict", queue, evicted.ID, OpResultOK)
+}
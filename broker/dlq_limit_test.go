@@ -0,0 +1,89 @@
+package broker
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// dlqIDs 返回死信隊列中消息的 ID
+func dlqIDs(b *SimpleBroker, queue string) []string {
+	var ids []string
+	for _, msg := range b.GetDLQ(queue) {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func TestMaxDLQSizeDropsOldest(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{MaxDLQSize: 3})
+	defer broker.Close()
+	broker.DeclareQueue("work", 10)
+
+	for i := 1; i <= 5; i++ {
+		broker.MoveToDLQ("work", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "work"))
+	}
+
+	if got := fmt.Sprint(dlqIDs(broker, "work")); got != "[msg-3 msg-4 msg-5]" {
+		t.Errorf("Expected the 3 newest dead letters, got %s", got)
+	}
+	stats, _ := broker.GetQueueStats("work")
+	if stats.DLQEvictedCount != 2 {
+		t.Errorf("Expected 2 evictions, got %d", stats.DLQEvictedCount)
+	}
+	if stats.DeadLetterCount != 5 {
+		t.Errorf("Expected all 5 dead letters counted, got %d", stats.DeadLetterCount)
+	}
+}
+
+func TestMaxDLQSizeRejectsNewest(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{MaxDLQSize: 3, DLQOverflow: DLQRejectNewest})
+	defer broker.Close()
+	broker.DeclareQueue("work", 10)
+
+	for i := 1; i <= 5; i++ {
+		broker.MoveToDLQ("work", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "work"))
+	}
+
+	if got := fmt.Sprint(dlqIDs(broker, "work")); got != "[msg-1 msg-2 msg-3]" {
+		t.Errorf("Expected the 3 oldest dead letters, got %s", got)
+	}
+	if stats, _ := broker.GetQueueStats("work"); stats.DLQEvictedCount != 2 {
+		t.Errorf("Expected 2 rejections, got %d", stats.DLQEvictedCount)
+	}
+}
+
+func TestDLQUnboundedByDefault(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	for i := 0; i < 50; i++ {
+		broker.MoveToDLQ("work", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "work"))
+	}
+	if got := len(broker.GetDLQ("work")); got != 50 {
+		t.Errorf("Expected 50 dead letters, got %d", got)
+	}
+}
+
+func TestEvictedDeadLettersNotRestored(t *testing.T) {
+	cfg := BrokerConfig{WALPath: filepath.Join(t.TempDir(), "broker.wal"), MaxDLQSize: 2}
+
+	broker, err := NewPersistentBroker(cfg)
+	if err != nil {
+		t.Fatalf("NewPersistentBroker failed: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		broker.MoveToDLQ("work", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "work"))
+	}
+	broker.Close()
+
+	// 被淘汰的死信消息已在 WAL 中標記為已消費
+	restored, err := NewPersistentBroker(cfg)
+	if err != nil {
+		t.Fatalf("NewPersistentBroker failed: %v", err)
+	}
+	defer restored.Close()
+	if got := fmt.Sprint(dlqIDs(restored.SimpleBroker, "work")); got != "[msg-3 msg-4]" {
+		t.Errorf("Expected retained dead letters after restart, got %s", got)
+	}
+}
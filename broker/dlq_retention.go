@@ -0,0 +1,72 @@
+package broker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDLQJanitorInterval 是死信保留期限 (BrokerConfig.DLQRetention) 的預設清理間隔
+const DefaultDLQJanitorInterval = time.Minute
+
+// runDLQJanitor 每隔 DLQJanitorInterval 清除超過 DLQRetention 的死信消息，直到 Broker 關閉
+func (b *SimpleBroker) runDLQJanitor() {
+	timer := b.clock.NewTimer(b.config.DLQJanitorInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-timer.C():
+			if b.ctx.Err() != nil {
+				return // 關閉後不再清理
+			}
+			b.sweepDLQs(b.clock.Now().Add(-b.config.DLQRetention))
+			timer.Reset(b.config.DLQJanitorInterval)
+		}
+	}
+}
+
+// sweepDLQs 移除所有死信隊列中 Timestamp 早於 cutoff 的消息，返回移除的數量
+// 被移除的消息計入 DLQExpiredCount 與 dlq_expired，並從 WAL 中標記為已消費，重啟後不會再恢復
+func (b *SimpleBroker) sweepDLQs(cutoff time.Time) int {
+	total := 0
+	b.deadLetters.Range(func(key, value interface{}) bool {
+		queue := key.(string)
+		expired := value.(*deadLetterQueue).removeBefore(cutoff)
+		if len(expired) == 0 {
+			return true
+		}
+
+		for _, msg := range expired {
+			b.journalConsume(msg)
+			b.logOp("dlq_expire", queue, msg.ID, OpResultOK)
+		}
+		if queueInterface, exists := b.queues.Load(queue); exists {
+			atomic.AddInt64(&queueInterface.(*messageQueue).stats.DLQExpiredCount, int64(len(expired)))
+		}
+		atomic.AddInt64(&b.metrics.DLQExpired, int64(len(expired)))
+		total += len(expired)
+		return true
+	})
+	return total
+}
+
+// removeBefore 移除並返回 Timestamp 早於 cutoff 的死信消息，其餘消息保持原本的順序
+func (d *deadLetterQueue) removeBefore(cutoff time.Time) []Message {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var expired []Message
+	kept := d.messages[:0]
+	for _, msg := range d.messages {
+		if msg.Timestamp.Before(cutoff) {
+			expired = append(expired, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	clear(d.messages[len(kept):]) // 釋放被移除消息的內容
+	d.messages = kept
+	return expired
+}
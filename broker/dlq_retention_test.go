@@ -0,0 +1,91 @@
+package broker
+
+import (
+	"testing"
+	"time"
+)
+
+// advanceJanitor 讓清理 goroutine 依序執行 n 次
+func advanceJanitor(clock *FakeClock, n int) {
+	for i := 0; i < n; i++ {
+		clock.BlockUntil(1) // 清理器正在等待下一次清理
+		clock.Advance(time.Minute)
+	}
+	clock.BlockUntil(1) // 等待最後一次清理完成
+}
+
+func TestDLQRetentionSweepsOldEntries(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		QueueBufferSize:    1,
+		Clock:              clock,
+		DLQRetention:       10 * time.Minute,
+		DLQJanitorInterval: time.Minute,
+	})
+	defer broker.Close()
+
+	// 隊列只能放一條，之後的消息進入死信隊列，Timestamp 為推送時間
+	broker.Push("test", NewMessage("queued", nil, "test"))
+	broker.Push("test", NewMessage("old", nil, "test"))
+
+	advanceJanitor(clock, 6)
+	broker.Push("test", NewMessage("new", nil, "test"))
+	if got := len(broker.GetDLQ("test")); got != 2 {
+		t.Fatalf("Expected 2 dead letters before retention elapses, got %d", got)
+	}
+
+	// 第 11 分鐘時 old 已超過 10 分鐘，new 只有 5 分鐘
+	advanceJanitor(clock, 5)
+	dlq := broker.GetDLQ("test")
+	if len(dlq) != 1 || dlq[0].ID != "new" {
+		t.Errorf("Expected only the new dead letter to remain, got %+v", dlq)
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.DLQExpiredCount != 1 {
+		t.Errorf("Expected DLQExpiredCount 1, got %d", stats.DLQExpiredCount)
+	}
+	if got := broker.GetMetrics().GetStats()["dlq_expired"]; got != int64(1) {
+		t.Errorf("Expected dlq_expired 1, got %v", got)
+	}
+	// 隊列中的消息不受影響
+	if stats.MessageCount != 1 {
+		t.Errorf("Expected queued message to be kept, got %d", stats.MessageCount)
+	}
+}
+
+func TestDLQJanitorStopsOnClose(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		QueueBufferSize:    1,
+		Clock:              clock,
+		DLQRetention:       time.Minute,
+		DLQJanitorInterval: time.Minute,
+	})
+
+	broker.Push("test", NewMessage("queued", nil, "test"))
+	broker.Push("test", NewMessage("dead", nil, "test"))
+	clock.BlockUntil(1)
+
+	broker.Close()
+	clock.Advance(time.Hour)
+
+	if got := len(broker.GetDLQ("test")); got != 1 {
+		t.Errorf("Expected no sweep after Close, got %d dead letters", got)
+	}
+}
+
+func TestDLQRetentionDisabledByDefault(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{QueueBufferSize: 1, Clock: clock})
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("queued", nil, "test"))
+	broker.Push("test", NewMessage("dead", nil, "test"))
+	clock.Advance(24 * time.Hour)
+
+	// 未設定 DLQRetention 時死信消息一直保留
+	if got := len(broker.GetDLQ("test")); got != 1 {
+		t.Errorf("Expected dead letter to be kept without retention, got %d", got)
+	}
+}
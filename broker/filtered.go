@@ -0,0 +1,88 @@
+package broker
+
+import (
+	"slices"
+	"sync/atomic"
+)
+
+// filteredSubscriber 是只接收符合 predicate 消息的訂閱者
+type filteredSubscriber struct {
+	ch        chan Message
+	predicate func(Message) bool
+	removed   bool // 已取消訂閱 (通道已關閉)，由 subscriberManager.mu 保護
+}
+
+// SubscribeFiltered 訂閱主題中 predicate 返回 true 的消息
+//
+// predicate 在 Publish 中於訂閱鎖之外執行，不會阻塞其他訂閱者的註冊與取消；
+// predicate panic 時視為不匹配。以 Unsubscribe 取消訂閱。
+func (b *SimpleBroker) SubscribeFiltered(topic string, predicate func(Message) bool) (<-chan Message, error) {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return nil, ErrBrokerClosed
+	}
+	if predicate == nil {
+		return b.Subscribe(topic)
+	}
+
+	sub := &filteredSubscriber{ch: make(chan Message, subscriberBufferSize), predicate: predicate}
+	subMgr := b.getOrCreateSubscriberManager(topic)
+	subMgr.mu.Lock()
+	if atomic.LoadInt32(&b.closed) == 1 {
+		subMgr.mu.Unlock()
+		return nil, ErrBrokerClosed
+	}
+	subMgr.filtered = append(subMgr.filtered, sub)
+	subMgr.mu.Unlock()
+
+	atomic.AddInt32(&b.metrics.ActiveConsumers, 1)
+	return sub.ch, nil
+}
+
+// matchFiltered 在不持有鎖的情況下評估每個過濾訂閱者的 predicate，返回匹配的訂閱者
+func (m *subscriberManager) matchFiltered(msg Message) []*filteredSubscriber {
+	m.mu.RLock()
+	candidates := slices.Clone(m.filtered)
+	m.mu.RUnlock()
+
+	var matched []*filteredSubscriber
+	for _, sub := range candidates {
+		if sub.matches(msg) {
+			matched = append(matched, sub)
+		}
+	}
+	return matched
+}
+
+// matches 執行 predicate，panic 時視為不匹配
+func (s *filteredSubscriber) matches(msg Message) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return s.predicate(msg)
+}
+
+// removeFiltered 移除並關閉過濾訂閱者的通道，返回是否找到 (呼叫者需持有寫鎖)
+func (m *subscriberManager) removeFiltered(subscriber <-chan Message) bool {
+	for i, sub := range m.filtered {
+		if sub.ch == subscriber {
+			m.filtered = append(m.filtered[:i], m.filtered[i+1:]...)
+			sub.removed = true
+			close(sub.ch)
+			return true
+		}
+	}
+	return false
+}
+
+// closeFiltered 關閉所有過濾訂閱者的通道，返回關閉的數量 (呼叫者需持有寫鎖)
+func (m *subscriberManager) closeFiltered() int {
+	closed := len(m.filtered)
+	for _, sub := range m.filtered {
+		sub.removed = true
+		close(sub.ch)
+	}
+	m.filtered = nil
+	return closed
+}
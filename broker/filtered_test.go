@@ -0,0 +1,100 @@
+package broker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSubscribeFilteredDeliversMatchingSubset(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	// 兩個訂閱者以不同條件訂閱同一主題
+	even, err := broker.SubscribeFiltered("events", func(msg Message) bool { return msg.Headers["parity"] == "even" })
+	if err != nil {
+		t.Fatalf("SubscribeFiltered failed: %v", err)
+	}
+	large, err := broker.SubscribeFiltered("events", func(msg Message) bool { return strings.HasPrefix(msg.ID, "big-") })
+	if err != nil {
+		t.Fatalf("SubscribeFiltered failed: %v", err)
+	}
+	all, _ := broker.Subscribe("events")
+
+	for i := 0; i < 4; i++ {
+		msg := NewMessage(fmt.Sprintf("small-%d", i), []byte("data"), "events")
+		if i >= 2 {
+			msg.ID = fmt.Sprintf("big-%d", i)
+		}
+		msg.Headers["parity"] = map[bool]string{true: "even", false: "odd"}[i%2 == 0]
+		broker.Publish("events", msg)
+	}
+
+	if got := drain(even); len(got) != 2 || got[0] != "small-0" || got[1] != "big-2" {
+		t.Errorf("Expected even subscriber to receive [small-0 big-2], got %v", got)
+	}
+	if got := drain(large); len(got) != 2 || got[0] != "big-2" || got[1] != "big-3" {
+		t.Errorf("Expected large subscriber to receive [big-2 big-3], got %v", got)
+	}
+	if got := drain(all); len(got) != 4 {
+		t.Errorf("Expected unfiltered subscriber to receive all 4 messages, got %v", got)
+	}
+}
+
+func TestSubscribeFilteredUnsubscribeAndClose(t *testing.T) {
+	broker := NewSimpleBroker()
+
+	panicking, _ := broker.SubscribeFiltered("events", func(Message) bool { panic("bad predicate") })
+	matchAll, _ := broker.SubscribeFiltered("events", func(Message) bool { return true })
+	if got := broker.GetMetrics().ActiveConsumers; got != 2 {
+		t.Errorf("Expected 2 active consumers, got %d", got)
+	}
+
+	// predicate panic 時視為不匹配，不影響其他訂閱者
+	if err := broker.Publish("events", NewMessage("m-1", nil, "events")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if got := drain(panicking); len(got) != 0 {
+		t.Errorf("Expected panicking predicate to match nothing, got %v", got)
+	}
+	if got := drain(matchAll); len(got) != 1 {
+		t.Errorf("Expected match-all subscriber to receive 1 message, got %v", got)
+	}
+
+	// 取消訂閱後通道關閉且不再收到消息
+	if err := broker.Unsubscribe("events", matchAll); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+	if _, ok := <-matchAll; ok {
+		t.Error("Expected unsubscribed channel to be closed")
+	}
+	broker.Publish("events", NewMessage("m-2", nil, "events"))
+	if got := broker.GetMetrics().ActiveConsumers; got != 1 {
+		t.Errorf("Expected 1 active consumer after unsubscribe, got %d", got)
+	}
+
+	// Close 關閉剩下的過濾訂閱者
+	broker.Close()
+	if _, ok := <-panicking; ok {
+		t.Error("Expected Close to close filtered subscribers")
+	}
+}
+
+func TestSubscribeFilteredConcurrentUnsubscribe(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	// Publish 評估 predicate 期間取消訂閱不會向已關閉的通道發送
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			broker.Publish("events", NewMessage(fmt.Sprintf("m-%d", i), nil, "events"))
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		ch, _ := broker.SubscribeFiltered("events", func(Message) bool { return true })
+		broker.Unsubscribe("events", ch)
+	}
+	<-done
+}
@@ -0,0 +1,112 @@
+package broker
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// subscriberBufferSize 是 Subscribe 與消費者組成員通道的預設緩衝大小
+const subscriberBufferSize = 100
+
+// consumerGroup 是主題上的一個具名消費者組，組內成員輪流接收消息 (competing consumers)
+type consumerGroup struct {
+	members []chan Message
+	next    uint64 // 輪詢計數，決定下一條消息交給哪個成員
+	offset  int64  // 已投遞給此組的消息數
+}
+
+// dispatch 將消息交給組內下一個成員，成員緩衝區已滿時改交給其後的成員
+// 所有成員都已滿 (或組內沒有成員) 時丟棄並返回 false，與 Publish 對一般訂閱者的處理一致
+func (g *consumerGroup) dispatch(msg Message) bool {
+	n := len(g.members)
+	if n == 0 {
+		return false
+	}
+
+	start := atomic.AddUint64(&g.next, 1) - 1
+	for i := 0; i < n; i++ {
+		select {
+		case g.members[(start+uint64(i))%uint64(n)] <- msg:
+			atomic.AddInt64(&g.offset, 1)
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+// remove 移除並關閉組內的成員通道，返回是否找到
+func (g *consumerGroup) remove(subscriber <-chan Message) bool {
+	for i, member := range g.members {
+		if member == subscriber {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			close(member)
+			return true
+		}
+	}
+	return false
+}
+
+// SubscribeGroup 以消費者組的身份訂閱主題
+//
+// 發布到主題的消息會廣播給每個消費者組 (以及以 Subscribe 訂閱的一般訂閱者)，
+// 但在同一個組內只交給其中一個成員，成員之間輪流接收。組在第一個成員加入時建立，
+// 以 Unsubscribe 取消成員訂閱。
+func (b *SimpleBroker) SubscribeGroup(topic, group string) (<-chan Message, error) {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return nil, ErrBrokerClosed
+	}
+	if group == "" {
+		return nil, fmt.Errorf("consumer group name is required")
+	}
+
+	member := make(chan Message, subscriberBufferSize)
+	subMgr := b.getOrCreateSubscriberManager(topic)
+
+	subMgr.mu.Lock()
+	if atomic.LoadInt32(&b.closed) == 1 {
+		subMgr.mu.Unlock()
+		return nil, ErrBrokerClosed
+	}
+	if subMgr.groups == nil {
+		subMgr.groups = make(map[string]*consumerGroup)
+	}
+	g, exists := subMgr.groups[group]
+	if !exists {
+		g = &consumerGroup{}
+		subMgr.groups[group] = g
+	}
+	g.members = append(g.members, member)
+	subMgr.mu.Unlock()
+
+	atomic.AddInt32(&b.metrics.ActiveConsumers, 1)
+	return member, nil
+}
+
+// GroupOffsets 返回主題上各消費者組已接收的消息數
+// 成員都已取消訂閱的組仍保留其 offset
+func (b *SimpleBroker) GroupOffsets(topic string) map[string]int64 {
+	result := make(map[string]int64)
+	subMgrInterface, exists := b.subscribers.Load(topic)
+	if !exists {
+		return result
+	}
+
+	subMgr := subMgrInterface.(*subscriberManager)
+	subMgr.mu.RLock()
+	defer subMgr.mu.RUnlock()
+	for name, g := range subMgr.groups {
+		result[name] = atomic.LoadInt64(&g.offset)
+	}
+	return result
+}
+
+// getOrCreateSubscriberManager 獲取主題的訂閱管理器，不存在時創建
+func (b *SimpleBroker) getOrCreateSubscriberManager(topic string) *subscriberManager {
+	subMgrInterface, _ := b.subscribers.LoadOrStore(topic, &subscriberManager{
+		topic:       topic,
+		subscribers: make([]chan Message, 0),
+		replay:      newReplayBuffer(b.config.TopicReplaySizes[topic]),
+	})
+	return subMgrInterface.(*subscriberManager)
+}
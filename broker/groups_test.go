@@ -0,0 +1,115 @@
+package broker
+
+import (
+	"fmt"
+	"testing"
+)
+
+// drain 非阻塞地取出通道中已有的消息 ID
+func drain(ch <-chan Message) []string {
+	var ids []string
+	for {
+		select {
+		case msg := <-ch:
+			ids = append(ids, msg.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestSubscribeGroupRoundRobin(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	members := make([]<-chan Message, 3)
+	for i := range members {
+		ch, err := broker.SubscribeGroup("events", "workers")
+		if err != nil {
+			t.Fatalf("SubscribeGroup failed: %v", err)
+		}
+		members[i] = ch
+	}
+
+	for i := 0; i < 6; i++ {
+		broker.Publish("events", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "events"))
+	}
+
+	// 組內成員輪流接收，每條消息只交給一個成員
+	for i, ch := range members {
+		got := drain(ch)
+		want := []string{fmt.Sprintf("msg-%d", i), fmt.Sprintf("msg-%d", i+3)}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Expected member %d to receive %v, got %v", i, want, got)
+		}
+	}
+
+	if offsets := broker.GroupOffsets("events"); offsets["workers"] != 6 {
+		t.Errorf("Expected group offset 6, got %v", offsets)
+	}
+}
+
+func TestSubscribeGroupFansOutAcrossGroups(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	a1, _ := broker.SubscribeGroup("events", "a")
+	a2, _ := broker.SubscribeGroup("events", "a")
+	b1, _ := broker.SubscribeGroup("events", "b")
+	plain, _ := broker.Subscribe("events")
+
+	for i := 0; i < 4; i++ {
+		broker.Publish("events", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "events"))
+	}
+
+	// 每個組都收到全部消息，組 a 由兩個成員分擔
+	if got := len(drain(a1)) + len(drain(a2)); got != 4 {
+		t.Errorf("Expected group a to receive 4 messages, got %d", got)
+	}
+	if got := len(drain(b1)); got != 4 {
+		t.Errorf("Expected group b to receive 4 messages, got %d", got)
+	}
+	if got := len(drain(plain)); got != 4 {
+		t.Errorf("Expected plain subscriber to receive 4 messages, got %d", got)
+	}
+
+	offsets := broker.GroupOffsets("events")
+	if offsets["a"] != 4 || offsets["b"] != 4 {
+		t.Errorf("Expected offsets a=4 b=4, got %v", offsets)
+	}
+}
+
+func TestSubscribeGroupUnsubscribe(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	first, _ := broker.SubscribeGroup("events", "workers")
+	second, _ := broker.SubscribeGroup("events", "workers")
+
+	if err := broker.Unsubscribe("events", first); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+	if _, ok := <-first; ok {
+		t.Error("Expected unsubscribed member channel to be closed")
+	}
+
+	// 剩下的成員接收所有消息
+	broker.Publish("events", NewMessage("msg-1", []byte("data"), "events"))
+	broker.Publish("events", NewMessage("msg-2", []byte("data"), "events"))
+	if got := drain(second); len(got) != 2 {
+		t.Errorf("Expected remaining member to receive 2 messages, got %v", got)
+	}
+
+	if broker.GetMetrics().ActiveConsumers != 1 {
+		t.Errorf("Expected 1 active consumer, got %d", broker.GetMetrics().ActiveConsumers)
+	}
+}
+
+func TestSubscribeGroupRequiresName(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	if _, err := broker.SubscribeGroup("events", ""); err == nil {
+		t.Error("Expected error for empty group name")
+	}
+}
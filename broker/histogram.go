@@ -0,0 +1,127 @@
+package broker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDepthBuckets 是隊列深度直方圖的預設分桶上界
+var DefaultDepthBuckets = []float64{0, 1, 5, 10, 50, 100, 500, 1000}
+
+// DepthHistogram 是單一隊列深度取樣的直方圖快照
+type DepthHistogram struct {
+	Buckets []float64 // 各分桶上界 (遞增)
+	Counts  []uint64  // 各分桶的累計取樣數 (深度 <= 上界)，與 Buckets 對應
+	Sum     float64   // 所有取樣深度的總和
+	Count   uint64    // 取樣總數
+}
+
+// depthHistogram 是單一隊列的直方圖，Counts 以非累計方式保存
+type depthHistogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// depthSampler 定期取樣各隊列的 MessageCount
+type depthSampler struct {
+	interval time.Duration
+	buckets  []float64
+
+	mu         sync.Mutex
+	histograms map[string]*depthHistogram
+}
+
+// EnableDepthHistogram 開啟隊列深度直方圖，每隔 interval 取樣一次所有隊列的深度
+// buckets 為空時使用 DefaultDepthBuckets；取樣 goroutine 會在 Close 時停止
+func (b *SimpleBroker) EnableDepthHistogram(interval time.Duration, buckets []float64) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid depth histogram interval %v", interval)
+	}
+	if len(buckets) == 0 {
+		buckets = DefaultDepthBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	s := &depthSampler{
+		interval:   interval,
+		buckets:    sorted,
+		histograms: make(map[string]*depthHistogram),
+	}
+	if !b.depthSampler.CompareAndSwap(nil, s) {
+		return fmt.Errorf("depth histogram is already enabled")
+	}
+
+	go b.runDepthSampler(s)
+	return nil
+}
+
+// runDepthSampler 定期取樣直到 Broker 關閉
+func (b *SimpleBroker) runDepthSampler(s *depthSampler) {
+	timer := b.clock.NewTimer(s.interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-timer.C():
+			if b.ctx.Err() != nil {
+				return // 關閉後不再取樣
+			}
+			s.sample(b.GetAllQueueStats())
+			timer.Reset(s.interval)
+		}
+	}
+}
+
+// sample 將每個隊列目前的深度記錄到直方圖
+func (s *depthSampler) sample(stats map[string]*QueueStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, qs := range stats {
+		h, exists := s.histograms[name]
+		if !exists {
+			h = &depthHistogram{counts: make([]uint64, len(s.buckets)+1)} // 最後一格為 +Inf
+			s.histograms[name] = h
+		}
+
+		depth := float64(qs.MessageCount)
+		i := sort.SearchFloat64s(s.buckets, depth) // 第一個 >= depth 的上界
+		h.counts[i]++
+		h.sum += depth
+		h.count++
+	}
+}
+
+// GetDepthHistograms 返回各隊列深度直方圖的快照，未開啟時返回 nil
+func (b *SimpleBroker) GetDepthHistograms() map[string]DepthHistogram {
+	s := b.depthSampler.Load()
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]DepthHistogram, len(s.histograms))
+	for name, h := range s.histograms {
+		cumulative := make([]uint64, len(s.buckets))
+		var running uint64
+		for i := range s.buckets {
+			running += h.counts[i]
+			cumulative[i] = running
+		}
+		result[name] = DepthHistogram{
+			Buckets: append([]float64(nil), s.buckets...),
+			Counts:  cumulative,
+			Sum:     h.sum,
+			Count:   h.count,
+		}
+	}
+	return result
+}
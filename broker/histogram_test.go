@@ -0,0 +1,69 @@
+package broker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fillQueue 將隊列調整為指定深度
+func fillQueue(t *testing.T, broker *SimpleBroker, queue string, depth int) {
+	t.Helper()
+	broker.PurgeQueue(queue)
+	for i := 0; i < depth; i++ {
+		broker.Push(queue, NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), queue))
+	}
+}
+
+func TestDepthHistogramSampling(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	if broker.GetDepthHistograms() != nil {
+		t.Error("Expected nil histograms when disabled")
+	}
+
+	if err := broker.EnableDepthHistogram(time.Second, []float64{0, 5, 10}); err != nil {
+		t.Fatalf("EnableDepthHistogram failed: %v", err)
+	}
+	if err := broker.EnableDepthHistogram(time.Second, nil); err == nil {
+		t.Error("Expected error enabling histogram twice")
+	}
+
+	// 依序在深度 0、3、3、8、20 時取樣
+	broker.Push("blocks", NewMessage("seed", []byte("data"), "blocks"))
+	for _, depth := range []int{0, 3, 3, 8, 20} {
+		clock.BlockUntil(1) // 上一次取樣已完成，取樣器正在等待下一次取樣
+		fillQueue(t, broker, "blocks", depth)
+		clock.Advance(time.Second)
+	}
+	clock.BlockUntil(1) // 等待最後一次取樣完成
+
+	h, ok := broker.GetDepthHistograms()["blocks"]
+	if !ok {
+		t.Fatal("Expected histogram for blocks queue")
+	}
+	// 累計分桶：<=0 一次、<=5 三次、<=10 四次，總共五次
+	if fmt.Sprint(h.Counts) != "[1 3 4]" || h.Count != 5 || h.Sum != 34 {
+		t.Errorf("Unexpected histogram: %+v", h)
+	}
+}
+
+func TestDepthHistogramStopsOnClose(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	broker := NewSimpleBrokerWithClock(clock)
+
+	broker.EnableDepthHistogram(time.Second, nil)
+	broker.Push("blocks", NewMessage("msg", []byte("data"), "blocks"))
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	clock.BlockUntil(1)
+
+	broker.Close()
+	clock.Advance(time.Minute)
+
+	if h := broker.GetDepthHistograms()["blocks"]; h.Count != 1 {
+		t.Errorf("Expected no samples after Close, got %d", h.Count)
+	}
+}
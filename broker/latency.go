@@ -0,0 +1,103 @@
+package broker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets 是消息等待時間 (入隊到被拉取) 直方圖的分桶上界
+var LatencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	5 * time.Second,
+	30 * time.Second,
+	60 * time.Second,
+}
+
+// LatencyStats 是隊列中消息等待時間的統計快照
+type LatencyStats struct {
+	Count   int64           `json:"count"`      // 記錄的消息數
+	Sum     time.Duration   `json:"sum_ns"`     // 等待時間總和
+	Max     time.Duration   `json:"max_ns"`     // 最長的等待時間
+	Buckets []time.Duration `json:"buckets_ns"` // 分桶上界，與 LatencyBuckets 相同
+	Counts  []int64         `json:"counts"`     // 各分桶的累計消息數 (等待時間 <= 上界)，與 Buckets 對應
+}
+
+// Mean 返回平均等待時間，沒有記錄時為 0
+func (s LatencyStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(s.Count)
+}
+
+// Quantile 以分桶估計第 q 分位 (0 < q <= 1) 的等待時間，返回累計數達到 q 的最小分桶上界
+// 超出最大分桶時返回 Max；沒有記錄時為 0
+func (s LatencyStats) Quantile(q float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	target := int64(q * float64(s.Count))
+	for i, count := range s.Counts {
+		if count >= target {
+			return min(s.Buckets[i], s.Max)
+		}
+	}
+	return s.Max
+}
+
+// latencyRecorder 以 atomic 累計單一隊列的等待時間，counts 以非累計方式保存
+type latencyRecorder struct {
+	count  int64
+	sum    int64 // 納秒
+	max    int64 // 納秒
+	counts []int64
+}
+
+// newLatencyRecorder 創建使用 LatencyBuckets 分桶的記錄器
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{counts: make([]int64, len(LatencyBuckets))}
+}
+
+// record 記錄一條消息的等待時間
+func (r *latencyRecorder) record(wait time.Duration) {
+	if wait < 0 {
+		wait = 0 // 時鐘調整造成的負值
+	}
+	atomic.AddInt64(&r.count, 1)
+	atomic.AddInt64(&r.sum, int64(wait))
+	for {
+		current := atomic.LoadInt64(&r.max)
+		if int64(wait) <= current || atomic.CompareAndSwapInt64(&r.max, current, int64(wait)) {
+			break
+		}
+	}
+	for i, bound := range LatencyBuckets {
+		if wait <= bound {
+			atomic.AddInt64(&r.counts[i], 1)
+			break
+		}
+	}
+}
+
+// snapshot 返回目前統計的副本，分桶數轉為累計值；r 為 nil (未經 Broker 創建的 QueueStats) 時返回空統計
+func (r *latencyRecorder) snapshot() LatencyStats {
+	if r == nil {
+		return LatencyStats{}
+	}
+	stats := LatencyStats{
+		Count:   atomic.LoadInt64(&r.count),
+		Sum:     time.Duration(atomic.LoadInt64(&r.sum)),
+		Max:     time.Duration(atomic.LoadInt64(&r.max)),
+		Buckets: append([]time.Duration(nil), LatencyBuckets...),
+		Counts:  make([]int64, len(LatencyBuckets)),
+	}
+	cumulative := int64(0)
+	for i := range r.counts {
+		cumulative += atomic.LoadInt64(&r.counts[i])
+		stats.Counts[i] = cumulative
+	}
+	return stats
+}
@@ -0,0 +1,75 @@
+package broker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueueLatencyRecordedOnPull(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", nil, "test"))
+	broker.Push("test", NewMessage("msg-2", nil, "test"))
+
+	clock.Advance(50 * time.Millisecond)
+	broker.Pull("test")
+	clock.Advance(2 * time.Second)
+	broker.Pull("test")
+
+	stats, _ := broker.GetQueueStats("test")
+	latency := stats.Latency
+	if latency.Count != 2 {
+		t.Fatalf("Expected 2 recorded waits, got %d", latency.Count)
+	}
+	if latency.Max != 2050*time.Millisecond {
+		t.Errorf("Expected max wait 2.05s, got %v", latency.Max)
+	}
+	if latency.Sum != 2100*time.Millisecond || latency.Mean() != 1050*time.Millisecond {
+		t.Errorf("Expected sum 2.1s and mean 1.05s, got %v and %v", latency.Sum, latency.Mean())
+	}
+
+	// 50ms 落在 100ms 分桶，2.05s 落在 5s 分桶
+	expected := []int64{0, 1, 1, 1, 2, 2, 2}
+	for i, want := range expected {
+		if latency.Counts[i] != want {
+			t.Errorf("Bucket <= %v: expected %d, got %d", latency.Buckets[i], want, latency.Counts[i])
+		}
+	}
+	if q := latency.Quantile(0.5); q != 100*time.Millisecond {
+		t.Errorf("Expected p50 estimate 100ms, got %v", q)
+	}
+	if q := latency.Quantile(1); q != 2050*time.Millisecond {
+		t.Errorf("Expected p100 estimate capped at max 2.05s, got %v", q)
+	}
+
+	// GetStats 中的隊列指標包含同樣的統計
+	queueMetrics := broker.GetMetrics().GetStats()["queue_metrics"].(map[string]*QueueStats)
+	if got := queueMetrics["test"].Latency; got.Count != 2 || got.Max != latency.Max {
+		t.Errorf("Expected metrics latency to match queue stats, got %+v", got)
+	}
+}
+
+func TestQueueLatencyRealClock(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", nil, "test"))
+	time.Sleep(20 * time.Millisecond)
+	if msg, _ := broker.Pull("test"); msg == nil {
+		t.Fatal("Expected a message")
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if wait := stats.Latency.Max; wait < 20*time.Millisecond || wait > 5*time.Second {
+		t.Errorf("Expected recorded wait of at least 20ms, got %v", wait)
+	}
+}
+
+func TestQueueLatencyEmpty(t *testing.T) {
+	var stats LatencyStats
+	if stats.Mean() != 0 || stats.Quantile(0.99) != 0 {
+		t.Error("Expected zero latency without records")
+	}
+}
@@ -0,0 +1,21 @@
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewMessageID 生成唯一的消息 ID (128 位元隨機數的十六進位表示，32 個字元)
+// Push 收到沒有 ID 的消息時以此補上；生產者也可以直接使用，讓 ID 格式一致
+func NewMessageID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// assignID 在消息沒有 ID 時生成一個，避免空 ID 讓去重與死信查詢失效
+func assignID(msg *Message) {
+	if msg.ID == "" {
+		msg.ID = NewMessageID()
+	}
+}
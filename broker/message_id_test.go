@@ -0,0 +1,75 @@
+package broker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewMessageID(t *testing.T) {
+	id1, id2 := NewMessageID(), NewMessageID()
+	if id1 == id2 {
+		t.Error("Expected unique message IDs")
+	}
+	if len(id1) != 32 {
+		t.Errorf("Expected 32 character ID, got %d", len(id1))
+	}
+}
+
+func TestPushAssignsEmptyID(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{DedupeWindow: time.Minute})
+	defer broker.Close()
+
+	// 開啟去重時，兩條沒有 ID 的消息也不會被當成重複而丟棄
+	broker.Push("test", NewMessage("", []byte("first"), "test"))
+	broker.Push("test", NewMessage("", []byte("second"), "test"))
+
+	first, err := broker.Pull("test")
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	second, err := broker.Pull("test")
+	if err != nil {
+		t.Fatalf("Expected second message not to be deduped: %v", err)
+	}
+	if len(first.ID) != 32 || len(second.ID) != 32 || first.ID == second.ID {
+		t.Errorf("Expected distinct generated IDs, got %q and %q", first.ID, second.ID)
+	}
+}
+
+func TestPushPreservesID(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", nil, "test"))
+	if msg, _ := broker.Pull("test"); msg.ID != "msg-1" {
+		t.Errorf("Expected ID msg-1 to be preserved, got %q", msg.ID)
+	}
+}
+
+func TestPushBatchAssignsEmptyIDs(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.PushBatch("test", []Message{NewMessage("", nil, "test"), NewMessage("kept", nil, "test")})
+
+	first, _ := broker.Pull("test")
+	second, _ := broker.Pull("test")
+	if first == nil || len(first.ID) != 32 {
+		t.Errorf("Expected generated ID for first message, got %+v", first)
+	}
+	if second == nil || second.ID != "kept" {
+		t.Errorf("Expected ID kept to be preserved, got %+v", second)
+	}
+}
+
+func TestPushDelayedAssignsEmptyID(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	broker.PushDelayed("test", NewMessage("", nil, "test"), time.Minute)
+	scheduled := broker.GetScheduled("test")
+	if len(scheduled) != 1 || len(scheduled[0].Message.ID) != 32 {
+		t.Fatalf("Expected scheduled message with generated ID, got %+v", scheduled)
+	}
+}
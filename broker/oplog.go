@@ -0,0 +1,106 @@
+package broker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 操作日誌中的結果值
+const (
+	OpResultOK           = "ok"
+	OpResultEmpty        = "empty"
+	OpResultDeadLettered = "dead_lettered"
+)
+
+// OpLogEntry 記錄一次 Broker 操作，用於事後排查問題
+type OpLogEntry struct {
+	Seq       uint64    `json:"seq"`
+	Op        string    `json:"op"`
+	Target    string    `json:"target"` // 隊列或主題名稱
+	MessageID string    `json:"message_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Result    string    `json:"result"`
+}
+
+// opLog 是固定大小的環形緩衝區，保存最近 N 次操作
+// 寫入只需要一次 atomic 遞增與一次 atomic 指標存放，不需要鎖
+type opLog struct {
+	slots []atomic.Pointer[OpLogEntry]
+	next  atomic.Uint64
+}
+
+// newOpLog 創建一個保存最近 size 次操作的環形緩衝區
+func newOpLog(size int) *opLog {
+	return &opLog{slots: make([]atomic.Pointer[OpLogEntry], size)}
+}
+
+// record 寫入一筆操作記錄，覆蓋最舊的記錄
+func (l *opLog) record(op, target, msgID, result string, at time.Time) {
+	seq := l.next.Add(1)
+	l.slots[(seq-1)%uint64(len(l.slots))].Store(&OpLogEntry{
+		Seq:       seq,
+		Op:        op,
+		Target:    target,
+		MessageID: msgID,
+		Timestamp: at,
+		Result:    result,
+	})
+}
+
+// entries 依時間順序返回最近 limit 筆記錄
+func (l *opLog) entries(limit int) []OpLogEntry {
+	last := l.next.Load()
+	size := uint64(len(l.slots))
+	count := last
+	if count > size {
+		count = size
+	}
+	if limit > 0 && uint64(limit) < count {
+		count = uint64(limit)
+	}
+
+	result := make([]OpLogEntry, 0, count)
+	for seq := last - count + 1; seq <= last && count > 0; seq++ {
+		entry := l.slots[(seq-1)%size].Load()
+		// 讀取期間可能被新的寫入覆蓋，或尚未寫入完成，略過序號不符的記錄
+		if entry == nil || entry.Seq != seq {
+			continue
+		}
+		result = append(result, *entry)
+	}
+	return result
+}
+
+// EnableOpLog 開啟操作日誌，保存最近 size 次操作 (size <= 0 時關閉)
+func (b *SimpleBroker) EnableOpLog(size int) {
+	if size <= 0 {
+		b.oplog.Store(nil)
+		return
+	}
+	b.oplog.Store(newOpLog(size))
+}
+
+// GetOpLog 依時間順序返回最近 limit 筆操作記錄 (limit <= 0 表示全部)
+// 未開啟操作日誌時返回 nil
+func (b *SimpleBroker) GetOpLog(limit int) []OpLogEntry {
+	log := b.oplog.Load()
+	if log == nil {
+		return nil
+	}
+	return log.entries(limit)
+}
+
+// logOp 在開啟操作日誌時記錄一次操作
+func (b *SimpleBroker) logOp(op, target, msgID, result string) {
+	if log := b.oplog.Load(); log != nil {
+		log.record(op, target, msgID, result, b.clock.Now())
+	}
+}
+
+// opResult 將操作的錯誤轉換為操作日誌中的結果字串
+func opResult(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return OpResultOK
+}
@@ -0,0 +1,83 @@
+package broker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestOpLogDisabledByDefault(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("1", []byte("data"), "test"))
+	if entries := broker.GetOpLog(0); entries != nil {
+		t.Errorf("Expected nil op log when disabled, got %v", entries)
+	}
+}
+
+func TestOpLogRecordsOperations(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	broker.EnableOpLog(10)
+
+	broker.Push("test", NewMessage("msg-1", []byte("data"), "test"))
+	broker.Pull("test")
+	broker.Pull("test") // 空隊列
+	broker.PullWithTimeout("missing", 0)
+	broker.MoveToDLQ("test", NewMessage("msg-2", []byte("data"), "test"))
+	broker.ReprocessDLQ("test", "msg-2")
+	broker.PushDelayed("test", NewMessage("msg-3", []byte("data"), "test"), time.Hour)
+	broker.CancelScheduled("test", "msg-3")
+
+	entries := broker.GetOpLog(0)
+	expected := []struct{ op, msgID, result string }{
+		{"push", "msg-1", OpResultOK},
+		{"pull", "msg-1", OpResultOK},
+		{"pull", "", OpResultEmpty},
+		{"pull", "", "queue not found: missing"},
+		{"move_to_dlq", "msg-2", OpResultOK},
+		{"reprocess_dlq", "msg-2", OpResultOK},
+		{"push", "msg-2", OpResultOK},
+		{"push_delayed", "msg-3", OpResultOK},
+		{"cancel_scheduled", "msg-3", OpResultOK},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, want := range expected {
+		got := entries[i]
+		if got.Op != want.op || got.MessageID != want.msgID || got.Result != want.result {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want, got)
+		}
+		if got.Seq != uint64(i+1) || got.Timestamp.IsZero() {
+			t.Errorf("Entry %d: unexpected seq/timestamp %+v", i, got)
+		}
+	}
+
+	// limit 只返回最近的記錄
+	if recent := broker.GetOpLog(2); len(recent) != 2 || recent[1].Op != "cancel_scheduled" {
+		t.Errorf("Expected 2 most recent entries, got %+v", recent)
+	}
+}
+
+func TestOpLogWrapsAround(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	broker.EnableOpLog(5)
+
+	for i := 0; i < 12; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "test"))
+	}
+
+	entries := broker.GetOpLog(0)
+	if len(entries) != 5 {
+		t.Fatalf("Expected ring to hold 5 entries, got %d", len(entries))
+	}
+	// 只保留最近 5 筆，且依時間順序排列
+	for i, entry := range entries {
+		if want := fmt.Sprintf("msg-%d", 7+i); entry.MessageID != want {
+			t.Errorf("Entry %d: expected %s, got %s", i, want, entry.MessageID)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrQueueFull 表示有序隊列在 OrderedPushTimeout 內都沒有騰出空間，消息未入隊
+var ErrQueueFull = errors.New("queue is full")
+
+// 有序隊列 (BrokerConfig.OrderedQueues) 的取捨：
+//
+// 一般隊列已滿時 Push 立即把消息移入死信隊列，生產者不會被阻塞，但之後從死信隊列重新處理的消息
+// 會排在較新的消息之後，順序因此被打亂。有序隊列改為阻塞等待消費者騰出空間 (最多 OrderedPushTimeout)，
+// 單一生產者依序推送的消息會依同樣的順序被拉取；代價是消費者跟不上時生產者 (包括 Nack 重新入隊與
+// 重試) 會被拖慢，逾時後返回 ErrQueueFull，由生產者決定重送或放棄。
+// 多個生產者並行推送時，不同生產者之間的消息仍會交錯，只保證各自的順序。
+
+// isOrdered 返回隊列是否為有序隊列 (優先級隊列本身依優先級排序，不適用)
+func (mq *messageQueue) isOrdered() bool {
+	return mq.ordered && mq.priority == nil
+}
+
+// offerOrdered 等待有序隊列騰出空間後放入消息，逾時返回 ErrQueueFull，Broker 關閉時返回 ErrBrokerClosed
+func (b *SimpleBroker) offerOrdered(mq *messageQueue, msg Message) error {
+	timer := b.clock.NewTimer(b.config.OrderedPushTimeout)
+	defer timer.Stop()
+
+	select {
+	case mq.messages <- msg:
+		mq.notify()
+		return nil
+	case <-timer.C():
+		return fmt.Errorf("%w: queue %s did not drain within %s", ErrQueueFull, mq.name, b.config.OrderedPushTimeout)
+	case <-b.ctx.Done():
+		return ErrBrokerClosed
+	}
+}
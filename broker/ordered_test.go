@@ -0,0 +1,116 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newOrderedBroker 創建 test 隊列為緩衝 2 的有序隊列的 Broker
+func newOrderedBroker(timeout time.Duration) *SimpleBroker {
+	return NewSimpleBrokerWithConfig(BrokerConfig{
+		QueueBufferSizes:   map[string]int{"test": 2},
+		OrderedQueues:      map[string]bool{"test": true},
+		OrderedPushTimeout: timeout,
+	})
+}
+
+func TestOrderedQueueFIFOUnderOverflow(t *testing.T) {
+	broker := newOrderedBroker(5 * time.Second)
+	defer broker.Close()
+
+	broker.DeclareQueue("test", 2)
+
+	// 單一生產者推送遠超過緩衝大小的消息，消費者較慢
+	const total = 50
+	errs := make(chan error, 1)
+	go func() {
+		for i := 0; i < total; i++ {
+			if err := broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test")); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+
+	for i := 0; i < total; i++ {
+		msg, err := broker.PullWithTimeout("test", 5*time.Second)
+		if err != nil {
+			t.Fatalf("Pull %d failed: %v", i, err)
+		}
+		if want := fmt.Sprintf("msg-%d", i); msg.ID != want {
+			t.Fatalf("Expected %s, got %s", want, msg.ID)
+		}
+		if i%10 == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if err := <-errs; err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	// 有序隊列已滿時等待而不是移入死信隊列
+	if dlq := broker.GetDLQ("test"); len(dlq) != 0 {
+		t.Errorf("Expected no dead letters for ordered queue, got %d", len(dlq))
+	}
+}
+
+func TestOrderedQueuePushTimesOut(t *testing.T) {
+	broker := newOrderedBroker(50 * time.Millisecond)
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-0", nil, "test"))
+	broker.Push("test", NewMessage("msg-1", nil, "test"))
+
+	// 沒有消費者騰出空間時，逾時返回 ErrQueueFull，消息不進入死信隊列
+	start := time.Now()
+	err := broker.Push("test", NewMessage("msg-2", nil, "test"))
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Expected ErrQueueFull, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected push to wait for the timeout, returned after %v", elapsed)
+	}
+	if len(broker.GetDLQ("test")) != 0 {
+		t.Error("Expected timed-out message not to be dead-lettered")
+	}
+	if stats, _ := broker.GetQueueStats("test"); stats.MessageCount != 2 {
+		t.Errorf("Expected 2 queued messages, got %d", stats.MessageCount)
+	}
+}
+
+func TestOrderedQueuePushUnblocksOnClose(t *testing.T) {
+	broker := newOrderedBroker(time.Minute)
+
+	broker.Push("test", NewMessage("msg-0", nil, "test"))
+	broker.Push("test", NewMessage("msg-1", nil, "test"))
+
+	errs := make(chan error, 1)
+	go func() { errs <- broker.Push("test", NewMessage("msg-2", nil, "test")) }()
+
+	time.Sleep(20 * time.Millisecond)
+	broker.Close()
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrBrokerClosed) {
+			t.Errorf("Expected ErrBrokerClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected blocked push to return after Close")
+	}
+}
+
+func TestUnorderedQueueStillDeadLetters(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{QueueBufferSizes: map[string]int{"test": 2}})
+	defer broker.Close()
+
+	for i := 0; i < 3; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+	}
+	if dlq := broker.GetDLQ("test"); len(dlq) != 1 || dlq[0].ID != "msg-2" {
+		t.Errorf("Expected msg-2 to be dead-lettered, got %+v", dlq)
+	}
+}
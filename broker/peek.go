@@ -0,0 +1,107 @@
+package broker
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Peek 返回隊列中的下一條消息但不取出，也不改變任何統計
+// FIFO 隊列的消息存放在通道中，Peek 會把隊首消息移到隊列的 head 緩衝，之後的 Pull 優先取出它；
+// 優先級隊列返回目前有效優先級最高的消息。隊列為空時返回 ErrQueueEmpty
+func (b *SimpleBroker) Peek(queue string) (*Message, error) {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return nil, ErrBrokerClosed
+	}
+
+	queueInterface, exists := b.queues.Load(queue)
+	if !exists {
+		err := queueNotFound(queue)
+		b.logOp("peek", queue, "", opResult(err))
+		return nil, err
+	}
+
+	mq := queueInterface.(*messageQueue)
+	msg, ok := mq.peek()
+	if !ok {
+		b.logOp("peek", queue, "", OpResultEmpty)
+		return nil, fmt.Errorf("%w: %s", ErrQueueEmpty, queue)
+	}
+	b.logOp("peek", queue, msg.ID, OpResultOK)
+	return &msg, nil
+}
+
+// peek 返回隊首消息的副本
+func (mq *messageQueue) peek() (Message, bool) {
+	if mq.priority != nil {
+		return mq.priority.peek()
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	if head := mq.head.Load(); head != nil {
+		return *head, true
+	}
+	select {
+	case msg := <-mq.messages:
+		mq.head.Store(&msg)
+		return msg, true
+	default:
+		return Message{}, false
+	}
+}
+
+// receive 依序取出 head 或通道中的隊首消息，呼叫者須持有 mu
+func (mq *messageQueue) receive() (Message, bool) {
+	if head := mq.head.Swap(nil); head != nil {
+		return *head, true
+	}
+	select {
+	case msg := <-mq.messages:
+		return msg, true
+	default:
+		return Message{}, false
+	}
+}
+
+// pollOrWait 取出隊首消息，隊列為空時返回在下一條消息入隊時關閉的通道
+func (mq *messageQueue) pollOrWait() (Message, bool, <-chan struct{}) {
+	if mq.priority != nil {
+		return mq.priority.pollOrWait()
+	}
+
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+
+	// 先登記為等待者再檢查通道；推送則是先放入通道再檢查等待者，兩者至少有一方會看到對方，不會錯過通知
+	atomic.AddInt32(&mq.waiters, 1)
+	if msg, ok := mq.receive(); ok {
+		atomic.AddInt32(&mq.waiters, -1)
+		return msg, true, nil
+	}
+	return Message{}, false, mq.ready
+}
+
+// notify 在消息放入通道後喚醒等待中的消費者，沒有等待者時不取鎖
+// 喚醒後等待者計數歸零，逾時離開的等待者最多只造成一次多餘的通知
+func (mq *messageQueue) notify() {
+	if atomic.LoadInt32(&mq.waiters) == 0 {
+		return
+	}
+	mq.mu.Lock()
+	defer mq.mu.Unlock()
+	close(mq.ready)
+	mq.ready = make(chan struct{})
+	atomic.StoreInt32(&mq.waiters, 0)
+}
+
+// peek 返回有效優先級最高的消息但不取出
+func (q *priorityQueue) peek() (Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return Message{}, false
+	}
+	return q.items[0].msg, true
+}
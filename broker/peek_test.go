@@ -0,0 +1,151 @@
+package broker
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPeekDoesNotConsume(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", []byte("first"), "test"))
+	broker.Push("test", NewMessage("msg-2", []byte("second"), "test"))
+
+	first, err := broker.Peek("test")
+	if err != nil || first == nil || first.ID != "msg-1" {
+		t.Fatalf("Expected msg-1 from Peek, got %v (err=%v)", first, err)
+	}
+	second, _ := broker.Peek("test")
+	if second == nil || second.ID != first.ID {
+		t.Errorf("Expected consecutive Peeks to return the same message, got %v", second)
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.MessageCount != 2 || stats.DequeuedTotal != 0 {
+		t.Errorf("Expected Peek not to change stats, got count %d dequeued %d", stats.MessageCount, stats.DequeuedTotal)
+	}
+
+	for _, want := range []string{"msg-1", "msg-2"} {
+		msg, _ := broker.Pull("test")
+		if msg == nil || msg.ID != want {
+			t.Errorf("Expected %s from Pull, got %v", want, msg)
+		}
+	}
+	if msg, _ := broker.Peek("test"); msg != nil {
+		t.Errorf("Expected nil from Peek on empty queue, got %s", msg.ID)
+	}
+	if _, err := broker.Peek("missing"); err == nil {
+		t.Error("Expected error peeking a missing queue")
+	}
+}
+
+func TestPeekWakesBlockedPull(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.DeclareQueue("test", DefaultQueueBufferSize)
+	result := make(chan *Message, 1)
+	go func() {
+		msg, _ := broker.PullWithTimeout("test", 2*time.Second)
+		result <- msg
+	}()
+
+	// 消息被 Peek 移到 head 後，正在等待的 Pull 仍應取得它
+	time.Sleep(20 * time.Millisecond)
+	broker.Push("test", NewMessage("msg-1", []byte("data"), "test"))
+	broker.Peek("test")
+
+	select {
+	case msg := <-result:
+		if msg == nil || msg.ID != "msg-1" {
+			t.Errorf("Expected blocked Pull to receive msg-1, got %v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Blocked Pull was not woken up")
+	}
+}
+
+func TestPeekConcurrentWithPullKeepsOrder(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	broker.DeclareQueue("test", DefaultQueueBufferSize)
+
+	// 單核環境也要讓 Peek 與 Pull 真正並行，才能觸發交錯
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	const n = 1000
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	// 持續 Peek，把隊首消息移到 head，與阻塞中的 Pull 競爭同一個通道
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					broker.Peek("test")
+				}
+			}
+		}()
+	}
+
+	// 單一消費者拉取的順序必須與推送順序相同
+	received := make(chan []string, 1)
+	go func() {
+		var ids []string
+		for len(ids) < n {
+			msg, err := broker.PullWithTimeout("test", 2*time.Second)
+			if err != nil {
+				break
+			}
+			ids = append(ids, msg.ID)
+		}
+		received <- ids
+	}()
+
+	for i := 0; i < n; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+		if i%50 == 0 {
+			time.Sleep(time.Millisecond) // 讓消費者把隊列取空後進入阻塞等待
+		}
+	}
+
+	ids := <-received
+	close(done)
+	wg.Wait()
+
+	if len(ids) != n {
+		t.Fatalf("Expected %d messages, got %d", n, len(ids))
+	}
+	for i, id := range ids {
+		if want := fmt.Sprintf("msg-%d", i); id != want {
+			t.Fatalf("Expected %s at position %d, got %s", want, i, id)
+		}
+	}
+}
+
+func TestPeekPriorityQueue(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	low := NewMessage("low", []byte("low"), "jobs")
+	high := NewMessage("high", []byte("high"), "jobs")
+	high.Priority = 10
+	broker.PushWithPriority("jobs", low)
+	broker.PushWithPriority("jobs", high)
+
+	if msg, _ := broker.Peek("jobs"); msg == nil || msg.ID != "high" {
+		t.Errorf("Expected highest priority message from Peek, got %v", msg)
+	}
+	if msg, _ := broker.Pull("jobs"); msg == nil || msg.ID != "high" {
+		t.Errorf("Expected Pull to return the peeked message, got %v", msg)
+	}
+}
@@ -0,0 +1,96 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PersistentBroker 是以預寫日誌 (WAL) 持久化隊列內容的 SimpleBroker
+//
+// 每次 Push 與移入死信隊列都會先附加到 BrokerConfig.WALPath，消息被消費後再附加一筆消費記錄；
+// 重啟時重放 WAL 重建尚未消費的隊列與死信隊列內容。開啟確認模式 (EnableAcks) 時消息在 Ack 後
+// 才算消費，崩潰時仍在處理中的消息會在重啟後重新投遞；未開啟時 Pull 取出即算消費。
+// 延遲投遞中的消息會以原本的投遞時間重新排程，重啟期間已到期的直接放回隊列。Pub/Sub 消息不會持久化。
+type PersistentBroker struct {
+	*SimpleBroker
+}
+
+// NewPersistentBroker 開啟 (或創建) cfg.WALPath 的 WAL 並恢復上次未消費的消息
+func NewPersistentBroker(cfg BrokerConfig) (*PersistentBroker, error) {
+	if cfg.WALPath == "" {
+		return nil, fmt.Errorf("wal path is required")
+	}
+
+	w, records, err := openWAL(cfg.WALPath, cfg.WALSync)
+	if err != nil {
+		return nil, err
+	}
+
+	b := NewSimpleBrokerWithConfig(cfg)
+	b.wal = w
+	restored, deadLettered, scheduled := 0, 0, 0
+	for _, rec := range records {
+		msg := *rec.Msg
+		msg.walSeq = rec.Seq
+		if rec.Op == walOpDLQ {
+			b.restoreDeadLetter(rec.Queue, msg)
+			deadLettered++
+			continue
+		}
+		if rec.Op == walOpDelay && rec.FireAt.After(b.clock.Now()) {
+			b.schedule(rec.Queue, msg, rec.FireAt, false, false)
+			scheduled++
+			continue
+		}
+		b.restore(rec.Queue, msg, rec.Priority)
+		restored++
+	}
+
+	if restored > 0 || deadLettered > 0 || scheduled > 0 {
+		logrus.WithFields(logrus.Fields{
+			"path":         cfg.WALPath,
+			"restored":     restored,
+			"deadLettered": deadLettered,
+			"scheduled":    scheduled,
+		}).Info("💾 已從 WAL 恢復未消費的消息")
+	}
+	return &PersistentBroker{SimpleBroker: b}, nil
+}
+
+// restore 將 WAL 中的消息放回隊列，保留原本的時間戳與嘗試次數
+// 優先級隊列的消息以原本的入隊時間重新計算排序鍵，恢復後的投遞順序與重啟前相同
+func (b *SimpleBroker) restore(queue string, msg Message, priority bool) {
+	var mq *messageQueue
+	if priority {
+		mq = b.getOrCreatePriorityQueue(queue)
+	} else {
+		mq = b.getOrCreateQueue(queue)
+	}
+	if !b.offer(mq, msg) {
+		b.deadLetter(queue, msg) // 緩衝大小比上次小時，放不下的消息進入死信隊列
+		return
+	}
+	atomic.AddInt64(&mq.stats.MessageCount, 1)
+	atomic.AddInt64(&mq.stats.EnqueuedTotal, 1)
+}
+
+// restoreDeadLetter 將 WAL 中的死信消息放回死信隊列，不重複寫入 WAL
+func (b *SimpleBroker) restoreDeadLetter(queue string, msg Message) {
+	b.addDeadLetter(queue, msg)
+}
+
+// Close 關閉 Broker 並關閉 WAL 檔案
+func (p *PersistentBroker) Close() error {
+	if err := p.SimpleBroker.Close(); err != nil {
+		return err
+	}
+	return p.wal.close()
+}
+
+// Shutdown 等待隊列清空後關閉 Broker 與 WAL，未消費的消息仍保存在 WAL 中
+func (p *PersistentBroker) Shutdown(ctx context.Context) error {
+	return p.shutdown(ctx, p.Close)
+}
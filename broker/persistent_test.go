@@ -0,0 +1,259 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openPersistent 在指定路徑開啟 PersistentBroker，模擬一次啟動
+func openPersistent(t *testing.T, path string) *PersistentBroker {
+	t.Helper()
+	broker, err := NewPersistentBroker(BrokerConfig{WALPath: path})
+	if err != nil {
+		t.Fatalf("NewPersistentBroker failed: %v", err)
+	}
+	return broker
+}
+
+func TestPersistentBrokerRecoversQueuedMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.wal")
+
+	broker := openPersistent(t, path)
+	for _, id := range []string{"msg-1", "msg-2", "msg-3"} {
+		msg := NewMessage(id, []byte(id), "blocks")
+		msg.Headers["source"] = "test"
+		broker.Push("blocks", msg)
+	}
+	broker.Push("alerts", NewMessage("alert-1", []byte("alert"), "alerts"))
+
+	// 已消費的消息不會在重啟後恢復
+	if msg, _ := broker.Pull("blocks"); msg == nil || msg.ID != "msg-1" {
+		t.Fatalf("Expected msg-1, got %v", msg)
+	}
+	broker.Close()
+
+	restarted := openPersistent(t, path)
+	defer restarted.Close()
+
+	for _, want := range []string{"msg-2", "msg-3"} {
+		msg, _ := restarted.Pull("blocks")
+		if msg == nil || msg.ID != want {
+			t.Fatalf("Expected %s after restart, got %v", want, msg)
+		}
+		if string(msg.Body) != want || msg.Headers["source"] != "test" {
+			t.Errorf("Expected message content to survive restart, got %+v", msg)
+		}
+	}
+	if msg, _ := restarted.Pull("blocks"); msg != nil {
+		t.Errorf("Expected no more blocks messages, got %s", msg.ID)
+	}
+	if msg, _ := restarted.Pull("alerts"); msg == nil || msg.ID != "alert-1" {
+		t.Errorf("Expected alert-1 after restart, got %v", msg)
+	}
+
+	stats, _ := restarted.GetQueueStats("blocks")
+	if stats.MessageCount != 0 || stats.EnqueuedTotal != 2 {
+		t.Errorf("Expected restored stats (count 0, enqueued 2), got %+v", stats)
+	}
+}
+
+func TestPersistentBrokerUnackedRedelivered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.wal")
+
+	broker := openPersistent(t, path)
+	broker.EnableAcks(time.Minute)
+	broker.Push("blocks", NewMessage("acked", []byte("a"), "blocks"))
+	broker.Push("blocks", NewMessage("unacked", []byte("b"), "blocks"))
+
+	acked, _ := broker.Pull("blocks")
+	if err := broker.Ack("blocks", acked.DeliveryTag); err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	broker.Pull("blocks") // 拉取後崩潰，未確認
+	broker.Close()
+
+	restarted := openPersistent(t, path)
+	defer restarted.Close()
+
+	msg, _ := restarted.Pull("blocks")
+	if msg == nil || msg.ID != "unacked" {
+		t.Fatalf("Expected unacked message to be redelivered after restart, got %v", msg)
+	}
+	if msg, _ := restarted.Pull("blocks"); msg != nil {
+		t.Errorf("Expected acked message not to be redelivered, got %s", msg.ID)
+	}
+}
+
+func TestPersistentBrokerRecoversDLQ(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.wal")
+
+	broker := openPersistent(t, path)
+	broker.Push("blocks", NewMessage("poison", []byte("p"), "blocks"))
+	broker.Push("blocks", NewMessage("retry", []byte("r"), "blocks"))
+	poison, _ := broker.Pull("blocks")
+	broker.MoveToDLQ("blocks", *poison)
+
+	retry, _ := broker.Pull("blocks")
+	broker.Requeue("blocks", *retry)
+	broker.Close()
+
+	restarted := openPersistent(t, path)
+	defer restarted.Close()
+
+	dlq := restarted.GetDLQ("blocks")
+	if len(dlq) != 1 || dlq[0].ID != "poison" || dlq[0].Attempts != 1 {
+		t.Fatalf("Expected poison message in DLQ after restart, got %+v", dlq)
+	}
+	msg, _ := restarted.Pull("blocks")
+	if msg == nil || msg.ID != "retry" || msg.Attempts != 1 {
+		t.Fatalf("Expected requeued message with 1 attempt after restart, got %+v", msg)
+	}
+
+	// 從 DLQ 重新處理後，消息回到隊列而不是死信隊列
+	if err := restarted.ReprocessDLQ("blocks", "poison"); err != nil {
+		t.Fatalf("ReprocessDLQ failed: %v", err)
+	}
+	restarted.Close()
+
+	again := openPersistent(t, path)
+	defer again.Close()
+	if dlq := again.GetDLQ("blocks"); len(dlq) != 0 {
+		t.Errorf("Expected empty DLQ after reprocessing, got %d", len(dlq))
+	}
+	if msg, _ := again.Pull("blocks"); msg == nil || msg.ID != "poison" {
+		t.Errorf("Expected reprocessed message in queue, got %v", msg)
+	}
+}
+
+func TestPersistentBrokerCompactsAndToleratesTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.wal")
+
+	broker := openPersistent(t, path)
+	for i := 0; i < 50; i++ {
+		broker.Push("blocks", NewMessage("consumed", []byte("c"), "blocks"))
+		broker.Pull("blocks")
+	}
+	broker.Push("blocks", NewMessage("kept", []byte("k"), "blocks"))
+	broker.Close()
+
+	// 模擬寫入途中崩潰留下的不完整記錄
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	file.WriteString(`{"op":"push","seq":999,"queue":"blo`)
+	file.Close()
+
+	restarted := openPersistent(t, path)
+	if msg, _ := restarted.Pull("blocks"); msg == nil || msg.ID != "kept" {
+		t.Fatalf("Expected kept message after restart, got %v", msg)
+	}
+	restarted.Close()
+
+	// 重啟時會以存活記錄重寫 WAL
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() > 1024 {
+		t.Errorf("Expected compacted wal, got %d bytes", info.Size())
+	}
+}
+
+func TestPersistentBrokerRequiresPath(t *testing.T) {
+	if _, err := NewPersistentBroker(BrokerConfig{}); err == nil {
+		t.Error("Expected error without wal path")
+	}
+}
+
+func TestPersistentBrokerKeepsPriorityQueues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.wal")
+
+	broker := openPersistent(t, path)
+	for i, priority := range []int{1, 9, 5} {
+		msg := NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "prio")
+		msg.Priority = priority
+		broker.PushWithPriority("prio", msg)
+	}
+	broker.Close()
+
+	restarted := openPersistent(t, path)
+	defer restarted.Close()
+
+	// 重啟後仍是優先級隊列
+	urgent := NewMessage("urgent", []byte("data"), "prio")
+	urgent.Priority = 10
+	if err := restarted.PushWithPriority("prio", urgent); err != nil {
+		t.Fatalf("Expected restored queue to stay a priority queue, got %v", err)
+	}
+	for _, want := range []string{"urgent", "msg-1", "msg-2", "msg-0"} {
+		if msg, _ := restarted.Pull("prio"); msg == nil || msg.ID != want {
+			t.Errorf("Expected %s after restart, got %v", want, msg)
+		}
+	}
+}
+
+func TestPersistentBrokerReschedulesDelayed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.wal")
+	clock := NewFakeClock(time.Unix(0, 0))
+	open := func() *PersistentBroker {
+		broker, err := NewPersistentBroker(BrokerConfig{WALPath: path, Clock: clock})
+		if err != nil {
+			t.Fatalf("NewPersistentBroker failed: %v", err)
+		}
+		return broker
+	}
+
+	broker := open()
+	broker.DeclareQueue("jobs", 10)
+	broker.PushDelayed("jobs", NewMessage("soon", []byte("s"), "jobs"), time.Minute)
+	broker.PushDelayed("jobs", NewMessage("later", []byte("l"), "jobs"), time.Hour)
+	broker.PushDelayed("jobs", NewMessage("cancelled", []byte("c"), "jobs"), time.Hour)
+	broker.CancelScheduled("jobs", "cancelled")
+	broker.Close()
+
+	// 停機期間 soon 已到期，重啟後直接放回隊列；later 以原本的投遞時間重新排程
+	clock.Advance(2 * time.Minute)
+	restarted := open()
+	defer restarted.Close()
+
+	if msg, _ := restarted.Pull("jobs"); msg == nil || msg.ID != "soon" {
+		t.Fatalf("Expected overdue delayed message after restart, got %v", msg)
+	}
+	scheduled := restarted.GetScheduled("jobs")
+	if len(scheduled) != 1 || scheduled[0].Message.ID != "later" || !scheduled[0].FireAt.Equal(time.Unix(0, 0).Add(time.Hour)) {
+		t.Fatalf("Expected later to be rescheduled at its original time, got %+v", scheduled)
+	}
+
+	clock.Advance(time.Hour)
+	if msg, _ := restarted.Pull("jobs"); msg == nil || msg.ID != "later" {
+		t.Errorf("Expected rescheduled message to fire, got %v", msg)
+	}
+	if msg, _ := restarted.Pull("jobs"); msg != nil {
+		t.Errorf("Expected cancelled message not to be restored, got %s", msg.ID)
+	}
+}
+
+func TestPersistentBrokerShutdownKeepsUndrainedMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broker.wal")
+
+	broker := openPersistent(t, path)
+	broker.Push("blocks", NewMessage("block-1", []byte("1"), "blocks"))
+	broker.Push("blocks", NewMessage("block-2", []byte("2"), "blocks"))
+	broker.Pull("blocks")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := broker.Shutdown(ctx); err == nil {
+		t.Fatal("Expected Shutdown to report the undrained queue")
+	}
+
+	// WAL 已關閉並可重新開啟，未消費的消息仍在
+	broker = openPersistent(t, path)
+	defer broker.Close()
+	msg, _ := broker.Pull("blocks")
+	if msg == nil || msg.ID != "block-2" {
+		t.Fatalf("Expected block-2 after restart, got %v", msg)
+	}
+}
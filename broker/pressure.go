@@ -0,0 +1,14 @@
+package broker
+
+import "sync/atomic"
+
+// QueuePressure 返回隊列目前的使用率 (0..1)，供生產者判斷消費端是否跟得上
+// 達到 1 時新推送的消息會被移入死信隊列；隊列不存在時返回 0
+func (b *SimpleBroker) QueuePressure(queue string) float64 {
+	queueInterface, exists := b.queues.Load(queue)
+	if !exists {
+		return 0
+	}
+	mq := queueInterface.(*messageQueue)
+	return utilization(atomic.LoadInt64(&mq.stats.MessageCount), mq.stats.Capacity)
+}
@@ -0,0 +1,35 @@
+package broker
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQueuePressureClimbsAsQueueFills(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	if got := broker.QueuePressure("test"); got != 0 {
+		t.Errorf("Expected pressure 0 for missing queue, got %v", got)
+	}
+
+	broker.DeclareQueue("test", 4)
+	want := []float64{0.25, 0.5, 0.75, 1}
+	for i, w := range want {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+		if got := broker.QueuePressure("test"); got != w {
+			t.Errorf("Expected pressure %v after %d pushes, got %v", w, i+1, got)
+		}
+	}
+
+	// 超過容量的消息進入死信隊列，壓力不超過 1
+	broker.Push("test", NewMessage("overflow", nil, "test"))
+	if got := broker.QueuePressure("test"); got != 1 {
+		t.Errorf("Expected pressure capped at 1, got %v", got)
+	}
+
+	broker.Pull("test")
+	if got := broker.QueuePressure("test"); got != 0.75 {
+		t.Errorf("Expected pressure 0.75 after a pull, got %v", got)
+	}
+}
@@ -0,0 +1,137 @@
+package broker
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// priorityItem 是優先級隊列中的一條消息
+type priorityItem struct {
+	msg Message
+	key float64 // 老化後的排序鍵，越大越先投遞
+	seq uint64  // 入隊順序，鍵相同時先入隊者優先
+}
+
+// priorityHeap 實作 heap.Interface
+type priorityHeap []*priorityItem
+
+func (h priorityHeap) Len() int { return len(h) }
+func (h priorityHeap) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key > h[j].key
+	}
+	return h[i].seq < h[j].seq
+}
+func (h priorityHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *priorityHeap) Push(x interface{}) { *h = append(*h, x.(*priorityItem)) }
+func (h *priorityHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// priorityQueue 是以 mutex 保護的 heap 實作的優先級隊列
+//
+// 優先級老化 (aging)：消息的有效優先級為 Priority + rate × 等待秒數。
+// 由於同一時間所有消息的等待時間以相同速度增加，排序只取決於
+// Priority - rate × 入隊時間，因此排序鍵在入隊時計算一次即可，heap 不需要重排。
+type priorityQueue struct {
+	mu       sync.Mutex
+	items    priorityHeap
+	seq      uint64
+	capacity int
+	ready    chan struct{} // 有新消息時關閉並替換，用於喚醒等待中的消費者
+}
+
+// newPriorityQueue 創建一個新的優先級隊列
+func newPriorityQueue(capacity int) *priorityQueue {
+	return &priorityQueue{capacity: capacity, ready: make(chan struct{})}
+}
+
+// offer 以老化後的排序鍵加入消息，隊列已滿時返回 false
+func (q *priorityQueue) offer(msg Message, key float64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) >= q.capacity {
+		return false
+	}
+	q.seq++
+	heap.Push(&q.items, &priorityItem{msg: msg, key: key, seq: q.seq})
+
+	close(q.ready)
+	q.ready = make(chan struct{})
+	return true
+}
+
+// poll 非阻塞地取出有效優先級最高的消息
+func (q *priorityQueue) poll() (Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return Message{}, false
+	}
+	return heap.Pop(&q.items).(*priorityItem).msg, true
+}
+
+// pollOrWait 取出消息，隊列為空時返回在下一條消息入隊時關閉的通道
+func (q *priorityQueue) pollOrWait() (Message, bool, <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return Message{}, false, q.ready
+	}
+	return heap.Pop(&q.items).(*priorityItem).msg, true, nil
+}
+
+// SetPriorityAging 設定優先級老化速率 (每等待一秒增加的優先級)，0 表示不老化
+//
+// 公平性保證：設定速率 r > 0 後，優先級為 p 的消息在入隊 (Pmax - p) / r 秒後，
+// 之後入隊的任何消息 (優先級不超過 Pmax) 都不會再排在它前面。因此它最多只需等待
+// 比它早入隊、或在它入隊後 (Pmax - p) / r 秒內入隊的消息被消費完，不會無限期飢餓。
+// 新速率只影響之後入隊的消息。
+func (b *SimpleBroker) SetPriorityAging(rate float64) {
+	if rate < 0 {
+		rate = 0
+	}
+	atomic.StoreUint64(&b.agingRate, math.Float64bits(rate))
+}
+
+// agingKey 計算消息的排序鍵 Priority - rate × 入隊時間 (相對於 Broker 啟動時間的秒數)
+func (b *SimpleBroker) agingKey(priority int, enqueuedAt time.Time) float64 {
+	rate := math.Float64frombits(atomic.LoadUint64(&b.agingRate))
+	return float64(priority) - rate*enqueuedAt.Sub(b.metrics.StartTime).Seconds()
+}
+
+// PushWithPriority 將消息推送到優先級隊列，msg.Priority 越大越先投遞，相同優先級依入隊順序
+// 隊列不存在時會以優先級模式創建；已存在的 FIFO 隊列返回錯誤
+func (b *SimpleBroker) PushWithPriority(queue string, msg Message) error {
+	if err := b.acceptingPushes(); err != nil {
+		return err
+	}
+
+	mq := b.getOrCreatePriorityQueue(queue)
+	if mq.priority == nil {
+		return fmt.Errorf("queue %s is not a priority queue", queue)
+	}
+	return b.Push(queue, msg)
+}
+
+// getOrCreatePriorityQueue 獲取隊列，不存在時以優先級模式創建
+func (b *SimpleBroker) getOrCreatePriorityQueue(name string) *messageQueue {
+	if queueInterface, exists := b.queues.Load(name); exists {
+		return queueInterface.(*messageQueue)
+	}
+
+	mq := b.createMessageQueue(name, 0)
+	mq.priority = newPriorityQueue(b.config.bufferSize(name))
+	return b.storeQueue(name, mq)
+}
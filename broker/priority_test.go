@@ -0,0 +1,154 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPriorityQueueOrdering(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	for i, priority := range []int{1, 5, 1, 10, 5} {
+		msg := NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "prio")
+		msg.Priority = priority
+		if err := broker.PushWithPriority("prio", msg); err != nil {
+			t.Fatalf("PushWithPriority failed: %v", err)
+		}
+	}
+
+	// 高優先級先出，相同優先級依入隊順序
+	expected := []string{"msg-3", "msg-1", "msg-4", "msg-0", "msg-2"}
+	for _, want := range expected {
+		msg, err := broker.Pull("prio")
+		if err != nil || msg == nil {
+			t.Fatalf("Pull failed: %v", err)
+		}
+		if msg.ID != want {
+			t.Errorf("Expected %s, got %s", want, msg.ID)
+		}
+	}
+
+	if msg, _ := broker.Pull("prio"); msg != nil {
+		t.Errorf("Expected empty priority queue, got %s", msg.ID)
+	}
+
+	// 已存在的 FIFO 隊列不能改為優先級隊列
+	broker.Push("fifo", NewMessage("fifo-1", []byte("data"), "fifo"))
+	if err := broker.PushWithPriority("fifo", NewMessage("fifo-2", []byte("data"), "fifo")); err == nil {
+		t.Error("Expected error pushing with priority to a FIFO queue")
+	}
+}
+
+func TestPriorityQueueBlockingPull(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.PushWithPriority("prio", NewMessage("seed", []byte("data"), "prio"))
+	broker.Pull("prio")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		msg, err := broker.PullWithTimeout("prio", time.Second)
+		if err != nil || msg == nil || msg.ID != "late" {
+			t.Errorf("Expected blocking pull to receive late message, got %v (err=%v)", msg, err)
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	broker.PushWithPriority("prio", NewMessage("late", []byte("data"), "prio"))
+	wg.Wait()
+}
+
+func TestPriorityAgingPreventsStarvation(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	const (
+		highPriority = 10
+		rate         = 1.0 // 每等待一秒增加 1 點優先級
+	)
+	broker.SetPriorityAging(rate)
+
+	low := NewMessage("low", []byte("data"), "prio")
+	low.Priority = 0
+	broker.PushWithPriority("prio", low)
+
+	// 持續湧入高優先級消息，每秒一條並消費一條
+	bound := time.Duration(float64(highPriority-low.Priority)/rate) * time.Second
+	var deliveredAt time.Duration
+	for elapsed := time.Duration(0); elapsed <= 2*bound; elapsed += time.Second {
+		clock.Advance(time.Second)
+		high := NewMessage(fmt.Sprintf("high-%d", elapsed/time.Second), []byte("data"), "prio")
+		high.Priority = highPriority
+		broker.PushWithPriority("prio", high)
+
+		msg, _ := broker.Pull("prio")
+		if msg != nil && msg.ID == "low" {
+			deliveredAt = elapsed + time.Second
+			break
+		}
+	}
+
+	if deliveredAt == 0 {
+		t.Fatal("Low-priority message starved behind high-priority flood")
+	}
+	if deliveredAt > bound {
+		t.Errorf("Expected low-priority message within %v, delivered after %v", bound, deliveredAt)
+	}
+}
+
+func TestPriorityWithoutAgingStarves(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	low := NewMessage("low", []byte("data"), "prio")
+	broker.PushWithPriority("prio", low)
+
+	// 未開啟老化時，持續的高優先級消息會一直排在前面
+	for i := 0; i < 50; i++ {
+		high := NewMessage(fmt.Sprintf("high-%d", i), []byte("data"), "prio")
+		high.Priority = 10
+		broker.PushWithPriority("prio", high)
+		if msg, _ := broker.Pull("prio"); msg.ID == "low" {
+			t.Fatal("Expected low-priority message to wait without aging")
+		}
+	}
+}
+
+func TestPriorityQueueInterleaved(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	push := func(id string, priority int) {
+		msg := NewMessage(id, []byte(id), "prio")
+		msg.Priority = priority
+		broker.PushWithPriority("prio", msg)
+	}
+	pull := func(want string) {
+		t.Helper()
+		msg, _ := broker.Pull("prio")
+		if msg == nil || msg.ID != want {
+			t.Errorf("Expected %s, got %v", want, msg)
+		}
+	}
+
+	// 入隊與出隊交錯進行，每次出隊都返回當下優先級最高、最早入隊的消息
+	push("low-1", 1)
+	push("mid-1", 5)
+	pull("mid-1")
+	push("high-1", 9)
+	push("mid-2", 5)
+	pull("high-1")
+	push("low-2", 1)
+	pull("mid-2")
+	push("high-2", 9)
+	pull("high-2")
+	pull("low-1")
+	pull("low-2")
+}
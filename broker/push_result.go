@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+// PushResult 描述一次推送的結果
+type PushResult string
+
+// 推送結果
+const (
+	PushEnqueued     PushResult = "enqueued"      // 消息已放入隊列
+	PushDeadLettered PushResult = "dead_lettered" // 隊列已滿，消息移入死信隊列
+	PushDeduplicated PushResult = "deduplicated"  // 消息 ID 在去重窗口內已推送過，被丟棄
+	PushRateLimited  PushResult = "rate_limited"  // 超過隊列的速率限制，推送被拒絕
+	PushFailed       PushResult = "failed"        // 其他錯誤，例如 broker 已關閉或寫入 WAL 失敗
+)
+
+// PushWithResult 與 PushContext 相同，但同時返回推送的結果
+// 錯誤的語意與 PushContext 一致：去重與移入死信隊列不返回錯誤，速率限制返回 ErrRateLimited
+func (b *SimpleBroker) PushWithResult(ctx context.Context, queue string, msg Message) (PushResult, error) {
+	if err := ctx.Err(); err != nil {
+		b.logOp("push", queue, msg.ID, opResult(err))
+		return PushFailed, err
+	}
+	if err := b.acceptingPushes(); err != nil {
+		return PushFailed, err
+	}
+	assignID(&msg)
+	if b.dropDuplicate("push", queue, msg) {
+		return PushDeduplicated, nil
+	}
+	if err := b.rateLimit("push", queue, msg); err != nil {
+		b.releaseDuplicate(queue, msg)
+		if errors.Is(err, ErrRateLimited) {
+			return PushRateLimited, err
+		}
+		return PushFailed, err
+	}
+
+	span := b.startPushSpan(ctx, "push", queue, &msg)
+	result, err := b.pushResult(queue, msg)
+	if result == PushFailed {
+		b.releaseDuplicate(queue, msg)
+	}
+	endSpan(span, err)
+	return result, err
+}
@@ -0,0 +1,65 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPushWithResult(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		Clock:           clock,
+		DedupeWindow:    time.Minute,
+		QueueRateLimits: map[string]RateLimit{"limited": {Rate: 1, Burst: 1}},
+	})
+	defer broker.Close()
+	broker.DeclareQueue("test", 1)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		queue   string
+		id      string
+		want    PushResult
+		wantErr error
+	}{
+		{"enqueued", "test", "msg-1", PushEnqueued, nil},
+		{"dead lettered when full", "test", "msg-2", PushDeadLettered, nil},
+		{"deduplicated", "test", "msg-1", PushDeduplicated, nil},
+		{"first within rate limit", "limited", "msg-3", PushEnqueued, nil},
+		{"rate limited", "limited", "msg-4", PushRateLimited, ErrRateLimited},
+	}
+	for _, tt := range tests {
+		got, err := broker.PushWithResult(ctx, tt.queue, NewMessage(tt.id, nil, tt.queue))
+		if got != tt.want {
+			t.Errorf("%s: expected result %s, got %s", tt.name, tt.want, got)
+		}
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+
+	// 死信與去重不改變 Push 原本不返回錯誤的行為
+	if dlq := broker.GetDLQ("test"); len(dlq) != 1 || dlq[0].ID != "msg-2" {
+		t.Errorf("Expected msg-2 in DLQ, got %+v", dlq)
+	}
+}
+
+func TestPushWithResultFailed(t *testing.T) {
+	broker := NewSimpleBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// ctx 已取消
+	if got, err := broker.PushWithResult(ctx, "test", NewMessage("msg-1", nil, "test")); got != PushFailed || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected failed result with context.Canceled, got %s, %v", got, err)
+	}
+
+	// broker 已關閉
+	broker.Close()
+	if got, err := broker.PushWithResult(context.Background(), "test", NewMessage("msg-2", nil, "test")); got != PushFailed || err == nil {
+		t.Errorf("Expected failed result with error after Close, got %s, %v", got, err)
+	}
+}
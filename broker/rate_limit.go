@@ -0,0 +1,91 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRateLimited 表示推送速率超過隊列的 BrokerConfig.QueueRateLimits 設定，消息未入隊
+var ErrRateLimited = errors.New("rate limited")
+
+// OpResultRateLimited 表示推送因超過速率限制而被拒絕
+const OpResultRateLimited = "rate_limited"
+
+// RateLimit 是單一隊列的推送速率限制 (token bucket)
+type RateLimit struct {
+	Rate  float64 // 每秒補充的消息數，<= 0 表示不限制
+	Burst int     // 可瞬間推送的消息數上限，<= 0 時使用 ceil(Rate) (至少 1)
+}
+
+// tokenBucket 以 token bucket 演算法限制推送速率，時間來源為 Broker 的 Clock
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket 創建初始為滿的 token bucket，limit.Rate <= 0 時返回 nil (不限制)
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if limit.Burst <= 0 {
+		burst = math.Max(1, math.Ceil(limit.Rate))
+	}
+	return &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst, last: now}
+}
+
+// allow 在 now 時取用一個 token，不足時返回 false
+func (tb *tokenBucket) allow(now time.Time) bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if elapsed := now.Sub(tb.last); elapsed > 0 {
+		tb.tokens = math.Min(tb.burst, tb.tokens+elapsed.Seconds()*tb.rate)
+		tb.last = now
+	}
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+// newRateLimiters 依設定為每個有限制的隊列創建 token bucket，創建後只讀
+func newRateLimiters(limits map[string]RateLimit, now time.Time) map[string]*tokenBucket {
+	limiters := make(map[string]*tokenBucket)
+	for queue, limit := range limits {
+		if tb := newTokenBucket(limit, now); tb != nil {
+			limiters[queue] = tb
+		}
+	}
+	return limiters
+}
+
+// rateLimit 檢查推送到隊列的速率，超過限制時計入 RateLimitedCount 並返回包裝 ErrRateLimited 的錯誤
+// BrokerConfig.RateLimitDeadLetter 為 true 時，被拒絕的消息同時移入死信隊列
+// 只套用於生產者經 Push / PushBatch 的推送；重試、重新處理與 PushDelayed 排程的消息 (含到期投遞) 不受限制
+func (b *SimpleBroker) rateLimit(op, queue string, msg Message) error {
+	tb := b.limiters[queue]
+	if tb == nil || tb.allow(b.clock.Now()) {
+		return nil
+	}
+
+	atomic.AddInt64(&b.getOrCreateQueue(queue).stats.RateLimitedCount, 1)
+	b.logOp(op, queue, msg.ID, OpResultRateLimited)
+	if b.config.RateLimitDeadLetter {
+		msg.Queue = queue
+		msg.Timestamp = b.clock.Now()
+		if err := b.MoveToDLQ(queue, msg); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: queue %s", ErrRateLimited, queue)
+}
@@ -0,0 +1,220 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newRateLimitedBroker 創建 test 隊列每秒 10 條、突發 5 條的 Broker
+func newRateLimitedBroker(deadLetter bool) (*SimpleBroker, *FakeClock) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		Clock:               clock,
+		QueueRateLimits:     map[string]RateLimit{"test": {Rate: 10, Burst: 5}},
+		RateLimitDeadLetter: deadLetter,
+	})
+	return broker, clock
+}
+
+func TestPushRateLimited(t *testing.T) {
+	broker, clock := newRateLimitedBroker(false)
+	defer broker.Close()
+
+	// 同一時刻推送 8 條，只有突發量內的 5 條被接受
+	var rejected int
+	for i := 0; i < 8; i++ {
+		err := broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+		if errors.Is(err, ErrRateLimited) {
+			rejected++
+		} else if err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+	if rejected != 3 {
+		t.Errorf("Expected 3 rate-limited pushes, got %d", rejected)
+	}
+
+	stats, _ := broker.GetQueueStats("test")
+	if stats.MessageCount != 5 || stats.RateLimitedCount != 3 {
+		t.Errorf("Expected 5 queued and 3 rate limited, got %d and %d", stats.MessageCount, stats.RateLimitedCount)
+	}
+	if len(broker.GetDLQ("test")) != 0 {
+		t.Error("Expected rate-limited messages not to be dead-lettered by default")
+	}
+
+	// 0.2 秒後補充 2 個 token
+	clock.Advance(200 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := broker.Push("test", NewMessage(fmt.Sprintf("later-%d", i), nil, "test")); err != nil {
+			t.Errorf("Expected push after refill to succeed, got %v", err)
+		}
+	}
+	if err := broker.Push("test", NewMessage("later-2", nil, "test")); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited once refilled tokens are used, got %v", err)
+	}
+}
+
+func TestPushRateLimitOnlyConfiguredQueues(t *testing.T) {
+	broker, _ := newRateLimitedBroker(false)
+	defer broker.Close()
+
+	for i := 0; i < 20; i++ {
+		if err := broker.Push("other", NewMessage(fmt.Sprintf("msg-%d", i), nil, "other")); err != nil {
+			t.Fatalf("Expected unlimited queue to accept push, got %v", err)
+		}
+	}
+}
+
+func TestPushRateLimitDeadLetters(t *testing.T) {
+	broker, _ := newRateLimitedBroker(true)
+	defer broker.Close()
+
+	for i := 0; i < 7; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+	}
+
+	dlq := broker.GetDLQ("test")
+	if len(dlq) != 2 || dlq[0].ID != "msg-5" || dlq[1].ID != "msg-6" {
+		t.Errorf("Expected msg-5 and msg-6 to be dead-lettered, got %+v", dlq)
+	}
+}
+
+func TestPushBatchRateLimited(t *testing.T) {
+	broker, _ := newRateLimitedBroker(false)
+	defer broker.Close()
+
+	msgs := make([]Message, 8)
+	for i := range msgs {
+		msgs[i] = NewMessage(fmt.Sprintf("msg-%d", i), nil, "test")
+	}
+
+	// 超過限制時保留已入隊的消息並停止推送其餘消息
+	if err := broker.PushBatch("test", msgs); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited, got %v", err)
+	}
+	if stats, _ := broker.GetQueueStats("test"); stats.MessageCount != 5 {
+		t.Errorf("Expected 5 queued messages, got %d", stats.MessageCount)
+	}
+}
+
+func TestPushRateLimitedRetryNotDeduplicated(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		Clock:           clock,
+		DedupeWindow:    time.Minute,
+		QueueRateLimits: map[string]RateLimit{"test": {Rate: 1, Burst: 1}},
+	})
+	defer broker.Close()
+	ctx := context.Background()
+
+	if result, err := broker.PushWithResult(ctx, "test", NewMessage("a", nil, "test")); result != PushEnqueued || err != nil {
+		t.Fatalf("Expected a to be enqueued, got %s, %v", result, err)
+	}
+	if result, err := broker.PushWithResult(ctx, "test", NewMessage("b", nil, "test")); result != PushRateLimited || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected b to be rate limited, got %s, %v", result, err)
+	}
+
+	// 令牌補充後重送被拒絕的消息，不應被當成重複而丟棄
+	clock.Advance(2 * time.Second)
+	if result, err := broker.PushWithResult(ctx, "test", NewMessage("b", nil, "test")); result != PushEnqueued || err != nil {
+		t.Errorf("Expected retried b to be enqueued, got %s, %v", result, err)
+	}
+
+	// 已入隊的消息仍在去重窗口內
+	clock.Advance(2 * time.Second)
+	if result, _ := broker.PushWithResult(ctx, "test", NewMessage("a", nil, "test")); result != PushDeduplicated {
+		t.Errorf("Expected a to still be deduplicated, got %s", result)
+	}
+}
+
+func TestPushBatchRateLimitedRetryNotDeduplicated(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		Clock:           clock,
+		DedupeWindow:    time.Minute,
+		QueueRateLimits: map[string]RateLimit{"test": {Rate: 1, Burst: 1}},
+	})
+	defer broker.Close()
+
+	batch := []Message{NewMessage("a", nil, "test"), NewMessage("b", nil, "test")}
+	if err := broker.PushBatch("test", batch); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected batch to be rate limited, got %v", err)
+	}
+
+	// 重送整批：a 已入隊被去重，b 這次入隊
+	clock.Advance(2 * time.Second)
+	if err := broker.PushBatch("test", batch); err != nil {
+		t.Fatalf("Expected retried batch to succeed, got %v", err)
+	}
+	if stats, _ := broker.GetQueueStats("test"); stats.MessageCount != 2 || stats.DedupedCount != 1 {
+		t.Errorf("Expected a and b queued once each, got count=%d deduped=%d", stats.MessageCount, stats.DedupedCount)
+	}
+}
+
+func TestDelayedMessageFiresIntoExhaustedBucket(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		Clock:           clock,
+		QueueRateLimits: map[string]RateLimit{"test": {Rate: 0.5, Burst: 1}},
+	})
+	defer broker.Close()
+
+	// 先用掉唯一的 token，1 秒後只補回半個
+	if err := broker.Push("test", NewMessage("now", nil, "test")); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := broker.PushDelayed("test", NewMessage("later", nil, "test"), time.Second); err != nil {
+		t.Fatalf("PushDelayed failed: %v", err)
+	}
+	clock.Advance(time.Second)
+
+	// 到期的延遲消息不受速率限制，必須入隊而不是憑空消失
+	if len(broker.GetScheduled("test")) != 0 {
+		t.Error("Expected delayed message to leave the schedule once fired")
+	}
+	var ids []string
+	for {
+		msg, err := broker.Pull("test")
+		if err != nil || msg == nil {
+			break
+		}
+		ids = append(ids, msg.ID)
+	}
+	if len(ids) != 2 || ids[1] != "later" {
+		t.Errorf("Expected now and later to be delivered, got %v", ids)
+	}
+	if stats, _ := broker.GetQueueStats("test"); stats.RateLimitedCount != 0 {
+		t.Errorf("Expected no rate-limited pushes, got %d", stats.RateLimitedCount)
+	}
+}
+
+func TestPushBatchRateLimitedAfterOverflow(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		Clock:           clock,
+		QueueBufferSize: 2,
+		QueueRateLimits: map[string]RateLimit{"test": {Rate: 1, Burst: 3}},
+	})
+	defer broker.Close()
+
+	msgs := make([]Message, 4)
+	for i := range msgs {
+		msgs[i] = NewMessage(fmt.Sprintf("msg-%d", i), nil, "test")
+	}
+
+	// msg-2 因隊列已滿移入死信隊列，msg-3 超過速率限制；兩者都必須反映在返回的錯誤中
+	err := broker.PushBatch("test", msgs)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited, got %v", err)
+	}
+	var overflow *BatchOverflowError
+	if !errors.As(err, &overflow) || len(overflow.DeadLettered) != 1 || overflow.DeadLettered[0] != "msg-2" {
+		t.Fatalf("Expected msg-2 reported as dead-lettered, got %v", err)
+	}
+	if stats, _ := broker.GetQueueStats("test"); stats.MessageCount != 2 || stats.RateLimitedCount != 1 {
+		t.Errorf("Expected 2 queued and 1 rate limited, got %d and %d", stats.MessageCount, stats.RateLimitedCount)
+	}
+}
@@ -0,0 +1,87 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// replayBuffer 是主題最近發布消息的環形緩衝，供 SubscribeWithReplay 補發
+type replayBuffer struct {
+	mu       sync.Mutex
+	messages []Message // 長度固定為保留數量
+	next     int       // 下一條消息寫入的位置
+	count    int       // 目前保留的消息數
+}
+
+// newReplayBuffer 創建保留最近 size 條消息的緩衝，size <= 0 時返回 nil (不保留)
+func newReplayBuffer(size int) *replayBuffer {
+	if size <= 0 {
+		return nil
+	}
+	return &replayBuffer{messages: make([]Message, size)}
+}
+
+// add 保留一條消息，已滿時覆蓋最舊的消息；nil 緩衝不做任何事
+func (r *replayBuffer) add(msg Message) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages[r.next] = msg
+	r.next = (r.next + 1) % len(r.messages)
+	if r.count < len(r.messages) {
+		r.count++
+	}
+}
+
+// last 依發布順序返回最近的 n 條消息 (不足 n 條時返回全部)
+func (r *replayBuffer) last(n int) []Message {
+	if r == nil || n <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n = min(n, r.count)
+	result := make([]Message, 0, n)
+	start := r.next - n + len(r.messages)
+	for i := 0; i < n; i++ {
+		result = append(result, r.messages[(start+i)%len(r.messages)])
+	}
+	return result
+}
+
+// SubscribeWithReplay 訂閱主題，先收到最近發布的 n 條消息，再收到之後發布的消息
+//
+// 只有在 BrokerConfig.TopicReplaySizes 中設定了保留數量的主題才會保留消息，
+// 其他主題的行為與 Subscribe 相同；保留的消息少於 n 條時補發全部。
+// 補發與註冊在同一個訂閱鎖內完成，每條消息只會收到一次 (補發或即時二擇一)。
+func (b *SimpleBroker) SubscribeWithReplay(topic string, n int) (<-chan Message, error) {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return nil, ErrBrokerClosed
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("replay count must not be negative, got %d", n)
+	}
+
+	subMgr := b.getOrCreateSubscriberManager(topic)
+	subMgr.mu.Lock()
+	if atomic.LoadInt32(&b.closed) == 1 {
+		subMgr.mu.Unlock()
+		return nil, ErrBrokerClosed
+	}
+	// 持有寫鎖時 Publish 無法同時廣播，保留的消息與之後的即時消息之間不會有遺漏或重複
+	replayed := subMgr.replay.last(n)
+	subscriberChan := make(chan Message, subscriberBufferSize+len(replayed))
+	for _, msg := range replayed {
+		subscriberChan <- msg
+	}
+	subMgr.subscribers = append(subMgr.subscribers, subscriberChan)
+	subMgr.mu.Unlock()
+
+	atomic.AddInt32(&b.metrics.ActiveConsumers, 1)
+	return subscriberChan, nil
+}
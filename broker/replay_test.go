@@ -0,0 +1,72 @@
+package broker
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSubscribeWithReplayDeliversRecentThenLive(t *testing.T) {
+	config := DefaultBrokerConfig()
+	config.TopicReplaySizes = map[string]int{"events": 10}
+	broker := NewSimpleBrokerWithConfig(config)
+	defer broker.Close()
+
+	// 訂閱前發布的消息 (此時主題還沒有任何訂閱者)
+	for i := 0; i < 5; i++ {
+		broker.Publish("events", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "events"))
+	}
+
+	ch, err := broker.SubscribeWithReplay("events", 3)
+	if err != nil {
+		t.Fatalf("SubscribeWithReplay failed: %v", err)
+	}
+
+	broker.Publish("events", NewMessage("msg-5", []byte("data"), "events"))
+	broker.Publish("events", NewMessage("msg-6", []byte("data"), "events"))
+
+	// 先收到最近的 3 條，再依序收到之後的即時消息
+	want := []string{"msg-2", "msg-3", "msg-4", "msg-5", "msg-6"}
+	if got := drain(ch); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestSubscribeWithReplayRetentionLimit(t *testing.T) {
+	config := DefaultBrokerConfig()
+	config.TopicReplaySizes = map[string]int{"events": 2}
+	broker := NewSimpleBrokerWithConfig(config)
+	defer broker.Close()
+
+	for i := 0; i < 5; i++ {
+		broker.Publish("events", NewMessage(fmt.Sprintf("msg-%d", i), []byte("data"), "events"))
+	}
+
+	// 要求的數量超過保留數量時只補發保留的消息
+	ch, _ := broker.SubscribeWithReplay("events", 10)
+	want := []string{"msg-3", "msg-4"}
+	if got := drain(ch); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestSubscribeWithReplayUnconfiguredTopic(t *testing.T) {
+	config := DefaultBrokerConfig()
+	config.TopicReplaySizes = map[string]int{"events": 5}
+	broker := NewSimpleBrokerWithConfig(config)
+	defer broker.Close()
+
+	broker.Publish("other", NewMessage("msg-0", []byte("data"), "other"))
+
+	// 未設定保留數量的主題不保留消息，行為同 Subscribe
+	ch, err := broker.SubscribeWithReplay("other", 3)
+	if err != nil {
+		t.Fatalf("SubscribeWithReplay failed: %v", err)
+	}
+	if got := drain(ch); len(got) != 0 {
+		t.Errorf("Expected no replayed messages, got %v", got)
+	}
+
+	if _, err := broker.SubscribeWithReplay("events", -1); err == nil {
+		t.Error("Expected error for negative replay count")
+	}
+}
@@ -0,0 +1,129 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 重試預算相關的消息標頭，會隨消息在各階段間重新入隊、進出 DLQ 而保留
+const (
+	HeaderRetryCount    = "x-retry-count"    // 跨所有階段累計的重試次數
+	HeaderRetryHistory  = "x-retry-history"  // 每次重試的階段與原因 (JSON 陣列)
+	HeaderRetryTerminal = "x-retry-terminal" // 預算耗盡後終止投遞，值為 "true"
+)
+
+// ErrMaxRetryExceeded 表示消息的投遞次數已超過 MaxRetry，已被移入死信隊列
+var ErrMaxRetryExceeded = errors.New("max retry exceeded")
+
+// ErrRetryBudgetExhausted 表示消息已用完全域重試預算，已被終止性地移入死信隊列
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// RetryRecord 是一次重試的記錄
+type RetryRecord struct {
+	Stage  string    `json:"stage"`
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// SetRetryBudget 設定每個邏輯事件跨所有階段 (requeue、DLQ 重新處理、外部階段的重試) 的重試總上限
+// 超過上限的消息會連同完整重試記錄終止性地移入死信隊列，不論是哪個階段想要重試；0 表示不限制
+func (b *SimpleBroker) SetRetryBudget(max int) {
+	if max < 0 {
+		max = 0
+	}
+	atomic.StoreInt64(&b.retryBudget, int64(max))
+}
+
+// ChargeRetry 為消息記錄一次重試並返回是否仍在預算內
+// 供 Broker 以外自行重試的階段 (例如 webhook 發送) 使用，使其重試也計入同一份預算
+func (b *SimpleBroker) ChargeRetry(msg *Message, stage string, cause error) bool {
+	count := RetryCount(*msg) + 1
+
+	reason := ""
+	if cause != nil {
+		reason = cause.Error()
+	}
+	history := append(RetryHistory(*msg), RetryRecord{Stage: stage, Reason: reason, At: b.clock.Now()})
+	encoded, _ := json.Marshal(history)
+
+	// 複製標頭，避免修改到與其他消息副本共用的 map
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderRetryCount] = strconv.Itoa(count)
+	headers[HeaderRetryHistory] = string(encoded)
+	msg.Headers = headers
+
+	budget := atomic.LoadInt64(&b.retryBudget)
+	if budget > 0 && int64(count) > budget {
+		msg.Headers[HeaderRetryTerminal] = "true"
+		return false
+	}
+	return true
+}
+
+// Retry 將處理失敗的消息重新推送到隊列，Attempts 加一並計入全域重試預算
+// 每條消息共有 MaxRetry+1 次投遞機會，Attempts 超過 MaxRetry 時移入死信隊列並返回 ErrMaxRetryExceeded；
+// 全域預算耗盡時終止性地移入死信隊列並返回 ErrRetryBudgetExhausted
+func (b *SimpleBroker) Retry(queue string, msg Message, stage string, cause error) error {
+	return b.retry(queue, msg, stage, cause, RetryPolicy{})
+}
+
+// retry 是 Retry 的實作，policy 開啟時依其退避延遲與重試上限經延遲投遞重新入隊
+func (b *SimpleBroker) retry(queue string, msg Message, stage string, cause error, policy RetryPolicy) error {
+	msg.Attempts++
+
+	if !b.ChargeRetry(&msg, stage, cause) {
+		b.logOp("retry", queue, msg.ID, OpResultDeadLettered)
+		if err := b.deadLetter(queue, msg); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: message %s after %d retries", ErrRetryBudgetExhausted, msg.ID, RetryCount(msg)-1)
+	}
+
+	if msg.Attempts > policy.maxRetry(msg) {
+		b.logOp("retry", queue, msg.ID, OpResultDeadLettered)
+		if err := b.deadLetter(queue, msg); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: message %s after %d attempts", ErrMaxRetryExceeded, msg.ID, msg.Attempts)
+	}
+
+	b.logOp("retry", queue, msg.ID, OpResultOK)
+	if !policy.enabled() {
+		return b.push(queue, msg)
+	}
+	msg.Queue = queue
+	return b.schedule(queue, msg, b.clock.Now().Add(policy.Delay(msg.Attempts)), true, true)
+}
+
+// Requeue 在處理失敗時將消息放回隊列，等同於以 "requeue" 階段呼叫 Retry
+// MaxRetry=3 的消息共會被投遞 4 次，第 4 次仍失敗時才移入死信隊列
+func (b *SimpleBroker) Requeue(queue string, msg Message) error {
+	return b.Retry(queue, msg, "requeue", nil)
+}
+
+// RetryCount 返回消息跨所有階段累計的重試次數
+func RetryCount(msg Message) int {
+	count, _ := strconv.Atoi(msg.Headers[HeaderRetryCount])
+	return count
+}
+
+// RetryHistory 返回消息的完整重試記錄
+func RetryHistory(msg Message) []RetryRecord {
+	var history []RetryRecord
+	if raw := msg.Headers[HeaderRetryHistory]; raw != "" {
+		json.Unmarshal([]byte(raw), &history)
+	}
+	return history
+}
+
+// isRetryTerminal 判斷消息是否已因預算耗盡而終止
+func isRetryTerminal(msg Message) bool {
+	return msg.Headers[HeaderRetryTerminal] == "true"
+}
@@ -0,0 +1,108 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryBudgetCapsRetriesAcrossStages(t *testing.T) {
+	broker := NewSimpleBrokerWithClock(NewFakeClock(time.Unix(0, 0)))
+	defer broker.Close()
+	broker.SetRetryBudget(3)
+
+	broker.Push("events", NewMessage("poison", []byte("data"), "events"))
+
+	// 階段一：消費者處理失敗，requeue 兩次
+	for i := 0; i < 2; i++ {
+		msg, _ := broker.Pull("events")
+		if err := broker.Retry("events", *msg, "consumer", errors.New("decode failed")); err != nil {
+			t.Fatalf("Retry %d failed: %v", i+1, err)
+		}
+	}
+
+	// 階段二：外部階段 (例如 webhook) 自行重試一次後放入 DLQ
+	msg, _ := broker.Pull("events")
+	if !broker.ChargeRetry(msg, "webhook", errors.New("status 500")) {
+		t.Fatal("Expected third retry to be within budget")
+	}
+	broker.MoveToDLQ("events", *msg)
+
+	// 階段三：從 DLQ 重新處理會超出預算，消息留在 DLQ
+	err := broker.ReprocessDLQ("events", "poison")
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("Expected ErrRetryBudgetExhausted, got %v", err)
+	}
+	if msg, _ := broker.Pull("events"); msg != nil {
+		t.Errorf("Expected poison message not to be requeued, got %s", msg.ID)
+	}
+
+	dlq := broker.GetDLQ("events")
+	if len(dlq) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(dlq))
+	}
+	if dlq[0].Headers[HeaderRetryTerminal] != "true" {
+		t.Error("Expected dead letter to be marked terminal")
+	}
+	history := RetryHistory(dlq[0])
+	stages := []string{"consumer", "consumer", "webhook", "reprocess_dlq"}
+	if len(history) != len(stages) {
+		t.Fatalf("Expected %d history records, got %d", len(stages), len(history))
+	}
+	for i, stage := range stages {
+		if history[i].Stage != stage {
+			t.Errorf("History %d: expected stage %s, got %s", i, stage, history[i].Stage)
+		}
+	}
+	if history[0].Reason != "decode failed" {
+		t.Errorf("Expected retry reason to be recorded, got %q", history[0].Reason)
+	}
+
+	// 終止的消息即使之後提高預算也不會再被重新處理
+	broker.SetRetryBudget(100)
+	if err := broker.ReprocessDLQ("events", "poison"); !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Errorf("Expected terminal message to stay dead-lettered, got %v", err)
+	}
+}
+
+func TestRetryExhaustedDeadLetters(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+	broker.SetRetryBudget(1)
+
+	msg := NewMessage("msg-1", []byte("data"), "events")
+	if err := broker.Retry("events", msg, "consumer", nil); err != nil {
+		t.Fatalf("First retry failed: %v", err)
+	}
+	pulled, _ := broker.Pull("events")
+	if RetryCount(*pulled) != 1 {
+		t.Errorf("Expected retry count to survive requeue, got %d", RetryCount(*pulled))
+	}
+
+	if err := broker.Retry("events", *pulled, "consumer", nil); !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Errorf("Expected ErrRetryBudgetExhausted, got %v", err)
+	}
+	if len(broker.GetDLQ("events")) != 1 {
+		t.Error("Expected message to be dead-lettered")
+	}
+
+	// 原本的消息副本不受影響
+	if RetryCount(msg) != 0 {
+		t.Errorf("Expected caller's message headers to be untouched, got %d", RetryCount(msg))
+	}
+}
+
+func TestRetryUnlimitedByDefault(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	msg := NewMessage("msg-1", []byte("data"), "events")
+	for i := 0; i < 10; i++ {
+		if !broker.ChargeRetry(&msg, "consumer", nil) {
+			t.Fatalf("Expected unlimited retries without a budget, stopped at %d", i+1)
+		}
+	}
+	if RetryCount(msg) != 10 {
+		t.Errorf("Expected retry count 10, got %d", RetryCount(msg))
+	}
+}
@@ -0,0 +1,52 @@
+package broker
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultRetryMultiplier 是 RetryPolicy 未設定 Multiplier 時每次重試延遲的倍數
+const DefaultRetryMultiplier = 2.0
+
+// RetryPolicy 是 Nack 重新入隊時的退避策略
+// 開啟後 (BaseDelay > 0) 被 Nack 的消息經延遲投遞重新入隊，第 n 次重試的延遲為
+// BaseDelay * Multiplier^(n-1)，超過 MaxAttempts 次重試後移入死信隊列
+type RetryPolicy struct {
+	BaseDelay   time.Duration // 第一次重試的延遲，<= 0 表示不退避 (立即重新入隊)
+	Multiplier  float64       // 每次重試延遲的倍數，<= 0 時使用 DefaultRetryMultiplier
+	MaxDelay    time.Duration // 延遲上限，<= 0 表示不限制
+	MaxAttempts int           // 最多重試次數，<= 0 時使用消息的 MaxRetry
+}
+
+// enabled 判斷是否開啟退避重試
+func (p RetryPolicy) enabled() bool {
+	return p.BaseDelay > 0
+}
+
+// Delay 返回第 attempt 次重試 (從 1 開始) 前的延遲
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	if !p.enabled() {
+		return 0
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(multiplier, float64(max(attempt-1, 0)))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	if delay > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
+
+// maxRetry 返回消息允許的重試次數
+func (p RetryPolicy) maxRetry(msg Message) int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return msg.MaxRetry
+}
@@ -0,0 +1,104 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, Multiplier: 3, MaxDelay: 20 * time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 3 * time.Second,
+		3: 9 * time.Second,
+		4: 20 * time.Second, // 27s 超過上限
+	} {
+		if got := policy.Delay(attempt); got != want {
+			t.Errorf("Delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	if got := (RetryPolicy{BaseDelay: time.Second}).Delay(3); got != 4*time.Second {
+		t.Errorf("Expected default multiplier 2 to give 4s, got %v", got)
+	}
+	if got := (RetryPolicy{}).Delay(3); got != 0 {
+		t.Errorf("Expected no delay when disabled, got %v", got)
+	}
+}
+
+func TestNackRetriesWithBackoff(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		Clock:       clock,
+		RetryPolicy: RetryPolicy{BaseDelay: time.Second, Multiplier: 2, MaxAttempts: 3},
+	})
+	defer broker.Close()
+	if err := broker.EnableAcks(time.Hour); err != nil {
+		t.Fatalf("EnableAcks failed: %v", err)
+	}
+
+	broker.Push("work", NewMessage("msg-1", []byte("data"), "work"))
+
+	// 每次 Nack 後的延遲依次為 1s、2s、4s
+	for attempt, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		msg, _ := broker.Pull("work")
+		if msg == nil {
+			t.Fatalf("Expected delivery before retry %d", attempt+1)
+		}
+		if err := broker.Nack("work", msg.DeliveryTag, true); err != nil {
+			t.Fatalf("Nack %d failed: %v", attempt+1, err)
+		}
+
+		scheduled := broker.GetScheduled("work")
+		if len(scheduled) != 1 {
+			t.Fatalf("Expected nacked message to be scheduled, got %v", scheduled)
+		}
+		if got := scheduled[0].FireAt.Sub(clock.Now()); got != delay {
+			t.Errorf("Expected retry %d after %v, got %v", attempt+1, delay, got)
+		}
+
+		// 延遲到期前不會被投遞
+		clock.Advance(delay - time.Millisecond)
+		if early, _ := broker.Pull("work"); early != nil {
+			t.Fatalf("Expected no delivery before the backoff elapsed, got %v", early)
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	// 第 3 次重試仍失敗後移入死信隊列
+	msg, _ := broker.Pull("work")
+	if msg == nil || msg.Attempts != 3 {
+		t.Fatalf("Expected final delivery with 3 attempts, got %v", msg)
+	}
+	if err := broker.Nack("work", msg.DeliveryTag, true); !errors.Is(err, ErrMaxRetryExceeded) {
+		t.Errorf("Expected ErrMaxRetryExceeded, got %v", err)
+	}
+	if scheduled := broker.GetScheduled("work"); len(scheduled) != 0 {
+		t.Errorf("Expected nothing scheduled after dead-lettering, got %v", scheduled)
+	}
+	if dlq := broker.GetDLQ("work"); len(dlq) != 1 || dlq[0].ID != "msg-1" {
+		t.Errorf("Expected message in DLQ, got %v", dlq)
+	}
+}
+
+func TestNackBackoffBypassesDedupe(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		Clock:        clock,
+		DedupeWindow: time.Hour,
+		RetryPolicy:  RetryPolicy{BaseDelay: time.Second},
+	})
+	defer broker.Close()
+	broker.EnableAcks(time.Hour)
+
+	broker.Push("work", NewMessage("msg-1", []byte("data"), "work"))
+	msg, _ := broker.Pull("work")
+	broker.Nack("work", msg.DeliveryTag, true)
+	clock.Advance(time.Second)
+
+	// 重試的消息沿用原本的 ID，不會被當成重複推送丟棄
+	if retried, _ := broker.Pull("work"); retried == nil || retried.ID != "msg-1" {
+		t.Errorf("Expected retried message to be delivered, got %v", retried)
+	}
+}
@@ -0,0 +1,153 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrScheduledMessageNotFound 表示指定的延遲消息不存在 (可能已被投遞或取消)
+var ErrScheduledMessageNotFound = errors.New("scheduled message not found")
+
+// ScheduledMessage 表示一條等待延遲投遞的消息
+type ScheduledMessage struct {
+	Message Message   `json:"message"`
+	FireAt  time.Time `json:"fire_at"`
+}
+
+// scheduledEntry 是排程中的一條延遲消息
+type scheduledEntry struct {
+	msg    Message
+	fireAt time.Time
+	timer  Timer
+	retry  bool // 退避重試的消息，到期時不做 ID 去重 (沿用原本的 ID)
+}
+
+// PushDelayed 在 delay 之後才將消息推送到指定隊列
+// delay <= 0 時等同於 Push
+func (b *SimpleBroker) PushDelayed(queue string, msg Message, delay time.Duration) error {
+	if err := b.acceptingPushes(); err != nil {
+		return err
+	}
+
+	if delay <= 0 {
+		return b.Push(queue, msg)
+	}
+
+	assignID(&msg) // 沒有 ID 的延遲消息無法以 CancelScheduled 取消
+	msg.Queue = queue
+	return b.schedule(queue, msg, b.clock.Now().Add(delay), true, false)
+}
+
+// schedule 將消息排程在 fireAt 投遞，persist 為 true 時先寫入 WAL (從 WAL 恢復時為 false)
+// retry 為 true 表示這是退避重試的消息，到期時與 Retry 一樣直接入隊而不做 ID 去重
+func (b *SimpleBroker) schedule(queue string, msg Message, fireAt time.Time, persist, retry bool) error {
+	b.scheduleMu.Lock()
+	defer b.scheduleMu.Unlock()
+
+	entries, exists := b.scheduled[queue]
+	if !exists {
+		entries = make(map[string]*scheduledEntry)
+		b.scheduled[queue] = entries
+	}
+	if _, exists := entries[msg.ID]; exists {
+		return fmt.Errorf("message %s is already scheduled on queue %s", msg.ID, queue)
+	}
+
+	// 延遲消息也寫入 WAL，重啟後會以原本的投遞時間重新排程
+	if persist {
+		if err := b.journalRecord(walRecord{Op: walOpDelay, Queue: queue, FireAt: fireAt}, &msg); err != nil {
+			b.logOp("push_delayed", queue, msg.ID, opResult(err))
+			return fmt.Errorf("failed to persist delayed message %s: %w", msg.ID, err)
+		}
+	}
+
+	entry := &scheduledEntry{msg: msg, fireAt: fireAt, retry: retry}
+	entry.timer = b.clock.AfterFunc(fireAt.Sub(b.clock.Now()), func() {
+		b.fireScheduled(queue, entry)
+	})
+	entries[msg.ID] = entry
+
+	b.logOp("push_delayed", queue, msg.ID, OpResultOK)
+	return nil
+}
+
+// fireScheduled 在延遲到期時將消息推送到隊列
+// 只有仍在排程表中的消息才會被投遞，因此與 CancelScheduled 並發時不會重複或誤投
+func (b *SimpleBroker) fireScheduled(queue string, entry *scheduledEntry) {
+	b.scheduleMu.Lock()
+	current, exists := b.scheduled[queue][entry.msg.ID]
+	if !exists || current != entry {
+		b.scheduleMu.Unlock()
+		return // 已被取消
+	}
+	delete(b.scheduled[queue], entry.msg.ID)
+	b.scheduleMu.Unlock()
+
+	// 延遲消息在 PushDelayed 時已被接受，到期時經內部路徑入隊而不再套用速率限制，
+	// 否則 token 不足時消息會同時從排程表與隊列中消失
+	if !entry.retry && b.dropDuplicate("push_delayed", queue, entry.msg) {
+		b.journalConsume(entry.msg)
+		return
+	}
+	if err := b.push(queue, entry.msg); err != nil {
+		if !entry.retry {
+			b.releaseDuplicate(queue, entry.msg)
+		}
+		b.logOp("push_delayed", queue, entry.msg.ID, opResult(err))
+		logrus.WithFields(logrus.Fields{
+			"queue": queue,
+			"msgID": entry.msg.ID,
+		}).WithError(err).Warn("⚠️ 延遲消息到期投遞失敗")
+	}
+}
+
+// GetScheduled 返回指定隊列中所有等待投遞的延遲消息，依投遞時間排序
+func (b *SimpleBroker) GetScheduled(queue string) []ScheduledMessage {
+	b.scheduleMu.Lock()
+	defer b.scheduleMu.Unlock()
+
+	result := make([]ScheduledMessage, 0, len(b.scheduled[queue]))
+	for _, entry := range b.scheduled[queue] {
+		result = append(result, ScheduledMessage{Message: entry.msg, FireAt: entry.fireAt})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FireAt.Before(result[j].FireAt)
+	})
+	return result
+}
+
+// CancelScheduled 取消一條尚未投遞的延遲消息
+// 若消息已經投遞或不存在，返回 ErrScheduledMessageNotFound
+func (b *SimpleBroker) CancelScheduled(queue, msgID string) error {
+	b.scheduleMu.Lock()
+	defer b.scheduleMu.Unlock()
+
+	entry, exists := b.scheduled[queue][msgID]
+	if !exists {
+		return fmt.Errorf("%w: %s on queue %s", ErrScheduledMessageNotFound, msgID, queue)
+	}
+
+	delete(b.scheduled[queue], msgID)
+	entry.timer.Stop()
+	b.journalConsume(entry.msg)
+	b.logOp("cancel_scheduled", queue, msgID, OpResultOK)
+	return nil
+}
+
+// stopScheduled 停止所有排程中的計時器 (於 Close 時呼叫)
+func (b *SimpleBroker) stopScheduled() {
+	b.scheduleMu.Lock()
+	defer b.scheduleMu.Unlock()
+
+	for queue, entries := range b.scheduled {
+		for _, entry := range entries {
+			entry.timer.Stop()
+		}
+		delete(b.scheduled, queue)
+	}
+}
@@ -0,0 +1,126 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPushDelayedFiresAfterDelay(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	queueName := "delayed-queue"
+	msg := NewMessage("delayed-1", []byte("later"), queueName)
+	if err := broker.PushDelayed(queueName, msg, 30*time.Millisecond); err != nil {
+		t.Fatalf("PushDelayed failed: %v", err)
+	}
+
+	// 到期前不可拉取
+	if pulled, _ := broker.Pull(queueName); pulled != nil {
+		t.Fatal("Expected message to be unavailable before the delay elapses")
+	}
+
+	// 隊列在消息到期投遞時才會建立
+	time.Sleep(60 * time.Millisecond)
+	pulled, err := broker.Pull(queueName)
+	if err != nil || pulled == nil || pulled.ID != msg.ID {
+		t.Fatalf("Expected delayed message after delay, got %v (err=%v)", pulled, err)
+	}
+
+	if len(broker.GetScheduled(queueName)) != 0 {
+		t.Error("Expected no scheduled messages after firing")
+	}
+}
+
+func TestGetAndCancelScheduled(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	queueName := "cancel-queue"
+	broker.PushDelayed(queueName, NewMessage("later", []byte("x"), queueName), 200*time.Millisecond)
+	broker.PushDelayed(queueName, NewMessage("sooner", []byte("x"), queueName), 50*time.Millisecond)
+
+	scheduled := broker.GetScheduled(queueName)
+	if len(scheduled) != 2 {
+		t.Fatalf("Expected 2 scheduled messages, got %d", len(scheduled))
+	}
+	if scheduled[0].Message.ID != "sooner" || scheduled[1].Message.ID != "later" {
+		t.Errorf("Expected scheduled messages ordered by fire time, got %s, %s", scheduled[0].Message.ID, scheduled[1].Message.ID)
+	}
+	if !scheduled[0].FireAt.Before(scheduled[1].FireAt) {
+		t.Error("Expected fire times to be ascending")
+	}
+
+	// 取消兩條消息，之後都不應被投遞
+	if err := broker.CancelScheduled(queueName, "sooner"); err != nil {
+		t.Errorf("CancelScheduled failed: %v", err)
+	}
+	if err := broker.CancelScheduled(queueName, "later"); err != nil {
+		t.Errorf("CancelScheduled failed: %v", err)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if queues := broker.GetAllQueues(); len(queues) != 0 {
+		t.Errorf("Expected cancelled messages never to fire, but queues exist: %v", queues)
+	}
+
+	// 重複取消返回 ErrScheduledMessageNotFound
+	if err := broker.CancelScheduled(queueName, "sooner"); !errors.Is(err, ErrScheduledMessageNotFound) {
+		t.Errorf("Expected ErrScheduledMessageNotFound, got %v", err)
+	}
+}
+
+func TestCancelScheduledRacesWithFire(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	queueName := "race-queue"
+	const n = 100
+	for i := 0; i < n; i++ {
+		broker.PushDelayed(queueName, NewMessage(fmt.Sprintf("race-%d", i), []byte("x"), queueName), time.Millisecond)
+	}
+
+	// 與計時器並發取消：每條消息最多只會被投遞或取消其中之一
+	var wg sync.WaitGroup
+	cancelled := make(chan string, n)
+	for _, s := range broker.GetScheduled(queueName) {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if broker.CancelScheduled(queueName, id) == nil {
+				cancelled <- id
+			}
+		}(s.Message.ID)
+	}
+	wg.Wait()
+	close(cancelled)
+	time.Sleep(20 * time.Millisecond)
+
+	delivered := 0
+	if stats, err := broker.GetQueueStats(queueName); err == nil {
+		delivered = int(stats.EnqueuedTotal)
+	}
+	if delivered+len(cancelled) != n {
+		t.Errorf("Expected delivered (%d) + cancelled (%d) == %d", delivered, len(cancelled), n)
+	}
+}
+
+func TestScheduledCancelledOnClose(t *testing.T) {
+	broker := NewSimpleBroker()
+
+	queueName := "close-queue"
+	broker.PushDelayed(queueName, NewMessage("pending", []byte("x"), queueName), 20*time.Millisecond)
+	broker.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := broker.GetQueueStats(queueName); err == nil {
+		t.Error("Expected scheduled message to be dropped when broker closes")
+	}
+
+	if err := broker.PushDelayed(queueName, NewMessage("late", nil, queueName), time.Second); err == nil {
+		t.Error("Expected error when scheduling on a closed broker")
+	}
+}
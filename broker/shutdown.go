@@ -0,0 +1,107 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownPollInterval 是 Shutdown 檢查隊列是否已清空的間隔
+const shutdownPollInterval = 10 * time.Millisecond
+
+// ShutdownError 表示 Shutdown 在期限到達時仍有隊列未清空，Broker 仍已關閉
+type ShutdownError struct {
+	Pending map[string]int64 // 隊列名稱 -> 期限到達時尚未消費或確認的消息數
+	Err     error            // context 的錯誤
+}
+
+// Error 實作 error 介面
+func (e *ShutdownError) Error() string {
+	names := make([]string, 0, len(e.Pending))
+	for name := range e.Pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pending := make([]string, len(names))
+	for i, name := range names {
+		pending[i] = fmt.Sprintf("%s (%d)", name, e.Pending[name])
+	}
+	return fmt.Sprintf("shutdown did not drain queues: %v, pending: %s", e.Err, strings.Join(pending, ", "))
+}
+
+// Unwrap 返回 context 的錯誤，讓呼叫者可以用 errors.Is 判斷是逾時還是取消
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// acceptingPushes 在 Broker 已關閉或正在 Shutdown 時返回錯誤
+func (b *SimpleBroker) acceptingPushes() error {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return ErrBrokerClosed
+	}
+	if atomic.LoadInt32(&b.draining) == 1 {
+		return fmt.Errorf("broker is shutting down")
+	}
+	return nil
+}
+
+// Shutdown 優雅地關閉 Broker：停止接受新的 Push，但繼續提供 Pull 直到所有隊列清空
+// (at-least-once 模式下也等待已投遞的消息被確認)，或 ctx 到期，然後關閉 Broker
+//
+// 尚未到期的延遲消息不會再被投遞。期限到達時仍有消息的隊列以 *ShutdownError 返回。
+func (b *SimpleBroker) Shutdown(ctx context.Context) error {
+	return b.shutdown(ctx, b.Close)
+}
+
+// shutdown 等待隊列清空後呼叫 closeFn，讓 PersistentBroker 能同時關閉 WAL
+func (b *SimpleBroker) shutdown(ctx context.Context, closeFn func() error) error {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return fmt.Errorf("broker is already closed")
+	}
+	if !atomic.CompareAndSwapInt32(&b.draining, 0, 1) {
+		return fmt.Errorf("broker is already shutting down")
+	}
+	b.stopScheduled()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	var drainErr error
+	for {
+		pending := b.pendingMessages()
+		if len(pending) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			drainErr = &ShutdownError{Pending: pending, Err: ctx.Err()}
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+
+	if err := closeFn(); err != nil {
+		return err
+	}
+	return drainErr
+}
+
+// pendingMessages 返回仍有消息待消費或待確認的隊列及其消息數
+func (b *SimpleBroker) pendingMessages() map[string]int64 {
+	pending := make(map[string]int64)
+	b.queues.Range(func(key, value interface{}) bool {
+		mq := value.(*messageQueue)
+		count := atomic.LoadInt64(&mq.stats.MessageCount) + atomic.LoadInt64(&mq.stats.InFlightCount)
+		if count > 0 {
+			pending[key.(string)] = count
+		}
+		return true
+	})
+	return pending
+}
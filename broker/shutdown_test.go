@@ -0,0 +1,117 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsQueues(t *testing.T) {
+	broker := NewSimpleBroker()
+
+	for i := 0; i < 20; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+	}
+
+	// 消費者在 Shutdown 期間持續拉取，直到 Broker 關閉
+	var mu sync.Mutex
+	var received []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			msg, err := broker.PullWithTimeout("test", 50*time.Millisecond)
+			if err != nil {
+				return
+			}
+			if msg != nil {
+				mu.Lock()
+				received = append(received, msg.ID)
+				mu.Unlock()
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := broker.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 20 {
+		t.Errorf("Expected all 20 messages to be drained, got %d", len(received))
+	}
+	if broker.IsHealthy() {
+		t.Error("Expected broker to be closed after Shutdown")
+	}
+}
+
+func TestShutdownRejectsNewPushes(t *testing.T) {
+	broker := NewSimpleBroker()
+	broker.Push("test", NewMessage("msg-1", nil, "test"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := make(chan error, 1)
+	go func() { result <- broker.Shutdown(ctx) }()
+
+	// 等待 Shutdown 開始後，新的 Push 應被拒絕，但 Pull 仍然可用
+	deadline := time.Now().Add(time.Second)
+	for broker.Push("test", NewMessage("late", nil, "test")) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected Push to be rejected during Shutdown")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	msg, err := broker.Pull("test")
+	if err != nil || msg == nil {
+		t.Fatalf("Expected Pull to work during Shutdown, got %v, %v", msg, err)
+	}
+
+	// 拉取的可能是在 Shutdown 開始前推送的 "late"，將剩餘消息取完
+	for {
+		msg, _ := broker.Pull("test")
+		if msg == nil {
+			break
+		}
+	}
+	if err := <-result; err != nil {
+		t.Errorf("Expected Shutdown to succeed after queues drained, got %v", err)
+	}
+}
+
+func TestShutdownDeadlineReportsPendingQueues(t *testing.T) {
+	broker := NewSimpleBroker()
+	broker.Push("a", NewMessage("msg-1", nil, "a"))
+	broker.Push("a", NewMessage("msg-2", nil, "a"))
+	broker.Push("b", NewMessage("msg-3", nil, "b"))
+	broker.Push("empty", NewMessage("msg-4", nil, "empty"))
+	broker.Pull("empty")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := broker.Shutdown(ctx)
+
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) {
+		t.Fatalf("Expected *ShutdownError, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error to wrap context.DeadlineExceeded, got %v", err)
+	}
+	if len(shutdownErr.Pending) != 2 || shutdownErr.Pending["a"] != 2 || shutdownErr.Pending["b"] != 1 {
+		t.Errorf("Expected pending a=2 b=1, got %v", shutdownErr.Pending)
+	}
+	if broker.IsHealthy() {
+		t.Error("Expected broker to be closed even when the deadline is reached")
+	}
+	if err := broker.Shutdown(context.Background()); err == nil {
+		t.Error("Expected second Shutdown to fail")
+	}
+}
@@ -0,0 +1,49 @@
+package broker
+
+import "time"
+
+// Snapshot 是 Broker 在某一時刻的完整狀態，用於事故排查時一次匯出
+type Snapshot struct {
+	TakenAt     time.Time              `json:"taken_at"`
+	Queues      map[string]*QueueStats `json:"queues"`       // 每個隊列的統計
+	DeadLetters map[string][]Message   `json:"dead_letters"` // 每個死信隊列的消息 (包含以 TopicDLQName 為名的訂閱者溢出)
+	Subscribers map[string]int         `json:"subscribers"`  // 每個主題的訂閱者數 (含過濾訂閱者與消費者組成員)
+	Metrics     map[string]interface{} `json:"metrics"`      // 全域指標，同 Metrics.GetStats
+}
+
+// Snapshot 匯出所有隊列統計、死信消息、訂閱者數與全域指標
+// 各部分分別取得，並非跨隊列的一致性快照，與之並行的操作可能只反映在其中一部分
+func (b *SimpleBroker) Snapshot() Snapshot {
+	snapshot := Snapshot{
+		TakenAt:     b.clock.Now(),
+		Queues:      b.GetAllQueueStats(),
+		DeadLetters: make(map[string][]Message),
+		Subscribers: make(map[string]int),
+		Metrics:     b.metrics.GetStats(),
+	}
+
+	b.deadLetters.Range(func(key, value interface{}) bool {
+		if messages := b.GetDLQ(key.(string)); len(messages) > 0 {
+			snapshot.DeadLetters[key.(string)] = messages
+		}
+		return true
+	})
+
+	b.subscribers.Range(func(key, value interface{}) bool {
+		snapshot.Subscribers[key.(string)] = value.(*subscriberManager).count()
+		return true
+	})
+	return snapshot
+}
+
+// count 返回主題目前的訂閱者數
+func (m *subscriberManager) count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := len(m.subscribers) + len(m.filtered)
+	for _, group := range m.groups {
+		n += len(group.members)
+	}
+	return n
+}
@@ -0,0 +1,29 @@
+package broker
+
+import "testing"
+
+func TestSnapshot(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("test", NewMessage("msg-1", nil, "test"))
+	broker.MoveToDLQ("test", NewMessage("dead-1", nil, "test"))
+	broker.Subscribe("events")
+	broker.SubscribeGroup("events", "workers")
+	broker.SubscribeFiltered("events", func(Message) bool { return true })
+
+	snapshot := broker.Snapshot()
+	if stats := snapshot.Queues["test"]; stats == nil || stats.MessageCount != 1 {
+		t.Errorf("Expected queue depth 1, got %+v", stats)
+	}
+	if dlq := snapshot.DeadLetters["test"]; len(dlq) != 1 || dlq[0].ID != "dead-1" {
+		t.Errorf("Expected dead letter dead-1, got %+v", dlq)
+	}
+	// 訂閱者數包含一般訂閱者、消費者組成員與過濾訂閱者
+	if got := snapshot.Subscribers["events"]; got != 3 {
+		t.Errorf("Expected 3 subscribers, got %d", got)
+	}
+	if got := snapshot.Metrics["active_consumers"]; got != int32(3) {
+		t.Errorf("Expected active_consumers 3, got %v", got)
+	}
+}
@@ -0,0 +1,55 @@
+package broker
+
+import "sync/atomic"
+
+// ResetQueueStats 將隊列的累計計數器歸零，但不刪除任何消息
+//
+// 用於壓測時量測新的時間窗口：入隊、出隊、死信、重複、過期、去重、淘汰、限流與死信過期計數以及等待時間統計歸零，
+// MessageCount (目前深度)、InFlightCount 與 ConsumerCount 反映即時狀態，保持不變。
+// 每個計數器各自以原子操作歸零，與之並行的操作可能落在歸零之前或之後。
+func (b *SimpleBroker) ResetQueueStats(queue string) error {
+	queueInterface, exists := b.queues.Load(queue)
+	if !exists {
+		return queueNotFound(queue)
+	}
+	queueInterface.(*messageQueue).stats.resetCounters()
+	b.logOp("reset_stats", queue, "", OpResultOK)
+	return nil
+}
+
+// resetCounters 將累計計數器與等待時間統計歸零
+func (s *QueueStats) resetCounters() {
+	atomic.StoreInt64(&s.EnqueuedTotal, 0)
+	atomic.StoreInt64(&s.DequeuedTotal, 0)
+	atomic.StoreInt64(&s.DeadLetterCount, 0)
+	atomic.StoreInt64(&s.DuplicateCount, 0)
+	atomic.StoreInt64(&s.ExpiredCount, 0)
+	atomic.StoreInt64(&s.DedupedCount, 0)
+	atomic.StoreInt64(&s.DLQEvictedCount, 0)
+	atomic.StoreInt64(&s.RateLimitedCount, 0)
+	atomic.StoreInt64(&s.DLQExpiredCount, 0)
+	s.latency.reset()
+}
+
+// reset 將等待時間統計歸零；r 為 nil 時不做任何事
+func (r *latencyRecorder) reset() {
+	if r == nil {
+		return
+	}
+	atomic.StoreInt64(&r.count, 0)
+	atomic.StoreInt64(&r.sum, 0)
+	atomic.StoreInt64(&r.max, 0)
+	for i := range r.counts {
+		atomic.StoreInt64(&r.counts[i], 0)
+	}
+}
+
+// ResetMetrics 將全域的累計計數器 (總消息數、已處理、失敗、訂閱者溢出與死信過期) 歸零
+// ActiveQueues 與 ActiveConsumers 反映即時狀態，保持不變；各隊列的計數以 ResetQueueStats 歸零
+func (m *Metrics) ResetMetrics() {
+	atomic.StoreInt64(&m.TotalMessages, 0)
+	atomic.StoreInt64(&m.ProcessedMessages, 0)
+	atomic.StoreInt64(&m.FailedMessages, 0)
+	atomic.StoreInt64(&m.SubscriberOverflows, 0)
+	atomic.StoreInt64(&m.DLQExpired, 0)
+}
@@ -0,0 +1,79 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestResetQueueStatsPreservesDepth(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{QueueBufferSize: 5})
+	defer broker.Close()
+
+	for i := 0; i < 7; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+	}
+	broker.Pull("test")
+
+	before, _ := broker.GetQueueStats("test")
+	if before.EnqueuedTotal == 0 || before.DequeuedTotal != 1 || before.DeadLetterCount != 2 {
+		t.Fatalf("Unexpected stats before reset: %+v", before)
+	}
+
+	if err := broker.ResetQueueStats("test"); err != nil {
+		t.Fatalf("ResetQueueStats failed: %v", err)
+	}
+
+	// 累計計數歸零，目前深度保持不變
+	after, _ := broker.GetQueueStats("test")
+	if after.EnqueuedTotal != 0 || after.DequeuedTotal != 0 || after.DeadLetterCount != 0 {
+		t.Errorf("Expected counters to be reset, got %+v", after)
+	}
+	if after.Latency.Count != 0 {
+		t.Errorf("Expected latency stats to be reset, got %d samples", after.Latency.Count)
+	}
+	if after.MessageCount != before.MessageCount {
+		t.Errorf("Expected message count %d to be preserved, got %d", before.MessageCount, after.MessageCount)
+	}
+	if len(broker.GetDLQ("test")) != 2 {
+		t.Errorf("Expected dead letters to be preserved, got %d", len(broker.GetDLQ("test")))
+	}
+
+	// 歸零後繼續累計
+	broker.Pull("test")
+	if stats, _ := broker.GetQueueStats("test"); stats.DequeuedTotal != 1 {
+		t.Errorf("Expected dequeued total 1 after reset, got %d", stats.DequeuedTotal)
+	}
+}
+
+func TestResetQueueStatsUnknownQueue(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	if err := broker.ResetQueueStats("missing"); !errors.Is(err, ErrQueueNotFound) {
+		t.Errorf("Expected ErrQueueNotFound, got %v", err)
+	}
+}
+
+func TestResetMetrics(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Subscribe("events")
+	for i := 0; i < 3; i++ {
+		broker.Push("test", NewMessage(fmt.Sprintf("msg-%d", i), nil, "test"))
+	}
+	broker.Pull("test")
+
+	metrics := broker.GetMetrics()
+	metrics.ResetMetrics()
+
+	stats := metrics.GetStats()
+	if stats["total_messages"] != int64(0) || stats["processed_messages"] != int64(0) || stats["failed_messages"] != int64(0) {
+		t.Errorf("Expected global counters to be reset, got %v", stats)
+	}
+	// 即時狀態保持不變
+	if stats["active_consumers"] != int32(1) || stats["active_queues"] != int32(1) {
+		t.Errorf("Expected gauges to be preserved, got %v", stats)
+	}
+}
@@ -0,0 +1,62 @@
+package broker
+
+import "sync/atomic"
+
+// SubscriberOverflowPolicy 決定 Publish 時訂閱者通道已滿如何處理消息
+type SubscriberOverflowPolicy int
+
+const (
+	SubscriberOverflowDrop       SubscriberOverflowPolicy = iota // 丟棄消息 (預設)，只計入指標
+	SubscriberOverflowDeadLetter                                 // 將消息寫入主題的死信隊列 (TopicDLQName)
+	SubscriberOverflowBlock                                      // 等待最多 SubscriberBlockTimeout，逾時後寫入主題的死信隊列
+)
+
+// topicDLQPrefix 區分主題與隊列的死信隊列，同名的主題與隊列 (例如 blocks) 不會共用死信
+const topicDLQPrefix = "topic:"
+
+// TopicDLQName 返回主題的死信隊列名稱，可用於 GetDLQ 等死信操作
+func TopicDLQName(topic string) string {
+	return topicDLQPrefix + topic
+}
+
+// sendToSubscriber 將消息送給一個訂閱者，通道已滿時依 SubscriberOverflow 處理
+// 呼叫者持有訂閱管理器的讀鎖，因此 Block 策略的等待時間會延遲同一主題的取消訂閱
+func (b *SimpleBroker) sendToSubscriber(topic string, ch chan Message, msg Message) {
+	select {
+	case ch <- msg:
+		return
+	default:
+	}
+
+	if b.config.SubscriberOverflow == SubscriberOverflowBlock && b.config.SubscriberBlockTimeout > 0 {
+		timer := b.clock.NewTimer(b.config.SubscriberBlockTimeout)
+		defer timer.Stop()
+		select {
+		case ch <- msg:
+			return
+		case <-timer.C():
+		case <-b.ctx.Done():
+		}
+	}
+	b.subscriberOverflow(topic, msg)
+}
+
+// subscriberOverflow 記錄一條因訂閱者跟不上而無法送達的消息
+// Drop 策略只計數，其餘策略同時寫入主題的死信隊列
+// 廣播消息沒有送達不是處理失敗，因此不計入 FailedMessages，也不影響同名隊列的統計
+func (b *SimpleBroker) subscriberOverflow(topic string, msg Message) {
+	atomic.AddInt64(&b.metrics.SubscriberOverflows, 1)
+	if b.config.SubscriberOverflow == SubscriberOverflowDrop {
+		b.logOp("publish_overflow", topic, msg.ID, OpResultOK)
+		return
+	}
+
+	dlq := TopicDLQName(topic)
+	msg.Queue = topic
+	if err := b.journal(walOpDLQ, dlq, &msg); err != nil {
+		b.logOp("publish_overflow", topic, msg.ID, opResult(err))
+		return
+	}
+	b.addDeadLetter(dlq, msg)
+	b.logOp("publish_overflow", topic, msg.ID, OpResultOK)
+}
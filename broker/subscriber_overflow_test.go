@@ -0,0 +1,192 @@
+package broker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fillSubscriber 發布足以填滿訂閱者緩衝區的消息
+func fillSubscriber(t *testing.T, broker *SimpleBroker, topic string) {
+	t.Helper()
+	for i := 0; i < subscriberBufferSize; i++ {
+		if err := broker.Publish(topic, NewMessage(fmt.Sprintf("fill-%d", i), nil, topic)); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+}
+
+func TestSubscriberOverflowDeadLetter(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{SubscriberOverflow: SubscriberOverflowDeadLetter})
+	defer broker.Close()
+
+	sub, _ := broker.Subscribe("events")
+	fillSubscriber(t, broker, "events")
+
+	// 緩衝區已滿，之後的消息進入主題的死信隊列
+	broker.Publish("events", NewMessage("overflow-1", nil, "events"))
+	broker.Publish("events", NewMessage("overflow-2", nil, "events"))
+
+	dlq := broker.GetDLQ(TopicDLQName("events"))
+	if len(dlq) != 2 || dlq[0].ID != "overflow-1" || dlq[1].ID != "overflow-2" {
+		t.Fatalf("Expected overflow messages in the topic DLQ, got %v", dlq)
+	}
+	if dlq[0].Queue != "events" {
+		t.Errorf("Expected dead letter queue name events, got %q", dlq[0].Queue)
+	}
+	if got := broker.GetMetrics().GetStats()["subscriber_overflows"]; got != int64(2) {
+		t.Errorf("Expected 2 subscriber overflows, got %v", got)
+	}
+	if got := len(drain(sub)); got != subscriberBufferSize {
+		t.Errorf("Expected subscriber to keep its %d buffered messages, got %d", subscriberBufferSize, got)
+	}
+}
+
+func TestSubscriberOverflowDropCountsOnly(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Subscribe("events")
+	fillSubscriber(t, broker, "events")
+	broker.Publish("events", NewMessage("dropped", nil, "events"))
+
+	if dlq := broker.GetDLQ(TopicDLQName("events")); len(dlq) != 0 {
+		t.Errorf("Expected drop policy not to dead-letter, got %v", dlq)
+	}
+	if got := broker.GetMetrics().GetStats()["subscriber_overflows"]; got != int64(1) {
+		t.Errorf("Expected dropped message to be counted, got %v", got)
+	}
+}
+
+func TestSubscriberOverflowBlockWaitsForSubscriber(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		SubscriberOverflow:     SubscriberOverflowBlock,
+		SubscriberBlockTimeout: time.Second,
+	})
+	defer broker.Close()
+
+	sub, _ := broker.Subscribe("events")
+	fillSubscriber(t, broker, "events")
+
+	// 訂閱者在逾時前取出一條消息，阻塞中的 Publish 得以送達
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-sub
+	}()
+	broker.Publish("events", NewMessage("waited", nil, "events"))
+
+	if dlq := broker.GetDLQ(TopicDLQName("events")); len(dlq) != 0 {
+		t.Errorf("Expected the blocked message to be delivered, got DLQ %v", dlq)
+	}
+	ids := drain(sub)
+	if len(ids) == 0 || ids[len(ids)-1] != "waited" {
+		t.Errorf("Expected the subscriber to receive the blocked message last, got %v", ids)
+	}
+}
+
+func TestSubscriberOverflowBlockTimesOutToDLQ(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{
+		SubscriberOverflow:     SubscriberOverflowBlock,
+		SubscriberBlockTimeout: 20 * time.Millisecond,
+	})
+	defer broker.Close()
+
+	broker.Subscribe("events")
+	fillSubscriber(t, broker, "events")
+
+	start := time.Now()
+	broker.Publish("events", NewMessage("timed-out", nil, "events"))
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected Publish to wait for the block timeout, returned after %v", elapsed)
+	}
+	if dlq := broker.GetDLQ(TopicDLQName("events")); len(dlq) != 1 || dlq[0].ID != "timed-out" {
+		t.Errorf("Expected timed-out message in the topic DLQ, got %v", dlq)
+	}
+}
+
+func TestSubscriberOverflowConsumerGroup(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{SubscriberOverflow: SubscriberOverflowDeadLetter})
+	defer broker.Close()
+
+	broker.SubscribeGroup("events", "workers")
+	fillSubscriber(t, broker, "events")
+	broker.Publish("events", NewMessage("group-overflow", nil, "events"))
+
+	if dlq := broker.GetDLQ(TopicDLQName("events")); len(dlq) != 1 || dlq[0].ID != "group-overflow" {
+		t.Errorf("Expected group overflow in the topic DLQ, got %v", dlq)
+	}
+}
+
+func TestSubscribeWithBufferOverflowsBySize(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{SubscriberOverflow: SubscriberOverflowDeadLetter})
+	defer broker.Close()
+
+	small, err := broker.SubscribeWithBuffer("small", 1)
+	if err != nil {
+		t.Fatalf("SubscribeWithBuffer failed: %v", err)
+	}
+	large, err := broker.SubscribeWithBuffer("large", 1000)
+	if err != nil {
+		t.Fatalf("SubscribeWithBuffer failed: %v", err)
+	}
+
+	// 相同的發布量下，緩衝大小 1 的訂閱者在第二條消息就溢出
+	const burst = 500
+	for i := 0; i < burst; i++ {
+		broker.Publish("small", NewMessage(fmt.Sprintf("s-%d", i), nil, "small"))
+		broker.Publish("large", NewMessage(fmt.Sprintf("l-%d", i), nil, "large"))
+	}
+
+	if got := len(drain(small)); got != 1 {
+		t.Errorf("Expected size-1 subscriber to hold 1 message, got %d", got)
+	}
+	if dlq := broker.GetDLQ(TopicDLQName("small")); len(dlq) != burst-1 || dlq[0].ID != "s-1" {
+		t.Errorf("Expected %d overflows starting at s-1, got %d", burst-1, len(dlq))
+	}
+	if got := len(drain(large)); got != burst {
+		t.Errorf("Expected size-1000 subscriber to receive all %d messages, got %d", burst, got)
+	}
+	if dlq := broker.GetDLQ(TopicDLQName("large")); len(dlq) != 0 {
+		t.Errorf("Expected no overflow for the size-1000 subscriber, got %d", len(dlq))
+	}
+}
+
+func TestSubscribeWithBufferRejectsNonPositiveSize(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	for _, size := range []int{0, -1} {
+		if _, err := broker.SubscribeWithBuffer("events", size); err == nil {
+			t.Errorf("Expected size %d to be rejected", size)
+		}
+	}
+	if got := broker.GetMetrics().ActiveConsumers; got != 0 {
+		t.Errorf("Expected rejected subscriptions not to count as consumers, got %d", got)
+	}
+}
+
+func TestSubscriberOverflowKeepsTopicDLQSeparateFromQueue(t *testing.T) {
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{SubscriberOverflow: SubscriberOverflowDeadLetter})
+	defer broker.Close()
+
+	// 主題與隊列同名 (如同區塊廣播主題與區塊隊列都叫 blocks)
+	broker.Push("blocks", NewMessage("block-1", nil, "blocks"))
+	sub, _ := broker.Subscribe("blocks")
+	defer broker.Unsubscribe("blocks", sub)
+	fillSubscriber(t, broker, "blocks")
+	broker.Publish("blocks", NewMessage("summary-overflow", nil, "blocks"))
+
+	if dlq := broker.GetDLQ("blocks"); len(dlq) != 0 {
+		t.Errorf("Expected queue DLQ untouched by topic overflow, got %v", dlq)
+	}
+	if dlq := broker.GetDLQ(TopicDLQName("blocks")); len(dlq) != 1 || dlq[0].ID != "summary-overflow" {
+		t.Errorf("Expected overflow in the topic DLQ, got %v", dlq)
+	}
+	stats, _ := broker.GetQueueStats("blocks")
+	if stats.DeadLetterCount != 0 {
+		t.Errorf("Expected queue DeadLetterCount 0, got %d", stats.DeadLetterCount)
+	}
+	if got := broker.GetMetrics().GetStats()["failed_messages"]; got != int64(0) {
+		t.Errorf("Expected topic overflow not to count as failed, got %v", got)
+	}
+}
@@ -0,0 +1,82 @@
+package broker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// throughputWindowSeconds 是 OpsPerSecond 的滾動窗口長度 (秒)
+const throughputWindowSeconds = 10
+
+// rateWindow 以每秒一格的環形計數器統計最近 N 秒的操作數
+// 共有 N+1 格：N 個完整的秒加上目前尚未結束的一秒
+// 每格以一個 uint64 同時保存秒數 (高 32 位) 與計數 (低 32 位)，
+// 因此記錄只需要一次 CAS，不需要鎖，也不會在換秒時遺失計數
+type rateWindow struct {
+	start time.Time
+	slots []atomic.Uint64
+}
+
+// newRateWindow 創建一個統計最近 seconds 秒、從 start 開始計時的滾動窗口
+func newRateWindow(seconds int, start time.Time) *rateWindow {
+	return &rateWindow{start: start, slots: make([]atomic.Uint64, seconds+1)}
+}
+
+// slot 返回某一秒對應的格子
+func (r *rateWindow) slot(sec int64) *atomic.Uint64 {
+	n := int64(len(r.slots))
+	return &r.slots[(sec%n+n)%n]
+}
+
+// record 記錄一次發生在 now 的操作
+func (r *rateWindow) record(now time.Time) {
+	sec := now.Unix()
+	tag := uint64(uint32(sec)) << 32
+	s := r.slot(sec)
+	for {
+		old := s.Load()
+		next := tag | 1
+		if old&^0xffffffff == tag {
+			next = old + 1 // 同一秒，累加計數
+		}
+		if s.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// rate 返回截至 now 為止滾動窗口內的平均每秒操作數
+// 窗口起點不早於開始計時的時間，因此剛啟動時不會因窗口未滿而低估
+func (r *rateWindow) rate(now time.Time) float64 {
+	sec := now.Unix()
+	n := int64(len(r.slots)) - 1
+
+	var total uint64
+	for k := int64(0); k <= n; k++ {
+		v := r.slot(sec - k).Load()
+		if uint32(v>>32) == uint32(sec-k) {
+			total += v & 0xffffffff
+		}
+	}
+
+	windowStart := time.Unix(sec-n, 0)
+	if windowStart.Before(r.start) {
+		windowStart = r.start
+	}
+	elapsed := now.Sub(windowStart).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(total) / elapsed
+}
+
+// RecordOp 記錄一次 Push 或 Pull 操作，用於計算 OpsPerSecond
+func (m *Metrics) RecordOp() {
+	m.ops.record(m.clock.Now())
+}
+
+// OpsPerSecond 返回最近 throughputWindowSeconds 秒內 Push 與 Pull 的平均每秒操作數
+// 這是 Broker 的吞吐量自我量測，可用於告警或在基準測試中檢查效能退化
+func (m *Metrics) OpsPerSecond() float64 {
+	return m.ops.rate(m.clock.Now())
+}
@@ -0,0 +1,54 @@
+package broker
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestOpsPerSecondSelfReport(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	// 連續 3 秒每秒 push + pull 各 100 次 = 每秒 200 次操作
+	for sec := 0; sec < 3; sec++ {
+		for i := 0; i < 100; i++ {
+			broker.Push("test", NewMessage(fmt.Sprintf("msg-%d-%d", sec, i), []byte("data"), "test"))
+			broker.Pull("test")
+		}
+		clock.Advance(time.Second)
+	}
+
+	if got := broker.GetMetrics().OpsPerSecond(); math.Abs(got-200) > 0.001 {
+		t.Errorf("Expected 200 ops/sec, got %.2f", got)
+	}
+
+	// 空轉超過滾動窗口後，舊的操作不再計入
+	clock.Advance(throughputWindowSeconds * time.Second)
+	if got := broker.GetMetrics().OpsPerSecond(); got != 0 {
+		t.Errorf("Expected 0 ops/sec after the window passed, got %.2f", got)
+	}
+
+	broker.Push("test", NewMessage("late", []byte("data"), "test"))
+	clock.Advance(time.Second)
+	want := 1.0 / throughputWindowSeconds
+	if got := broker.GetMetrics().OpsPerSecond(); math.Abs(got-want) > 0.001 {
+		t.Errorf("Expected %.2f ops/sec, got %.2f", want, got)
+	}
+}
+
+func TestRateWindowSlotReuse(t *testing.T) {
+	start := time.Unix(0, 0)
+	r := newRateWindow(2, start)
+
+	// 窗口 2 秒共 3 格，第 0 秒與第 3 秒落在同一格，第 3 秒的記錄應覆蓋而不是累加
+	r.record(start)
+	r.record(start)
+	r.record(start.Add(3 * time.Second))
+
+	if got := r.rate(start.Add(4 * time.Second)); got != 0.5 {
+		t.Errorf("Expected 0.5 ops/sec, got %.2f", got)
+	}
+}
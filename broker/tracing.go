@@ -0,0 +1,67 @@
+package broker
+
+import (
+	"context"
+	"maps"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName 是 Broker 建立 span 時使用的 instrumentation 名稱
+const tracerName = "github.com/YCLstock/transaction-watcher/broker"
+
+// tracePropagator 以 W3C Trace Context 格式 (traceparent / tracestate) 將追蹤上下文寫入消息 Headers
+var tracePropagator = propagation.TraceContext{}
+
+// ContextFromMessage 從消息 Headers 取出追蹤上下文，消費者可用它建立處理消息的子 span
+// 消息沒有追蹤上下文時返回 context.Background()
+func ContextFromMessage(msg Message) context.Context {
+	return tracePropagator.Extract(context.Background(), propagation.MapCarrier(msg.Headers))
+}
+
+// messagingAttributes 返回 span 的隊列與消息 ID 屬性
+func messagingAttributes(op, queue string, msg Message) trace.SpanStartEventOption {
+	return trace.WithAttributes(
+		attribute.String("messaging.operation", op),
+		attribute.String("messaging.destination.name", queue),
+		attribute.String("messaging.message.id", msg.ID),
+	)
+}
+
+// startPushSpan 為推送建立 producer span，並將追蹤上下文寫入消息 Headers
+// 消息已帶有追蹤上下文 (例如上游服務或重新推送的消息) 時延續原本的 trace，否則以 ctx 中的 span 為父 span。
+// 未設定 TracerProvider 時 span 無效，不會改動 Headers
+func (b *SimpleBroker) startPushSpan(ctx context.Context, op, queue string, msg *Message) trace.Span {
+	parent := tracePropagator.Extract(ctx, propagation.MapCarrier(msg.Headers))
+	ctx, span := b.tracer.Start(parent, op+" "+queue,
+		trace.WithSpanKind(trace.SpanKindProducer), messagingAttributes(op, queue, *msg))
+	if !span.SpanContext().IsValid() {
+		return span
+	}
+
+	// 複製 Headers 再寫入，避免改動呼叫者持有的 map
+	headers := make(map[string]string, len(msg.Headers)+2)
+	maps.Copy(headers, msg.Headers)
+	tracePropagator.Inject(ctx, propagation.MapCarrier(headers))
+	msg.Headers = headers
+	return span
+}
+
+// traceDequeue 為取出的消息建立 consumer span，作為推送 span 的子 span
+func (b *SimpleBroker) traceDequeue(op, queue string, msg Message) {
+	_, span := b.tracer.Start(ContextFromMessage(msg), op+" "+queue,
+		trace.WithSpanKind(trace.SpanKindConsumer), messagingAttributes(op, queue, msg))
+	span.End()
+}
+
+// endSpan 記錄操作結果並結束 span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,89 @@
+package broker
+
+import (
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracedBroker 創建使用記憶體 span exporter 的 Broker
+func newTracedBroker(t *testing.T) (*SimpleBroker, *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(t.Context()) })
+
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{TracerProvider: provider})
+	t.Cleanup(func() { broker.Close() })
+	return broker, exporter
+}
+
+// findSpan 依名稱找出已結束的 span
+func findSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("Expected span %q, got %v", name, exporter.GetSpans())
+	return tracetest.SpanStub{}
+}
+
+func TestPushAndPullSpansShareTrace(t *testing.T) {
+	broker, exporter := newTracedBroker(t)
+
+	if err := broker.Push("traced", NewMessage("msg-1", []byte("data"), "traced")); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	msg, err := broker.Pull("traced")
+	if err != nil || msg == nil {
+		t.Fatalf("Expected message, got %v (err %v)", msg, err)
+	}
+
+	push := findSpan(t, exporter, "push traced")
+	pull := findSpan(t, exporter, "pull traced")
+	if push.SpanKind != trace.SpanKindProducer || pull.SpanKind != trace.SpanKindConsumer {
+		t.Errorf("Expected producer and consumer spans, got %v and %v", push.SpanKind, pull.SpanKind)
+	}
+	if pull.SpanContext.TraceID() != push.SpanContext.TraceID() {
+		t.Errorf("Expected pull span in trace %s, got %s", push.SpanContext.TraceID(), pull.SpanContext.TraceID())
+	}
+	if pull.Parent.SpanID() != push.SpanContext.SpanID() {
+		t.Errorf("Expected pull span to be a child of the push span")
+	}
+
+	// 消費者可從消息延續同一個 trace
+	if got := trace.SpanContextFromContext(ContextFromMessage(*msg)).TraceID(); got != push.SpanContext.TraceID() {
+		t.Errorf("Expected message to carry trace %s, got %s", push.SpanContext.TraceID(), got)
+	}
+}
+
+func TestPushSpanDoesNotModifyCallerHeaders(t *testing.T) {
+	broker, _ := newTracedBroker(t)
+
+	msg := NewMessage("msg-1", []byte("data"), "traced")
+	broker.Push("traced", msg)
+
+	if _, exists := msg.Headers["traceparent"]; exists {
+		t.Error("Expected caller's headers to stay unchanged")
+	}
+}
+
+func TestTracingDisabledByDefault(t *testing.T) {
+	broker := NewSimpleBroker()
+	defer broker.Close()
+
+	broker.Push("untraced", NewMessage("msg-1", []byte("data"), "untraced"))
+	msg, _ := broker.Pull("untraced")
+	if msg == nil {
+		t.Fatal("Expected message")
+	}
+	if _, exists := msg.Headers["traceparent"]; exists {
+		t.Errorf("Expected no trace context without a tracer provider, got %v", msg.Headers)
+	}
+}
@@ -0,0 +1,39 @@
+package broker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// OpResultExpired 表示消息在投遞前已超過 TTL 而被丟棄
+const OpResultExpired = "expired"
+
+// NewMessageWithTTL 創建一條在入隊 ttl 之後過期的消息，過期的消息不會再被投遞
+func NewMessageWithTTL(id string, body []byte, queue string, ttl time.Duration) Message {
+	msg := NewMessage(id, body, queue)
+	msg.TTL = ttl
+	return msg
+}
+
+// expired 判斷消息在 now 時是否已超過 TTL (以入隊時間 Timestamp 起算)
+func (msg Message) expired(now time.Time) bool {
+	return msg.TTL > 0 && !now.Before(msg.Timestamp.Add(msg.TTL))
+}
+
+// expire 在消息已過期時將其丟棄 (或依 BrokerConfig.DeadLetterExpired 移入死信隊列) 並返回 true
+func (b *SimpleBroker) expire(mq *messageQueue, msg Message) bool {
+	if !msg.expired(b.clock.Now()) {
+		return false
+	}
+
+	atomic.AddInt64(&mq.stats.MessageCount, -1)
+	atomic.AddInt64(&mq.stats.ExpiredCount, 1)
+	b.logOp("expire", mq.name, msg.ID, OpResultExpired)
+
+	if b.config.DeadLetterExpired {
+		b.deadLetter(mq.name, msg)
+	} else {
+		b.journalConsume(msg)
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package broker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiredMessageNotDelivered(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	broker.Push("blocks", NewMessageWithTTL("stale", []byte("old"), "blocks", time.Second))
+	broker.Push("blocks", NewMessage("fresh", []byte("new"), "blocks"))
+
+	clock.Advance(time.Second)
+	msg, err := broker.Pull("blocks")
+	if err != nil || msg == nil || msg.ID != "fresh" {
+		t.Fatalf("Expected expired message to be skipped, got %v (err=%v)", msg, err)
+	}
+
+	stats, _ := broker.GetQueueStats("blocks")
+	if stats.ExpiredCount != 1 || stats.MessageCount != 0 || stats.DequeuedTotal != 1 {
+		t.Errorf("Expected 1 expired, 0 queued and 1 dequeued, got %+v", stats)
+	}
+	if dlq := broker.GetDLQ("blocks"); len(dlq) != 0 {
+		t.Errorf("Expected expired message to be dropped by default, got %d in DLQ", len(dlq))
+	}
+}
+
+func TestUnexpiredMessageDelivered(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithClock(clock)
+	defer broker.Close()
+
+	broker.Push("blocks", NewMessageWithTTL("msg-1", []byte("data"), "blocks", time.Second))
+	clock.Advance(999 * time.Millisecond)
+	if msg, _ := broker.Pull("blocks"); msg == nil || msg.ID != "msg-1" {
+		t.Errorf("Expected message within TTL to be delivered, got %v", msg)
+	}
+}
+
+func TestExpiredMessageToDLQ(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	broker := NewSimpleBrokerWithConfig(BrokerConfig{Clock: clock, DeadLetterExpired: true})
+	defer broker.Close()
+
+	broker.Push("blocks", NewMessageWithTTL("stale", []byte("old"), "blocks", time.Second))
+	clock.Advance(time.Minute)
+
+	if msg, _ := broker.Pull("blocks"); msg != nil {
+		t.Fatalf("Expected no delivery, got %s", msg.ID)
+	}
+	dlq := broker.GetDLQ("blocks")
+	if len(dlq) != 1 || dlq[0].ID != "stale" {
+		t.Errorf("Expected expired message in DLQ, got %v", dlq)
+	}
+}
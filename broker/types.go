@@ -1,6 +1,7 @@
 package broker
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +16,12 @@ type Message struct {
 	Attempts  int               `json:"attempts"`
 	MaxRetry  int               `json:"max_retry"`
 	Queue     string            `json:"queue"`
+	Priority  int               `json:"priority,omitempty"` // 只在優先級隊列中生效，越大越先投遞
+	ContentID string            `json:"content_id,omitempty"` // 由內容決定的 ID，用於 effectively-once 去重
+	DeliveryTag string          `json:"delivery_tag,omitempty"` // at-least-once 模式下的投遞標籤，用於 Ack/Nack
+	TTL       time.Duration     `json:"ttl,omitempty"` // 入隊後超過此時間仍未投遞則過期，0 表示永不過期
+
+	walSeq uint64 // 在 WAL 中對應的記錄序號，只用於 PersistentBroker
 }
 
 // Queue 表示一個消息隊列的統計信息
@@ -25,6 +32,26 @@ type QueueStats struct {
 	EnqueuedTotal  int64  `json:"enqueued_total"`
 	DequeuedTotal  int64  `json:"dequeued_total"`
 	DeadLetterCount int64  `json:"dead_letter_count"`
+	DuplicateCount int64  `json:"duplicate_count"` // 已處理過而被自動確認丟棄的重複投遞數
+	InFlightCount  int64  `json:"in_flight_count"` // 已投遞但尚未確認的消息數 (at-least-once 模式)
+	ExpiredCount   int64  `json:"expired_count"`   // 超過 TTL 而未被投遞的消息數
+	DedupedCount   int64  `json:"deduped_count"`   // Push 時因 ID 在去重窗口內重複而被丟棄的消息數
+	DLQEvictedCount int64 `json:"dlq_evicted_count"` // 死信隊列超過 MaxDLQSize 而被淘汰 (或拒絕) 的死信消息數
+	RateLimitedCount int64 `json:"rate_limited_count"` // Push 時因超過速率限制而被拒絕的消息數
+	DLQExpiredCount int64 `json:"dlq_expired_count"` // 超過 DLQRetention 而被清除的死信消息數
+	Capacity       int64  `json:"capacity"`        // 隊列緩衝大小，消息數達到此值後新消息進入死信隊列
+	Utilization    float64 `json:"utilization"`   // MessageCount / Capacity，接近 1 表示即將開始移入死信隊列
+	Latency        LatencyStats `json:"latency"`  // 消息從入隊到被拉取的等待時間
+
+	latency *latencyRecorder // 累計等待時間，快照時轉為 Latency
+}
+
+// utilization 計算隊列使用率，最大為 1 (消費者 Peek 暫存的一條消息可能讓消息數略超過緩衝大小)
+func utilization(count, capacity int64) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return min(float64(count)/float64(capacity), 1)
 }
 
 // Metrics 包含 Broker 的運行指標
@@ -35,9 +62,13 @@ type Metrics struct {
 	FailedMessages    int64 // 失敗消息數
 	ActiveQueues      int32 // 活躍隊列數
 	ActiveConsumers   int32 // 活躍消費者數
+	SubscriberOverflows int64 // Publish 時因訂閱者緩衝區已滿而無法送達的消息數
+	DLQExpired        int64 // 超過 DLQRetention 而被清除的死信消息數
 	StartTime         time.Time
 	mu                sync.RWMutex
 	QueueMetrics      map[string]*QueueStats
+	clock             Clock
+	ops               *rateWindow // Push 與 Pull 的滾動吞吐量
 }
 
 // IncrementTotalMessages 原子性地增加總消息數
@@ -66,7 +97,10 @@ func (m *Metrics) GetStats() map[string]interface{} {
 		"failed_messages":    atomic.LoadInt64(&m.FailedMessages),
 		"active_queues":      atomic.LoadInt32(&m.ActiveQueues),
 		"active_consumers":   atomic.LoadInt32(&m.ActiveConsumers),
-		"uptime_seconds":     time.Since(m.StartTime).Seconds(),
+		"subscriber_overflows": atomic.LoadInt64(&m.SubscriberOverflows),
+		"dlq_expired":        atomic.LoadInt64(&m.DLQExpired),
+		"uptime_seconds":     m.clock.Now().Sub(m.StartTime).Seconds(),
+		"ops_per_second":     m.OpsPerSecond(),
 		"queue_metrics":      m.copyQueueMetrics(),
 	}
 }
@@ -82,6 +116,16 @@ func (m *Metrics) copyQueueMetrics() map[string]*QueueStats {
 			EnqueuedTotal:   atomic.LoadInt64(&stats.EnqueuedTotal),
 			DequeuedTotal:   atomic.LoadInt64(&stats.DequeuedTotal),
 			DeadLetterCount: atomic.LoadInt64(&stats.DeadLetterCount),
+			DuplicateCount:  atomic.LoadInt64(&stats.DuplicateCount),
+			InFlightCount:   atomic.LoadInt64(&stats.InFlightCount),
+			ExpiredCount:    atomic.LoadInt64(&stats.ExpiredCount),
+			DedupedCount:    atomic.LoadInt64(&stats.DedupedCount),
+			RateLimitedCount: atomic.LoadInt64(&stats.RateLimitedCount),
+			DLQExpiredCount: atomic.LoadInt64(&stats.DLQExpiredCount),
+			DLQEvictedCount: atomic.LoadInt64(&stats.DLQEvictedCount),
+			Capacity:        stats.Capacity,
+			Utilization:     utilization(atomic.LoadInt64(&stats.MessageCount), stats.Capacity),
+			Latency:         stats.latency.snapshot(),
 		}
 	}
 	return result
@@ -91,27 +135,67 @@ func (m *Metrics) copyQueueMetrics() map[string]*QueueStats {
 type Broker interface {
 	// Queue 模式 (點對點)
 	Push(queue string, msg Message) error
+	PushContext(ctx context.Context, queue string, msg Message) error
+	PushWithResult(ctx context.Context, queue string, msg Message) (PushResult, error)
+	PushBatch(queue string, msgs []Message) error
 	Pull(queue string) (*Message, error)
 	PullWithTimeout(queue string, timeout time.Duration) (*Message, error)
+	PullContext(ctx context.Context, queue string) (*Message, error)
+	PullAny(queues []string) (*Message, string, error)
+	PullBatch(queue string, max int, timeout time.Duration) ([]*Message, error)
+	PullBatchContext(ctx context.Context, queue string, max int, timeout time.Duration) ([]*Message, error)
+	Peek(queue string) (*Message, error)
+	PushWithPriority(queue string, msg Message) error
+	
+	// 延遲投遞
+	PushDelayed(queue string, msg Message, delay time.Duration) error
+	GetScheduled(queue string) []ScheduledMessage
+	CancelScheduled(queue, msgID string) error
 	
 	// Pub/Sub 模式 (廣播)
 	Publish(topic string, msg Message) error
 	Subscribe(topic string) (<-chan Message, error)
+	SubscribeWithBuffer(topic string, size int) (<-chan Message, error)
+	SubscribeGroup(topic, group string) (<-chan Message, error)
+	SubscribeFiltered(topic string, predicate func(Message) bool) (<-chan Message, error)
+	SubscribeWithReplay(topic string, n int) (<-chan Message, error)
 	Unsubscribe(topic string, subscriber <-chan Message) error
 	
 	// Dead Letter Queue 處理
 	GetDLQ(queue string) []Message
+	GetDLQPage(queue string, offset, limit int) ([]Message, int, error)
 	MoveToDLQ(queue string, msg Message) error
 	ReprocessDLQ(queue string, msgID string) error
+	DrainDLQ(srcQueue, dstQueue string) (int, error)
+	
+	// 失敗重試與跨階段重試預算
+	Requeue(queue string, msg Message) error
+	Retry(queue string, msg Message, stage string, cause error) error
+	ChargeRetry(msg *Message, stage string, cause error) bool
+	
+	// 消息確認 (at-least-once) 與 effectively-once 投遞
+	Ack(queue, deliveryTag string) error
+	Nack(queue, deliveryTag string, requeue bool) error
+	MarkProcessed(queue string, msg Message) error
 	
 	// 管理和監控
+	DeclareQueue(name string, bufferSize int) error
+	RegisterConsumer(queue string) (consumerID string, release func())
 	GetQueueStats(queue string) (*QueueStats, error)
+	GetAllQueueStats() map[string]*QueueStats
+	QueuePressure(queue string) float64
+	GetDepthHistograms() map[string]DepthHistogram
 	GetMetrics() *Metrics
 	GetAllQueues() []string
 	PurgeQueue(queue string) error
+	DeleteQueue(queue string) error
+	ResetQueueStats(queue string) error
+	GetOpLog(limit int) []OpLogEntry
+	Snapshot() Snapshot
 	
 	// 生命周期管理
 	Close() error
+	Shutdown(ctx context.Context) error
 	IsHealthy() bool
 }
 
@@ -124,9 +208,17 @@ type SubscriberInfo struct {
 
 // NewMetrics 創建新的指標實例
 func NewMetrics() *Metrics {
+	return newMetricsWithClock(RealClock{})
+}
+
+// newMetricsWithClock 創建使用指定時間來源計算運行時間的指標實例
+func newMetricsWithClock(clock Clock) *Metrics {
+	start := clock.Now()
 	return &Metrics{
-		StartTime:    time.Now(),
+		StartTime:    start,
 		QueueMetrics: make(map[string]*QueueStats),
+		clock:        clock,
+		ops:          newRateWindow(throughputWindowSeconds, start),
 	}
 }
 
@@ -0,0 +1,210 @@
+package broker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WAL 記錄類型
+const (
+	walOpPush    = "push"    // 消息進入隊列
+	walOpDLQ     = "dlq"     // 消息進入死信隊列
+	walOpDelay   = "delay"   // 消息等待延遲投遞
+	walOpConsume = "consume" // 消息已被消費 (已確認或丟棄)
+)
+
+// walRecord 是 WAL 中的一行 JSON 記錄
+// Prev 指向被此記錄取代的舊記錄 (例如重試時的舊 push、移入死信隊列前的 push)，重放時舊記錄會被移除
+type walRecord struct {
+	Op    string   `json:"op"`
+	Seq   uint64   `json:"seq,omitempty"`
+	Prev  uint64   `json:"prev,omitempty"`
+	Queue string   `json:"queue,omitempty"`
+	Msg   *Message `json:"msg,omitempty"`
+
+	Priority bool      `json:"priority,omitempty"` // 消息所在的隊列為優先級隊列，重啟後以相同模式重建
+	FireAt   time.Time `json:"fire_at,omitempty"`  // 延遲消息的投遞時間
+}
+
+// wal 是 append-only 的預寫日誌，每行一筆 walRecord
+type wal struct {
+	mu   sync.Mutex
+	file *os.File
+	seq  uint64
+	sync bool // 每次寫入後 fsync
+}
+
+// openWAL 讀取既有的 WAL 並返回仍然存活的記錄 (依 seq 排序)
+// 讀取後會以存活記錄重寫 (壓縮) 檔案，再以附加模式開啟供後續寫入
+func openWAL(path string, syncWrites bool) (*wal, []walRecord, error) {
+	live, maxSeq, err := replayWAL(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := compactWAL(path, live); err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open wal %s: %w", path, err)
+	}
+	return &wal{file: file, seq: maxSeq, sync: syncWrites}, live, nil
+}
+
+// replayWAL 重放 WAL，返回存活的 push/dlq/delay 記錄與目前最大的 seq
+// 最後一行不完整 (寫入途中崩潰) 時忽略該行
+func replayWAL(path string) ([]walRecord, uint64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open wal %s: %w", path, err)
+	}
+	defer file.Close()
+
+	live := make(map[uint64]walRecord)
+	var maxSeq uint64
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var rec walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"path": path,
+				"line": line,
+			}).Warn("⚠️ WAL 記錄損毀，忽略之後的內容")
+			break
+		}
+
+		if rec.Prev != 0 {
+			delete(live, rec.Prev)
+		}
+		if rec.Seq > maxSeq {
+			maxSeq = rec.Seq
+		}
+		if rec.Op != walOpConsume && rec.Msg != nil {
+			rec.Prev = 0
+			live[rec.Seq] = rec
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read wal %s: %w", path, err)
+	}
+
+	records := make([]walRecord, 0, len(live))
+	for _, rec := range live {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+	return records, maxSeq, nil
+}
+
+// compactWAL 以存活記錄原子地重寫 WAL，避免檔案隨重啟次數無限增長
+func compactWAL(path string, live []walRecord) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create wal %s: %w", tmp, err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, rec := range live {
+		if err := encoder.Encode(rec); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to compact wal: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to compact wal: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync wal: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close wal: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace wal %s: %w", path, err)
+	}
+	return nil
+}
+
+// append 寫入一筆記錄，消費以外的記錄會被分配新的 seq 並返回
+func (w *wal) append(rec walRecord) (uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if rec.Op != walOpConsume {
+		w.seq++
+		rec.Seq = w.seq
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal wal record: %w", err)
+	}
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return 0, fmt.Errorf("failed to write wal: %w", err)
+	}
+	if w.sync {
+		if err := w.file.Sync(); err != nil {
+			return 0, fmt.Errorf("failed to sync wal: %w", err)
+		}
+	}
+	return rec.Seq, nil
+}
+
+// close 關閉 WAL 檔案
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// journal 在 WAL 中記錄消息進入隊列或死信隊列，並將新的 seq 記在消息上
+func (b *SimpleBroker) journal(op, queue string, msg *Message) error {
+	return b.journalRecord(walRecord{Op: op, Queue: queue}, msg)
+}
+
+// journalRecord 以 rec 為範本寫入 msg 的記錄，並將新的 seq 記在消息上
+func (b *SimpleBroker) journalRecord(rec walRecord, msg *Message) error {
+	if b.wal == nil {
+		return nil
+	}
+
+	entry := *msg
+	entry.DeliveryTag = ""
+	rec.Prev, rec.Msg = msg.walSeq, &entry
+	if queueInterface, exists := b.queues.Load(rec.Queue); exists {
+		rec.Priority = queueInterface.(*messageQueue).priority != nil
+	}
+	seq, err := b.wal.append(rec)
+	if err != nil {
+		return err
+	}
+	msg.walSeq = seq
+	return nil
+}
+
+// journalConsume 在 WAL 中記錄消息已被消費，重啟後不會再投遞
+func (b *SimpleBroker) journalConsume(msg Message) {
+	if b.wal == nil || msg.walSeq == 0 {
+		return
+	}
+	if _, err := b.wal.append(walRecord{Op: walOpConsume, Prev: msg.walSeq}); err != nil {
+		logrus.WithError(err).WithField("msgID", msg.ID).Warn("⚠️ 寫入 WAL 消費記錄失敗，重啟後可能重複投遞")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/sirupsen/logrus"
+)
+
+// codecHeader 記錄消息內容使用的編碼，消費端依此選擇解碼方式
+// 沒有此標頭的消息 (例如升級前寫入 WAL 的區塊) 視為 JSON
+const codecHeader = "codec"
+
+// 支援的編碼名稱
+const (
+	codecJSON = "json"
+	codecGob  = "gob"
+)
+
+// Codec 負責區塊隊列中消息內容的序列化
+// 偵測事件 (eventEnvelope) 會直接送到 webhook 與 /stream/deposits，固定使用 JSON
+type Codec interface {
+	Name() string
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte, v interface{}) error
+}
+
+// jsonCodec 以 JSON 編碼，方便除錯與外部工具讀取 (預設)
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return codecJSON }
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Decode(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// gobCodec 以 gob 編碼，比 JSON 更精簡，適合高吞吐量的部署
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return codecGob }
+
+func (gobCodec) Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// codecByName 返回指定名稱的編碼，空字串表示 JSON
+func codecByName(name string) (Codec, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", codecJSON:
+		return jsonCodec{}, nil
+	case codecGob:
+		return gobCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+}
+
+// codecFromEnv 依 MESSAGE_CODEC 選擇區塊消息的編碼，未設定或無法識別時使用 JSON
+func codecFromEnv() Codec {
+	codec, err := codecByName(os.Getenv("MESSAGE_CODEC"))
+	if err != nil {
+		logrus.WithError(err).Warn("⚠️ 未知的消息編碼，改用 JSON")
+		return jsonCodec{}
+	}
+	return codec
+}
+
+// codecFor 返回消息內容使用的編碼
+func codecFor(msg broker.Message) (Codec, error) {
+	return codecByName(msg.Headers[codecHeader])
+}
+
+// encodeMessage 以 codec 編碼內容並建立消息，標頭記錄使用的編碼
+func encodeMessage(codec Codec, id string, v interface{}, queue string) (broker.Message, error) {
+	body, err := codec.Encode(v)
+	if err != nil {
+		return broker.Message{}, fmt.Errorf("failed to encode message with %s: %w", codec.Name(), err)
+	}
+	msg := broker.NewMessage(id, body, queue)
+	msg.Headers[codecHeader] = codec.Name()
+	return msg, nil
+}
+
+// decodeMessage 依消息標頭記錄的編碼解碼內容
+func decodeMessage(msg broker.Message, v interface{}) error {
+	codec, err := codecFor(msg)
+	if err != nil {
+		return err
+	}
+	if err := codec.Decode(msg.Body, v); err != nil {
+		return fmt.Errorf("failed to decode message with %s: %w", codec.Name(), err)
+	}
+	return nil
+}
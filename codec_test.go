@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+func testBlockMessage() BlockMessage {
+	return BlockMessage{
+		BlockNumber: "12345",
+		BlockHash:   "0xabc",
+		Timestamp:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		TxCount:     2,
+		Transactions: []TransactionInfo{
+			{Hash: "0x1", To: "0xto", From: "unknown", Value: "100", GasPrice: "1"},
+			{Hash: "0x2", To: "0xto", From: "unknown", Value: "5", GasPrice: "1", Token: "0xtoken", LogIndex: 3},
+		},
+	}
+}
+
+func TestCodecsRoundTripBlockMessage(t *testing.T) {
+	for _, codec := range []Codec{jsonCodec{}, gobCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			want := testBlockMessage()
+			msg, err := encodeMessage(codec, "msg-1", want, blockQueueName)
+			if err != nil {
+				t.Fatalf("encodeMessage failed: %v", err)
+			}
+			if msg.Headers[codecHeader] != codec.Name() {
+				t.Errorf("Expected codec header %q, got %q", codec.Name(), msg.Headers[codecHeader])
+			}
+
+			// 解碼端依標頭選擇編碼
+			var got BlockMessage
+			if err := decodeMessage(msg, &got); err != nil {
+				t.Fatalf("decodeMessage failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestGobCodecIsSmallerThanJSON(t *testing.T) {
+	block := testBlockMessage()
+	for i := 0; i < 50; i++ {
+		block.Transactions = append(block.Transactions, block.Transactions[0])
+	}
+
+	jsonBody, _ := jsonCodec{}.Encode(block)
+	gobBody, _ := gobCodec{}.Encode(block)
+	if len(gobBody) >= len(jsonBody) {
+		t.Errorf("Expected gob encoding (%d bytes) to be smaller than JSON (%d bytes)", len(gobBody), len(jsonBody))
+	}
+}
+
+func TestDecodeMessageWithoutCodecHeader(t *testing.T) {
+	// 沒有編碼標頭的舊消息視為 JSON
+	body, _ := json.Marshal(testBlockMessage())
+	msg := broker.NewMessage("msg-1", body, blockQueueName)
+
+	var got BlockMessage
+	if err := decodeMessage(msg, &got); err != nil {
+		t.Fatalf("decodeMessage failed: %v", err)
+	}
+	if got.BlockNumber != "12345" {
+		t.Errorf("Expected block number 12345, got %s", got.BlockNumber)
+	}
+
+	msg.Headers[codecHeader] = "msgpack"
+	if err := decodeMessage(msg, &got); err == nil {
+		t.Error("Expected error for unknown codec header")
+	}
+}
+
+func TestCodecFromEnv(t *testing.T) {
+	t.Setenv("MESSAGE_CODEC", "GOB")
+	if got := codecFromEnv().Name(); got != codecGob {
+		t.Errorf("Expected gob codec, got %s", got)
+	}
+
+	t.Setenv("MESSAGE_CODEC", "bogus")
+	if got := codecFromEnv().Name(); got != codecJSON {
+		t.Errorf("Expected fallback to json codec, got %s", got)
+	}
+}
+
+func TestBlockFrameConvertsToJSON(t *testing.T) {
+	// /ws/blocks 的客戶端總是收到 JSON，不受區塊消息編碼影響
+	msg, _ := encodeMessage(gobCodec{}, "msg-1", testBlockMessage(), blocksTopicName)
+	frame, err := blockFrame(msg)
+	if err != nil {
+		t.Fatalf("blockFrame failed: %v", err)
+	}
+
+	var got BlockMessage
+	if err := json.Unmarshal(frame, &got); err != nil {
+		t.Fatalf("Expected JSON frame, got %q: %v", frame, err)
+	}
+	if !reflect.DeepEqual(got, testBlockMessage()) {
+		t.Errorf("Expected %+v, got %+v", testBlockMessage(), got)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// envInt 讀取整數型環境變數，未設定或格式錯誤時返回預設值
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logrus.WithField("key", key).WithError(err).Warn("⚠️ 環境變數格式錯誤，使用預設值")
+		return def
+	}
+	return n
+}
+
+// envDuration 讀取時間長度型環境變數 (例如 "30s"、"5m")，未設定或格式錯誤時返回預設值
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logrus.WithField("key", key).WithError(err).Warn("⚠️ 環境變數格式錯誤，使用預設值")
+		return def
+	}
+	return d
+}
+
+// envFloats 讀取逗號分隔的浮點數清單 (例如 "0,1,10,100")，未設定或格式錯誤時返回預設值
+func envFloats(key string, def []float64) []float64 {
+	parts := splitList(os.Getenv(key))
+	if len(parts) == 0 {
+		return def
+	}
+	values := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		f, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			logrus.WithField("key", key).WithError(err).Warn("⚠️ 環境變數格式錯誤，使用預設值")
+			return def
+		}
+		values = append(values, f)
+	}
+	return values
+}
+
+// envFloat 讀取浮點數型環境變數，未設定或格式錯誤時返回預設值
+func envFloat(key string, def float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		logrus.WithField("key", key).WithError(err).Warn("⚠️ 環境變數格式錯誤，使用預設值")
+		return def
+	}
+	return f
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// confirmations 讓偵測等待足夠的確認數後才轉發，未設定 CONFIRMATIONS 或設為 0 時為 nil
+var confirmations *confirmationTracker
+
+// confirmationTracker 追蹤鏈頭高度，將尚未達到確認數的偵測依區塊號暫存
+//
+// 區塊 N 中的偵測在鏈頭到達 N + depth 時才轉發。新的鏈頭由區塊監聽器送入，
+// worker 處理區塊時鏈頭可能已足夠高，此時偵測會直接轉發。
+type confirmationTracker struct {
+	depth uint64
+
+	mu      sync.Mutex
+	head    uint64              // 目前看到的最高區塊號
+	pending map[uint64][]func() // 區塊號 -> 等待確認的轉發函式
+}
+
+// newConfirmationTracker 創建確認數追蹤器，depth 為轉發前需要的確認數
+func newConfirmationTracker(depth uint64) *confirmationTracker {
+	return &confirmationTracker{depth: depth, pending: make(map[uint64][]func())}
+}
+
+// newConfirmationTrackerFromEnv 從 CONFIRMATIONS 讀取確認數，未設定或為 0 時關閉
+func newConfirmationTrackerFromEnv() *confirmationTracker {
+	depth := envInt("CONFIRMATIONS", 0)
+	if depth <= 0 {
+		return nil
+	}
+	return newConfirmationTracker(uint64(depth))
+}
+
+// confirmed 判斷區塊在目前鏈頭下是否已有足夠的確認數，呼叫者需持有鎖
+func (c *confirmationTracker) confirmed(blockNumber uint64) bool {
+	return c.head >= blockNumber+c.depth
+}
+
+// wait 返回 true 表示偵測已暫存，會在區塊達到確認數時呼叫 forward；
+// 返回 false 表示區塊已有足夠的確認數，呼叫者應直接轉發
+func (c *confirmationTracker) wait(blockNumber uint64, forward func()) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.confirmed(blockNumber) {
+		return false
+	}
+	c.pending[blockNumber] = append(c.pending[blockNumber], forward)
+	return true
+}
+
+// observe 記錄新的鏈頭高度，並依區塊順序轉發已達到確認數的偵測
+func (c *confirmationTracker) observe(head uint64) {
+	c.mu.Lock()
+	if head <= c.head {
+		c.mu.Unlock()
+		return
+	}
+	c.head = head
+
+	var blocks []uint64
+	for n := range c.pending {
+		if c.confirmed(n) {
+			blocks = append(blocks, n)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+
+	var release []func()
+	for _, n := range blocks {
+		release = append(release, c.pending[n]...)
+		delete(c.pending, n)
+	}
+	c.mu.Unlock()
+
+	if len(release) > 0 {
+		logrus.WithFields(logrus.Fields{
+			"head":     head,
+			"released": len(release),
+		}).Debug("✅ 偵測已達到確認數")
+	}
+	for _, forward := range release {
+		forward()
+	}
+}
+
+// status 返回目前的鏈頭高度與等待確認中的偵測數
+func (c *confirmationTracker) status() (uint64, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	held := 0
+	for _, forwards := range c.pending {
+		held += len(forwards)
+	}
+	return c.head, held
+}
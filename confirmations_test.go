@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+func TestConfirmationsDelayForwardingUntilDepth(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+	confirmations = newConfirmationTracker(3)
+	defer func() { confirmations = nil }()
+
+	h10 := newChainHeader(10, nil, 0)
+	observeHeader(h10)
+	processBlockMessage(BlockMessage{
+		BlockNumber:  "10",
+		BlockHash:    h10.Hash().Hex(),
+		Transactions: []TransactionInfo{{Hash: "0xdeposit", To: targetAddress, Value: "1"}},
+	}, 1)
+
+	// 鏈頭在 10 + 3 之前都不轉發
+	parent := h10
+	for n := int64(11); n <= 12; n++ {
+		parent = newChainHeader(n, parent, 0)
+		observeHeader(parent)
+		if got := forwardedTxHashes(t); len(got) != 0 {
+			t.Fatalf("Expected no forward at head %d, got %v", n, got)
+		}
+	}
+	if _, held := confirmations.status(); held != 1 {
+		t.Errorf("Expected 1 unconfirmed detection, got %d", held)
+	}
+
+	observeHeader(newChainHeader(13, parent, 0))
+	if got := forwardedTxHashes(t); len(got) != 1 || got[0] != "0xdeposit" {
+		t.Errorf("Expected deposit to be forwarded after 3 confirmations, got %v", got)
+	}
+	if _, held := confirmations.status(); held != 0 {
+		t.Errorf("Expected no unconfirmed detections left, got %d", held)
+	}
+}
+
+func TestConfirmationsForwardImmediatelyWhenAlreadyDeep(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+	confirmations = newConfirmationTracker(2)
+	defer func() { confirmations = nil }()
+
+	// worker 處理落後時，鏈頭可能早已超過確認數
+	confirmations.observe(20)
+	processBlockMessage(BlockMessage{
+		BlockNumber:  "15",
+		Transactions: []TransactionInfo{{Hash: "0xold", To: targetAddress, Value: "1"}},
+	}, 1)
+
+	if got := forwardedTxHashes(t); len(got) != 1 || got[0] != "0xold" {
+		t.Errorf("Expected already-confirmed deposit to be forwarded immediately, got %v", got)
+	}
+}
+
+func TestConfirmationsReleaseInBlockOrder(t *testing.T) {
+	c := newConfirmationTracker(1)
+	c.observe(5)
+
+	var order []int
+	c.wait(7, func() { order = append(order, 7) })
+	c.wait(6, func() { order = append(order, 6) })
+	c.wait(9, func() { order = append(order, 9) })
+
+	// 鏈頭跳到 8 時同時釋放區塊 6 與 7，區塊 9 仍等待
+	c.observe(8)
+	if len(order) != 2 || order[0] != 6 || order[1] != 7 {
+		t.Errorf("Expected blocks 6 and 7 released in order, got %v", order)
+	}
+	if _, held := c.status(); held != 1 {
+		t.Errorf("Expected block 9 to still be pending, got %d held", held)
+	}
+}
+
+func TestConfirmationsDropOrphanedAfterReorg(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+	confirmations = newConfirmationTracker(2)
+	reorgs = newReorgGuard(1)
+	defer func() { confirmations, reorgs = nil, nil }()
+
+	h1 := newChainHeader(1, nil, 0)
+	h2 := newChainHeader(2, h1, 0)
+	observeHeader(h1)
+	observeHeader(h2)
+	processBlockMessage(BlockMessage{
+		BlockNumber:  "2",
+		BlockHash:    h2.Hash().Hex(),
+		Transactions: []TransactionInfo{{Hash: "0xorphan", To: targetAddress, Value: "1"}},
+	}, 1)
+
+	// 區塊 2 被 2' 取代後鏈繼續延伸，確認時已不在 canonical 鏈上
+	h2b := newChainHeader(2, h1, 1)
+	h3 := newChainHeader(3, h2b, 0)
+	observeHeader(h2b)
+	observeHeader(h3)
+	observeHeader(newChainHeader(4, h3, 0))
+
+	if got := forwardedTxHashes(t); len(got) != 0 {
+		t.Errorf("Expected orphaned deposit to be dropped after confirmation, got %v", got)
+	}
+}
+
+func TestConfirmationTrackerFromEnv(t *testing.T) {
+	t.Setenv("CONFIRMATIONS", "")
+	if c := newConfirmationTrackerFromEnv(); c != nil {
+		t.Error("Expected confirmations to be disabled by default")
+	}
+
+	t.Setenv("CONFIRMATIONS", "12")
+	if c := newConfirmationTrackerFromEnv(); c == nil || c.depth != 12 {
+		t.Errorf("Expected depth 12, got %+v", c)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cursor 儲存最後一個已完整處理的區塊號碼，供重啟後補追 (backfill) 使用
+// 尚未寫入過的 cursor (全新啟動) 時 Get 返回 0 且不返回錯誤
+type Cursor interface {
+	Get() (uint64, error)
+	Set(block uint64) error
+}
+
+// fileCursor 將 cursor 存在本地檔案中
+type fileCursor struct {
+	path string
+	mu   sync.Mutex
+}
+
+// newFileCursor 創建以檔案為後端的 cursor
+func newFileCursor(path string) *fileCursor {
+	return &fileCursor{path: path}
+}
+
+// Get 讀取檔案中的區塊號碼，檔案不存在時返回 0
+func (c *fileCursor) Get() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cursor file: %w", err)
+	}
+
+	block, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor file content: %w", err)
+	}
+	return block, nil
+}
+
+// Set 以「寫入暫存檔再改名」的方式原子地更新區塊號碼
+func (c *fileCursor) Set(block uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".cursor-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cursor temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatUint(block, 10)); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cursor: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cursor: %w", err)
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// redisCursor 將 cursor 存在 Redis 中，讓多個實例可以共用
+type redisCursor struct {
+	client *redis.Client
+	key    string
+}
+
+// newRedisCursor 創建以 Redis 為後端的 cursor
+func newRedisCursor(client *redis.Client, key string) *redisCursor {
+	return &redisCursor{client: client, key: key}
+}
+
+// Get 讀取 Redis 中的區塊號碼，key 不存在時返回 0
+func (c *redisCursor) Get() (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	block, err := c.client.Get(ctx, c.key).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cursor from redis: %w", err)
+	}
+	return block, nil
+}
+
+// Set 將區塊號碼寫入 Redis
+func (c *redisCursor) Set(block uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.client.Set(ctx, c.key, block, 0).Err(); err != nil {
+		return fmt.Errorf("failed to write cursor to redis: %w", err)
+	}
+	return nil
+}
+
+// throttledCursor 包裝另一個 cursor，限制寫入頻率以避免每個區塊都寫一次
+// 讀取時返回最新的值 (包含尚未寫入的值)，Flush 會寫入最後一個待寫值
+type throttledCursor struct {
+	inner    Cursor
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	pending   uint64
+	dirty     bool
+	lastWrite time.Time
+}
+
+// newThrottledCursor 創建一個最多每 interval 寫入一次的 cursor
+func newThrottledCursor(inner Cursor, interval time.Duration) *throttledCursor {
+	return &throttledCursor{inner: inner, interval: interval, now: time.Now}
+}
+
+// Get 返回最新的區塊號碼
+func (c *throttledCursor) Get() (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dirty {
+		return c.pending, nil
+	}
+	return c.inner.Get()
+}
+
+// Set 記錄區塊號碼，距離上次寫入超過 interval 時才實際寫入
+func (c *throttledCursor) Set(block uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending = block
+	c.dirty = true
+	if c.now().Sub(c.lastWrite) < c.interval {
+		return nil
+	}
+	return c.flushLocked()
+}
+
+// Flush 立即寫入尚未寫入的區塊號碼
+func (c *throttledCursor) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flushLocked()
+}
+
+// flushLocked 在持有鎖的情況下寫入待寫值
+func (c *throttledCursor) flushLocked() error {
+	if !c.dirty {
+		return nil
+	}
+	if err := c.inner.Set(c.pending); err != nil {
+		return err
+	}
+	c.dirty = false
+	c.lastWrite = c.now()
+	return nil
+}
+
+// newCursorFromEnv 依 CURSOR_BACKEND (file / redis) 建立 cursor，未設定時返回 nil
+func newCursorFromEnv() (*throttledCursor, error) {
+	var inner Cursor
+	switch backend := os.Getenv("CURSOR_BACKEND"); backend {
+	case "", "none":
+		return nil, nil
+	case "file":
+		path := os.Getenv("CURSOR_FILE")
+		if path == "" {
+			path = "cursor.dat"
+		}
+		inner = newFileCursor(path)
+	case "redis":
+		addr := os.Getenv("REDIS_ADDR")
+		if addr == "" {
+			addr = "localhost:6379"
+		}
+		key := os.Getenv("CURSOR_KEY")
+		if key == "" {
+			key = "txwatcher:cursor"
+		}
+		inner = newRedisCursor(redis.NewClient(&redis.Options{Addr: addr}), key)
+	default:
+		return nil, fmt.Errorf("unknown cursor backend %q", backend)
+	}
+
+	return newThrottledCursor(inner, envDuration("CURSOR_FLUSH_INTERVAL", 5*time.Second)), nil
+}
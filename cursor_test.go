@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testCursorBackend 對任一 cursor 後端執行共同的測試
+func testCursorBackend(t *testing.T, c Cursor) {
+	t.Helper()
+
+	// 全新啟動時返回 0
+	block, err := c.Get()
+	if err != nil {
+		t.Fatalf("Get on fresh cursor failed: %v", err)
+	}
+	if block != 0 {
+		t.Errorf("Expected fresh cursor to be 0, got %d", block)
+	}
+
+	if err := c.Set(12345); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	block, err = c.Get()
+	if err != nil {
+		t.Fatalf("Get after Set failed: %v", err)
+	}
+	if block != 12345 {
+		t.Errorf("Expected cursor 12345, got %d", block)
+	}
+
+	if err := c.Set(12346); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if block, _ := c.Get(); block != 12346 {
+		t.Errorf("Expected cursor 12346 after overwrite, got %d", block)
+	}
+}
+
+func TestFileCursor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursor.dat")
+	testCursorBackend(t, newFileCursor(path))
+
+	// 新的實例 (模擬重啟) 能讀到同一個值
+	if block, _ := newFileCursor(path).Get(); block != 12346 {
+		t.Errorf("Expected persisted cursor 12346 after restart, got %d", block)
+	}
+}
+
+func TestFileCursorInvalidContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursor.dat")
+	os.WriteFile(path, []byte("not-a-number"), 0o644)
+
+	if _, err := newFileCursor(path).Get(); err == nil {
+		t.Error("Expected error for corrupted cursor file")
+	}
+}
+
+func TestRedisCursor(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	testCursorBackend(t, newRedisCursor(client, "test:cursor"))
+
+	if value, _ := server.Get("test:cursor"); value != "12346" {
+		t.Errorf("Expected redis key to hold 12346, got %q", value)
+	}
+}
+
+func TestThrottledCursor(t *testing.T) {
+	inner := newFileCursor(filepath.Join(t.TempDir(), "cursor.dat"))
+	c := newThrottledCursor(inner, time.Minute)
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	// 第一次寫入立即落地
+	c.Set(100)
+	if block, _ := inner.Get(); block != 100 {
+		t.Errorf("Expected first write to go through, got %d", block)
+	}
+
+	// 間隔內的寫入只更新記憶體中的值
+	now = now.Add(10 * time.Second)
+	c.Set(101)
+	c.Set(102)
+	if block, _ := inner.Get(); block != 100 {
+		t.Errorf("Expected throttled writes to be deferred, got %d", block)
+	}
+	if block, _ := c.Get(); block != 102 {
+		t.Errorf("Expected Get to return the latest value 102, got %d", block)
+	}
+
+	// 超過間隔後寫入
+	now = now.Add(time.Minute)
+	c.Set(103)
+	if block, _ := inner.Get(); block != 103 {
+		t.Errorf("Expected write after interval, got %d", block)
+	}
+
+	// Flush 寫入最後的待寫值
+	now = now.Add(time.Second)
+	c.Set(104)
+	c.Flush()
+	if block, _ := inner.Get(); block != 104 {
+		t.Errorf("Expected Flush to persist 104, got %d", block)
+	}
+}
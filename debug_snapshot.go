@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// handleDebugSnapshot 處理 GET /debug/snapshot
+// 將每個 Broker 的隊列統計、死信消息、訂閱者數與全域指標匯出為一份 JSON，供事故排查
+// 死信消息可能包含交易內容，因此與其他管理類端點一樣需要 API key
+func handleDebugSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	snapshots := make(map[string]broker.Snapshot)
+	for name, b := range allBrokers() {
+		snapshots[name] = b.Snapshot()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"taken_at": clock.Now(),
+		"brokers":  snapshots,
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+func TestHTTPDebugSnapshot(t *testing.T) {
+	blocks, alerts := withBrokerRegistry(t)
+	t.Setenv("API_KEY", "secret")
+
+	blocks.Push(blockQueueName, broker.NewMessage("block-1", []byte("{}"), blockQueueName))
+	blocks.Push(blockQueueName, broker.NewMessage("block-2", []byte("{}"), blockQueueName))
+	alerts.Push(transactionQueueName, broker.NewMessage("tx-1", []byte("{}"), transactionQueueName))
+	alerts.MoveToDLQ(transactionQueueName, broker.NewMessage("tx-dead", []byte("{}"), transactionQueueName))
+	alerts.Subscribe(depositsTopicName)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/snapshot", nil)
+	req.Header.Set(apiKeyHeader, "secret")
+	rr := httptest.NewRecorder()
+	requireAPIKey(handleDebugSnapshot)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Brokers map[string]broker.Snapshot `json:"brokers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+
+	// 每個 Broker 的隊列深度、死信消息與訂閱者數都應反映在快照中
+	blockSnapshot := response.Brokers[brokerPurposeBlocks]
+	if stats := blockSnapshot.Queues[blockQueueName]; stats == nil || stats.MessageCount != 2 {
+		t.Errorf("Expected block queue depth 2, got %+v", stats)
+	}
+
+	alertSnapshot := response.Brokers[brokerPurposeAlerts]
+	if stats := alertSnapshot.Queues[transactionQueueName]; stats == nil || stats.MessageCount != 1 {
+		t.Errorf("Expected transaction queue depth 1, got %+v", stats)
+	}
+	dlq := alertSnapshot.DeadLetters[transactionQueueName]
+	if len(dlq) != 1 || dlq[0].ID != "tx-dead" {
+		t.Errorf("Expected dead letter tx-dead, got %+v", dlq)
+	}
+	if got := alertSnapshot.Subscribers[depositsTopicName]; got != 1 {
+		t.Errorf("Expected 1 deposits subscriber, got %d", got)
+	}
+	if got := alertSnapshot.Metrics["active_consumers"]; got != float64(1) {
+		t.Errorf("Expected active_consumers 1 in metrics, got %v", got)
+	}
+}
+
+func TestHTTPDebugSnapshotRequiresAPIKey(t *testing.T) {
+	withBrokerRegistry(t)
+	t.Setenv("API_KEY", "secret")
+
+	rr := httptest.NewRecorder()
+	requireAPIKey(handleDebugSnapshot)(rr, httptest.NewRequest(http.MethodGet, "/debug/snapshot", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without API key, got %d", rr.Code)
+	}
+}
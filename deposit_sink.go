@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// DepositSink 持久化偵測到的目標存款，供事後查詢
+type DepositSink interface {
+	Save(ctx context.Context, tx TransactionInfo) error
+}
+
+// noopDepositSink 不保存任何存款，是未設定 DEPOSIT_SINK_PATH 時的預設值
+type noopDepositSink struct{}
+
+// Save 直接返回
+func (noopDepositSink) Save(ctx context.Context, tx TransactionInfo) error { return nil }
+
+// jsonlDepositSink 將每筆存款以一行 JSON 附加到本地檔案
+type jsonlDepositSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// newJSONLDepositSink 開啟 (不存在時建立) 以附加模式寫入的 JSONL 檔案
+func newJSONLDepositSink(path string) (*jsonlDepositSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open deposit sink: %w", err)
+	}
+	return &jsonlDepositSink{file: file}, nil
+}
+
+// Save 將存款寫成一行 JSON，整行一次寫入，避免並行寫入時交錯
+func (s *jsonlDepositSink) Save(ctx context.Context, tx TransactionInfo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	line, err := json.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal deposit: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write deposit: %w", err)
+	}
+	return nil
+}
+
+// Close 關閉檔案
+func (s *jsonlDepositSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// readJSONLDeposits 讀取 JSONL 檔案中保存的所有存款，檔案不存在時返回空清單
+func readJSONLDeposits(path string) ([]TransactionInfo, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open deposit sink: %w", err)
+	}
+	defer file.Close()
+
+	var deposits []TransactionInfo
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var tx TransactionInfo
+		if err := json.Unmarshal(scanner.Bytes(), &tx); err != nil {
+			return deposits, fmt.Errorf("invalid deposit on line %d: %w", line, err)
+		}
+		deposits = append(deposits, tx)
+	}
+	if err := scanner.Err(); err != nil {
+		return deposits, fmt.Errorf("failed to read deposit sink: %w", err)
+	}
+	return deposits, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestJSONLDepositSinkWritesAndReadsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deposits.jsonl")
+	sink, err := newJSONLDepositSink(path)
+	if err != nil {
+		t.Fatalf("newJSONLDepositSink failed: %v", err)
+	}
+
+	want := []TransactionInfo{
+		{Hash: "0x01", To: targetAddress, Value: "1"},
+		{Hash: "0x02", To: targetAddress, Value: "2", Token: "0xtoken", LogIndex: 3},
+		{Hash: "0x03", To: targetAddress, Value: "3"},
+	}
+	for _, tx := range want {
+		if err := sink.Save(context.Background(), tx); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	sink.Close()
+
+	// 重新開啟後以附加模式繼續寫入
+	sink, err = newJSONLDepositSink(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	extra := TransactionInfo{Hash: "0x04", To: targetAddress, Value: "4"}
+	sink.Save(context.Background(), extra)
+	sink.Close()
+	want = append(want, extra)
+
+	got, err := readJSONLDeposits(path)
+	if err != nil {
+		t.Fatalf("readJSONLDeposits failed: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d deposits, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Deposit %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestJSONLDepositSinkConcurrentSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deposits.jsonl")
+	sink, err := newJSONLDepositSink(path)
+	if err != nil {
+		t.Fatalf("newJSONLDepositSink failed: %v", err)
+	}
+	defer sink.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sink.Save(context.Background(), TransactionInfo{Hash: generateMessageID(), To: targetAddress})
+		}()
+	}
+	wg.Wait()
+
+	// 每筆存款都是完整的一行
+	got, err := readJSONLDeposits(path)
+	if err != nil || len(got) != 50 {
+		t.Errorf("Expected 50 intact deposits, got %d (err %v)", len(got), err)
+	}
+}
+
+func TestReadJSONLDepositsMissingAndCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	if got, err := readJSONLDeposits(filepath.Join(dir, "missing.jsonl")); err != nil || len(got) != 0 {
+		t.Errorf("Expected no deposits for a missing file, got %v (err %v)", got, err)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.jsonl")
+	os.WriteFile(corrupt, []byte("{\"hash\":\"0x01\"}\nnot json\n"), 0o644)
+	got, err := readJSONLDeposits(corrupt)
+	if err == nil || len(got) != 1 {
+		t.Errorf("Expected an error after the first valid deposit, got %d deposits (err %v)", len(got), err)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// /deposits 分頁的預設與最大筆數
+const (
+	defaultDepositPageLimit = 100
+	maxDepositPageLimit     = 1000
+)
+
+// depositStore 是設定 DEPOSIT_DB_PATH 時的 SQLite 存款紀錄，未設定時為 nil
+var depositStore *sqliteDepositStore
+
+// depositSchema 建立存款紀錄表，同一筆交易 (含代幣轉帳的 log 索引) 只保存一次
+const depositSchema = `
+CREATE TABLE IF NOT EXISTS deposits (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	hash         TEXT    NOT NULL,
+	to_address   TEXT    NOT NULL,
+	from_address TEXT    NOT NULL,
+	value        TEXT    NOT NULL,
+	gas_price    TEXT    NOT NULL,
+	token        TEXT    NOT NULL DEFAULT '',
+	log_index    INTEGER NOT NULL DEFAULT 0,
+	detected_at  INTEGER NOT NULL,
+	UNIQUE (hash, log_index)
+);
+CREATE INDEX IF NOT EXISTS deposits_to_detected ON deposits (to_address, detected_at);
+CREATE INDEX IF NOT EXISTS deposits_detected ON deposits (detected_at);
+`
+
+// storedDeposit 是存款紀錄中的一筆存款及其偵測時間
+type storedDeposit struct {
+	TransactionInfo
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// depositQuery 是查詢存款紀錄的條件，空值表示不限制
+type depositQuery struct {
+	Address string
+	From    time.Time // 包含
+	To      time.Time // 不包含
+	Offset  int
+	Limit   int
+}
+
+// sqliteDepositStore 將存款保存在 SQLite 中，並支援依地址與時間範圍查詢
+type sqliteDepositStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// newSQLiteDepositStore 開啟 SQLite 資料庫，資料表不存在時建立
+// SQLite 驅動需要 cgo，以 CGO_ENABLED=0 建置時返回錯誤
+func newSQLiteDepositStore(path string) (*sqliteDepositStore, error) {
+	if !sqliteAvailable {
+		return nil, errors.New("deposit store requires a cgo build (CGO_ENABLED=1)")
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open deposit store: %w", err)
+	}
+	// SQLite 同時只允許一個寫入者，單一連線避免 database is locked
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(depositSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create deposit schema: %w", err)
+	}
+	return &sqliteDepositStore{db: db, now: func() time.Time { return clock.Now() }}, nil
+}
+
+// Save 以偵測時間保存存款，重複投遞的同一筆存款會被忽略
+func (s *sqliteDepositStore) Save(ctx context.Context, tx TransactionInfo) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO deposits (hash, to_address, from_address, value, gas_price, token, log_index, detected_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		tx.Hash, strings.ToLower(tx.To), tx.From, tx.Value, tx.GasPrice, tx.Token, tx.LogIndex, s.now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to save deposit: %w", err)
+	}
+	return nil
+}
+
+// Query 返回符合條件的存款 (依偵測時間排序) 與符合條件的總筆數
+func (s *sqliteDepositStore) Query(ctx context.Context, q depositQuery) ([]storedDeposit, int, error) {
+	var where []string
+	var args []interface{}
+	if q.Address != "" {
+		where = append(where, "to_address = ?")
+		args = append(args, strings.ToLower(q.Address))
+	}
+	if !q.From.IsZero() {
+		where = append(where, "detected_at >= ?")
+		args = append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "detected_at < ?")
+		args = append(args, q.To.UnixNano())
+	}
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM deposits"+filter, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count deposits: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT hash, to_address, from_address, value, gas_price, token, log_index, detected_at FROM deposits`+filter+
+			` ORDER BY detected_at, id LIMIT ? OFFSET ?`,
+		append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query deposits: %w", err)
+	}
+	defer rows.Close()
+
+	deposits := make([]storedDeposit, 0)
+	for rows.Next() {
+		var d storedDeposit
+		var detectedAt int64
+		if err := rows.Scan(&d.Hash, &d.To, &d.From, &d.Value, &d.GasPrice, &d.Token, &d.LogIndex, &detectedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan deposit: %w", err)
+		}
+		d.DetectedAt = time.Unix(0, detectedAt).UTC()
+		deposits = append(deposits, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query deposits: %w", err)
+	}
+	return deposits, total, nil
+}
+
+// Close 關閉資料庫
+func (s *sqliteDepositStore) Close() error {
+	return s.db.Close()
+}
+
+// parsePage 解析 offset 與 limit 查詢參數，limit 超過 maxLimit 時截斷
+func parsePage(r *http.Request, defaultLimit, maxLimit int) (offset, limit int, err error) {
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		offset, err = strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("invalid offset parameter")
+		}
+	}
+
+	limit = defaultLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return 0, 0, errors.New("invalid limit parameter")
+		}
+		limit = min(limit, maxLimit)
+	}
+	return offset, limit, nil
+}
+
+// parseQueryTime 解析 RFC 3339 時間或 Unix 秒數，空字串返回零值
+func parseQueryTime(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// handleDeposits 處理 GET /deposits?address=0x...&from=...&to=...&offset=N&limit=M
+// from (包含) 與 to (不包含) 可為 RFC 3339 時間或 Unix 秒數，count 為符合條件的總筆數
+func handleDeposits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if depositStore == nil {
+		http.Error(w, "deposit store is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	offset, limit, err := parsePage(r, defaultDepositPageLimit, maxDepositPageLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseQueryTime(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "invalid from parameter", http.StatusBadRequest)
+		return
+	}
+	to, err := parseQueryTime(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "invalid to parameter", http.StatusBadRequest)
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	deposits, total, err := depositStore.Query(r.Context(), depositQuery{
+		Address: address,
+		From:    from,
+		To:      to,
+		Offset:  offset,
+		Limit:   limit,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deposits": deposits,
+		"count":    total,
+		"offset":   offset,
+		"limit":    limit,
+	})
+}
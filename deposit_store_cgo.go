@@ -0,0 +1,8 @@
+//go:build cgo
+
+package main
+
+import _ "github.com/mattn/go-sqlite3"
+
+// sqliteAvailable 表示此建置包含 SQLite 驅動
+const sqliteAvailable = true
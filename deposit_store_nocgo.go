@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package main
+
+// sqliteAvailable 表示此建置包含 SQLite 驅動
+// go-sqlite3 需要 cgo，以 CGO_ENABLED=0 建置的靜態執行檔不支援 DEPOSIT_DB_PATH
+const sqliteAvailable = false
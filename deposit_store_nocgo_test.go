@@ -0,0 +1,14 @@
+//go:build !cgo
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteDepositStoreRequiresCgo(t *testing.T) {
+	if _, err := newSQLiteDepositStore(filepath.Join(t.TempDir(), "deposits.db")); err == nil {
+		t.Error("Expected an error opening the deposit store without cgo")
+	}
+}
@@ -0,0 +1,168 @@
+//go:build cgo
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// depositsResponse 是 /deposits 的回應
+type depositsResponse struct {
+	Deposits []storedDeposit `json:"deposits"`
+	Count    int             `json:"count"`
+	Offset   int             `json:"offset"`
+	Limit    int             `json:"limit"`
+}
+
+// withDepositStore 以暫存資料庫設定 depositStore，測試結束後還原
+func withDepositStore(t *testing.T) *sqliteDepositStore {
+	t.Helper()
+	store, err := newSQLiteDepositStore(filepath.Join(t.TempDir(), "deposits.db"))
+	if err != nil {
+		t.Fatalf("newSQLiteDepositStore failed: %v", err)
+	}
+	previous := depositStore
+	depositStore = store
+	t.Cleanup(func() {
+		depositStore = previous
+		store.Close()
+	})
+	return store
+}
+
+// queryDeposits 呼叫 /deposits 並解析回應
+func queryDeposits(t *testing.T, query string) depositsResponse {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handleDeposits(rr, httptest.NewRequest(http.MethodGet, "/deposits?"+query, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for %q, got %d: %s", query, rr.Code, rr.Body.String())
+	}
+	var resp depositsResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse deposits response: %v", err)
+	}
+	return resp
+}
+
+// depositHashes 返回存款的交易哈希
+func depositHashes(deposits []storedDeposit) []string {
+	hashes := make([]string, len(deposits))
+	for i, d := range deposits {
+		hashes[i] = d.Hash
+	}
+	return hashes
+}
+
+// seedDeposits 在 base 之後每分鐘保存一筆存款，偶數筆發往 addrA，奇數筆發往 addrB
+func seedDeposits(t *testing.T, store *sqliteDepositStore, base time.Time, addrA, addrB string, hashes ...string) {
+	t.Helper()
+	for i, hash := range hashes {
+		at := base.Add(time.Duration(i) * time.Minute)
+		store.now = func() time.Time { return at }
+		to := addrA
+		if i%2 == 1 {
+			to = addrB
+		}
+		if err := store.Save(context.Background(), TransactionInfo{Hash: hash, To: to, Value: "1"}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+}
+
+func TestDepositStoreQueryFiltersAndPaginates(t *testing.T) {
+	store := withDepositStore(t)
+	const addrA = "0x00000000000000000000000000000000000000Aa"
+	const addrB = "0x00000000000000000000000000000000000000bB"
+	base := time.Unix(1_700_000_000, 0)
+	seedDeposits(t, store, base, addrA, addrB, "0x1", "0x2", "0x3", "0x4", "0x5")
+
+	// 不加條件時返回全部，依偵測時間排序
+	all := queryDeposits(t, "")
+	if all.Count != 5 || len(all.Deposits) != 5 || all.Deposits[0].Hash != "0x1" || all.Deposits[4].Hash != "0x5" {
+		t.Errorf("Expected all 5 deposits in order, got count=%d %v", all.Count, depositHashes(all.Deposits))
+	}
+	if !all.Deposits[1].DetectedAt.Equal(base.Add(time.Minute)) {
+		t.Errorf("Expected detected_at %v, got %v", base.Add(time.Minute), all.Deposits[1].DetectedAt)
+	}
+
+	// 地址比對不分大小寫
+	byAddress := queryDeposits(t, "address=0x00000000000000000000000000000000000000AA")
+	if got := depositHashes(byAddress.Deposits); byAddress.Count != 3 || len(got) != 3 || got[0] != "0x1" || got[1] != "0x3" || got[2] != "0x5" {
+		t.Errorf("Expected deposits 0x1, 0x3, 0x5 for address A, got %v", got)
+	}
+
+	// 時間範圍：from 包含、to 不包含，可用 Unix 秒數或 RFC 3339
+	from := base.Add(time.Minute).Unix()
+	to := base.Add(3 * time.Minute).UTC().Format(time.RFC3339)
+	ranged := queryDeposits(t, "from="+strconv.FormatInt(from, 10)+"&to="+to)
+	if got := depositHashes(ranged.Deposits); ranged.Count != 2 || len(got) != 2 || got[0] != "0x2" || got[1] != "0x3" {
+		t.Errorf("Expected deposits 0x2 and 0x3 in range, got %v", got)
+	}
+
+	// 分頁：count 仍為符合條件的總數
+	page := queryDeposits(t, "offset=1&limit=2")
+	if got := depositHashes(page.Deposits); page.Count != 5 || page.Offset != 1 || page.Limit != 2 || len(got) != 2 || got[0] != "0x2" || got[1] != "0x3" {
+		t.Errorf("Expected page [0x2 0x3] of 5, got %v (count=%d offset=%d limit=%d)", got, page.Count, page.Offset, page.Limit)
+	}
+	last := queryDeposits(t, "address="+addrB+"&offset=1&limit=2")
+	if got := depositHashes(last.Deposits); last.Count != 2 || len(got) != 1 || got[0] != "0x4" {
+		t.Errorf("Expected last page [0x4] of 2 for address B, got %v (count=%d)", got, last.Count)
+	}
+	if beyond := queryDeposits(t, "offset=10"); beyond.Count != 5 || len(beyond.Deposits) != 0 {
+		t.Errorf("Expected an empty page beyond the end, got %v", depositHashes(beyond.Deposits))
+	}
+}
+
+func TestDepositStoreIgnoresDuplicatesAndReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deposits.db")
+	store, err := newSQLiteDepositStore(path)
+	if err != nil {
+		t.Fatalf("newSQLiteDepositStore failed: %v", err)
+	}
+	tx := TransactionInfo{Hash: "0xdup", To: targetAddress, Value: "1"}
+	store.Save(context.Background(), tx)
+	store.Save(context.Background(), tx)
+	store.Close()
+
+	// 資料表已存在時重新開啟不會失敗，且保留先前的資料
+	store, err = newSQLiteDepositStore(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+	deposits, total, err := store.Query(context.Background(), depositQuery{Limit: 10})
+	if err != nil || total != 1 || len(deposits) != 1 {
+		t.Errorf("Expected a single deduplicated deposit, got %d (total %d, err %v)", len(deposits), total, err)
+	}
+}
+
+func TestHTTPDepositsValidation(t *testing.T) {
+	previous := depositStore
+	depositStore = nil
+	rr := httptest.NewRecorder()
+	handleDeposits(rr, httptest.NewRequest(http.MethodGet, "/deposits", nil))
+	depositStore = previous
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without a deposit store, got %d", rr.Code)
+	}
+
+	withDepositStore(t)
+	for _, query := range []string{"offset=-1", "limit=0", "from=yesterday", "to=nope"} {
+		rr := httptest.NewRecorder()
+		handleDeposits(rr, httptest.NewRequest(http.MethodGet, "/deposits?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, rr.Code)
+		}
+	}
+	if page := queryDeposits(t, "limit=5000"); page.Limit != maxDepositPageLimit {
+		t.Errorf("Expected limit clamped to %d, got %d", maxDepositPageLimit, page.Limit)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// defaultDetectionWindow 是偵測索引預設保留的區塊數量
+const defaultDetectionWindow = 256
+
+// detectionIndex 以區塊號碼索引最近偵測到的目標交易
+// 只保留最近 window 個區塊，超出時淘汰最早寫入的區塊
+type detectionIndex struct {
+	mu     sync.RWMutex
+	window int
+	blocks map[uint64][]TransactionInfo
+	order  []uint64 // 區塊的寫入順序，用於淘汰
+}
+
+// newDetectionIndex 創建一個保留最近 window 個區塊的偵測索引
+func newDetectionIndex(window int) *detectionIndex {
+	if window <= 0 {
+		window = defaultDetectionWindow
+	}
+	return &detectionIndex{
+		window: window,
+		blocks: make(map[uint64][]TransactionInfo),
+		order:  make([]uint64, 0, window),
+	}
+}
+
+// Add 記錄一筆在指定區塊中偵測到的交易
+func (idx *detectionIndex) Add(block uint64, tx TransactionInfo) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, exists := idx.blocks[block]; !exists {
+		idx.order = append(idx.order, block)
+		// 超出視窗時淘汰最早的區塊
+		for len(idx.order) > idx.window {
+			delete(idx.blocks, idx.order[0])
+			idx.order = idx.order[1:]
+		}
+	}
+	idx.blocks[block] = append(idx.blocks[block], tx)
+}
+
+// GetDetectionsForBlock 返回指定區塊中偵測到的交易副本
+func (idx *detectionIndex) GetDetectionsForBlock(n uint64) []TransactionInfo {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	txs := idx.blocks[n]
+	result := make([]TransactionInfo, len(txs))
+	copy(result, txs)
+	return result
+}
+
+// handleDetections 處理 /detections 端點
+func handleDetections(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	blockParam := r.URL.Query().Get("block")
+	if blockParam == "" {
+		http.Error(w, "block parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	blockNumber, err := strconv.ParseUint(blockParam, 10, 64)
+	if err != nil {
+		http.Error(w, "block parameter must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	txs := detections.GetDetectionsForBlock(blockNumber)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"block":        blockNumber,
+		"transactions": txs,
+		"count":        len(txs),
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestDetectionIndexQueryByBlock(t *testing.T) {
+	idx := newDetectionIndex(10)
+
+	idx.Add(100, TransactionInfo{Hash: "0x1"})
+	idx.Add(100, TransactionInfo{Hash: "0x2"})
+	idx.Add(101, TransactionInfo{Hash: "0x3"})
+
+	txs := idx.GetDetectionsForBlock(100)
+	if len(txs) != 2 {
+		t.Fatalf("Expected 2 detections in block 100, got %d", len(txs))
+	}
+	if txs[0].Hash != "0x1" || txs[1].Hash != "0x2" {
+		t.Errorf("Unexpected detections for block 100: %+v", txs)
+	}
+
+	if txs := idx.GetDetectionsForBlock(101); len(txs) != 1 || txs[0].Hash != "0x3" {
+		t.Errorf("Unexpected detections for block 101: %+v", txs)
+	}
+
+	if txs := idx.GetDetectionsForBlock(999); len(txs) != 0 {
+		t.Errorf("Expected no detections for unknown block, got %d", len(txs))
+	}
+}
+
+func TestDetectionIndexEviction(t *testing.T) {
+	idx := newDetectionIndex(3)
+
+	for block := uint64(1); block <= 5; block++ {
+		idx.Add(block, TransactionInfo{Hash: fmt.Sprintf("0x%d", block)})
+	}
+
+	// 只應保留最近 3 個區塊
+	for _, evicted := range []uint64{1, 2} {
+		if txs := idx.GetDetectionsForBlock(evicted); len(txs) != 0 {
+			t.Errorf("Expected block %d to be evicted, got %d detections", evicted, len(txs))
+		}
+	}
+	for _, kept := range []uint64{3, 4, 5} {
+		if txs := idx.GetDetectionsForBlock(kept); len(txs) != 1 {
+			t.Errorf("Expected block %d to be retained, got %d detections", kept, len(txs))
+		}
+	}
+}
+
+func TestDetectionIndexConcurrentAccess(t *testing.T) {
+	idx := newDetectionIndex(50)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			idx.Add(uint64(i%100), TransactionInfo{Hash: fmt.Sprintf("0x%d", i)})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			idx.GetDetectionsForBlock(uint64(i % 100))
+		}
+	}()
+	wg.Wait()
+}
+
+func TestHTTPDetectionsEndpoint(t *testing.T) {
+	detections = newDetectionIndex(10)
+	detections.Add(42, TransactionInfo{Hash: "0xdeadbeef", To: targetAddress})
+
+	req := httptest.NewRequest("GET", "/detections?block=42", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handleDetections).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response struct {
+		Block        uint64            `json:"block"`
+		Transactions []TransactionInfo `json:"transactions"`
+		Count        int               `json:"count"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse JSON response: %v", err)
+	}
+
+	if response.Block != 42 || response.Count != 1 || response.Transactions[0].Hash != "0xdeadbeef" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	// 缺少或無效的 block 參數
+	for _, target := range []string{"/detections", "/detections?block=abc", "/detections?block=-1"} {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(handleDetections).ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status code %d, got %d", target, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/sirupsen/logrus"
+)
+
+// alertNotifier 是可以發送告警的通知器 (webhookNotifier 即實作了此介面)
+type alertNotifier interface {
+	Notify(ctx context.Context, payload interface{}) error
+}
+
+// logNotifier 在沒有設定 webhook 時，將告警寫入日誌
+type logNotifier struct{}
+
+// Notify 以警告等級記錄告警內容
+func (logNotifier) Notify(ctx context.Context, payload interface{}) error {
+	logrus.WithField("alert", payload).Warn("🚨 告警")
+	return nil
+}
+
+// dlqMonitorConfig 是死信隊列增長監控的設定
+type dlqMonitorConfig struct {
+	Interval          time.Duration // 取樣間隔
+	Window            time.Duration // 計算增長量的時間窗
+	GrowthThreshold   int64         // 時間窗內增長超過此數量即告警 (0 表示停用)
+	AbsoluteThreshold int64         // 死信數量達到此值即告警 (0 表示停用)
+	Cooldown          time.Duration // 同一隊列兩次告警之間的最短間隔
+}
+
+// dlqMonitorConfigFromEnv 從環境變數讀取 DLQ 告警設定
+func dlqMonitorConfigFromEnv() dlqMonitorConfig {
+	return dlqMonitorConfig{
+		Interval:          envDuration("DLQ_ALERT_INTERVAL", 15*time.Second),
+		Window:            envDuration("DLQ_ALERT_WINDOW", 5*time.Minute),
+		GrowthThreshold:   int64(envInt("DLQ_ALERT_GROWTH", 0)),
+		AbsoluteThreshold: int64(envInt("DLQ_ALERT_THRESHOLD", 0)),
+		Cooldown:          envDuration("DLQ_ALERT_COOLDOWN", 10*time.Minute),
+	}
+}
+
+// enabled 判斷是否設定了任何告警門檻
+func (c dlqMonitorConfig) enabled() bool {
+	return c.GrowthThreshold > 0 || c.AbsoluteThreshold > 0
+}
+
+// dlqAlert 是 DLQ 告警的內容
+type dlqAlert struct {
+	Event           string  `json:"event"`
+	Broker          string  `json:"broker"`
+	Queue           string  `json:"queue"`
+	Reason          string  `json:"reason"`
+	DeadLetterCount int64   `json:"dead_letter_count"`
+	Growth          int64   `json:"growth"`
+	WindowSeconds   float64 `json:"window_seconds"`
+	RatePerSecond   float64 `json:"rate_per_second"`
+}
+
+// dlqKey 以 Broker 名稱與隊列名稱識別一個死信隊列，不同 Broker 可能有同名的隊列
+type dlqKey struct {
+	broker string
+	queue  string
+}
+
+// dlqSample 是某一時間點的死信數量
+type dlqSample struct {
+	at    time.Time
+	count int64
+}
+
+// dlqMonitor 在背景監控所有 Broker 各隊列的 DeadLetterCount，增長過快或超過門檻時發出告警
+type dlqMonitor struct {
+	brokers  func() map[string]broker.Broker // 每次取樣時返回要監控的 Broker，以名稱為鍵
+	notifier alertNotifier
+	cfg      dlqMonitorConfig
+	now      func() time.Time
+
+	mu        sync.Mutex
+	samples   map[dlqKey][]dlqSample
+	rates     map[dlqKey]float64
+	lastAlert map[dlqKey]time.Time
+}
+
+// newDLQMonitor 創建一個新的 DLQ 增長監控器，brokers 通常為 allBrokers
+func newDLQMonitor(brokers func() map[string]broker.Broker, n alertNotifier, cfg dlqMonitorConfig) *dlqMonitor {
+	if n == nil {
+		n = logNotifier{}
+	}
+	return &dlqMonitor{
+		brokers:   brokers,
+		notifier:  n,
+		cfg:       cfg,
+		now:       time.Now,
+		samples:   make(map[dlqKey][]dlqSample),
+		rates:     make(map[dlqKey]float64),
+		lastAlert: make(map[dlqKey]time.Time),
+	}
+}
+
+// Run 依設定的間隔定期取樣，直到 ctx 被取消
+func (m *dlqMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.check(ctx)
+		}
+	}
+}
+
+// check 對所有 Broker 的所有隊列取樣一次，並在需要時發出告警
+func (m *dlqMonitor) check(ctx context.Context) {
+	now := m.now()
+	var alerts []dlqAlert
+
+	m.mu.Lock()
+	for name, b := range m.brokers() {
+		for _, queue := range b.GetAllQueues() {
+			stats, err := b.GetQueueStats(queue)
+			if err != nil {
+				continue
+			}
+			if alert, ok := m.observe(dlqKey{broker: name, queue: queue}, stats.DeadLetterCount, now); ok {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	for _, alert := range alerts {
+		if err := m.notifier.Notify(ctx, alert); err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"broker": alert.Broker,
+				"queue":  alert.Queue,
+			}).Warn("⚠️ DLQ 告警發送失敗")
+		}
+	}
+}
+
+// observe 記錄一個死信隊列的取樣，需要告警時返回告警內容，呼叫者需持有鎖
+func (m *dlqMonitor) observe(key dlqKey, count int64, now time.Time) (dlqAlert, bool) {
+	// 保留時間窗內的樣本，並加入本次取樣
+	samples := append(m.samples[key], dlqSample{at: now, count: count})
+	cutoff := now.Add(-m.cfg.Window)
+	for len(samples) > 1 && samples[0].at.Before(cutoff) {
+		samples = samples[1:]
+	}
+	m.samples[key] = samples
+
+	oldest := samples[0]
+	growth := count - oldest.count
+	rate := 0.0
+	if elapsed := now.Sub(oldest.at).Seconds(); elapsed > 0 {
+		rate = float64(growth) / elapsed
+	}
+	m.rates[key] = rate
+
+	reason := ""
+	switch {
+	case m.cfg.GrowthThreshold > 0 && growth > m.cfg.GrowthThreshold:
+		reason = "growth_rate"
+	case m.cfg.AbsoluteThreshold > 0 && count >= m.cfg.AbsoluteThreshold:
+		reason = "absolute_threshold"
+	}
+	if reason == "" {
+		return dlqAlert{}, false
+	}
+
+	// 去抖動：冷卻期間內不重複告警
+	if last, ok := m.lastAlert[key]; ok && now.Sub(last) < m.cfg.Cooldown {
+		return dlqAlert{}, false
+	}
+	m.lastAlert[key] = now
+
+	return dlqAlert{
+		Event:           "dlq_growth",
+		Broker:          key.broker,
+		Queue:           key.queue,
+		Reason:          reason,
+		DeadLetterCount: count,
+		Growth:          growth,
+		WindowSeconds:   m.cfg.Window.Seconds(),
+		RatePerSecond:   rate,
+	}, true
+}
+
+// GrowthRates 返回各 Broker 各隊列最近一次計算的死信增長速率 (每秒)
+func (m *dlqMonitor) GrowthRates() map[dlqKey]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[dlqKey]float64, len(m.rates))
+	for key, rate := range m.rates {
+		result[key] = rate
+	}
+	return result
+}
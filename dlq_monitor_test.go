@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// recordingNotifier 記錄收到的所有告警，供測試斷言
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []interface{}
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, payload interface{}) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, payload)
+	return nil
+}
+
+func (n *recordingNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.alerts)
+}
+
+// singleBroker 返回只包含 b 的 Broker 來源，名稱為 default
+func singleBroker(b broker.Broker) func() map[string]broker.Broker {
+	return func() map[string]broker.Broker {
+		return map[string]broker.Broker{"default": b}
+	}
+}
+
+func TestDLQMonitorAlertsOnGrowthRate(t *testing.T) {
+	b := broker.NewSimpleBroker()
+	defer b.Close()
+
+	queue := "alerts-queue"
+	b.Push(queue, broker.NewMessage("seed", []byte("seed"), queue))
+
+	rec := &recordingNotifier{}
+	mon := newDLQMonitor(singleBroker(b), rec, dlqMonitorConfig{
+		Window:          time.Minute,
+		GrowthThreshold: 10,
+		Cooldown:        5 * time.Minute,
+	})
+	now := time.Unix(1700000000, 0)
+	mon.now = func() time.Time { return now }
+
+	// 基準樣本
+	mon.check(context.Background())
+	if rec.count() != 0 {
+		t.Fatalf("Expected no alert on baseline, got %d", rec.count())
+	}
+
+	// 緩慢增長，低於門檻
+	for i := 0; i < 5; i++ {
+		b.MoveToDLQ(queue, broker.NewMessage(fmt.Sprintf("slow-%d", i), nil, queue))
+	}
+	now = now.Add(10 * time.Second)
+	mon.check(context.Background())
+	if rec.count() != 0 {
+		t.Fatalf("Expected no alert below growth threshold, got %d", rec.count())
+	}
+
+	// 快速增長，超過門檻
+	for i := 0; i < 20; i++ {
+		b.MoveToDLQ(queue, broker.NewMessage(fmt.Sprintf("fast-%d", i), nil, queue))
+	}
+	now = now.Add(10 * time.Second)
+	mon.check(context.Background())
+	if rec.count() != 1 {
+		t.Fatalf("Expected exactly 1 alert once growth threshold is crossed, got %d", rec.count())
+	}
+
+	alert := rec.alerts[0].(dlqAlert)
+	if alert.Broker != "default" || alert.Queue != queue || alert.Reason != "growth_rate" || alert.Growth != 25 {
+		t.Errorf("Unexpected alert: %+v", alert)
+	}
+
+	// 冷卻期間內持續增長也不應重複告警
+	for i := 0; i < 20; i++ {
+		b.MoveToDLQ(queue, broker.NewMessage(fmt.Sprintf("more-%d", i), nil, queue))
+	}
+	now = now.Add(10 * time.Second)
+	mon.check(context.Background())
+	if rec.count() != 1 {
+		t.Errorf("Expected alert to be debounced, got %d alerts", rec.count())
+	}
+
+	if rate := mon.GrowthRates()[dlqKey{broker: "default", queue: queue}]; rate <= 0 {
+		t.Errorf("Expected positive growth rate, got %f", rate)
+	}
+}
+
+func TestDLQMonitorAlertsOnAbsoluteThreshold(t *testing.T) {
+	b := broker.NewSimpleBroker()
+	defer b.Close()
+
+	queue := "threshold-queue"
+	b.Push(queue, broker.NewMessage("seed", []byte("seed"), queue))
+	for i := 0; i < 3; i++ {
+		b.MoveToDLQ(queue, broker.NewMessage(fmt.Sprintf("dead-%d", i), nil, queue))
+	}
+
+	rec := &recordingNotifier{}
+	mon := newDLQMonitor(singleBroker(b), rec, dlqMonitorConfig{Window: time.Minute, AbsoluteThreshold: 3})
+	mon.check(context.Background())
+
+	if rec.count() != 1 {
+		t.Fatalf("Expected 1 alert when absolute threshold is reached, got %d", rec.count())
+	}
+	if alert := rec.alerts[0].(dlqAlert); alert.Reason != "absolute_threshold" {
+		t.Errorf("Expected absolute_threshold reason, got %s", alert.Reason)
+	}
+}
+
+func TestHTTPMetricsIncludesDLQGrowthRate(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	queue := "metrics-dlq-queue"
+	messageBroker.Push(queue, broker.NewMessage("seed", []byte("seed"), queue))
+
+	dlqMon = newDLQMonitor(allBrokers, &recordingNotifier{}, dlqMonitorConfig{Window: time.Minute, GrowthThreshold: 100})
+	defer func() { dlqMon = nil }()
+	dlqMon.check(context.Background())
+
+	rr := httptest.NewRecorder()
+	handleMetrics(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !bytes.Contains(rr.Body.Bytes(), []byte(`dlq_growth_rate{broker="default",queue="metrics-dlq-queue"}`)) {
+		t.Errorf("Expected dlq_growth_rate series in metrics, got:\n%s", rr.Body.String())
+	}
+}
+
+func TestDLQMonitorWatchesAllBrokers(t *testing.T) {
+	blocks, alerts := withBrokerRegistry(t)
+	blocks.Push(blockQueueName, broker.NewMessage("seed", nil, blockQueueName))
+	alerts.Push(transactionQueueName, broker.NewMessage("seed", nil, transactionQueueName))
+
+	rec := &recordingNotifier{}
+	mon := newDLQMonitor(allBrokers, rec, dlqMonitorConfig{Window: time.Minute, AbsoluteThreshold: 2})
+
+	// 交易隊列與 webhook 失敗的死信都在 alerts Broker 中
+	for i := 0; i < 2; i++ {
+		alerts.MoveToDLQ(transactionQueueName, broker.NewMessage(fmt.Sprintf("tx-%d", i), nil, transactionQueueName))
+	}
+	mon.check(context.Background())
+
+	if rec.count() != 1 {
+		t.Fatalf("Expected 1 alert for the alerts broker, got %d", rec.count())
+	}
+	if alert := rec.alerts[0].(dlqAlert); alert.Broker != brokerPurposeAlerts || alert.Queue != transactionQueueName {
+		t.Errorf("Expected alert for alerts/%s, got %+v", transactionQueueName, alert)
+	}
+	rates := mon.GrowthRates()
+	for _, key := range []dlqKey{{brokerPurposeBlocks, blockQueueName}, {brokerPurposeAlerts, transactionQueueName}} {
+		if _, ok := rates[key]; !ok {
+			t.Errorf("Expected growth rate for %+v, got %v", key, rates)
+		}
+	}
+}
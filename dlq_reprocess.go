@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/sirupsen/logrus"
+)
+
+// reprocessFailure 是批次重新處理中失敗的單條消息
+type reprocessFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// reprocessStatus 將 ReprocessDLQ 的錯誤對應到 HTTP 狀態碼
+func reprocessStatus(err error) int {
+	switch {
+	case errors.Is(err, broker.ErrDLQMessageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, broker.ErrRetryBudgetExhausted):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// handleDLQReprocess 處理 POST /dlq/reprocess?queue=NAME&id=MSGID
+// 將死信隊列中的單條消息重新推送回原隊列
+func handleDLQReprocess(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	queueName := r.URL.Query().Get("queue")
+	msgID := r.URL.Query().Get("id")
+	if queueName == "" || msgID == "" {
+		http.Error(w, "queue and id parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := brokerForQueue(queueName).ReprocessDLQ(queueName, msgID); err != nil {
+		http.Error(w, err.Error(), reprocessStatus(err))
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"queue": queueName,
+		"id":    msgID,
+	}).Info("♻️ 已重新處理死信消息")
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"queue":       queueName,
+		"id":          msgID,
+		"reprocessed": true,
+	})
+}
+
+// handleDLQReprocessAll 處理 POST /dlq/reprocess-all?queue=NAME
+// 將死信隊列中的所有消息重新推送回原隊列，無法重新處理的消息 (例如重試預算已耗盡) 留在死信隊列並列在 failed 中
+func handleDLQReprocessAll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	queueName := r.URL.Query().Get("queue")
+	if queueName == "" {
+		http.Error(w, "queue parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	b := brokerForQueue(queueName)
+	reprocessed := 0
+	failed := []reprocessFailure{}
+	for _, msg := range b.GetDLQ(queueName) {
+		if err := b.ReprocessDLQ(queueName, msg.ID); err != nil {
+			failed = append(failed, reprocessFailure{ID: msg.ID, Error: err.Error()})
+			continue
+		}
+		reprocessed++
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"queue":       queueName,
+		"reprocessed": reprocessed,
+		"failed":      len(failed),
+	}).Info("♻️ 已重新處理整個死信隊列")
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"queue":       queueName,
+		"reprocessed": reprocessed,
+		"failed":      failed,
+	})
+}
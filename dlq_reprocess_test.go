@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// reprocessDLQ 以 POST 呼叫死信重新處理端點
+func reprocessDLQ(t *testing.T, handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPost, target, nil))
+	return rr
+}
+
+func TestHandleDLQReprocess(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	messageBroker.MoveToDLQ("blocks", broker.NewMessage("dead-1", []byte("1"), "blocks"))
+	messageBroker.MoveToDLQ("blocks", broker.NewMessage("dead-2", []byte("2"), "blocks"))
+
+	rr := reprocessDLQ(t, handleDLQReprocess, "/dlq/reprocess?queue=blocks&id=dead-1")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if msg, _ := messageBroker.Pull("blocks"); msg == nil || msg.ID != "dead-1" {
+		t.Errorf("Expected dead-1 back in the queue, got %v", msg)
+	}
+	if dlq := messageBroker.GetDLQ("blocks"); len(dlq) != 1 || dlq[0].ID != "dead-2" {
+		t.Errorf("Expected only dead-2 left in the DLQ, got %v", dlq)
+	}
+
+	// 已重新處理的消息不在死信隊列中
+	if rr := reprocessDLQ(t, handleDLQReprocess, "/dlq/reprocess?queue=blocks&id=dead-1"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a message not in the DLQ, got %d", rr.Code)
+	}
+	if rr := reprocessDLQ(t, handleDLQReprocess, "/dlq/reprocess?queue=unknown&id=dead-1"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a queue without dead letters, got %d", rr.Code)
+	}
+	if rr := reprocessDLQ(t, handleDLQReprocess, "/dlq/reprocess?queue=blocks"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without id, got %d", rr.Code)
+	}
+}
+
+func TestHandleDLQReprocessAll(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	for _, id := range []string{"dead-1", "dead-2", "dead-3"} {
+		messageBroker.MoveToDLQ("blocks", broker.NewMessage(id, nil, "blocks"))
+	}
+
+	rr := reprocessDLQ(t, handleDLQReprocessAll, "/dlq/reprocess-all?queue=blocks")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Reprocessed int                `json:"reprocessed"`
+		Failed      []reprocessFailure `json:"failed"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Reprocessed != 3 || len(resp.Failed) != 0 {
+		t.Errorf("Expected 3 reprocessed and none failed, got %+v", resp)
+	}
+
+	if dlq := messageBroker.GetDLQ("blocks"); len(dlq) != 0 {
+		t.Errorf("Expected empty DLQ, got %d messages", len(dlq))
+	}
+	if stats, _ := messageBroker.GetQueueStats("blocks"); stats.MessageCount != 3 {
+		t.Errorf("Expected 3 messages requeued, got %d", stats.MessageCount)
+	}
+
+	// 空的死信隊列仍返回成功
+	rr = reprocessDLQ(t, handleDLQReprocessAll, "/dlq/reprocess-all?queue=blocks")
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || resp.Reprocessed != 0 {
+		t.Errorf("Expected nothing to reprocess, got %d %+v", rr.Code, resp)
+	}
+}
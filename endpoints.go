@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+)
+
+// endpoints 是設定的節點端點，未設定時為 nil
+var endpoints *endpointPool
+
+// parseEndpoints 解析以逗號分隔的 WSS URL 清單，忽略空白項目
+func parseEndpoints(raw string) []string {
+	var urls []string
+	for _, u := range strings.Split(raw, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// dialWithFailover 從 start 開始依序連線各端點，返回第一個連線成功的客戶端與其索引
+// 所有端點都失敗時返回合併的錯誤，由呼叫者退避後再重試
+func dialWithFailover(ctx context.Context, urls []string, start int) (*rpc.Client, int, error) {
+	if len(urls) == 0 {
+		return nil, -1, errors.New("no endpoints configured")
+	}
+
+	var errs []error
+	for i := range urls {
+		idx := (start + i) % len(urls)
+		client, err := rpc.DialContext(ctx, urls[idx])
+		if err == nil {
+			return client, idx, nil
+		}
+		logrus.WithFields(logrus.Fields{
+			"endpoint": redactEndpoint(urls[idx]),
+			"index":    idx,
+		}).WithError(err).Warn("⚠️ 節點端點連線失敗，嘗試下一個端點")
+		errs = append(errs, fmt.Errorf("%s: %w", redactEndpoint(urls[idx]), err))
+	}
+	return nil, -1, errors.Join(errs...)
+}
+
+// redactEndpoint 只保留端點的 scheme 與 host，避免在日誌與 /health 中洩漏路徑中的 API key
+func redactEndpoint(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "invalid"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// endpointPool 保存設定的端點與目前使用的端點
+// 訂閱中斷時輪換到下一個端點，下一次連線從該端點開始嘗試
+type endpointPool struct {
+	mu        sync.Mutex
+	urls      []string
+	current   int  // 下一次連線優先嘗試的端點 (連線中時即為使用中的端點)
+	connected bool // 是否已連線到 current
+}
+
+// newEndpointPool 創建端點池，urls 不可為空
+func newEndpointPool(urls []string) *endpointPool {
+	return &endpointPool{urls: urls}
+}
+
+// dial 從目前的端點開始連線，成功時記錄使用中的端點
+func (p *endpointPool) dial(ctx context.Context) (*rpc.Client, error) {
+	p.mu.Lock()
+	urls, start := p.urls, p.current
+	p.mu.Unlock()
+
+	client, idx, err := dialWithFailover(ctx, urls, start)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.current, p.connected = idx, true
+	p.mu.Unlock()
+	logrus.WithFields(logrus.Fields{
+		"endpoint": redactEndpoint(urls[idx]),
+		"index":    idx,
+	}).Info("🔌 已選用節點端點")
+	return client, nil
+}
+
+// rotate 在訂閱中斷時呼叫，下一次連線改從下一個端點開始
+func (p *endpointPool) rotate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.connected = false
+	p.current = (p.current + 1) % len(p.urls)
+}
+
+// status 返回使用中的端點 (已遮蔽) 與索引，未連線時端點為空字串
+func (p *endpointPool) status() (endpoint string, index int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.connected {
+		return "", -1
+	}
+	return redactEndpoint(p.urls[p.current]), p.current
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newTestRPCEndpoint 啟動一個可接受 WebSocket 連線的 RPC 服務器，返回 ws:// URL
+func newTestRPCEndpoint(t *testing.T) string {
+	t.Helper()
+	server := rpc.NewServer()
+	t.Cleanup(server.Stop)
+	httpServer := httptest.NewServer(server.WebsocketHandler([]string{"*"}))
+	t.Cleanup(httpServer.Close)
+	return "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/v2/secret-key"
+}
+
+// unreachableEndpoint 是無法連線的端點
+const unreachableEndpoint = "ws://127.0.0.1:1/v2/secret-key"
+
+func TestParseEndpoints(t *testing.T) {
+	got := parseEndpoints(" wss://a.example/v2/k1, ,wss://b.example/v2/k2 ")
+	if len(got) != 2 || got[0] != "wss://a.example/v2/k1" || got[1] != "wss://b.example/v2/k2" {
+		t.Errorf("Expected two trimmed endpoints, got %v", got)
+	}
+	if got := parseEndpoints(""); len(got) != 0 {
+		t.Errorf("Expected no endpoints, got %v", got)
+	}
+}
+
+func TestDialWithFailoverSkipsFailingEndpoint(t *testing.T) {
+	good := newTestRPCEndpoint(t)
+
+	client, idx, err := dialWithFailover(context.Background(), []string{unreachableEndpoint, good}, 0)
+	if err != nil {
+		t.Fatalf("Expected failover to the second endpoint, got %v", err)
+	}
+	defer client.Close()
+	if idx != 1 {
+		t.Errorf("Expected endpoint index 1, got %d", idx)
+	}
+
+	// 所有端點都失敗時返回錯誤
+	if _, _, err := dialWithFailover(context.Background(), []string{unreachableEndpoint}, 0); err == nil {
+		t.Error("Expected an error when every endpoint fails")
+	}
+}
+
+func TestEndpointPoolRotatesAndReportsActive(t *testing.T) {
+	withBrokerRegistry(t)
+	good := newTestRPCEndpoint(t)
+	previous := endpoints
+	endpoints = newEndpointPool([]string{unreachableEndpoint, good})
+	t.Cleanup(func() { endpoints = previous })
+
+	client, err := endpoints.dial(context.Background())
+	if err != nil {
+		t.Fatalf("Expected dial to succeed, got %v", err)
+	}
+	client.Close()
+
+	// /health 回報使用中的端點，且不包含路徑中的 API key
+	rr := httptest.NewRecorder()
+	handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &health)
+	endpoint, _ := health["endpoint"].(string)
+	if endpoint != redactEndpoint(good) || strings.Contains(endpoint, "secret-key") || health["endpoint_index"] != float64(1) {
+		t.Errorf("Expected redacted second endpoint in /health, got %v (index %v)", health["endpoint"], health["endpoint_index"])
+	}
+
+	// 訂閱中斷後輪換，下一次連線從第一個端點開始嘗試
+	endpoints.rotate()
+	if endpoint, index := endpoints.status(); endpoint != "" || index != -1 {
+		t.Errorf("Expected no active endpoint after rotating, got %q (%d)", endpoint, index)
+	}
+	if endpoints.current != 0 {
+		t.Errorf("Expected rotation to wrap to endpoint 0, got %d", endpoints.current)
+	}
+}
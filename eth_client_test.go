@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// mockEthClient 是測試用的節點，訂閱後透過 heads 送出新區塊頭
+type mockEthClient struct {
+	*mockBlockFetcher
+	heads      chan *types.Header
+	sub        *mockSubscription
+	subscribed chan struct{}
+	receipts   map[common.Hash]*types.Receipt
+}
+
+func newMockEthClient(blocks ...*types.Block) *mockEthClient {
+	c := &mockEthClient{
+		mockBlockFetcher: newMockBlockFetcher(),
+		heads:            make(chan *types.Header),
+		sub:              &mockSubscription{errCh: make(chan error, 1)},
+		subscribed:       make(chan struct{}),
+		receipts:         make(map[common.Hash]*types.Receipt),
+	}
+	for _, block := range blocks {
+		c.blocks[block.Hash()] = block
+	}
+	return c
+}
+
+func (c *mockEthClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	go func() {
+		for header := range c.heads {
+			ch <- header
+		}
+	}()
+	close(c.subscribed)
+	return c.sub, nil
+}
+
+func (c *mockEthClient) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	receipt, ok := c.receipts[hash]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return receipt, nil
+}
+
+func (c *mockEthClient) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func TestBlockWatcherWatchPushesMatchedTransactions(t *testing.T) {
+	blocks, alerts := withBrokerRegistry(t)
+	resetUpstream(t)
+
+	// 區塊中只有一筆交易發往監聽地址
+	header := newTestHeader(42)
+	matched := newTestTx(1, targetAddress)
+	other := newTestTx(2, "0x000000000000000000000000000000000000dEaD")
+	block := types.NewBlockWithHeader(header).WithBody(types.Body{Transactions: []*types.Transaction{matched, other}})
+
+	client := newMockEthClient(block)
+	w := &blockWatcher{clock: broker.RealClock{}, grace: time.Second, fetchTimeout: time.Second}
+	result := make(chan error, 1)
+	go func() { result <- w.watch(context.Background(), client) }()
+
+	<-client.subscribed
+	client.heads <- header
+	close(client.heads)
+
+	// 等待區塊推送到隊列後再中斷訂閱
+	deadline := time.Now().Add(2 * time.Second)
+	for upstream.lastBlock.Load() == 0 || messageQueueLen(t, blocks, blockQueueName) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the block to be pushed to the block queue")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !upstreamSubscribed.Load() {
+		t.Error("Expected the watcher to report an active subscription")
+	}
+	dropped := errors.New("subscription dropped")
+	client.sub.errCh <- dropped
+	if err := <-result; !errors.Is(err, dropped) {
+		t.Errorf("Expected watch to return the subscription error, got %v", err)
+	}
+	if upstreamSubscribed.Load() {
+		t.Error("Expected the subscription to be reported inactive after watch returns")
+	}
+
+	msg, err := blocks.Pull(blockQueueName)
+	if err != nil {
+		t.Fatalf("Expected a block message, got %v", err)
+	}
+	var blockMessage BlockMessage
+	if err := json.Unmarshal(msg.Body, &blockMessage); err != nil {
+		t.Fatalf("Failed to parse block message: %v", err)
+	}
+	if blockMessage.BlockNumber != "42" || len(blockMessage.Transactions) != 1 || blockMessage.Transactions[0].Hash != matched.Hash().Hex() {
+		t.Errorf("Expected block 42 with only the matched transaction, got %+v", blockMessage)
+	}
+
+	// worker 處理後偵測被推送到交易隊列
+	handleBlockDelivery(msg, 1)
+	forwarded, err := alerts.Pull(transactionQueueName)
+	if err != nil {
+		t.Fatalf("Expected a forwarded detection, got %v", err)
+	}
+	var txInfo TransactionInfo
+	unmarshalEvent(forwarded.Body, &txInfo)
+	if txInfo.Hash != matched.Hash().Hex() {
+		t.Errorf("Expected matched transaction forwarded, got %s", txInfo.Hash)
+	}
+}
+
+func TestBlockWatcherWatchReturnsSubscribeError(t *testing.T) {
+	resetUpstream(t)
+
+	w := &blockWatcher{clock: broker.RealClock{}, grace: time.Second, fetchTimeout: time.Second}
+	err := w.watch(context.Background(), failingSubscribeClient{newMockEthClient()})
+	if err == nil {
+		t.Fatal("Expected subscribe failure to be returned")
+	}
+	if upstream.connects.Load() != 0 || upstreamSubscribed.Load() {
+		t.Error("Expected a failed subscription not to count as connected")
+	}
+}
+
+// failingSubscribeClient 是無法訂閱新區塊的節點
+type failingSubscribeClient struct {
+	*mockEthClient
+}
+
+func (failingSubscribeClient) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return nil, errors.New("subscriptions not supported")
+}
+
+// messageQueueLen 返回隊列中的消息數，隊列尚未建立時為 0
+func messageQueueLen(t *testing.T, b broker.Broker, queue string) int64 {
+	t.Helper()
+	stats, err := b.GetQueueStats(queue)
+	if err != nil {
+		return 0
+	}
+	return stats.MessageCount
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// eventSchemaVersion 是目前發出的偵測事件格式版本
+// 變更 data 的欄位語意或移除欄位時必須遞增；新增可選欄位不需要
+const eventSchemaVersion = 1
+
+// 偵測事件類型
+const (
+	eventTypeDeposit  = "deposit"  // 已上鏈的目標交易
+	eventTypePending  = "pending"  // mempool 中尚未上鏈的目標交易
+	eventTypeFiltered = "filtered" // 匹配但被過濾掉的交易
+	eventTypeReverted = "reverted" // 已轉發但所在區塊被重組掉的交易
+)
+
+// errUnsupportedEventVersion 表示事件版本比目前程式支援的更新，消費端應略過而非中止
+var errUnsupportedEventVersion = errors.New("unsupported event version")
+
+// eventEnvelope 是內部發布的偵測事件外層格式：{"v":1,"type":"deposit","data":{...}}
+// 所有發出偵測事件的路徑都應透過 marshalEvent 產生，消費端依 type 與 v 分派
+type eventEnvelope struct {
+	V    int             `json:"v"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// marshalEvent 將事件內容包裝為目前版本的 envelope 並序列化
+func marshalEvent(eventType string, data interface{}) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+	return json.Marshal(eventEnvelope{V: eventSchemaVersion, Type: eventType, Data: raw})
+}
+
+// unmarshalEvent 解析 envelope，版本不受支援時返回 errUnsupportedEventVersion
+// data 只在版本受支援時才解析到 out (out 為 nil 時不解析)
+func unmarshalEvent(body []byte, out interface{}) (*eventEnvelope, error) {
+	var envelope eventEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	if envelope.V < 1 || envelope.V > eventSchemaVersion {
+		return &envelope, fmt.Errorf("%w: v%d %s", errUnsupportedEventVersion, envelope.V, envelope.Type)
+	}
+	if out != nil {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return &envelope, fmt.Errorf("failed to unmarshal %s event data: %w", envelope.Type, err)
+		}
+	}
+	return &envelope, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/sirupsen/logrus"
+)
+
+// filteredQueueName 是匹配但被過濾掉的交易的稽核隊列
+const filteredQueueName = "filtered"
+
+// 被過濾交易的消息標頭
+const (
+	filterReasonHeader      = "filter_reason"
+	filterBlockNumberHeader = "block_number"
+)
+
+// 交易被過濾的原因
+const (
+	filterReasonSampled       = "sampled_out"     // 流量過高，未被取樣轉發
+	filterReasonBelowMinValue = "below_min_value" // 金額低於 MIN_VALUE_WEI
+)
+
+// filteredAuditEnabled 為 true 時，被過濾的匹配交易會推送到 filtered 隊列
+// 預設關閉以避免額外開銷
+var filteredAuditEnabled bool
+
+// filteredAuditEnabledFromEnv 從 FILTERED_QUEUE_ENABLED 讀取是否開啟稽核隊列
+func filteredAuditEnabledFromEnv() bool {
+	return strings.EqualFold(os.Getenv("FILTERED_QUEUE_ENABLED"), "true")
+}
+
+// recordFiltered 將被過濾的匹配交易連同原因推送到 filtered 隊列 (未開啟時不做任何事)
+func recordFiltered(blockNumber string, txInfo TransactionInfo, reason string) {
+	if !filteredAuditEnabled {
+		return
+	}
+
+	txData, _ := marshalEvent(eventTypeFiltered, txInfo)
+	msg := broker.NewMessage(generateMessageID(), txData, filteredQueueName)
+	msg.Headers[filterReasonHeader] = reason
+	msg.Headers[filterBlockNumberHeader] = blockNumber
+
+	if err := brokerFor(brokerPurposeAlerts).Push(filteredQueueName, msg); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"txHash": txInfo.Hash,
+			"reason": reason,
+		}).WithError(err).Warn("⚠️ 推送被過濾交易到稽核隊列失敗")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+func TestFilteredMatchesLandInAuditQueue(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	sampler = newDetectionSampler(map[string]samplingRule{
+		targetAddress: {Threshold: 1, EveryN: 10},
+	})
+	filteredAuditEnabled = true
+	defer func() {
+		sampler = newDetectionSampler(nil)
+		filteredAuditEnabled = false
+	}()
+
+	// 第 1 筆超過門檻前全部轉發，第 2 筆被取樣過濾
+	processBlockMessage(BlockMessage{
+		BlockNumber: "800",
+		Transactions: []TransactionInfo{
+			{Hash: "0x1", To: targetAddress, Value: "1"},
+			{Hash: "0x2", To: targetAddress, Value: "1"},
+		},
+	}, 1)
+
+	msg, err := messageBroker.Pull(filteredQueueName)
+	if err != nil || msg == nil {
+		t.Fatalf("Expected a filtered transaction, got %v (err=%v)", msg, err)
+	}
+	var txInfo TransactionInfo
+	unmarshalEvent(msg.Body, &txInfo)
+	if txInfo.Hash != "0x2" {
+		t.Errorf("Expected filtered transaction 0x2, got %s", txInfo.Hash)
+	}
+	if msg.Headers[filterReasonHeader] != filterReasonSampled || msg.Headers[filterBlockNumberHeader] != "800" {
+		t.Errorf("Unexpected filter headers: %v", msg.Headers)
+	}
+
+	if extra, _ := messageBroker.Pull(filteredQueueName); extra != nil {
+		t.Errorf("Expected forwarded transaction to stay out of the filtered queue, got %s", extra.Body)
+	}
+}
+
+func TestFilteredAuditDisabledByDefault(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	recordFiltered("1", TransactionInfo{Hash: "0x1"}, filterReasonSampled)
+	if _, err := messageBroker.GetQueueStats(filteredQueueName); err == nil {
+		t.Errorf("Expected no %s queue when audit is disabled", filteredQueueName)
+	}
+}
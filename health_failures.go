@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 失敗比例健康檢查的預設值
+const (
+	defaultHealthFailureRatio       = 0.5             // 時間窗內失敗比例超過此值時回報 degraded
+	defaultHealthFailureWindow      = 5 * time.Minute // 計算失敗比例的時間窗
+	defaultHealthFailureMinMessages = 20              // 時間窗內消息數少於此值時不判斷，避免少量消息造成誤報
+)
+
+// failureHealth 供 /health 判斷近期失敗比例，HEALTH_FAILURE_RATIO=0 時為 nil
+var failureHealth *failureRatioTracker
+
+// failureSample 是某一時間點所有 Broker 的累計消息數與失敗數
+type failureSample struct {
+	at     time.Time
+	total  int64
+	failed int64
+}
+
+// failureRatioTracker 以 /health 讀取到的累計計數取樣，計算最近時間窗內的失敗比例
+// 只看時間窗內的增量，早期的失敗不會讓 /health 永遠停在 degraded
+type failureRatioTracker struct {
+	threshold   float64
+	window      time.Duration
+	minMessages int64
+
+	mu      sync.Mutex
+	samples []failureSample
+}
+
+// newFailureRatioTracker 創建失敗比例追蹤器
+func newFailureRatioTracker(threshold float64, window time.Duration, minMessages int64) *failureRatioTracker {
+	return &failureRatioTracker{threshold: threshold, window: window, minMessages: minMessages}
+}
+
+// newFailureRatioTrackerFromEnv 從 HEALTH_FAILURE_RATIO、HEALTH_FAILURE_WINDOW 與
+// HEALTH_FAILURE_MIN_MESSAGES 讀取設定，門檻為 0 時停用並返回 nil
+func newFailureRatioTrackerFromEnv() *failureRatioTracker {
+	threshold := envFloat("HEALTH_FAILURE_RATIO", defaultHealthFailureRatio)
+	if threshold <= 0 {
+		return nil
+	}
+	return newFailureRatioTracker(
+		threshold,
+		envDuration("HEALTH_FAILURE_WINDOW", defaultHealthFailureWindow),
+		int64(envInt("HEALTH_FAILURE_MIN_MESSAGES", defaultHealthFailureMinMessages)),
+	)
+}
+
+// observe 記錄一次累計計數，返回時間窗內的失敗比例，以及是否超過門檻
+// 保留一個早於時間窗起點的樣本作為基準，讓增量涵蓋完整的時間窗
+func (t *failureRatioTracker) observe(now time.Time, total, failed int64) (ratio float64, degraded bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// 計數變小表示 Broker 統計被重設，舊樣本已無法作為基準
+	if n := len(t.samples); n > 0 && (total < t.samples[n-1].total || failed < t.samples[n-1].failed) {
+		t.samples = nil
+	}
+	t.samples = append(t.samples, failureSample{at: now, total: total, failed: failed})
+	cutoff := now.Add(-t.window)
+	for len(t.samples) > 1 && !t.samples[1].at.After(cutoff) {
+		t.samples = t.samples[1:]
+	}
+
+	base := t.samples[0]
+	messages := total - base.total
+	if messages <= 0 {
+		return 0, false
+	}
+	ratio = float64(failed-base.failed) / float64(messages)
+	return ratio, messages >= t.minMessages && ratio > t.threshold
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// healthStatus 呼叫 handleHealth 並返回狀態碼與回應內容
+func healthStatus(t *testing.T) (int, map[string]interface{}) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Failed to decode /health: %v", err)
+	}
+	return rr.Code, health
+}
+
+func TestHTTPHealthDegradedOnFailureRatio(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+
+	fake := broker.NewFakeClock(time.Now())
+	clock = fake
+	failureHealth = newFailureRatioTracker(0.5, time.Minute, 1)
+	t.Cleanup(func() {
+		clock = broker.RealClock{}
+		failureHealth = nil
+	})
+
+	push := func(n, failed int) {
+		for i := 0; i < n; i++ {
+			msg := broker.NewMessage("", nil, "test")
+			blocks.Push("test", msg)
+			if i < failed {
+				blocks.MoveToDLQ("test", msg)
+			}
+		}
+	}
+
+	// 建立基準樣本
+	if _, health := healthStatus(t); health["status"] != "healthy" {
+		t.Errorf("Expected healthy before any traffic, got %v", health["status"])
+	}
+
+	// 10 條中 6 條失敗，超過 0.5 門檻；liveness 仍返回 200
+	push(10, 6)
+	code, health := healthStatus(t)
+	if health["status"] != "degraded" || health["failure_ratio"] != 0.6 {
+		t.Errorf("Expected degraded with ratio 0.6, got %v (%v)", health["status"], health["failure_ratio"])
+	}
+	if code != http.StatusOK {
+		t.Errorf("Expected status code 200 while degraded, got %d", code)
+	}
+
+	// 超過時間窗後，之前的失敗不再計入
+	fake.Advance(2 * time.Minute)
+	if _, health := healthStatus(t); health["status"] != "healthy" {
+		t.Errorf("Expected healthy once old failures left the window, got %v", health["status"])
+	}
+
+	// 10 條中 1 條失敗，低於門檻
+	push(10, 1)
+	if _, health := healthStatus(t); health["status"] != "healthy" || health["failure_ratio"] != 0.1 {
+		t.Errorf("Expected healthy with ratio 0.1, got %v (%v)", health["status"], health["failure_ratio"])
+	}
+}
+
+func TestFailureRatioTracker(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tracker := newFailureRatioTracker(0.5, time.Minute, 20)
+
+	tracker.observe(start, 0, 0)
+
+	// 消息數未達下限時不判斷為 degraded
+	if ratio, degraded := tracker.observe(start.Add(time.Second), 10, 10); degraded || ratio != 1 {
+		t.Errorf("Expected ratio 1 without degraded below min messages, got %v %v", ratio, degraded)
+	}
+	if _, degraded := tracker.observe(start.Add(2*time.Second), 40, 30); !degraded {
+		t.Error("Expected degraded once min messages reached")
+	}
+
+	// 計數重設後以新的樣本為基準
+	if ratio, degraded := tracker.observe(start.Add(3*time.Second), 5, 0); degraded || ratio != 0 {
+		t.Errorf("Expected reset counters to start a new baseline, got %v %v", ratio, degraded)
+	}
+}
+
+func TestFailureRatioTrackerFromEnv(t *testing.T) {
+	t.Setenv("HEALTH_FAILURE_RATIO", "0")
+	if newFailureRatioTrackerFromEnv() != nil {
+		t.Error("Expected tracker to be disabled when HEALTH_FAILURE_RATIO=0")
+	}
+
+	t.Setenv("HEALTH_FAILURE_RATIO", "0.25")
+	t.Setenv("HEALTH_FAILURE_WINDOW", "30s")
+	tracker := newFailureRatioTrackerFromEnv()
+	if tracker == nil || tracker.threshold != 0.25 || tracker.window != 30*time.Second {
+		t.Errorf("Unexpected tracker %+v", tracker)
+	}
+}
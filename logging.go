@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// parseLogLevel 解析 LOG_LEVEL (trace/debug/info/warn/error)，未設定時為 info
+func parseLogLevel(value string) (logrus.Level, error) {
+	if strings.TrimSpace(value) == "" {
+		return logrus.InfoLevel, nil
+	}
+	return logrus.ParseLevel(strings.TrimSpace(value))
+}
+
+// configureLogging 依 LOG_LEVEL 設定日誌等級，LOG_FORMAT=json 時改用 JSON 格式方便日誌收集系統解析
+func configureLogging() {
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "json") {
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	}
+
+	level, err := parseLogLevel(os.Getenv("LOG_LEVEL"))
+	if err != nil {
+		logrus.WithField("key", "LOG_LEVEL").WithError(err).Warn("⚠️ 環境變數格式錯誤，使用預設值")
+		level = logrus.InfoLevel
+	}
+	logrus.SetLevel(level)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  logrus.Level
+	}{
+		{"", logrus.InfoLevel},
+		{"trace", logrus.TraceLevel},
+		{"debug", logrus.DebugLevel},
+		{"INFO", logrus.InfoLevel},
+		{"warn", logrus.WarnLevel},
+		{" error ", logrus.ErrorLevel},
+	} {
+		got, err := parseLogLevel(tc.value)
+		if err != nil {
+			t.Errorf("parseLogLevel(%q) failed: %v", tc.value, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("parseLogLevel(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+
+	if _, err := parseLogLevel("verbose"); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+}
@@ -2,16 +2,20 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/YCLstock/transaction-watcher/broker"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
@@ -20,9 +24,44 @@ import (
 // 全局變數
 const targetAddress = "0x7AF963CF6D228E564E2A0AA0DDBF06210B38615D"
 
+// 隊列名稱
+const (
+	blockQueueName       = "blocks"
+	transactionQueueName = "transactions"
+)
+
+// defaultSubscriberBlockTimeout 是 SUBSCRIBER_OVERFLOW=block 時等待訂閱者的預設時間
+const defaultSubscriberBlockTimeout = 100 * time.Millisecond
+
+// 建置資訊，發布時以 -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse --short HEAD)" 設定
+var (
+	version = "dev"
+	commit  = "dev"
+)
+
+// defaultEffectivelyOnceMaxIDs 是 effectively-once 模式預設保存的已處理 ID 上限
+const defaultEffectivelyOnceMaxIDs = 10000
+
 var (
 	messageBroker broker.Broker
+	clock         broker.Clock = broker.RealClock{} // 測試中可替換為 broker.FakeClock
 	startTime     time.Time
+	notifier      *webhookRouter   // 未設定 WEBHOOK_URL(S) 時為 nil
+	detections    = newDetectionIndex(defaultDetectionWindow)
+	dlqMon        *dlqMonitor // 未設定 DLQ 告警門檻時為 nil
+	blockCursor   *throttledCursor // 未設定 CURSOR_BACKEND 時為 nil
+	mempoolCfg    mempoolConfig
+	mempoolCounts *mempoolStats // 未設定 MEMPOOL_WATCH 時為 nil
+	pendingTxs    = newPendingTracker(defaultPendingTTL)
+
+	// effectivelyOnceEnabled 為 true 時，worker 處理完區塊後會向 Broker 確認
+	effectivelyOnceEnabled bool
+	// acksEnabled 為 true 時，區塊隊列以 at-least-once 模式投遞，worker 必須 Ack/Nack 每個消息
+	acksEnabled bool
+
+	// 偵測取樣與計數，取樣器預設轉發所有交易
+	sampler           = newDetectionSampler(nil)
+	detectionCounters = newDetectionCounter()
 )
 
 // BlockMessage 代表區塊訊息的結構
@@ -41,77 +80,201 @@ type TransactionInfo struct {
 	From     string `json:"from"`
 	Value    string `json:"value"`
 	GasPrice string `json:"gas_price"`
+	Pending  bool   `json:"pending,omitempty"` // 來自 mempool，尚未上鏈
+	Token    string `json:"token,omitempty"`     // ERC-20 代幣合約地址，原生 ETH 轉帳時為空
+	LogIndex uint   `json:"log_index,omitempty"` // 代幣轉帳在區塊中的 log 索引
 }
 
-// generateMessageID 生成唯一的消息ID
+// generateMessageID 生成唯一的消息ID，與 Broker 為空 ID 補上的 ID 使用同一個產生器
 func generateMessageID() string {
-	b := make([]byte, 16)
-	rand.Read(b)
-	return fmt.Sprintf("%x", b)
+	return broker.NewMessageID()
 }
 
-// startHTTPServer 啟動 HTTP API 服務器
-func startHTTPServer() {
-	http.HandleFunc("/metrics", handleMetrics)
-	http.HandleFunc("/health", handleHealth)
-	http.HandleFunc("/queues", handleQueues)
-	http.HandleFunc("/dlq", handleDLQ)
+// defaultHTTPAddr 是未設定 HTTP_ADDR 時 HTTP API 監聽的位址
+const defaultHTTPAddr = ":8080"
+
+// newHTTPHandler 註冊所有 HTTP API 路由，streamsDone 關閉時結束所有串流連線
+func newHTTPHandler(streamsDone <-chan struct{}) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/health", handleHealth)
+	mux.HandleFunc("/ready", handleReady)
+	mux.HandleFunc("/queues", handleQueues)
+	mux.HandleFunc("/queues/detail", handleQueueDetail)
+	mux.HandleFunc("/dlq", handleDLQ)
+	mux.HandleFunc("/dlq/reprocess", requireAPIKey(handleDLQReprocess))
+	mux.HandleFunc("/dlq/reprocess-all", requireAPIKey(handleDLQReprocessAll))
+	mux.HandleFunc("/detections", handleDetections)
+	mux.HandleFunc("/deposits", handleDeposits)
+	mux.HandleFunc("/scheduled", handleScheduled)
+	mux.HandleFunc("/oplog", handleOpLog)
+	mux.HandleFunc("/debug/snapshot", requireAPIKey(handleDebugSnapshot))
+	mux.HandleFunc("/metrics/queue-histogram", handleQueueHistogram)
+	mux.HandleFunc("/replay/block", requireAPIKey(handleReplayBlock))
+	mux.HandleFunc("/watched", handleWatched)
+	mux.HandleFunc("/watched/toggle", requireAPIKey(handleWatchedToggle))
+	mux.HandleFunc("/watch", requireAPIKey(handleWatch))
+	mux.HandleFunc("/publish", requireAPIKey(handlePublish))
+	mux.HandleFunc("/stream/deposits", depositStreamHandler(streamsDone))
+	mux.HandleFunc("/ws/blocks", blockSocketHandler(streamsDone))
+	return mux
+}
 
-	logrus.Info("🌐 HTTP API 服務器已啟動: http://localhost:8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		logrus.WithError(err).Error("HTTP 服務器啟動失敗")
+// startHTTPServer 在 addr 上啟動 HTTP API 服務器並返回，可用 Shutdown 優雅關閉
+// 監聽失敗時直接返回錯誤；addr 的埠號為 0 時使用系統分配的埠，實際位址保存在 server.Addr
+func startHTTPServer(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
 	}
+
+	// Shutdown 只等待進行中的請求完成，長連線的串流需要另外通知結束
+	streamsDone := make(chan struct{})
+	server := &http.Server{Addr: listener.Addr().String(), Handler: newHTTPHandler(streamsDone)}
+	server.RegisterOnShutdown(func() { close(streamsDone) })
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Error("HTTP 服務器異常停止")
+		}
+	}()
+
+	logrus.WithField("addr", server.Addr).Info("🌐 HTTP API 服務器已啟動")
+	return server, nil
 }
 
-// handleMetrics 處理 /metrics 端點 (Prometheus 格式)
-func handleMetrics(w http.ResponseWriter, r *http.Request) {
+// handleQueueHistogram 以 Prometheus histogram 格式輸出各隊列的深度分佈
+func handleQueueHistogram(w http.ResponseWriter, r *http.Request) {
+	all := allBrokers()
+	names := make([]string, 0, len(all))
+	histograms := make(map[string]map[string]broker.DepthHistogram, len(all))
+	for name, b := range all {
+		if h := b.GetDepthHistograms(); h != nil {
+			names = append(names, name)
+			histograms[name] = h
+		}
+	}
+	if len(names) == 0 {
+		http.Error(w, "queue depth histogram is disabled", http.StatusNotFound)
+		return
+	}
+	sort.Strings(names)
+
 	w.Header().Set("Content-Type", "text/plain")
-	metrics := messageBroker.GetMetrics().GetStats()
-	
-	fmt.Fprintf(w, "# HELP messages_total Total messages processed\n")
-	fmt.Fprintf(w, "# TYPE messages_total counter\n")
-	fmt.Fprintf(w, "messages_total %d\n", metrics["total_messages"])
-	
-	fmt.Fprintf(w, "# HELP messages_processed_total Total messages processed successfully\n")
-	fmt.Fprintf(w, "# TYPE messages_processed_total counter\n")
-	fmt.Fprintf(w, "messages_processed_total %d\n", metrics["processed_messages"])
-	
-	fmt.Fprintf(w, "# HELP messages_failed_total Total messages failed\n")
-	fmt.Fprintf(w, "# TYPE messages_failed_total counter\n")
-	fmt.Fprintf(w, "messages_failed_total %d\n", metrics["failed_messages"])
-	
-	fmt.Fprintf(w, "# HELP active_queues Number of active queues\n")
-	fmt.Fprintf(w, "# TYPE active_queues gauge\n")
-	fmt.Fprintf(w, "active_queues %d\n", metrics["active_queues"])
-	
-	fmt.Fprintf(w, "# HELP uptime_seconds Uptime in seconds\n")
-	fmt.Fprintf(w, "# TYPE uptime_seconds counter\n")
-	fmt.Fprintf(w, "uptime_seconds %.2f\n", metrics["uptime_seconds"])
+	fmt.Fprintf(w, "# HELP txwatcher_queue_depth Distribution of sampled queue depths\n")
+	fmt.Fprintf(w, "# TYPE txwatcher_queue_depth histogram\n")
+	for _, name := range names {
+		queues := make([]string, 0, len(histograms[name]))
+		for queue := range histograms[name] {
+			queues = append(queues, queue)
+		}
+		sort.Strings(queues)
+
+		for _, queue := range queues {
+			h := histograms[name][queue]
+			for i, le := range h.Buckets {
+				fmt.Fprintf(w, "txwatcher_queue_depth_bucket{broker=%q,queue=%q,le=\"%s\"} %d\n",
+					name, queue, strconv.FormatFloat(le, 'f', -1, 64), h.Counts[i])
+			}
+			fmt.Fprintf(w, "txwatcher_queue_depth_bucket{broker=%q,queue=%q,le=\"+Inf\"} %d\n", name, queue, h.Count)
+			fmt.Fprintf(w, "txwatcher_queue_depth_sum{broker=%q,queue=%q} %g\n", name, queue, h.Sum)
+			fmt.Fprintf(w, "txwatcher_queue_depth_count{broker=%q,queue=%q} %d\n", name, queue, h.Count)
+		}
+	}
+}
+
+// sortedQueueNames 返回排序後的隊列名稱，讓指標輸出順序穩定
+func sortedQueueNames(stats map[string]*broker.QueueStats) []string {
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 // handleHealth 處理 /health 端點
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+
+	// 所有 Broker 都健康時才算健康；Broker 忙碌時使用上一次的快照
+	snapshot, stale := brokerStatsCache.get()
+	healthy := true
+	queueCount := 0
+	brokerHealth := make(map[string]bool)
+	var totalMessages, processedMessages, failedMessages int64
+	for name, s := range snapshot.Brokers {
+		brokerHealth[name] = s.Healthy
+		healthy = healthy && brokerHealth[name]
+		queueCount += len(s.Queues)
+		totalMessages += int64(brokerStat("total_messages")(s.Stats))
+		processedMessages += int64(brokerStat("processed_messages")(s.Stats))
+		failedMessages += int64(brokerStat("failed_messages")(s.Stats))
+	}
 	
 	health := map[string]interface{}{
 		"status":     "healthy",
-		"uptime":     time.Since(startTime).Seconds(),
-		"broker":     messageBroker.IsHealthy(),
-		"queues":     len(messageBroker.GetAllQueues()),
-		"timestamp":  time.Now(),
+		"version":    version,
+		"commit":     commit,
+		"uptime":     clock.Now().Sub(startTime).Seconds(),
+		"broker":     healthy,
+		"brokers":    brokerHealth,
+		"queues":     queueCount,
+		"timestamp":  clock.Now(),
+
+		"total_messages":     totalMessages,
+		"processed_messages": processedMessages,
+		"failed_messages":    failedMessages,
+	}
+	if failureHealth != nil {
+		// /health 同時是 liveness probe，degraded 仍返回 200，避免因消息失敗而重啟程序
+		ratio, degraded := failureHealth.observe(clock.Now(), totalMessages, failedMessages)
+		health["failure_ratio"] = ratio
+		if degraded {
+			health["status"] = "degraded"
+		}
+	}
+	if stale {
+		// Broker 忙碌，以上 Broker 相關數值來自上一次的快照
+		health["stats_stale"] = true
+		health["stats_age_seconds"] = clock.Now().Sub(snapshot.TakenAt).Seconds()
+	}
+	if reorgs != nil {
+		inProgress, held := reorgs.status()
+		health["reorg_in_progress"] = inProgress
+		health["reorg_held_detections"] = held
+	}
+	if endpoints != nil {
+		endpoint, index := endpoints.status()
+		health["endpoint"] = endpoint
+		health["endpoint_index"] = index
+	}
+	if confirmations != nil {
+		_, held := confirmations.status()
+		health["confirmations_required"] = confirmations.depth
+		health["unconfirmed_detections"] = held
 	}
 	
 	json.NewEncoder(w).Encode(health)
 }
 
 // handleQueues 處理 /queues 端點
+// 可用 ?broker=NAME 只查看單一 Broker 的隊列，否則合併所有 Broker 的隊列
 func handleQueues(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+
+	targets := allBrokers()
+	if name := r.URL.Query().Get("broker"); name != "" {
+		b, ok := brokers.Get(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("broker %s not found", name), http.StatusNotFound)
+			return
+		}
+		targets = map[string]broker.Broker{name: b}
+	}
 	
 	queues := make(map[string]interface{})
-	for _, queueName := range messageBroker.GetAllQueues() {
-		stats, err := messageBroker.GetQueueStats(queueName)
-		if err == nil {
+	for _, b := range targets {
+		for queueName, stats := range b.GetAllQueueStats() {
 			queues[queueName] = stats
 		}
 	}
@@ -119,7 +282,14 @@ func handleQueues(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(queues)
 }
 
+// /dlq 分頁參數
+const (
+	defaultDLQPageLimit = 100  // 未指定 limit 時每頁的消息數
+	maxDLQPageLimit     = 1000 // limit 的上限，超過時使用此值
+)
+
 // handleDLQ 處理 /dlq 端點
+// 以 offset 與 limit 分頁列出死信消息，count 為死信消息總數
 func handleDLQ(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	
@@ -128,157 +298,304 @@ func handleDLQ(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "queue parameter is required", http.StatusBadRequest)
 		return
 	}
-	
-	dlqMessages := messageBroker.GetDLQ(queueName)
+
+	offset, limit, err := parsePage(r, defaultDLQPageLimit, maxDLQPageLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dlqMessages, total, err := brokerForQueue(queueName).GetDLQPage(queueName, offset, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"queue":    queueName,
 		"messages": dlqMessages,
-		"count":    len(dlqMessages),
+		"count":    total,
+		"offset":   offset,
+		"limit":    limit,
 	})
 }
 
-// startWatching 函式包含了我們所有的核心監聽邏輯
-func startWatching() {
-	// 從環境變數讀取 WSS URL
-	wssURL := os.Getenv("ALCHEMY_WSS_URL")
-	if wssURL == "" {
-		logrus.Fatal("❌ 環境變數 ALCHEMY_WSS_URL 未設定，請設定您的 Alchemy WebSocket URL")
+// handleScheduled 處理 /scheduled 端點
+// GET 列出指定隊列中等待投遞的延遲消息，DELETE 取消其中一條
+func handleScheduled(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	queueName := r.URL.Query().Get("queue")
+	if queueName == "" {
+		http.Error(w, "queue parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		scheduled := []broker.ScheduledMessage{}
+		for _, b := range allBrokers() {
+			scheduled = append(scheduled, b.GetScheduled(queueName)...)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"queue":     queueName,
+			"scheduled": scheduled,
+			"count":     len(scheduled),
+		})
+
+	case http.MethodDelete:
+		msgID := r.URL.Query().Get("id")
+		if msgID == "" {
+			http.Error(w, "id parameter is required", http.StatusBadRequest)
+			return
+		}
+		for _, b := range allBrokers() {
+			if err := b.CancelScheduled(queueName, msgID); err == nil {
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"queue":     queueName,
+					"id":        msgID,
+					"cancelled": true,
+				})
+				return
+			}
+		}
+		http.Error(w, fmt.Sprintf("scheduled message %s not found", msgID), http.StatusNotFound)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// defaultOpLogLimit 是 /oplog 預設返回的記錄數
+const defaultOpLogLimit = 100
+
+// handleOpLog 處理 /oplog 端點
+// 可用 ?limit=N 指定每個 Broker 返回的最近記錄數，未開啟操作日誌時返回 404
+func handleOpLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := defaultOpLogLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			http.Error(w, "invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	entries := make(map[string][]broker.OpLogEntry)
+	for name, b := range allBrokers() {
+		if log := b.GetOpLog(limit); log != nil {
+			entries[name] = log
+		}
+	}
+	if len(entries) == 0 {
+		http.Error(w, "operation log is disabled", http.StatusNotFound)
 		return
 	}
 
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"limit":   limit,
+		"brokers": entries,
+	})
+}
+
+// processBlockMessage 檢查區塊消息中的交易，將目標交易推送到交易隊列
+func processBlockMessage(blockMessage BlockMessage, workerID int) {
+	// 從消息中獲取區塊信息 (已預處理)
+	blockNumber := blockMessage.BlockNumber
+	blockNum, blockNumErr := strconv.ParseUint(blockNumber, 10, 64)
+
+	// 本區塊中每個地址已匹配的交易數，用於取樣
+	matchesInBlock := make(map[string]int)
+
+	// 可直接轉發的偵測，在掃描完整個區塊後一次推送
+	var ready []TransactionInfo
+	
+	// 處理交易 (如果有目標交易)
+	for _, txInfo := range blockMessage.Transactions {
+		if !watched.Contains(txInfo.To) {
+			continue
+		}
+
+		// 暫時停用的地址不匹配也不告警，只計入 suppressed 指標
+		if !watched.Enabled(txInfo.To) {
+			detectionCounters.recordSuppressed(strings.ToLower(txInfo.To))
+			continue
+		}
+
+		// 曾以 pending 狀態發出的交易在此完成對帳
+		if wait, ok := pendingTxs.confirm(txInfo.Hash); ok {
+			logrus.WithFields(logrus.Fields{
+				"blockNumber": blockNumber,
+				"txHash":      txInfo.Hash,
+				"pendingFor":  wait.String(),
+			}).Info("⛏️ pending 交易已上鏈")
+		}
+
+		address := strings.ToLower(txInfo.To)
+		matchesInBlock[address]++
+		detectionCounters.recordMatch(address)
+
+		// 記錄到區塊偵測索引，供 /detections 查詢
+		if blockNumErr == nil {
+			detections.Add(blockNum, txInfo)
+		}
+
+		// 低於金額門檻的小額交易不轉發，也不佔用取樣名額
+		if !meetsMinValue(txInfo, minValueWei) {
+			recordFiltered(blockNumber, txInfo, filterReasonBelowMinValue)
+			continue
+		}
+
+		// 流量過高時只轉發取樣的交易，但所有交易都會計入指標
+		if !sampler.shouldForward(address, matchesInBlock[address]) {
+			recordFiltered(blockNumber, txInfo, filterReasonSampled)
+			continue
+		}
+
+		// 鏈重組期間先緩衝，等鏈穩定後只轉發仍在 canonical 鏈上的偵測
+		forward := func() { forwardDetection(blockNumber, blockMessage.BlockHash, txInfo, workerID) }
+		if reorgs != nil && blockNumErr == nil && reorgs.hold(blockNum, blockMessage.BlockHash, forward) {
+			logrus.WithFields(logrus.Fields{
+				"blockNumber": blockNumber,
+				"txHash":      txInfo.Hash,
+			}).Info("⏸️ 受鏈重組影響，暫緩或丟棄偵測")
+			continue
+		}
+
+		// 區塊尚未達到確認數時先暫存；確認後再次檢查重組，已被孤立的區塊不轉發
+		if confirmations != nil && blockNumErr == nil && confirmations.wait(blockNum, func() {
+			if reorgs == nil || !reorgs.hold(blockNum, blockMessage.BlockHash, forward) {
+				forward()
+			}
+		}) {
+			continue
+		}
+		ready = append(ready, txInfo)
+	}
+
+	if len(ready) > 0 {
+		forwardDetections(blockNumber, blockMessage.BlockHash, ready, workerID)
+	}
+}
+
+// forwardDetection 將單筆偵測到的目標交易推送到交易隊列並發送 webhook 通知
+func forwardDetection(blockNumber, blockHash string, txInfo TransactionInfo, workerID int) {
+	forwardDetections(blockNumber, blockHash, []TransactionInfo{txInfo}, workerID)
+}
+
+// forwardDetections 將同一區塊偵測到的目標交易一次推送到交易隊列，設定 webhook 時由 webhook 消費者發送通知
+// 轉發的偵測會被記錄，所在區塊之後被重組掉時發布撤回事件
+func forwardDetections(blockNumber, blockHash string, txs []TransactionInfo, workerID int) {
+	// 發現目標交易，推送到交易隊列進行進一步處理
+	msgs := make([]broker.Message, 0, len(txs))
+	for _, txInfo := range txs {
+		detectionCounters.recordForward(strings.ToLower(txInfo.To))
+		txMsgData, _ := marshalEvent(eventTypeDeposit, txInfo)
+		msg := broker.NewMessage(generateMessageID(), txMsgData, transactionQueueName)
+		msg.Headers[blockNumberHeader] = blockNumber // 供 webhook 通知帶上區塊號
+		msgs = append(msgs, msg)
+	}
+
+	err := brokerFor(brokerPurposeAlerts).PushBatch(transactionQueueName, msgs)
+	var overflow *broker.BatchOverflowError
+	if errors.As(err, &overflow) {
+		logrus.WithFields(logrus.Fields{
+			"blockNumber":  blockNumber,
+			"deadLettered": overflow.DeadLettered,
+		}).Warn("⚠️ 交易隊列已滿，部分偵測已移入死信隊列")
+		err = overflow.Err // 批次之後仍可能因速率限制等原因提前停止
+	}
+	if err != nil {
+		logrus.WithField("blockNumber", blockNumber).WithError(err).Warn("⚠️ 推送偵測到交易隊列失敗")
+	}
+
+	if n, err := strconv.ParseUint(blockNumber, 10, 64); err == nil && retractions != nil {
+		retractions.record(n, blockHash, txs)
+	}
+
+	// 同時廣播到 deposits 主題，供 /stream/deposits 即時推送
+	for _, msg := range msgs {
+		if err := brokerFor(brokerPurposeAlerts).Publish(depositsTopicName, msg); err != nil {
+			logrus.WithError(err).Debug("⚠️ 廣播偵測到 deposits 主題失敗")
+		}
+	}
+
+	for _, txInfo := range txs {
+		logDetection(blockNumber, txInfo, workerID)
+	}
+}
+
+// logDetection 記錄單筆偵測的日誌
+func logDetection(blockNumber string, txInfo TransactionInfo, workerID int) {
+	logrus.WithFields(logrus.Fields{
+		"blockNumber": blockNumber,
+		"txHash":      txInfo.Hash,
+		"to":          txInfo.To,
+		"valueWei":    txInfo.Value,
+		"workerID":    workerID,
+	}).Info("🚨🚨🚨 偵測到目標存款！")
+}
+
+// startWatching 函式包含了我們所有的核心監聽邏輯
+// ctx 取消時 (收到停止信號) 監聽器與本次連線啟動的 worker 都會停止
+func startWatching(ctx context.Context, watcher *blockWatcher) {
 	logrus.WithFields(logrus.Fields{
 		"targetAddress": targetAddress,
 	}).Info("🎯 正在啟動監聽器...")
 
-	client, err := ethclient.Dial(wssURL)
+	// 依序嘗試設定的端點，全部失敗時返回並由外層退避後重試
+	rpcClient, err := endpoints.dial(ctx)
 	if err != nil {
 		logrus.WithError(err).Error("❌ WebSocket 連線失敗")
 		return
 	}
+	// 訂閱中斷後下一次連線改用下一個端點
+	defer endpoints.rotate()
+	client := ethclient.NewClient(rpcClient)
 	defer client.Close()
 	logrus.Info("🎉 WebSocket 連線成功！")
 
-	headers := make(chan *types.Header)
-	sub, err := client.SubscribeNewHead(context.Background(), headers)
-	if err != nil {
-		logrus.WithError(err).Error("❌ 訂閱新區塊事件失敗")
-		return
-	}
-	logrus.Info("✅ 訂閱成功！正在等待新的區塊...")
+	// 本次連線的 worker 與 mempool 監聽在連線結束時一併停止
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// --- 使用 Message Broker 處理區塊 ---
-	const numWorkers = 4
-	const blockQueueName = "blocks"
-	const transactionQueueName = "transactions"
-
-	// 啟動 Worker Pool 從 Broker 消費消息
-	for i := 1; i <= numWorkers; i++ {
-		go func(workerID int) {
-			for {
-				// 從區塊隊列拉取消息
-				blockMsg, err := messageBroker.PullWithTimeout(blockQueueName, 1*time.Second)
-				if err != nil || blockMsg == nil {
-					continue
-				}
-
-				// 解析區塊消息
-				var blockMessage BlockMessage
-				if err := json.Unmarshal(blockMsg.Body, &blockMessage); err != nil {
-					logrus.WithError(err).Warn("⚠️ 解析區塊消息失敗")
-					continue
-				}
-
-				logrus.WithFields(logrus.Fields{
-					"workerID":    workerID,
-					"blockNumber": blockMessage.BlockNumber,
-					"txCount":     blockMessage.TxCount,
-				}).Debug("🛠️ 工人開始處理區塊")
-
-				// 從消息中獲取區塊信息 (已預處理)
-				blockNumber := blockMessage.BlockNumber
-				
-				// 處理交易 (如果有目標交易)
-				for _, txInfo := range blockMessage.Transactions {
-					if strings.EqualFold(txInfo.To, targetAddress) {
-						// 發現目標交易，推送到交易隊列進行進一步處理
-						txMsgData, _ := json.Marshal(txInfo)
-						txMsg := broker.NewMessage(
-							generateMessageID(),
-							txMsgData,
-							transactionQueueName,
-						)
-						
-						messageBroker.Push(transactionQueueName, txMsg)
-						
-						logrus.WithFields(logrus.Fields{
-							"blockNumber": blockNumber,
-							"txHash":      txInfo.Hash,
-							"to":          txInfo.To,
-							"valueWei":    txInfo.Value,
-							"workerID":    workerID,
-						}).Info("🚨🚨🚨 偵測到目標存款！")
-					}
-				}
+	// 讓 /replay/block 使用目前的連線
+	replayBlocks.set(client, watcher)
+	defer replayBlocks.set(nil, nil)
+
+	// mempool 監聽 (可選)，與區塊訂閱共用同一條連線
+	if mempoolCfg.Enabled {
+		watcher := newMempoolWatcher(newRPCPendingClient(rpcClient), mempoolCfg, pendingTxs, mempoolCounts)
+		go func() {
+			if err := watcher.Run(ctx); err != nil {
+				logrus.WithError(err).Warn("⚠️ mempool 訂閱中斷")
 			}
-		}(i)
+		}()
 	}
 
-	// 主迴圈：接收新區塊並發送到隊列
-	for {
-		select {
-		case err := <-sub.Err():
-			logrus.WithError(err).Error("😥 訂閱連線中斷")
-			// Broker 會自動處理清理，無需手動關閉
-			return              // 返回後，main 函式的迴圈會讓我們重試
-
-		case header := <-headers:
-			// 收到新區塊，立刻發送到處理隊列，不阻塞
-			// 創建區塊消息並推送到 Broker
-			block, err := client.BlockByHash(context.Background(), header.Hash())
-			if err != nil {
-				logrus.WithError(err).Warn("⚠️ 獲取區塊詳情失敗")
-				continue
-			}
-			
-			var transactions []TransactionInfo
-			for _, tx := range block.Transactions() {
-				if tx.To() != nil && strings.EqualFold(tx.To().Hex(), targetAddress) {
-					// 只包含目標地址的交易
-					txInfo := TransactionInfo{
-						Hash:     tx.Hash().Hex(),
-						To:       tx.To().Hex(),
-						Value:    tx.Value().String(),
-						GasPrice: tx.GasPrice().String(),
-					}
-					// 簡化處理，不獲取 from 地址（需要簽名信息）
-					txInfo.From = "unknown"
-					transactions = append(transactions, txInfo)
-				}
-			}
-			
-			blockMessage := BlockMessage{
-				BlockNumber:  header.Number.String(),
-				BlockHash:    header.Hash().Hex(),
-				Timestamp:    time.Now(),
-				TxCount:      len(block.Transactions()),
-				Transactions: transactions,
-			}
-			
-			blockMsgData, _ := json.Marshal(blockMessage)
-			msg := broker.NewMessage(
-				generateMessageID(),
-				blockMsgData,
-				blockQueueName,
-			)
-			
-			err = messageBroker.Push(blockQueueName, msg)
-			if err != nil {
-				logrus.WithField("blockNumber", header.Number.String()).WithError(err).Warn("⚠️ 推送區塊到隊列失敗！")
-			}
-		}
+	// --- 使用 Message Broker 處理區塊 ---
+	numWorkers := workerCountFromEnv()
+	logrus.WithField("workers", numWorkers).Info("👷 啟動區塊 worker")
+
+	// 主迴圈：訂閱新區塊並發送到隊列，訂閱中斷時會先完成已收到的區塊再返回重新連線
+	err = watchSession(ctx, numWorkers, func(ctx context.Context) error {
+		return watcher.watch(ctx, client)
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logrus.WithError(err).Error("😥 訂閱連線中斷")
 	}
 }
 
+// watchSession 在一次連線期間執行 watch，並啟動 numWorkers 個 worker 消費區塊隊列
+// watch 返回時停止 worker 並等待它們結束，重新連線不會留下上一次連線的 worker
+func watchSession(ctx context.Context, numWorkers int, watch func(ctx context.Context) error) error {
+	stopWorkers := startWorkerPool(ctx, numWorkers)
+	defer stopWorkers()
+	return watch(ctx)
+}
+
 func main() {
 	// 在程式啟動時，從 .env 檔案載入環境變數
 	err := godotenv.Load()
@@ -286,12 +603,124 @@ func main() {
 		logrus.Warn("⚠️ 找不到 .env 檔案，將會直接使用環境變數")
 	}
 
+	// 日誌等級由 LOG_LEVEL 設定 (預設 info)，LOG_FORMAT=json 時輸出 JSON
+	configureLogging()
+
+	// 收到 SIGINT / SIGTERM 時停止監聽並優雅關閉 HTTP 服務器與 Broker
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 記錄啟動時間
-	startTime = time.Now()
+	startTime = clock.Now()
 	
-	// 初始化 Message Broker
-	messageBroker = broker.NewSimpleBroker()
-	defer messageBroker.Close()
+	// 初始化 Message Broker：區塊與告警管線使用各自獨立的 Broker
+	// 每個隊列的緩衝大小可由 QUEUE_BUFFER_SIZE 設定，隊列滿時新消息會進入死信隊列
+	// 過期的消息預設直接丟棄，EXPIRED_TO_DLQ=true 時改為移入死信隊列
+	// 設定 DEDUPE_WINDOW 時，窗口內以相同 ID 重複推送的消息會被丟棄
+	// 每個死信隊列最多保存 MAX_DLQ_SIZE 條消息 (預設不限制)，超過時淘汰最舊的，DLQ_OVERFLOW=reject 時改為丟棄新的
+	// 設定 DLQ_RETENTION 時，每隔 DLQ_JANITOR_INTERVAL (預設 1m) 清除超過保留期限的死信消息
+	// 訂閱者跟不上時預設丟棄廣播消息，SUBSCRIBER_OVERFLOW=deadletter 時寫入主題的死信隊列 (topic:主題名)，
+	// =block 時最多等待 SUBSCRIBER_BLOCK_TIMEOUT (預設 100ms) 後再寫入死信隊列
+	brokerCfg := broker.BrokerConfig{
+		QueueBufferSize:    envInt("QUEUE_BUFFER_SIZE", broker.DefaultQueueBufferSize),
+		DeadLetterExpired:  os.Getenv("EXPIRED_TO_DLQ") == "true",
+		DedupeWindow:       envDuration("DEDUPE_WINDOW", 0),
+		MaxDLQSize:         envInt("MAX_DLQ_SIZE", 0),
+		DLQRetention:       envDuration("DLQ_RETENTION", 0),
+		DLQJanitorInterval: envDuration("DLQ_JANITOR_INTERVAL", 0),
+	}
+	if os.Getenv("DLQ_OVERFLOW") == "reject" {
+		brokerCfg.DLQOverflow = broker.DLQRejectNewest
+	}
+	switch os.Getenv("SUBSCRIBER_OVERFLOW") {
+	case "deadletter":
+		brokerCfg.SubscriberOverflow = broker.SubscriberOverflowDeadLetter
+	case "block":
+		brokerCfg.SubscriberOverflow = broker.SubscriberOverflowBlock
+		brokerCfg.SubscriberBlockTimeout = envDuration("SUBSCRIBER_BLOCK_TIMEOUT", defaultSubscriberBlockTimeout)
+	}
+	alertsBroker := broker.NewSimpleBrokerWithConfig(brokerCfg)
+
+	// 區塊隊列可由 BLOCK_QUEUE_BUFFER_SIZE 單獨加大，以承受重新連線後的補塊尖峰
+	blocksCfg := brokerCfg
+	if size := envInt("BLOCK_QUEUE_BUFFER_SIZE", 0); size > 0 {
+		blocksCfg.QueueBufferSizes = map[string]int{blockQueueName: size}
+		logrus.WithField("bufferSize", size).Info("📦 區塊隊列緩衝大小已設定")
+	}
+
+	// 設定 WAL_PATH 時區塊 Broker 以預寫日誌持久化，重啟後恢復尚未處理的區塊與死信
+	var blocksBroker *broker.SimpleBroker
+	var registeredBlocks broker.Broker
+	if path := os.Getenv("WAL_PATH"); path != "" {
+		blocksCfg.WALPath = path
+		blocksCfg.WALSync = os.Getenv("WAL_SYNC") == "true"
+		persistent, err := broker.NewPersistentBroker(blocksCfg)
+		if err != nil {
+			logrus.WithError(err).Fatal("❌ 開啟 WAL 失敗")
+		}
+		blocksBroker, registeredBlocks = persistent.SimpleBroker, persistent
+		logrus.WithFields(logrus.Fields{
+			"path": path,
+			"sync": blocksCfg.WALSync,
+		}).Info("💾 區塊 Broker 持久化已啟用")
+	} else {
+		blocksBroker = broker.NewSimpleBrokerWithConfig(blocksCfg)
+		registeredBlocks = blocksBroker
+	}
+
+	// 操作日誌 (可選)，保存最近 OPLOG_SIZE 次操作供事後排查
+	if size := envInt("OPLOG_SIZE", 0); size > 0 {
+		blocksBroker.EnableOpLog(size)
+		alertsBroker.EnableOpLog(size)
+		logrus.WithField("size", size).Info("📜 Broker 操作日誌已啟用")
+	}
+
+	// 每個事件跨所有階段的重試總上限 (可選)，避免毒訊息在各階段間無限重試
+	if budget := envInt("RETRY_BUDGET", 0); budget > 0 {
+		blocksBroker.SetRetryBudget(budget)
+		alertsBroker.SetRetryBudget(budget)
+		logrus.WithField("budget", budget).Info("🧮 全域重試預算已啟用")
+	}
+
+	// 隊列深度直方圖 (可選)，每隔 QUEUE_HISTOGRAM_INTERVAL 取樣一次
+	if interval := envDuration("QUEUE_HISTOGRAM_INTERVAL", 0); interval > 0 {
+		buckets := envFloats("QUEUE_HISTOGRAM_BUCKETS", broker.DefaultDepthBuckets)
+		for _, b := range []*broker.SimpleBroker{blocksBroker, alertsBroker} {
+			if err := b.EnableDepthHistogram(interval, buckets); err != nil {
+				logrus.WithError(err).Fatal("❌ 啟用隊列深度直方圖失敗")
+			}
+		}
+		logrus.WithFields(logrus.Fields{
+			"interval": interval,
+			"buckets":  buckets,
+		}).Info("📊 隊列深度直方圖已啟用")
+	}
+
+	// 區塊隊列 effectively-once 投遞 (可選)，避免重新連線後重複處理同一區塊
+	if window := envDuration("EFFECTIVELY_ONCE_WINDOW", 0); window > 0 {
+		maxIDs := envInt("EFFECTIVELY_ONCE_MAX_IDS", defaultEffectivelyOnceMaxIDs)
+		if err := blocksBroker.EnableEffectivelyOnce(window, maxIDs); err != nil {
+			logrus.WithError(err).Fatal("❌ 啟用 effectively-once 投遞失敗")
+		}
+		effectivelyOnceEnabled = true
+		logrus.WithFields(logrus.Fields{
+			"window": window,
+			"maxIDs": maxIDs,
+		}).Info("🔂 區塊隊列 effectively-once 投遞已啟用")
+	}
+
+	// 區塊隊列 at-least-once 投遞 (可選)，worker 崩潰或卡住時未確認的區塊會在逾時後重新投遞
+	if timeout := envDuration("VISIBILITY_TIMEOUT", 0); timeout > 0 {
+		if err := blocksBroker.EnableAcks(timeout); err != nil {
+			logrus.WithError(err).Fatal("❌ 啟用消息確認失敗")
+		}
+		acksEnabled = true
+		logrus.WithField("visibilityTimeout", timeout).Info("📬 區塊隊列 at-least-once 投遞已啟用")
+	}
+
+	messageBroker = blocksBroker
+	brokers.Register(brokerPurposeBlocks, registeredBlocks)
+	brokers.Register(brokerPurposeAlerts, alertsBroker)
 	
 	logrus.Info("🚀 高性能 Message Broker 已啟動")
 	logrus.WithFields(logrus.Fields{
@@ -299,15 +728,182 @@ func main() {
 		"broker_type":   "SimpleBroker",
 	}).Info("🎯 區塊鏈交易監聽服務已啟動")
 	
+	// 監控端點等待最新 Broker 統計的時限，超過時改用快取
+	brokerStatsCache.deadline = envDuration("STATS_DEADLINE", defaultStatsDeadline)
+
+	// 初始化區塊 cursor (可選)，用於重啟後得知上次處理到哪個區塊
+	cursor, err := newCursorFromEnv()
+	if err != nil {
+		logrus.WithError(err).Fatal("❌ 初始化區塊 cursor 失敗")
+	}
+	if cursor != nil {
+		blockCursor = cursor
+		defer blockCursor.Flush()
+
+		last, err := blockCursor.Get()
+		switch {
+		case err != nil:
+			logrus.WithError(err).Warn("⚠️ 讀取區塊 cursor 失敗")
+		case last == 0:
+			logrus.Info("📍 未找到區塊 cursor，視為全新啟動")
+		default:
+			logrus.WithField("lastBlock", last).Info("📍 已載入區塊 cursor")
+		}
+	}
+
+	// 初始化偵測取樣規則 (可選)
+	if sampler, err = newDetectionSamplerFromEnv(); err != nil {
+		logrus.WithError(err).Fatal("❌ 解析 SAMPLING_RULES 失敗")
+	}
+
+	// 存款偵測的最低金額 (可選)，過濾小額的粉塵交易
+	if minValueWei, err = minValueFromEnv(); err != nil {
+		logrus.WithError(err).Fatal("❌ 解析 MIN_VALUE_WEI 失敗")
+	}
+	if minValueWei != nil {
+		logrus.WithField("minValueWei", minValueWei.String()).Info("🪙 存款金額門檻已啟用")
+	}
+
+	// 監聽地址清單，WATCHED_ADDRESSES 可加入 targetAddress 以外的地址
+	watched = newWatchListFromEnv()
+	logrus.WithField("count", len(watched.List())).Info("👀 監聽地址清單已載入")
+
+	// 鏈重組保護，重組期間暫緩轉發偵測 (REORG_DEPTH=0 關閉)
+	reorgs = newReorgGuardFromEnv()
+	if reorgs != nil {
+		logrus.WithField("depth", reorgs.depth).Info("🔀 鏈重組保護已啟用")
+	}
+
+	// 已轉發偵測的撤回，所在區塊被重組掉時在 reorgs 主題發布 reverted 事件
+	retractions = newRetractionTracker()
+
+	// 確認數 (可選)，區塊達到 CONFIRMATIONS 個確認後才轉發其中的偵測
+	confirmations = newConfirmationTrackerFromEnv()
+	if confirmations != nil {
+		logrus.WithField("confirmations", confirmations.depth).Info("⏳ 偵測確認數已啟用")
+	}
+
+	// 近期失敗比例過高時 /health 回報 degraded (HEALTH_FAILURE_RATIO=0 關閉)
+	failureHealth = newFailureRatioTrackerFromEnv()
+	if failureHealth != nil {
+		logrus.WithFields(logrus.Fields{
+			"threshold": failureHealth.threshold,
+			"window":    failureHealth.window,
+		}).Info("🩺 失敗比例健康檢查已啟用")
+	}
+
+	// 被過濾交易的稽核隊列 (可選)
+	filteredAuditEnabled = filteredAuditEnabledFromEnv()
+	if filteredAuditEnabled {
+		logrus.WithField("queue", filteredQueueName).Info("🗂️ 被過濾交易稽核隊列已啟用")
+	}
+
+	// mempool 監聽設定 (可選)，流量高且依賴節點供應商支援
+	mempoolCfg = mempoolConfigFromEnv()
+	if mempoolCfg.Enabled {
+		mempoolCounts = &mempoolStats{}
+		logrus.WithFields(logrus.Fields{
+			"workers":     mempoolCfg.Workers,
+			"sampleEvery": mempoolCfg.SampleEvery,
+		}).Info("⏳ mempool 監聽已啟用")
+	}
+
+	// 初始化 webhook 通知器 (可選)
+	if notifier, err = newWebhookRouterFromEnv(); err != nil {
+		logrus.WithError(err).Fatal("❌ 解析 webhook 設定失敗")
+	}
+	if notifier != nil {
+		logrus.WithFields(logrus.Fields{
+			"mode":      notifier.mode,
+			"endpoints": len(notifier.endpoints),
+			"signed":    os.Getenv("WEBHOOK_SECRET") != "",
+		}).Info("🔔 Webhook 通知已啟用")
+	}
+
+	// 設定 DEPOSIT_DB_PATH 時將偵測到的存款保存到 SQLite 並開放 /deposits 查詢，
+	// 否則設定 DEPOSIT_SINK_PATH 時以 JSONL 保存 (可選)
+	var sink DepositSink = noopDepositSink{}
+	if path := os.Getenv("DEPOSIT_DB_PATH"); path != "" {
+		store, err := newSQLiteDepositStore(path)
+		if err != nil {
+			logrus.WithError(err).Fatal("❌ 開啟存款資料庫失敗")
+		}
+		defer store.Close()
+		depositStore, sink = store, store
+		logrus.WithField("path", path).Info("🗄️ 存款資料庫已啟用")
+	} else if path := os.Getenv("DEPOSIT_SINK_PATH"); path != "" {
+		jsonl, err := newJSONLDepositSink(path)
+		if err != nil {
+			logrus.WithError(err).Fatal("❌ 開啟存款紀錄檔失敗")
+		}
+		defer jsonl.Close()
+		sink = jsonl
+		logrus.WithField("path", path).Info("🗄️ 存款紀錄已啟用")
+	}
+
+	// 交易隊列消費者保存存款並發送 webhook (失敗時重試，重試耗盡後寫入死信隊列)
+	// 兩者都未設定時不啟動，交易隊列留給外部消費者
+	if _, noop := sink.(noopDepositSink); notifier != nil || !noop {
+		var hooks alertNotifier
+		if notifier != nil {
+			hooks = notifier
+		}
+		go runTransactionConsumer(ctx, brokerFor(brokerPurposeAlerts), sink, hooks)
+	}
+
+	// 啟動 DLQ 增長監控 (可選)，涵蓋所有 Broker 的死信隊列
+	if cfg := dlqMonitorConfigFromEnv(); cfg.enabled() {
+		var alerts alertNotifier
+		if notifier != nil {
+			alerts = notifier
+		}
+		dlqMon = newDLQMonitor(allBrokers, alerts, cfg)
+		go dlqMon.Run(ctx)
+		logrus.WithFields(logrus.Fields{
+			"growth":    cfg.GrowthThreshold,
+			"window":    cfg.Window,
+			"threshold": cfg.AbsoluteThreshold,
+		}).Info("📈 DLQ 增長告警已啟用")
+	}
+
 	// 啟動 HTTP API 服務器
-	go startHTTPServer()
+	httpAddr := os.Getenv("HTTP_ADDR")
+	if httpAddr == "" {
+		httpAddr = defaultHTTPAddr
+	}
+	server, err := startHTTPServer(httpAddr)
+	if err != nil {
+		logrus.WithError(err).Fatal("❌ HTTP 服務器啟動失敗")
+	}
+
+	// 從環境變數讀取 WSS URL，可用逗號分隔多個端點，連線失敗或訂閱中斷時輪換到下一個
+	urls := parseEndpoints(os.Getenv("ALCHEMY_WSS_URL"))
+	if len(urls) == 0 {
+		logrus.Fatal("❌ 環境變數 ALCHEMY_WSS_URL 未設定，請設定您的 Alchemy WebSocket URL")
+	}
+	endpoints = newEndpointPool(urls)
 
 	// --- 這是我們的「永動機」和「錯誤重試」核心 ---
-	for {
-		startWatching() // 啟動監聽器
+	watcher := newBlockWatcher()
+	backoff := newReconnectBackoff()
+	for ctx.Err() == nil {
+		started := clock.Now()
+		startWatching(ctx, watcher) // 啟動監聽器
+		if ctx.Err() != nil {
+			break
+		}
+
+		// 如果 startWatching 因為任何錯誤而返回，以指數退避等待後再重啟
+		delay := backoff.next(clock.Now().Sub(started))
+		logrus.WithField("delay", delay).Warn("監聽器已停止，將在退避後嘗試重啟...")
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
 
-		// 如果 startWatching 因為任何錯誤而返回，我們會在這裡等待 15 秒
-		logrus.Warn("監聽器已停止，將在 15 秒後嘗試重啟...")
-		time.Sleep(15 * time.Second)
+	logrus.Info("🛑 收到停止信號，正在關閉服務...")
+	if err := shutdownService(server, envDuration("SHUTDOWN_GRACE", defaultShutdownGrace)); err != nil {
+		logrus.WithError(err).Warn("⚠️ 服務未能在寬限時間內完整關閉")
 	}
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -303,7 +304,7 @@ func TestBrokerIntegrationFlow(t *testing.T) {
 	}
 	
 	// 模擬處理交易消息
-	txMsgData, _ := json.Marshal(tx)
+	txMsgData, _ := marshalEvent(eventTypeDeposit, tx)
 	txMsg := broker.NewMessage(generateMessageID(), txMsgData, "transactions")
 	err = messageBroker.Push("transactions", txMsg)
 	if err != nil {
@@ -317,7 +318,7 @@ func TestBrokerIntegrationFlow(t *testing.T) {
 	}
 	
 	var pulledTxInfo TransactionInfo
-	err = json.Unmarshal(pulledTxMsg.Body, &pulledTxInfo)
+	_, err = unmarshalEvent(pulledTxMsg.Body, &pulledTxInfo)
 	if err != nil {
 		t.Fatalf("Failed to unmarshal transaction message: %v", err)
 	}
@@ -389,4 +390,254 @@ func TestWorkerPoolIntegration(t *testing.T) {
 	if processedCount != numBlocks {
 		t.Errorf("Expected to process %d messages, got %d", numBlocks, processedCount)
 	}
-}
\ No newline at end of file
+}
+func TestHTTPScheduledEndpoint(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	msg := broker.NewMessage("retry-1", []byte("retry later"), "transactions")
+	messageBroker.PushDelayed("transactions", msg, time.Hour)
+
+	// 列出延遲消息
+	rr := httptest.NewRecorder()
+	handleScheduled(rr, httptest.NewRequest("GET", "/scheduled?queue=transactions", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var listing struct {
+		Count     int                       `json:"count"`
+		Scheduled []broker.ScheduledMessage `json:"scheduled"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &listing)
+	if listing.Count != 1 || listing.Scheduled[0].Message.ID != "retry-1" || listing.Scheduled[0].FireAt.IsZero() {
+		t.Errorf("Unexpected scheduled listing: %+v", listing)
+	}
+
+	// 取消延遲消息
+	rr = httptest.NewRecorder()
+	handleScheduled(rr, httptest.NewRequest("DELETE", "/scheduled?queue=transactions&id=retry-1", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d on cancel, got %d", http.StatusOK, rr.Code)
+	}
+	if len(messageBroker.GetScheduled("transactions")) != 0 {
+		t.Error("Expected scheduled message to be cancelled")
+	}
+
+	// 再次取消返回 404
+	rr = httptest.NewRecorder()
+	handleScheduled(rr, httptest.NewRequest("DELETE", "/scheduled?queue=transactions&id=retry-1", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for unknown message, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	// 缺少參數
+	rr = httptest.NewRecorder()
+	handleScheduled(rr, httptest.NewRequest("GET", "/scheduled", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for missing queue, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHTTPOpLogEndpoint(t *testing.T) {
+	b := broker.NewSimpleBroker()
+	messageBroker = b
+	defer messageBroker.Close()
+
+	// 未開啟時返回 404
+	rr := httptest.NewRecorder()
+	handleOpLog(rr, httptest.NewRequest("GET", "/oplog", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d when disabled, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	b.EnableOpLog(100)
+	for i := 0; i < 3; i++ {
+		b.Push("blocks", broker.NewMessage(fmt.Sprintf("block-%d", i), []byte("data"), "blocks"))
+	}
+
+	rr = httptest.NewRecorder()
+	handleOpLog(rr, httptest.NewRequest("GET", "/oplog?limit=2", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var response struct {
+		Brokers map[string][]broker.OpLogEntry `json:"brokers"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	entries := response.Brokers["default"]
+	if len(entries) != 2 || entries[0].MessageID != "block-1" || entries[1].MessageID != "block-2" {
+		t.Errorf("Unexpected op log entries: %+v", entries)
+	}
+
+	rr = httptest.NewRecorder()
+	handleOpLog(rr, httptest.NewRequest("GET", "/oplog?limit=abc", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for invalid limit, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHTTPHealthUptimeFakeClock(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	fake := broker.NewFakeClock(time.Now())
+	clock = fake
+	defer func() { clock = broker.RealClock{} }()
+
+	startTime = clock.Now()
+	fake.Advance(5 * time.Minute)
+
+	rr := httptest.NewRecorder()
+	handleHealth(rr, httptest.NewRequest("GET", "/health", nil))
+
+	var health map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &health)
+	if health["uptime"] != float64(300) {
+		t.Errorf("Expected uptime of 300s from the fake clock, got %v", health["uptime"])
+	}
+}
+
+func TestHTTPQueueHistogramEndpoint(t *testing.T) {
+	fake := broker.NewFakeClock(time.Now())
+	b := broker.NewSimpleBrokerWithClock(fake)
+	messageBroker = b
+	defer messageBroker.Close()
+
+	rr := httptest.NewRecorder()
+	handleQueueHistogram(rr, httptest.NewRequest("GET", "/metrics/queue-histogram", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d when disabled, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	b.EnableDepthHistogram(time.Second, []float64{1, 10})
+	b.Push("blocks", broker.NewMessage("1", []byte("data"), "blocks"))
+	b.Push("blocks", broker.NewMessage("2", []byte("data"), "blocks"))
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+	fake.BlockUntil(1)
+
+	rr = httptest.NewRecorder()
+	handleQueueHistogram(rr, httptest.NewRequest("GET", "/metrics/queue-histogram", nil))
+	for _, want := range []string{
+		`txwatcher_queue_depth_bucket{broker="default",queue="blocks",le="1"} 0`,
+		`txwatcher_queue_depth_bucket{broker="default",queue="blocks",le="10"} 1`,
+		`txwatcher_queue_depth_bucket{broker="default",queue="blocks",le="+Inf"} 1`,
+		`txwatcher_queue_depth_count{broker="default",queue="blocks"} 1`,
+	} {
+		if !bytes.Contains(rr.Body.Bytes(), []byte(want)) {
+			t.Errorf("Expected %q in histogram output, got:\n%s", want, rr.Body.String())
+		}
+	}
+}
+
+func TestStartHTTPServerEphemeralPort(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+	startTime = time.Now()
+
+	server, err := startHTTPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("startHTTPServer failed: %v", err)
+	}
+	defer server.Close()
+
+	if server.Addr == "127.0.0.1:0" {
+		t.Fatalf("Expected server.Addr to hold the assigned port, got %s", server.Addr)
+	}
+
+	resp, err := http.Get("http://" + server.Addr + "/health")
+	if err != nil {
+		t.Fatalf("GET /health failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 from /health, got %d", resp.StatusCode)
+	}
+
+	// 位址已被佔用時返回錯誤而不是在背景失敗
+	if _, err := startHTTPServer(server.Addr); err == nil {
+		t.Error("Expected error when the address is already in use")
+	}
+}
+
+func TestHTTPDLQPagination(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	for i := 0; i < 5; i++ {
+		messageBroker.MoveToDLQ("paged", broker.NewMessage(fmt.Sprintf("dead-%d", i), []byte("failed"), "paged"))
+	}
+
+	fetch := func(query string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		handleDLQ(rr, httptest.NewRequest(http.MethodGet, "/dlq?queue=paged"+query, nil))
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response
+	}
+
+	// 第一頁與最後的部分頁
+	for _, tc := range []struct {
+		query string
+		ids   []string
+		limit float64
+	}{
+		{"&limit=2", []string{"dead-0", "dead-1"}, 2},
+		{"&offset=3&limit=2", []string{"dead-3", "dead-4"}, 2},
+		{"&offset=4&limit=2", []string{"dead-4"}, 2},
+		{"&offset=9", []string{}, defaultDLQPageLimit},
+		{"&limit=100000", []string{"dead-0", "dead-1", "dead-2", "dead-3", "dead-4"}, maxDLQPageLimit},
+	} {
+		rr, response := fetch(tc.query)
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tc.query, rr.Code)
+			continue
+		}
+		messages := response["messages"].([]interface{})
+		ids := make([]string, len(messages))
+		for i, m := range messages {
+			ids[i] = m.(map[string]interface{})["id"].(string)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tc.ids) {
+			t.Errorf("%s: expected %v, got %v", tc.query, tc.ids, ids)
+		}
+		if response["count"] != float64(5) || response["limit"] != tc.limit {
+			t.Errorf("%s: expected count 5 and limit %v, got %v and %v", tc.query, tc.limit, response["count"], response["limit"])
+		}
+	}
+
+	for _, query := range []string{"&offset=-1", "&offset=abc", "&limit=0", "&limit=x"} {
+		if rr, _ := fetch(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestHTTPHealthReportsBuildInfoAndCounts(t *testing.T) {
+	withBrokerRegistry(t)
+	startTime = time.Now()
+
+	brokerFor(brokerPurposeBlocks).Push("blocks", broker.NewMessage("b-1", []byte("block"), "blocks"))
+	brokerFor(brokerPurposeBlocks).Pull("blocks")
+	brokerFor(brokerPurposeAlerts).Push("transactions", broker.NewMessage("t-1", []byte("tx"), "transactions"))
+	brokerFor(brokerPurposeAlerts).MoveToDLQ("transactions", broker.NewMessage("t-2", []byte("tx"), "transactions"))
+
+	rr := httptest.NewRecorder()
+	handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Failed to parse health response: %v", err)
+	}
+
+	// 未以 -ldflags 設定時使用預設值
+	if health["version"] != "dev" || health["commit"] != "dev" {
+		t.Errorf("Expected dev build info, got version=%v commit=%v", health["version"], health["commit"])
+	}
+	for key, want := range map[string]float64{"total_messages": 2, "processed_messages": 1, "failed_messages": 1} {
+		if health[key] != want {
+			t.Errorf("Expected %s = %v, got %v", key, want, health[key])
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/sirupsen/logrus"
+)
+
+// pendingQueueName 是尚未上鏈 (mempool) 交易的隊列
+const pendingQueueName = "pending"
+
+// mempool 監聽的預設值
+const (
+	defaultMempoolWorkers     = 8
+	defaultMempoolBuffer      = 1000
+	defaultMempoolSampleEvery = 1
+	defaultPendingTTL         = 30 * time.Minute
+)
+
+// pendingTxClient 是 mempool 監聽所需的節點操作，方便在測試中替換
+type pendingTxClient interface {
+	SubscribePendingTransactions(ctx context.Context, ch chan<- common.Hash) (ethereum.Subscription, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+}
+
+// rpcPendingClient 透過 eth_subscribe("newPendingTransactions") 訂閱 pending 交易
+type rpcPendingClient struct {
+	rpc *rpc.Client
+	eth *ethclient.Client
+}
+
+// newRPCPendingClient 以同一條 RPC 連線創建 pending 交易客戶端
+func newRPCPendingClient(c *rpc.Client) *rpcPendingClient {
+	return &rpcPendingClient{rpc: c, eth: ethclient.NewClient(c)}
+}
+
+// SubscribePendingTransactions 訂閱新的 pending 交易 hash
+func (c *rpcPendingClient) SubscribePendingTransactions(ctx context.Context, ch chan<- common.Hash) (ethereum.Subscription, error) {
+	return c.rpc.EthSubscribe(ctx, ch, "newPendingTransactions")
+}
+
+// TransactionByHash 依 hash 查詢交易
+func (c *rpcPendingClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	return c.eth.TransactionByHash(ctx, hash)
+}
+
+// mempoolConfig 是 mempool 監聽的設定
+type mempoolConfig struct {
+	Enabled     bool
+	Workers     int // 查詢交易詳情的 worker 數
+	Buffer      int // 等待查詢的 hash 緩衝區大小，滿了就丟棄
+	SampleEvery int // 每 N 個 pending hash 只查詢一個，1 表示全部查詢
+}
+
+// mempoolConfigFromEnv 從 MEMPOOL_* 環境變數讀取設定
+func mempoolConfigFromEnv() mempoolConfig {
+	cfg := mempoolConfig{
+		Enabled:     strings.EqualFold(os.Getenv("MEMPOOL_WATCH"), "true"),
+		Workers:     envInt("MEMPOOL_WORKERS", defaultMempoolWorkers),
+		Buffer:      envInt("MEMPOOL_BUFFER", defaultMempoolBuffer),
+		SampleEvery: envInt("MEMPOOL_SAMPLE_EVERY", defaultMempoolSampleEvery),
+	}
+	if cfg.Workers < 1 {
+		cfg.Workers = defaultMempoolWorkers
+	}
+	if cfg.Buffer < 1 {
+		cfg.Buffer = defaultMempoolBuffer
+	}
+	if cfg.SampleEvery < 1 {
+		cfg.SampleEvery = defaultMempoolSampleEvery
+	}
+	return cfg
+}
+
+// pendingTracker 記錄已發出的 pending 交易，以便上鏈時對帳
+type pendingTracker struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	seen map[string]time.Time // hash (小寫) → 首次發現時間
+	now  func() time.Time
+}
+
+// newPendingTracker 創建 pending 交易追蹤器，超過 ttl 仍未上鏈的交易會被遺忘
+func newPendingTracker(ttl time.Duration) *pendingTracker {
+	return &pendingTracker{ttl: ttl, seen: make(map[string]time.Time), now: time.Now}
+}
+
+// add 記錄一筆 pending 交易，已記錄過則返回 false
+func (p *pendingTracker) add(hash string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for h, at := range p.seen {
+		if now.Sub(at) > p.ttl {
+			delete(p.seen, h)
+		}
+	}
+
+	key := strings.ToLower(hash)
+	if _, exists := p.seen[key]; exists {
+		return false
+	}
+	p.seen[key] = now
+	return true
+}
+
+// confirm 在交易上鏈時移除記錄，返回交易是否曾以 pending 狀態發出及其等待時間
+func (p *pendingTracker) confirm(hash string) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := strings.ToLower(hash)
+	at, exists := p.seen[key]
+	if !exists {
+		return 0, false
+	}
+	delete(p.seen, key)
+	return p.now().Sub(at), true
+}
+
+// mempoolStats 是 mempool 監聽的計數，跨重新連線累積
+type mempoolStats struct {
+	received atomic.Int64
+	dropped  atomic.Int64
+	emitted  atomic.Int64
+}
+
+// mempoolWatcher 訂閱 pending 交易並將匹配監聽地址的交易推送到 pending 隊列
+type mempoolWatcher struct {
+	client  pendingTxClient
+	cfg     mempoolConfig
+	tracker *pendingTracker
+	stats   *mempoolStats
+	match   func(to string) bool
+}
+
+// newMempoolWatcher 創建 mempool 監聽器
+func newMempoolWatcher(client pendingTxClient, cfg mempoolConfig, tracker *pendingTracker, stats *mempoolStats) *mempoolWatcher {
+	return &mempoolWatcher{
+		client:  client,
+		cfg:     cfg,
+		tracker: tracker,
+		stats:   stats,
+		match: func(to string) bool {
+			return watched.Enabled(to)
+		},
+	}
+}
+
+// Run 訂閱 pending 交易直到 ctx 取消或訂閱中斷
+func (m *mempoolWatcher) Run(ctx context.Context) error {
+	hashes := make(chan common.Hash, m.cfg.Buffer)
+	incoming := make(chan common.Hash, m.cfg.Buffer)
+
+	sub, err := m.client.SubscribePendingTransactions(ctx, incoming)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	// 獨立的 worker pool，避免高流量的 pending 交易拖慢區塊處理
+	var wg sync.WaitGroup
+	for i := 0; i < m.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hash := range hashes {
+				m.handle(ctx, hash)
+			}
+		}()
+	}
+	defer func() {
+		close(hashes)
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case hash := <-incoming:
+			n := m.stats.received.Add(1)
+			if (n-1)%int64(m.cfg.SampleEvery) != 0 {
+				continue
+			}
+			select {
+			case hashes <- hash:
+			default:
+				// 緩衝區已滿，丟棄以保護節點與 Broker
+				m.stats.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// handle 查詢單筆 pending 交易並在匹配時推送到 pending 隊列
+func (m *mempoolWatcher) handle(ctx context.Context, hash common.Hash) {
+	tx, isPending, err := m.client.TransactionByHash(ctx, hash)
+	if err != nil || tx == nil || !isPending || tx.To() == nil {
+		return // 已上鏈、已被替換或節點查無此交易
+	}
+	if !m.match(tx.To().Hex()) {
+		return
+	}
+	if m.tracker != nil && !m.tracker.add(tx.Hash().Hex()) {
+		return // 已經發出過
+	}
+
+	txInfo := TransactionInfo{
+		Hash:     tx.Hash().Hex(),
+		To:       tx.To().Hex(),
+		From:     "unknown",
+		Value:    tx.Value().String(),
+		GasPrice: tx.GasPrice().String(),
+		Pending:  true,
+	}
+	txData, _ := marshalEvent(eventTypePending, txInfo)
+	msg := broker.NewMessage(generateMessageID(), txData, pendingQueueName)
+	if err := brokerFor(brokerPurposeAlerts).Push(pendingQueueName, msg); err != nil {
+		logrus.WithField("txHash", txInfo.Hash).WithError(err).Warn("⚠️ 推送 pending 交易到隊列失敗")
+		return
+	}
+	m.stats.emitted.Add(1)
+
+	logrus.WithFields(logrus.Fields{
+		"txHash": txInfo.Hash,
+		"value":  txInfo.Value,
+	}).Info("⏳ 偵測到尚未上鏈的目標交易")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// mockSubscription 是測試用的訂閱
+type mockSubscription struct {
+	errCh chan error
+	once  sync.Once
+}
+
+func (s *mockSubscription) Unsubscribe()      { s.once.Do(func() { close(s.errCh) }) }
+func (s *mockSubscription) Err() <-chan error { return s.errCh }
+
+// mockPendingClient 是測試用的節點客戶端，透過 hashes 送出 pending 交易
+type mockPendingClient struct {
+	hashes chan common.Hash
+	txs    map[common.Hash]*types.Transaction
+	sub    *mockSubscription
+}
+
+func newMockPendingClient(txs ...*types.Transaction) *mockPendingClient {
+	c := &mockPendingClient{
+		hashes: make(chan common.Hash),
+		txs:    make(map[common.Hash]*types.Transaction),
+		sub:    &mockSubscription{errCh: make(chan error, 1)},
+	}
+	for _, tx := range txs {
+		c.txs[tx.Hash()] = tx
+	}
+	return c
+}
+
+func (c *mockPendingClient) SubscribePendingTransactions(ctx context.Context, ch chan<- common.Hash) (ethereum.Subscription, error) {
+	go func() {
+		for hash := range c.hashes {
+			ch <- hash
+		}
+	}()
+	return c.sub, nil
+}
+
+func (c *mockPendingClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	tx, ok := c.txs[hash]
+	if !ok {
+		return nil, false, errors.New("not found")
+	}
+	return tx, true, nil
+}
+
+func newTestTx(nonce uint64, to string) *types.Transaction {
+	addr := common.HexToAddress(to)
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       &addr,
+		Value:    big.NewInt(1000),
+		Gas:      21000,
+		GasPrice: big.NewInt(1),
+	})
+}
+
+// runMempoolWatcher 送出 hashes 並在全部被接收、處理完畢後停止監聽器
+func runMempoolWatcher(t *testing.T, watcher *mempoolWatcher, client *mockPendingClient, hashes ...common.Hash) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx) }()
+
+	for _, hash := range hashes {
+		client.hashes <- hash
+	}
+	close(client.hashes)
+
+	deadline := time.Now().Add(time.Second)
+	for watcher.stats.received.Load() < int64(len(hashes)) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Run 返回前會等待 worker 處理完緩衝區中的 hash
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+}
+
+func TestMempoolWatcherEmitsPendingMatches(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	match := newTestTx(1, targetAddress)
+	other := newTestTx(2, "0x0000000000000000000000000000000000000001")
+	client := newMockPendingClient(match, other)
+
+	tracker := newPendingTracker(time.Minute)
+	stats := &mempoolStats{}
+	watcher := newMempoolWatcher(client, mempoolConfig{Enabled: true, Workers: 2, Buffer: 10, SampleEvery: 1}, tracker, stats)
+
+	// 同一筆交易重複出現只發出一次，節點查無的交易被忽略
+	runMempoolWatcher(t, watcher, client, match.Hash(), other.Hash(), match.Hash(), common.HexToHash("0xdead"))
+
+	msg, err := messageBroker.Pull(pendingQueueName)
+	if err != nil || msg == nil {
+		t.Fatalf("Expected a pending transaction, got %v (err=%v)", msg, err)
+	}
+	var txInfo TransactionInfo
+	unmarshalEvent(msg.Body, &txInfo)
+	if !txInfo.Pending || txInfo.Hash != match.Hash().Hex() {
+		t.Errorf("Expected pending match %s, got %+v", match.Hash().Hex(), txInfo)
+	}
+
+	if extra, _ := messageBroker.Pull(pendingQueueName); extra != nil {
+		t.Errorf("Expected a single pending message, got another: %s", extra.Body)
+	}
+	if stats.received.Load() != 4 || stats.emitted.Load() != 1 {
+		t.Errorf("Unexpected stats: received=%d emitted=%d", stats.received.Load(), stats.emitted.Load())
+	}
+
+	// 上鏈時完成對帳
+	if _, ok := tracker.confirm(match.Hash().Hex()); !ok {
+		t.Error("Expected emitted pending transaction to be reconcilable")
+	}
+	if _, ok := tracker.confirm(match.Hash().Hex()); ok {
+		t.Error("Expected transaction to be reconciled only once")
+	}
+}
+
+func TestMempoolWatcherSampling(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	var txs []*types.Transaction
+	for i := 0; i < 6; i++ {
+		txs = append(txs, newTestTx(uint64(i), targetAddress))
+	}
+	client := newMockPendingClient(txs...)
+	stats := &mempoolStats{}
+	watcher := newMempoolWatcher(client, mempoolConfig{Enabled: true, Workers: 1, Buffer: 10, SampleEvery: 3}, nil, stats)
+
+	var hashes []common.Hash
+	for _, tx := range txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	runMempoolWatcher(t, watcher, client, hashes...)
+
+	// 每 3 個 hash 只查詢一個
+	if stats.emitted.Load() != 2 {
+		t.Errorf("Expected 2 sampled transactions emitted, got %d", stats.emitted.Load())
+	}
+}
+
+func TestProcessBlockMessageReconcilesPending(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	pendingTxs = newPendingTracker(time.Minute)
+	defer func() { pendingTxs = newPendingTracker(defaultPendingTTL) }()
+
+	pendingTxs.add("0xABC")
+	processBlockMessage(BlockMessage{
+		BlockNumber:  "700",
+		Transactions: []TransactionInfo{{Hash: "0xabc", To: targetAddress, Value: "1"}},
+	}, 1)
+
+	if _, ok := pendingTxs.confirm("0xabc"); ok {
+		t.Error("Expected mined transaction to be removed from pending tracker")
+	}
+}
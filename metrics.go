@@ -0,0 +1,271 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// metricDesc 描述一個由統計快照計算出的指標
+type metricDesc[T any] struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(T) float64
+}
+
+// brokerMetric 是以 broker 為標籤的指標
+type brokerMetric = metricDesc[map[string]interface{}]
+
+// queueMetric 是以 broker 與 queue 為標籤的指標
+type queueMetric = metricDesc[*broker.QueueStats]
+
+var (
+	statsStaleDesc    = prometheus.NewDesc("stats_stale", "Whether broker stats are served from cache because a fresh read timed out", nil, nil)
+	statsAgeDesc      = prometheus.NewDesc("stats_age_seconds", "Age of the broker stats snapshot", nil, nil)
+	messagesDesc      = prometheus.NewDesc("messages_total", "Total messages processed", nil, nil)
+	processedDesc     = prometheus.NewDesc("messages_processed_total", "Total messages processed successfully", nil, nil)
+	failedDesc        = prometheus.NewDesc("messages_failed_total", "Total messages failed", nil, nil)
+	activeQueuesDesc  = prometheus.NewDesc("active_queues", "Number of active queues", nil, nil)
+	uptimeDesc        = prometheus.NewDesc("uptime_seconds", "Uptime in seconds", nil, nil)
+	queueWaitDesc     = prometheus.NewDesc("queue_wait_seconds", "Time messages waited in the queue before being pulled", []string{"broker", "queue"}, nil)
+	scanLimitHitsDesc = prometheus.NewDesc("block_scan_limit_hits_total", "Blocks whose transaction count exceeded BLOCK_SCAN_LIMIT", nil, nil)
+
+	detectionsMatchedDesc    = prometheus.NewDesc("detections_matched_total", "Transactions matching a watched address, including unsampled ones", []string{"address"}, nil)
+	detectionsForwardedDesc  = prometheus.NewDesc("detections_forwarded_total", "Matching transactions forwarded after sampling", []string{"address"}, nil)
+	detectionsSuppressedDesc = prometheus.NewDesc("detections_suppressed_total", "Transactions to a watched address skipped because alerting is disabled", []string{"address"}, nil)
+
+	mempoolReceivedDesc = prometheus.NewDesc("mempool_pending_received_total", "Pending transaction hashes received from the node", nil, nil)
+	mempoolDroppedDesc  = prometheus.NewDesc("mempool_pending_dropped_total", "Pending transaction hashes dropped because the buffer was full", nil, nil)
+	mempoolEmittedDesc  = prometheus.NewDesc("mempool_pending_emitted_total", "Matching pending transactions pushed to the pending queue", nil, nil)
+
+	webhookRequestsDesc  = prometheus.NewDesc("webhook_requests_total", "Webhook requests per endpoint and result", []string{"endpoint", "result"}, nil)
+	webhookAvailableDesc = prometheus.NewDesc("webhook_endpoint_available", "Whether the webhook endpoint is available (0 while circuit-broken)", []string{"endpoint"}, nil)
+
+	dlqGrowthRateDesc = prometheus.NewDesc("dlq_growth_rate", "Dead letter growth rate per second over the alert window", []string{"broker", "queue"}, nil)
+
+	wsConnectedDesc        = prometheus.NewDesc("ws_connected", "Whether the upstream new-head subscription is currently active", nil, nil)
+	wsReconnectsDesc       = prometheus.NewDesc("ws_reconnects_total", "Successful upstream subscriptions after the first one", nil, nil)
+	lastBlockTimestampDesc = prometheus.NewDesc("last_block_timestamp", "Unix time the last new block header was received (0 before the first)", nil, nil)
+	queueBackpressureDesc  = prometheus.NewDesc("queue_backpressure", "1 while the block queue utilization is at or above QUEUE_PRESSURE_THRESHOLD", []string{"queue"}, nil)
+	blockPushResultsDesc   = prometheus.NewDesc("block_push_results_total", "Block pushes by result (enqueued, dead_lettered, deduplicated, rate_limited, failed)", []string{"result"}, nil)
+)
+
+// brokerMetrics 是每個 Broker 輸出的指標
+var brokerMetrics = []brokerMetric{
+	{newBrokerDesc("broker_messages_total", "Total messages per broker"), prometheus.CounterValue, brokerStat("total_messages")},
+	{newBrokerDesc("broker_messages_processed_total", "Messages processed successfully per broker"), prometheus.CounterValue, brokerStat("processed_messages")},
+	{newBrokerDesc("broker_messages_failed_total", "Messages failed per broker"), prometheus.CounterValue, brokerStat("failed_messages")},
+	{newBrokerDesc("broker_ops_per_second", "Push and pull operations per second over a rolling window"), prometheus.GaugeValue, brokerStat("ops_per_second")},
+	{newBrokerDesc("broker_active_queues", "Active queues per broker"), prometheus.GaugeValue, brokerStat("active_queues")},
+	{newBrokerDesc("broker_subscriber_overflows_total", "Published messages a full subscriber could not receive"), prometheus.CounterValue, brokerStat("subscriber_overflows")},
+	{newBrokerDesc("broker_dlq_expired_total", "Dead-lettered messages dropped after exceeding DLQ_RETENTION"), prometheus.CounterValue, brokerStat("dlq_expired")},
+}
+
+// queueMetrics 是每個隊列輸出的指標
+var queueMetrics = []queueMetric{
+	{newQueueDesc("queue_messages", "Messages currently waiting per queue"), prometheus.GaugeValue,
+		func(s *broker.QueueStats) float64 { return float64(s.MessageCount) }},
+	{newQueueDesc("queue_enqueued_total", "Messages enqueued per queue"), prometheus.CounterValue,
+		func(s *broker.QueueStats) float64 { return float64(s.EnqueuedTotal) }},
+	{newQueueDesc("queue_dequeued_total", "Messages dequeued per queue"), prometheus.CounterValue,
+		func(s *broker.QueueStats) float64 { return float64(s.DequeuedTotal) }},
+	{newQueueDesc("queue_dead_lettered_total", "Messages moved to the dead letter queue per queue"), prometheus.CounterValue,
+		func(s *broker.QueueStats) float64 { return float64(s.DeadLetterCount) }},
+	{newQueueDesc("queue_dlq_evicted_total", "Dead letters evicted or rejected because the dead letter queue reached MAX_DLQ_SIZE"), prometheus.CounterValue,
+		func(s *broker.QueueStats) float64 { return float64(s.DLQEvictedCount) }},
+	{newQueueDesc("queue_duplicates_total", "Already-processed deliveries dropped per queue"), prometheus.CounterValue,
+		func(s *broker.QueueStats) float64 { return float64(s.DuplicateCount) }},
+	{newQueueDesc("queue_consumers", "Registered consumers per queue"), prometheus.GaugeValue,
+		func(s *broker.QueueStats) float64 { return float64(s.ConsumerCount) }},
+	{newQueueDesc("queue_in_flight", "Delivered but unacknowledged messages per queue"), prometheus.GaugeValue,
+		func(s *broker.QueueStats) float64 { return float64(s.InFlightCount) }},
+	{newQueueDesc("queue_capacity", "Buffer size per queue; messages beyond it are dead-lettered"), prometheus.GaugeValue,
+		func(s *broker.QueueStats) float64 { return float64(s.Capacity) }},
+	{newQueueDesc("queue_utilization", "Fraction of the queue buffer in use (1 means new messages are dead-lettered)"), prometheus.GaugeValue,
+		func(s *broker.QueueStats) float64 { return s.Utilization }},
+	{newQueueDesc("queue_wait_seconds_max", "Longest time a message waited in the queue before being pulled"), prometheus.GaugeValue,
+		func(s *broker.QueueStats) float64 { return s.Latency.Max.Seconds() }},
+	{newQueueDesc("queue_deduped_total", "Messages dropped on push because their ID was already pushed within the dedupe window"), prometheus.CounterValue,
+		func(s *broker.QueueStats) float64 { return float64(s.DedupedCount) }},
+	{newQueueDesc("queue_expired_total", "Messages dropped because their TTL elapsed before delivery"), prometheus.CounterValue,
+		func(s *broker.QueueStats) float64 { return float64(s.ExpiredCount) }},
+	{newQueueDesc("queue_rate_limited_total", "Messages rejected on push because the queue's rate limit was exceeded"), prometheus.CounterValue,
+		func(s *broker.QueueStats) float64 { return float64(s.RateLimitedCount) }},
+}
+
+// newBrokerDesc 建立以 broker 為標籤的指標描述
+func newBrokerDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(name, help, []string{"broker"}, nil)
+}
+
+// newQueueDesc 建立以 broker 與 queue 為標籤的指標描述
+func newQueueDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(name, help, []string{"broker", "queue"}, nil)
+}
+
+// brokerStat 從 GetStats 的結果中讀取數值欄位
+func brokerStat(key string) func(map[string]interface{}) float64 {
+	return func(stats map[string]interface{}) float64 {
+		switch v := stats[key].(type) {
+		case int64:
+			return float64(v)
+		case int32:
+			return float64(v)
+		case float64:
+			return v
+		}
+		return 0
+	}
+}
+
+// metricsCollector 在每次抓取時從 Broker 統計快照與各計數器產生 Prometheus 指標
+// 數值本身仍由 Broker 與各元件以 atomic 累計，因此以 const metric 輸出而不是另外維護一份計數器
+type metricsCollector struct{}
+
+// Describe 實作 prometheus.Collector
+func (metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		statsStaleDesc, statsAgeDesc, messagesDesc, processedDesc, failedDesc, activeQueuesDesc, uptimeDesc,
+		queueWaitDesc, scanLimitHitsDesc,
+		detectionsMatchedDesc, detectionsForwardedDesc, detectionsSuppressedDesc,
+		mempoolReceivedDesc, mempoolDroppedDesc, mempoolEmittedDesc,
+		webhookRequestsDesc, webhookAvailableDesc, dlqGrowthRateDesc,
+		wsConnectedDesc, wsReconnectsDesc, lastBlockTimestampDesc, queueBackpressureDesc,
+		blockPushResultsDesc,
+	} {
+		ch <- desc
+	}
+	for _, m := range brokerMetrics {
+		ch <- m.desc
+	}
+	for _, m := range queueMetrics {
+		ch <- m.desc
+	}
+}
+
+// Collect 實作 prometheus.Collector
+func (metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	collectBrokerMetrics(ch)
+
+	ch <- prometheus.MustNewConstMetric(scanLimitHitsDesc, prometheus.CounterValue, float64(scanLimitHits.Load()))
+
+	connected := 0.0
+	if upstreamSubscribed.Load() {
+		connected = 1
+	}
+	ch <- prometheus.MustNewConstMetric(wsConnectedDesc, prometheus.GaugeValue, connected)
+	ch <- prometheus.MustNewConstMetric(wsReconnectsDesc, prometheus.CounterValue, float64(upstream.reconnects.Load()))
+	ch <- prometheus.MustNewConstMetric(lastBlockTimestampDesc, prometheus.GaugeValue, upstream.lastBlockTimestamp())
+
+	backpressure := 0.0
+	if blockBackpressure.Load() {
+		backpressure = 1
+	}
+	ch <- prometheus.MustNewConstMetric(queueBackpressureDesc, prometheus.GaugeValue, backpressure, blockQueueName)
+	for result, c := range blockPushResults {
+		ch <- prometheus.MustNewConstMetric(blockPushResultsDesc, prometheus.CounterValue, float64(c.Load()), string(result))
+	}
+
+	for _, c := range detectionCounters.snapshot() {
+		ch <- prometheus.MustNewConstMetric(detectionsMatchedDesc, prometheus.CounterValue, float64(c.Matched), c.Address)
+		ch <- prometheus.MustNewConstMetric(detectionsForwardedDesc, prometheus.CounterValue, float64(c.Forwarded), c.Address)
+		ch <- prometheus.MustNewConstMetric(detectionsSuppressedDesc, prometheus.CounterValue, float64(c.Suppressed), c.Address)
+	}
+
+	if mempoolCounts != nil {
+		ch <- prometheus.MustNewConstMetric(mempoolReceivedDesc, prometheus.CounterValue, float64(mempoolCounts.received.Load()))
+		ch <- prometheus.MustNewConstMetric(mempoolDroppedDesc, prometheus.CounterValue, float64(mempoolCounts.dropped.Load()))
+		ch <- prometheus.MustNewConstMetric(mempoolEmittedDesc, prometheus.CounterValue, float64(mempoolCounts.emitted.Load()))
+	}
+
+	if notifier != nil {
+		for _, e := range notifier.stats() {
+			ch <- prometheus.MustNewConstMetric(webhookRequestsDesc, prometheus.CounterValue, float64(e.Successes), e.URL, "success")
+			ch <- prometheus.MustNewConstMetric(webhookRequestsDesc, prometheus.CounterValue, float64(e.Failures), e.URL, "failure")
+			available := 0.0
+			if e.Available {
+				available = 1
+			}
+			ch <- prometheus.MustNewConstMetric(webhookAvailableDesc, prometheus.GaugeValue, available, e.URL)
+		}
+	}
+
+	if dlqMon != nil {
+		for key, rate := range dlqMon.GrowthRates() {
+			ch <- prometheus.MustNewConstMetric(dlqGrowthRateDesc, prometheus.GaugeValue, rate, key.broker, key.queue)
+		}
+	}
+}
+
+// collectBrokerMetrics 輸出所有 Broker 的合計指標，以及各 Broker 與各隊列的獨立指標
+// Broker 忙碌到無法及時讀取時，使用上一次的快照並以 stats_stale 標示
+func collectBrokerMetrics(ch chan<- prometheus.Metric) {
+	snapshot, stale := brokerStatsCache.get()
+	names := make([]string, 0, len(snapshot.Brokers))
+	var totalMessages, processedMessages, failedMessages, activeQueues float64
+	for name, s := range snapshot.Brokers {
+		names = append(names, name)
+		totalMessages += brokerStat("total_messages")(s.Stats)
+		processedMessages += brokerStat("processed_messages")(s.Stats)
+		failedMessages += brokerStat("failed_messages")(s.Stats)
+		activeQueues += brokerStat("active_queues")(s.Stats)
+	}
+	sort.Strings(names)
+
+	staleValue := 0.0
+	if stale {
+		staleValue = 1
+	}
+	ch <- prometheus.MustNewConstMetric(statsStaleDesc, prometheus.GaugeValue, staleValue)
+	ch <- prometheus.MustNewConstMetric(statsAgeDesc, prometheus.GaugeValue, clock.Now().Sub(snapshot.TakenAt).Seconds())
+	ch <- prometheus.MustNewConstMetric(messagesDesc, prometheus.CounterValue, totalMessages)
+	ch <- prometheus.MustNewConstMetric(processedDesc, prometheus.CounterValue, processedMessages)
+	ch <- prometheus.MustNewConstMetric(failedDesc, prometheus.CounterValue, failedMessages)
+	ch <- prometheus.MustNewConstMetric(activeQueuesDesc, prometheus.GaugeValue, activeQueues)
+	ch <- prometheus.MustNewConstMetric(uptimeDesc, prometheus.CounterValue, clock.Now().Sub(startTime).Seconds())
+
+	for _, name := range names {
+		s := snapshot.Brokers[name]
+		for _, m := range brokerMetrics {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, m.value(s.Stats), name)
+		}
+		for _, queue := range sortedQueueNames(s.Queues) {
+			stats := s.Queues[queue]
+			for _, m := range queueMetrics {
+				ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, m.value(stats), name, queue)
+			}
+			ch <- queueWaitHistogram(name, queue, stats.Latency)
+		}
+	}
+}
+
+// queueWaitHistogram 將隊列的等待時間統計轉為 Prometheus histogram
+func queueWaitHistogram(brokerName, queue string, latency broker.LatencyStats) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(latency.Buckets))
+	for i, bound := range latency.Buckets {
+		buckets[bound.Seconds()] = uint64(latency.Counts[i])
+	}
+	return prometheus.MustNewConstHistogram(queueWaitDesc, uint64(latency.Count), latency.Sum.Seconds(), buckets, brokerName, queue)
+}
+
+// newMetricsHandler 建立只包含服務指標的 registry，並返回輸出 Prometheus 格式的 handler
+func newMetricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(metricsCollector{})
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		ErrorLog:      logrus.StandardLogger(),
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// metricsHandler 是 /metrics 端點使用的 handler
+var metricsHandler = newMetricsHandler()
+
+// handleMetrics 處理 /metrics 端點 (Prometheus 格式)
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metricsHandler.ServeHTTP(w, r)
+}
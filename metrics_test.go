@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// scrapeMetrics 抓取 /metrics 並解析 Prometheus 文字格式
+func scrapeMetrics(t *testing.T) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	handleMetrics(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(rr.Body)
+	if err != nil {
+		t.Fatalf("Failed to parse metrics: %v", err)
+	}
+	return families
+}
+
+// findQueueMetric 找出指定 broker 與 queue 標籤的時間序列
+func findQueueMetric(t *testing.T, families map[string]*dto.MetricFamily, name, brokerName, queue string) *dto.Metric {
+	t.Helper()
+
+	family, exists := families[name]
+	if !exists {
+		t.Fatalf("Expected metric family %s", name)
+	}
+	for _, m := range family.GetMetric() {
+		labels := make(map[string]string)
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["broker"] == brokerName && labels["queue"] == queue {
+			return m
+		}
+	}
+	t.Fatalf("Expected %s{broker=%q,queue=%q}", name, brokerName, queue)
+	return nil
+}
+
+func TestMetricsExposeLabeledQueueSeries(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+	startTime = time.Now()
+
+	queue := "metrics-queue"
+	for _, id := range []string{"m-1", "m-2", "m-3"} {
+		blocks.Push(queue, broker.NewMessage(id, []byte("data"), queue))
+	}
+	blocks.Pull(queue)
+	blocks.MoveToDLQ(queue, broker.NewMessage("m-4", []byte("data"), queue))
+
+	families := scrapeMetrics(t)
+
+	// 計數器與量表都帶有 broker 與 queue 標籤
+	for _, tc := range []struct {
+		name  string
+		value func(*dto.Metric) float64
+		want  float64
+	}{
+		{"queue_messages", func(m *dto.Metric) float64 { return m.GetGauge().GetValue() }, 2},
+		{"queue_enqueued_total", func(m *dto.Metric) float64 { return m.GetCounter().GetValue() }, 3},
+		{"queue_dequeued_total", func(m *dto.Metric) float64 { return m.GetCounter().GetValue() }, 1},
+		{"queue_dead_lettered_total", func(m *dto.Metric) float64 { return m.GetCounter().GetValue() }, 1},
+	} {
+		m := findQueueMetric(t, families, tc.name, brokerPurposeBlocks, queue)
+		if got := tc.value(m); got != tc.want {
+			t.Errorf("Expected %s = %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	// 等待時間以 histogram 輸出，包含各分桶與 +Inf
+	histogram := findQueueMetric(t, families, "queue_wait_seconds", brokerPurposeBlocks, queue).GetHistogram()
+	if histogram.GetSampleCount() != 1 {
+		t.Errorf("Expected 1 wait sample, got %d", histogram.GetSampleCount())
+	}
+	if len(histogram.GetBucket()) != len(broker.LatencyBuckets)+1 {
+		t.Errorf("Expected %d buckets, got %d", len(broker.LatencyBuckets)+1, len(histogram.GetBucket()))
+	}
+	if families["queue_wait_seconds"].GetType() != dto.MetricType_HISTOGRAM {
+		t.Errorf("Expected queue_wait_seconds to be a histogram, got %v", families["queue_wait_seconds"].GetType())
+	}
+
+	if got := families["messages_total"].GetMetric()[0].GetCounter().GetValue(); got != 3 {
+		t.Errorf("Expected messages_total 3, got %v", got)
+	}
+}
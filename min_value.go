@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// minValueWei 是轉發存款偵測的最低金額 (wei)，nil 表示不限制
+var minValueWei *big.Int
+
+// parseMinValue 解析十進位的 wei 金額門檻，空字串表示不限制
+func parseMinValue(raw string) (*big.Int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	value, ok := new(big.Int).SetString(raw, 10)
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("invalid wei amount %q", raw)
+	}
+	return value, nil
+}
+
+// minValueFromEnv 從 MIN_VALUE_WEI 讀取金額門檻
+func minValueFromEnv() (*big.Int, error) {
+	return parseMinValue(os.Getenv("MIN_VALUE_WEI"))
+}
+
+// meetsMinValue 判斷交易金額是否大於或等於門檻
+// 代幣轉帳的金額單位取決於代幣精度，無法與 wei 門檻比較，因此一律視為達標；
+// 金額無法解析時也視為達標，寧可多告警也不漏掉存款
+func meetsMinValue(txInfo TransactionInfo, min *big.Int) bool {
+	if min == nil || txInfo.Token != "" {
+		return true
+	}
+	value, ok := new(big.Int).SetString(txInfo.Value, 10)
+	if !ok {
+		return true
+	}
+	return value.Cmp(min) >= 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+func TestMeetsMinValue(t *testing.T) {
+	min := big.NewInt(1000)
+
+	tests := []struct {
+		name string
+		tx   TransactionInfo
+		want bool
+	}{
+		{"below threshold", TransactionInfo{Value: "999"}, false},
+		{"at threshold", TransactionInfo{Value: "1000"}, true},
+		{"above threshold", TransactionInfo{Value: "1000000000000000000000"}, true},
+		{"unparseable value", TransactionInfo{Value: "n/a"}, true},
+		{"token transfer", TransactionInfo{Value: "1", Token: "0xtoken"}, true},
+	}
+	for _, tt := range tests {
+		if got := meetsMinValue(tt.tx, min); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	if !meetsMinValue(TransactionInfo{Value: "0"}, nil) {
+		t.Error("Expected no threshold to accept every value")
+	}
+}
+
+func TestParseMinValue(t *testing.T) {
+	if v, err := parseMinValue(""); err != nil || v != nil {
+		t.Errorf("Expected no threshold for empty value, got %v (err=%v)", v, err)
+	}
+	if v, err := parseMinValue(" 1000000000000000000 "); err != nil || v.String() != "1000000000000000000" {
+		t.Errorf("Expected 1e18, got %v (err=%v)", v, err)
+	}
+	for _, raw := range []string{"1e18", "-1", "0x10", "abc"} {
+		if _, err := parseMinValue(raw); err == nil {
+			t.Errorf("Expected error for %q", raw)
+		}
+	}
+}
+
+func TestMinValueFiltersDust(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	minValueWei = big.NewInt(1000)
+	filteredAuditEnabled = true
+	defer func() {
+		minValueWei = nil
+		filteredAuditEnabled = false
+	}()
+
+	processBlockMessage(BlockMessage{
+		BlockNumber: "810",
+		Transactions: []TransactionInfo{
+			{Hash: "0xdust", To: targetAddress, Value: "999"},
+			{Hash: "0xexact", To: targetAddress, Value: "1000"},
+			{Hash: "0xlarge", To: targetAddress, Value: "5000"},
+		},
+	}, 1)
+
+	got := forwardedTxHashes(t)
+	if len(got) != 2 || got[0] != "0xexact" || got[1] != "0xlarge" {
+		t.Errorf("Expected only transactions at or above the threshold, got %v", got)
+	}
+
+	msg, _ := messageBroker.Pull(filteredQueueName)
+	if msg == nil || msg.Headers[filterReasonHeader] != filterReasonBelowMinValue {
+		t.Errorf("Expected dust transaction in the filtered queue, got %v", msg)
+	}
+}
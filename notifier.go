@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/webhook"
+)
+
+// webhookPayload 是推送給 webhook 接收端的存款通知內容
+type webhookPayload struct {
+	Event       string          `json:"event"`
+	BlockNumber string          `json:"block_number"`
+	Transaction TransactionInfo `json:"transaction"`
+}
+
+// webhookNotifier 負責將偵測事件以 HTTP POST 推送到外部 webhook
+// 設定 secret 時以 webhook.SetHeaders 簽章，接收端可匯入 webhook 套件以 webhook.Verify 驗證
+type webhookNotifier struct {
+	url    string
+	secret string // 為空時不簽章
+	client *http.Client
+
+	// 以下兩個欄位可在測試中替換，以得到可預期的簽章
+	now   func() time.Time
+	nonce func() string
+}
+
+// newWebhookNotifier 創建一個新的 webhook 通知器
+func newWebhookNotifier(url, secret string) *webhookNotifier {
+	return &webhookNotifier{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+		nonce:  generateMessageID,
+	}
+}
+
+// Notify 將 payload 序列化為 JSON 並 POST 到 webhook
+func (n *webhookNotifier) Notify(ctx context.Context, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// 只有在設定了 secret 時才簽章
+	if n.secret != "" {
+		webhook.SetHeaders(req.Header, n.secret, n.now().Unix(), n.nonce(), body)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
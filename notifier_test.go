@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/webhook"
+)
+
+func TestWebhookNotifierSignsPayload(t *testing.T) {
+	const secret = "test-secret"
+	const nonce = "0123456789abcdef"
+	fixedTime := time.Unix(1700000000, 0)
+
+	var gotBody []byte
+	var gotHeaders http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeaders = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	n := newWebhookNotifier(server.URL, secret)
+	n.now = func() time.Time { return fixedTime }
+	n.nonce = func() string { return nonce }
+
+	payload := webhookPayload{
+		Event:       "deposit",
+		BlockNumber: "12345",
+		Transaction: TransactionInfo{Hash: "0xabc", To: targetAddress, Value: "1"},
+	}
+	if err := n.Notify(context.Background(), payload); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	// 以標準字串獨立計算預期簽章
+	expectedBody, _ := json.Marshal(payload)
+	if string(gotBody) != string(expectedBody) {
+		t.Errorf("Expected body %s, got %s", expectedBody, gotBody)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("1700000000." + nonce + "." + string(expectedBody)))
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if got := gotHeaders.Get(webhook.SignatureHeader); got != expected {
+		t.Errorf("Expected signature %s, got %s", expected, got)
+	}
+	if got := gotHeaders.Get(webhook.TimestampHeader); got != "1700000000" {
+		t.Errorf("Expected timestamp header 1700000000, got %s", got)
+	}
+	if got := gotHeaders.Get(webhook.NonceHeader); got != nonce {
+		t.Errorf("Expected nonce header %s, got %s", nonce, got)
+	}
+}
+
+func TestWebhookNotifierWithoutSecret(t *testing.T) {
+	var gotHeaders http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n := newWebhookNotifier(server.URL, "")
+	if err := n.Notify(context.Background(), webhookPayload{Event: "deposit"}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	// 未設定 secret 時不應該附帶簽章
+	if gotHeaders.Get(webhook.SignatureHeader) != "" {
+		t.Error("Expected no signature header without a secret")
+	}
+}
+
+func TestWebhookNotifierNon2xx(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n := newWebhookNotifier(server.URL, "secret")
+	if err := n.Notify(context.Background(), webhookPayload{Event: "deposit"}); err == nil {
+		t.Error("Expected error for non-2xx response")
+	}
+}
+
+func TestWebhookNotifierSignatureVerifies(t *testing.T) {
+	const secret = "test-secret"
+
+	var verifyErr, tamperedErr error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = webhook.Verify(r.Header, secret, body)
+		tamperedErr = webhook.Verify(r.Header, secret, append(body, ' '))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	n := newWebhookNotifier(server.URL, secret)
+	if err := n.Notify(context.Background(), webhookPayload{Event: "deposit", BlockNumber: "1"}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if verifyErr != nil {
+		t.Errorf("Expected signed request to verify, got %v", verifyErr)
+	}
+	if !errors.Is(tamperedErr, webhook.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a tampered body, got %v", tamperedErr)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/sirupsen/logrus"
+)
+
+// maxPublishBodyBytes 是 /publish 請求內容的大小上限
+const maxPublishBodyBytes = 1 << 20
+
+// publishRequest 是 /publish 端點的請求內容
+type publishRequest struct {
+	ID      string            `json:"id"`
+	Body    string            `json:"body"`
+	Headers map[string]string `json:"headers"`
+}
+
+// handlePublish 處理 POST /publish?queue=NAME，請求內容為 {"id": "...", "body": "...", "headers": {...}}
+// 讓沒有 Go 客戶端的外部系統將消息推送到隊列，未提供 id 時自動生成
+func handlePublish(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	queue := r.URL.Query().Get("queue")
+	if queue == "" {
+		http.Error(w, "queue parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	var req publishRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPublishBodyBytes)).Decode(&req); err != nil {
+		http.Error(w, "request body must be JSON with a body field", http.StatusBadRequest)
+		return
+	}
+
+	// 推送到擁有此隊列的 Broker (例如 transactions 屬於 alerts)，避免在其他 Broker 建立沒有消費者的同名隊列
+	target := brokerForQueue(queue)
+	if !target.IsHealthy() {
+		http.Error(w, "broker is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if req.ID == "" {
+		req.ID = generateMessageID()
+	}
+	msg := broker.NewMessage(req.ID, []byte(req.Body), queue)
+	for key, value := range req.Headers {
+		msg.Headers[key] = value
+	}
+
+	if err := target.Push(queue, msg); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"queue": queue,
+		"id":    msg.ID,
+	}).Debug("📮 已透過 HTTP 推送消息")
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"queue": queue,
+		"id":    msg.ID,
+	})
+}
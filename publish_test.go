@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// publishMessage 發送 /publish 請求
+func publishMessage(t *testing.T, query, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handlePublish(rr, httptest.NewRequest(http.MethodPost, "/publish"+query, strings.NewReader(body)))
+	return rr
+}
+
+func TestHandlePublish(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	rr := publishMessage(t, "?queue=integration", `{"id":"ext-1","body":"hello","headers":{"source":"ci"}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	msg, err := messageBroker.Pull("integration")
+	if err != nil || msg == nil {
+		t.Fatalf("Expected published message in queue, got %v, %v", msg, err)
+	}
+	if msg.ID != "ext-1" || string(msg.Body) != "hello" || msg.Headers["source"] != "ci" {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+
+	// 未提供 id 時自動生成
+	rr = publishMessage(t, "?queue=integration", `{"body":"no id"}`)
+	var resp map[string]string
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusCreated || resp["id"] == "" {
+		t.Errorf("Expected generated id, got %d %v", rr.Code, resp)
+	}
+}
+
+func TestHandlePublishValidation(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	if rr := publishMessage(t, "", `{"body":"hello"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing queue, got %d", rr.Code)
+	}
+	if rr := publishMessage(t, "?queue=integration", `{"body":`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for malformed JSON, got %d", rr.Code)
+	}
+
+	rr := httptest.NewRecorder()
+	handlePublish(rr, httptest.NewRequest(http.MethodGet, "/publish?queue=integration", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", rr.Code)
+	}
+
+	if stats, _ := messageBroker.GetQueueStats("integration"); stats != nil && stats.MessageCount != 0 {
+		t.Errorf("Expected no messages from rejected requests, got %d", stats.MessageCount)
+	}
+}
+
+func TestHandlePublishUnhealthyBroker(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	messageBroker.Close()
+
+	if rr := publishMessage(t, "?queue=integration", `{"body":"hello"}`); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when the broker is closed, got %d", rr.Code)
+	}
+}
+
+func TestHandlePublishRoutesToOwningBroker(t *testing.T) {
+	blocks, alerts := withBrokerRegistry(t)
+	alerts.DeclareQueue(transactionQueueName, 10)
+
+	rr := publishMessage(t, "?queue="+transactionQueueName, `{"id":"tx-1","body":"hello"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// 消息進入 alerts 的交易隊列，blocks 不會出現同名的影子隊列
+	if msg, _ := alerts.Pull(transactionQueueName); msg == nil || msg.ID != "tx-1" {
+		t.Errorf("Expected tx-1 in the alerts broker, got %+v", msg)
+	}
+	if stats, _ := blocks.GetQueueStats(transactionQueueName); stats != nil {
+		t.Errorf("Expected no %s queue in the blocks broker, got %+v", transactionQueueName, stats)
+	}
+}
+
+func TestHandlePublishRoutesUndeclaredQueueToOwningBroker(t *testing.T) {
+	blocks, alerts := withBrokerRegistry(t)
+
+	// 交易隊列尚未建立時也不能回退到 blocks 建立影子隊列
+	rr := publishMessage(t, "?queue="+transactionQueueName, `{"id":"tx-1","body":"hello"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if msg, _ := alerts.Pull(transactionQueueName); msg == nil || msg.ID != "tx-1" {
+		t.Errorf("Expected tx-1 in the alerts broker, got %+v", msg)
+	}
+	if stats, _ := blocks.GetQueueStats(transactionQueueName); stats != nil {
+		t.Errorf("Expected no %s queue in the blocks broker, got %+v", transactionQueueName, stats)
+	}
+}
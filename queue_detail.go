@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// dlqSummary 是 /queues/detail 中死信隊列的摘要，完整內容以 /dlq 分頁查詢
+type dlqSummary struct {
+	Count    int        `json:"count"`
+	OldestAt *time.Time `json:"oldest_at,omitempty"` // 死信消息中最早的入隊時間
+	NewestAt *time.Time `json:"newest_at,omitempty"` // 死信消息中最近的入隊時間
+}
+
+// summarizeDLQ 統計死信消息數與時間範圍
+func summarizeDLQ(messages []broker.Message) dlqSummary {
+	summary := dlqSummary{Count: len(messages)}
+	for i := range messages {
+		ts := messages[i].Timestamp
+		if summary.OldestAt == nil || ts.Before(*summary.OldestAt) {
+			summary.OldestAt = &ts
+		}
+		if summary.NewestAt == nil || ts.After(*summary.NewestAt) {
+			summary.NewestAt = &ts
+		}
+	}
+	return summary
+}
+
+// handleQueueDetail 處理 GET /queues/detail?queue=NAME
+// 返回單一隊列的完整統計 (容量、使用率、等待時間) 與死信隊列摘要，隊列不存在時返回 404
+func handleQueueDetail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	queueName := r.URL.Query().Get("queue")
+	if queueName == "" {
+		http.Error(w, "queue parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	b := brokerForQueue(queueName)
+	stats, err := b.GetQueueStats(queueName)
+	if errors.Is(err, broker.ErrQueueNotFound) {
+		http.Error(w, fmt.Sprintf("queue %s not found", queueName), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"queue": queueName,
+		"stats": stats,
+		"dlq":   summarizeDLQ(b.GetDLQ(queueName)),
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+func TestHTTPQueueDetail(t *testing.T) {
+	_, alerts := withBrokerRegistry(t)
+
+	alerts.DeclareQueue(transactionQueueName, 4)
+	alerts.Push(transactionQueueName, broker.NewMessage("tx-1", []byte("{}"), transactionQueueName))
+	alerts.Push(transactionQueueName, broker.NewMessage("tx-2", []byte("{}"), transactionQueueName))
+	alerts.MoveToDLQ(transactionQueueName, broker.NewMessage("tx-dead", []byte("{}"), transactionQueueName))
+
+	rr := httptest.NewRecorder()
+	handleQueueDetail(rr, httptest.NewRequest(http.MethodGet, "/queues/detail?queue="+transactionQueueName, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var detail struct {
+		Queue string            `json:"queue"`
+		Stats broker.QueueStats `json:"stats"`
+		DLQ   dlqSummary        `json:"dlq"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// 在 alerts Broker 上找到隊列，並帶上容量、使用率與死信摘要
+	if detail.Queue != transactionQueueName || detail.Stats.MessageCount != 2 {
+		t.Errorf("Expected 2 messages in %s, got %+v", transactionQueueName, detail)
+	}
+	if detail.Stats.Capacity != 4 || detail.Stats.Utilization != 0.5 {
+		t.Errorf("Expected capacity 4 and utilization 0.5, got %d and %v", detail.Stats.Capacity, detail.Stats.Utilization)
+	}
+	if detail.DLQ.Count != 1 || detail.DLQ.OldestAt == nil {
+		t.Errorf("Expected 1 dead letter with timestamps, got %+v", detail.DLQ)
+	}
+}
+
+func TestHTTPQueueDetailMissingQueue(t *testing.T) {
+	withBrokerRegistry(t)
+
+	rr := httptest.NewRecorder()
+	handleQueueDetail(rr, httptest.NewRequest(http.MethodGet, "/queues/detail?queue=missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing queue, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleQueueDetail(rr, httptest.NewRequest(http.MethodGet, "/queues/detail", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without queue parameter, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// upstreamSubscribed 表示 startWatching 目前是否持有有效的新區塊訂閱
+// 由 upstream.connected / upstream.disconnected 在訂閱成功與連線結束時切換
+var upstreamSubscribed atomic.Bool
+
+// handleReady 處理 /ready 端點 (readiness probe)
+// 與 /health (liveness) 不同，上游 WebSocket 未訂閱時返回 503，讓編排系統暫停導入流量而不重啟程序
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ready := upstreamSubscribed.Load()
+	status := "ready"
+	if !ready {
+		status = "not_ready"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     status,
+		"subscribed": ready,
+		"timestamp":  clock.Now(),
+	})
+}
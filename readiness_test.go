@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// readyStatus 呼叫 handleReady 並返回狀態碼與回應內容
+func readyStatus(t *testing.T) (int, map[string]interface{}) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handleReady(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse ready response: %v", err)
+	}
+	return rr.Code, body
+}
+
+func TestHTTPReadyFollowsSubscription(t *testing.T) {
+	t.Cleanup(func() { upstreamSubscribed.Store(false) })
+
+	// 尚未訂閱時未就緒
+	upstreamSubscribed.Store(false)
+	if code, body := readyStatus(t); code != http.StatusServiceUnavailable || body["status"] != "not_ready" || body["subscribed"] != false {
+		t.Errorf("Expected 503 not_ready before subscribing, got %d %v", code, body)
+	}
+
+	// 訂閱成功後就緒
+	upstreamSubscribed.Store(true)
+	if code, body := readyStatus(t); code != http.StatusOK || body["status"] != "ready" || body["subscribed"] != true {
+		t.Errorf("Expected 200 ready while subscribed, got %d %v", code, body)
+	}
+
+	// 斷線後回到未就緒
+	upstreamSubscribed.Store(false)
+	if code, _ := readyStatus(t); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 after disconnect, got %d", code)
+	}
+}
+
+func TestHTTPHealthStaysLiveWhenDisconnected(t *testing.T) {
+	withBrokerRegistry(t)
+	upstreamSubscribed.Store(false)
+
+	// liveness 不受上游連線狀態影響
+	rr := httptest.NewRecorder()
+	handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected /health to stay 200 while disconnected, got %d", rr.Code)
+	}
+}
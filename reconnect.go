@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// 重新連線退避的預設值
+const (
+	reconnectBaseDelay   = 1 * time.Second  // 第一次重試前的等待時間
+	reconnectMaxDelay    = 60 * time.Second // 等待時間的上限 (不含抖動)
+	reconnectStableAfter = 60 * time.Second // 連線維持超過此時間才視為成功，重置退避
+	reconnectJitter      = 0.2              // 抖動上限佔等待時間的比例
+)
+
+// reconnectBackoff 計算監聽器停止後重新連線前的等待時間
+//
+// 等待時間從 base 開始每次加倍，直到 max 為止；連線維持超過 stableAfter 後才重置，
+// 避免連上後立刻斷線的端點讓退避失效。每次等待再加上最多 jitter 比例的隨機抖動，
+// 避免多個實例在供應商恢復時同時重新連線。
+type reconnectBackoff struct {
+	base        time.Duration
+	max         time.Duration
+	stableAfter time.Duration
+	jitter      float64
+	rng         *rand.Rand // nil 時使用全域亂數來源
+
+	attempt int
+}
+
+// newReconnectBackoff 創建使用預設參數的重新連線退避
+func newReconnectBackoff() *reconnectBackoff {
+	return &reconnectBackoff{
+		base:        reconnectBaseDelay,
+		max:         reconnectMaxDelay,
+		stableAfter: reconnectStableAfter,
+		jitter:      reconnectJitter,
+	}
+}
+
+// next 返回下一次重新連線前的等待時間，connectedFor 為上一次監聽持續的時間
+func (b *reconnectBackoff) next(connectedFor time.Duration) time.Duration {
+	if connectedFor >= b.stableAfter {
+		b.attempt = 0
+	}
+
+	delay := b.max
+	if b.attempt < 32 && b.base<<b.attempt < b.max {
+		delay = b.base << b.attempt
+	}
+	b.attempt++
+
+	return jitteredTimeout(delay, time.Duration(float64(delay)*b.jitter), b.rng)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoffProgression(t *testing.T) {
+	backoff := newReconnectBackoff()
+	backoff.jitter = 0
+
+	// 連線每次都立刻失敗：1s, 2s, 4s ... 到 60s 上限
+	expected := []time.Duration{1, 2, 4, 8, 16, 32, 60, 60, 60}
+	for i, want := range expected {
+		if got := backoff.next(0); got != want*time.Second {
+			t.Errorf("Attempt %d: expected %v, got %v", i+1, want*time.Second, got)
+		}
+	}
+
+	// 大量失敗後不應溢位
+	for i := 0; i < 100; i++ {
+		backoff.next(0)
+	}
+	if got := backoff.next(0); got != 60*time.Second {
+		t.Errorf("Expected delay to stay capped, got %v", got)
+	}
+}
+
+func TestReconnectBackoffResetsAfterStableConnection(t *testing.T) {
+	backoff := newReconnectBackoff()
+	backoff.jitter = 0
+
+	for i := 0; i < 4; i++ {
+		backoff.next(0)
+	}
+
+	// 短暫的連線不算成功，退避繼續增加
+	if got := backoff.next(5 * time.Second); got != 16*time.Second {
+		t.Errorf("Expected short-lived connection to keep backing off, got %v", got)
+	}
+
+	// 維持足夠久的連線後斷線，從頭開始
+	if got := backoff.next(10 * time.Minute); got != time.Second {
+		t.Errorf("Expected backoff to reset after a stable connection, got %v", got)
+	}
+	if got := backoff.next(0); got != 2*time.Second {
+		t.Errorf("Expected progression to restart after reset, got %v", got)
+	}
+}
+
+func TestReconnectBackoffJitter(t *testing.T) {
+	backoff := newReconnectBackoff()
+	backoff.rng = rand.New(rand.NewPCG(1, 2))
+
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 50; i++ {
+		backoff.attempt = 2
+		d := backoff.next(0)
+		if d < 4*time.Second || d >= 4*time.Second+800*time.Millisecond {
+			t.Fatalf("Jittered delay %v out of range", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("Expected jitter to vary the delay between attempts")
+	}
+}
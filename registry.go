@@ -0,0 +1,126 @@
+package main
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// Broker 的用途名稱
+// blocks 處理高流量的區塊管線，alerts 處理低流量的交易/告警管線，
+// 兩者隔離後區塊積壓不會拖慢告警的投遞
+const (
+	brokerPurposeBlocks = "blocks"
+	brokerPurposeAlerts = "alerts"
+)
+
+// brokerRegistry 以用途名稱管理多個獨立的 Broker 實例
+type brokerRegistry struct {
+	mu      sync.RWMutex
+	brokers map[string]broker.Broker
+}
+
+// newBrokerRegistry 創建一個空的 Broker 註冊表
+func newBrokerRegistry() *brokerRegistry {
+	return &brokerRegistry{
+		brokers: make(map[string]broker.Broker),
+	}
+}
+
+// Register 以指定名稱註冊一個 Broker，同名時覆蓋
+func (r *brokerRegistry) Register(name string, b broker.Broker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.brokers[name] = b
+}
+
+// Get 取得指定名稱的 Broker
+func (r *brokerRegistry) Get(name string) (broker.Broker, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, ok := r.brokers[name]
+	return b, ok
+}
+
+// Names 返回所有已註冊的名稱 (已排序)
+func (r *brokerRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.brokers))
+	for name := range r.brokers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CloseAll 關閉所有已註冊的 Broker，返回遇到的第一個錯誤
+func (r *brokerRegistry) CloseAll() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var firstErr error
+	for _, b := range r.brokers {
+		if err := b.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// brokers 是全域的 Broker 註冊表
+var brokers = newBrokerRegistry()
+
+// brokerFor 返回指定用途的 Broker，未註冊時回退到預設的 messageBroker
+func brokerFor(purpose string) broker.Broker {
+	if b, ok := brokers.Get(purpose); ok {
+		return b
+	}
+	return messageBroker
+}
+
+// allBrokers 返回所有 Broker，未註冊任何 Broker 時只包含預設的 messageBroker
+func allBrokers() map[string]broker.Broker {
+	names := brokers.Names()
+	if len(names) == 0 {
+		return map[string]broker.Broker{"default": messageBroker}
+	}
+
+	result := make(map[string]broker.Broker, len(names))
+	for _, name := range names {
+		b, _ := brokers.Get(name)
+		result[name] = b
+	}
+	return result
+}
+
+// queueOwners 是已知隊列所屬的 Broker 用途，這些隊列不論是否已建立都固定路由到擁有者，
+// 避免在其他 Broker 建立沒有消費者的同名影子隊列
+var queueOwners = map[string]string{
+	blockQueueName:        brokerPurposeBlocks,
+	missedBlocksQueueName: brokerPurposeBlocks,
+	transactionQueueName:  brokerPurposeAlerts,
+	filteredQueueName:     brokerPurposeAlerts,
+	pendingQueueName:      brokerPurposeAlerts,
+	webhookQueueName:      brokerPurposeAlerts,
+}
+
+// brokerForQueue 找出擁有指定隊列的 Broker
+// 已知隊列依 queueOwners 路由；其他隊列依名稱順序找第一個已建立該隊列的 Broker，找不到時回退到預設的 messageBroker
+func brokerForQueue(queue string) broker.Broker {
+	if purpose, ok := queueOwners[queue]; ok {
+		return brokerFor(purpose)
+	}
+
+	for _, name := range brokers.Names() {
+		b, _ := brokers.Get(name)
+		for _, existing := range b.GetAllQueues() {
+			if existing == queue {
+				return b
+			}
+		}
+	}
+	return messageBroker
+}
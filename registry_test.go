@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// withBrokerRegistry 在測試期間註冊 blocks / alerts 兩個獨立 Broker，結束後還原
+func withBrokerRegistry(t *testing.T) (blocks, alerts *broker.SimpleBroker) {
+	t.Helper()
+
+	blocks = broker.NewSimpleBroker()
+	alerts = broker.NewSimpleBroker()
+	previous := brokers
+	brokers = newBrokerRegistry()
+	brokers.Register(brokerPurposeBlocks, blocks)
+	brokers.Register(brokerPurposeAlerts, alerts)
+	messageBroker = blocks
+
+	t.Cleanup(func() {
+		brokers.CloseAll()
+		brokers = previous
+	})
+	return blocks, alerts
+}
+
+func TestBrokerRegistryIndependentMetrics(t *testing.T) {
+	blocks, alerts := withBrokerRegistry(t)
+
+	for i := 0; i < 3; i++ {
+		brokerFor(brokerPurposeBlocks).Push("blocks", broker.NewMessage(generateMessageID(), []byte("block"), "blocks"))
+	}
+	brokerFor(brokerPurposeAlerts).Push("transactions", broker.NewMessage(generateMessageID(), []byte("tx"), "transactions"))
+
+	if got := blocks.GetMetrics().GetStats()["total_messages"].(int64); got != 3 {
+		t.Errorf("Expected blocks broker to have 3 messages, got %d", got)
+	}
+	if got := alerts.GetMetrics().GetStats()["total_messages"].(int64); got != 1 {
+		t.Errorf("Expected alerts broker to have 1 message, got %d", got)
+	}
+
+	if len(blocks.GetAllQueues()) != 1 || blocks.GetAllQueues()[0] != "blocks" {
+		t.Errorf("Expected blocks broker to only own the blocks queue, got %v", blocks.GetAllQueues())
+	}
+
+	// 關閉其中一個 Broker 不影響另一個
+	blocks.Close()
+	if !alerts.IsHealthy() {
+		t.Error("Expected alerts broker to stay healthy after blocks broker closed")
+	}
+}
+
+func TestBrokerForFallsBackToDefault(t *testing.T) {
+	previous := brokers
+	brokers = newBrokerRegistry()
+	defer func() { brokers = previous }()
+
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	if brokerFor(brokerPurposeAlerts) != messageBroker {
+		t.Error("Expected unregistered purpose to fall back to messageBroker")
+	}
+	if len(allBrokers()) != 1 {
+		t.Errorf("Expected only the default broker, got %d", len(allBrokers()))
+	}
+}
+
+func TestBrokerForQueuePrefersOwner(t *testing.T) {
+	blocks, alerts := withBrokerRegistry(t)
+
+	// 兩個 Broker 都有同名隊列時，已知隊列固定路由到擁有者
+	blocks.DeclareQueue(transactionQueueName, 10)
+	alerts.DeclareQueue(transactionQueueName, 10)
+	for i := 0; i < 20; i++ {
+		if brokerForQueue(transactionQueueName) != alerts {
+			t.Fatal("Expected transactions to always resolve to the alerts broker")
+		}
+	}
+
+	// 未知隊列依名稱順序選擇第一個擁有它的 Broker
+	blocks.DeclareQueue("custom", 10)
+	alerts.DeclareQueue("custom", 10)
+	for i := 0; i < 20; i++ {
+		if brokerForQueue("custom") != alerts {
+			t.Fatal("Expected custom to resolve to the first broker by name")
+		}
+	}
+	if brokerForQueue("missing") != messageBroker {
+		t.Error("Expected an unknown queue to fall back to messageBroker")
+	}
+}
+
+func TestHTTPEndpointsReportPerBrokerStats(t *testing.T) {
+	withBrokerRegistry(t)
+	startTime = time.Now()
+
+	brokerFor(brokerPurposeBlocks).Push("blocks", broker.NewMessage("b-1", []byte("block"), "blocks"))
+	brokerFor(brokerPurposeBlocks).Push("blocks", broker.NewMessage("b-2", []byte("block"), "blocks"))
+	brokerFor(brokerPurposeAlerts).Push("transactions", broker.NewMessage("t-1", []byte("tx"), "transactions"))
+	brokerFor(brokerPurposeAlerts).MoveToDLQ("transactions", broker.NewMessage("t-2", []byte("tx"), "transactions"))
+
+	// /metrics 包含各 Broker 的獨立指標與合計
+	rr := httptest.NewRecorder()
+	handleMetrics(rr, httptest.NewRequest("GET", "/metrics", nil))
+	for _, series := range []string{
+		`broker_messages_total{broker="blocks"} 2`,
+		`broker_messages_total{broker="alerts"} 1`,
+		`broker_messages_failed_total{broker="alerts"} 1`,
+		"messages_total 3",
+	} {
+		if !bytes.Contains(rr.Body.Bytes(), []byte(series)) {
+			t.Errorf("Expected %q in metrics, got:\n%s", series, rr.Body.String())
+		}
+	}
+
+	// /queues?broker= 只返回指定 Broker 的隊列
+	rr = httptest.NewRecorder()
+	handleQueues(rr, httptest.NewRequest("GET", "/queues?broker=alerts", nil))
+	var queues map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &queues)
+	if _, ok := queues["transactions"]; !ok || len(queues) != 1 {
+		t.Errorf("Expected only the transactions queue for the alerts broker, got %v", queues)
+	}
+
+	rr = httptest.NewRecorder()
+	handleQueues(rr, httptest.NewRequest("GET", "/queues?broker=missing", nil))
+	if rr.Code != 404 {
+		t.Errorf("Expected 404 for unknown broker, got %d", rr.Code)
+	}
+
+	// /dlq 會找到擁有該隊列的 Broker
+	rr = httptest.NewRecorder()
+	handleDLQ(rr, httptest.NewRequest("GET", "/dlq?queue=transactions", nil))
+	var dlq map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &dlq)
+	if dlq["count"].(float64) != 1 {
+		t.Errorf("Expected 1 DLQ message for transactions, got %v", dlq["count"])
+	}
+
+	// /health 列出各 Broker 的健康狀態
+	rr = httptest.NewRecorder()
+	handleHealth(rr, httptest.NewRequest("GET", "/health", nil))
+	var health map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &health)
+	brokerHealth := health["brokers"].(map[string]interface{})
+	if brokerHealth["blocks"] != true || brokerHealth["alerts"] != true {
+		t.Errorf("Expected both brokers healthy, got %v", brokerHealth)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+)
+
+// reorg 處理的預設值
+const (
+	defaultReorgDepth  = 3   // 重組後需要再延伸多少個區塊才視為穩定
+	reorgHistoryBlocks = 128 // 保留多少個最近區塊的 hash 用於偵測重組
+)
+
+// reorgs 在觀察到鏈重組時暫停轉發偵測，設定 REORG_DEPTH=0 時為 nil
+var reorgs *reorgGuard
+
+// heldDetection 是重組期間暫緩轉發的偵測
+type heldDetection struct {
+	blockNumber uint64
+	blockHash   string
+	forward     func()
+}
+
+// reorgGuard 追蹤最近區塊的 canonical hash，偵測到重組時暫緩轉發偵測
+//
+// 重組期間轉發的偵測會先緩衝起來，直到 canonical 鏈在重組高度之後再延伸 depth 個區塊。
+// 屆時仍在 canonical 鏈上的偵測會被轉發，所在區塊已被孤立的偵測則直接丟棄。
+// 所在區塊在穩定前就移出 reorgHistoryBlocks 的追蹤視窗時，偵測會提前以同樣的規則處理，不會因 hash 被清除而遺失。
+type reorgGuard struct {
+	depth uint64
+
+	mu         sync.Mutex
+	canonical  map[uint64]string // 區塊號 -> canonical hash
+	tip        uint64            // 目前看到的最高區塊號
+	inProgress bool
+	resumeAt   uint64 // 看到此高度的區塊後視為穩定
+	held       []heldDetection
+}
+
+// newReorgGuard 創建重組保護，depth 為重組後視為穩定所需的區塊數
+func newReorgGuard(depth uint64) *reorgGuard {
+	return &reorgGuard{depth: depth, canonical: make(map[uint64]string)}
+}
+
+// newReorgGuardFromEnv 從 REORG_DEPTH 讀取穩定所需的區塊數，設為 0 時關閉
+func newReorgGuardFromEnv() *reorgGuard {
+	depth := envInt("REORG_DEPTH", defaultReorgDepth)
+	if depth <= 0 {
+		return nil
+	}
+	return newReorgGuard(uint64(depth))
+}
+
+// observe 記錄新收到的區塊頭，偵測重組並在鏈穩定後釋放緩衝的偵測
+func (g *reorgGuard) observe(header *types.Header) {
+	number := header.Number.Uint64()
+	hash := header.Hash().Hex()
+
+	g.mu.Lock()
+	reorgAt, reorged := g.detect(number, hash, header.ParentHash.Hex())
+	g.canonical[number] = hash
+	if number > g.tip {
+		g.tip = number
+	}
+	expired, dropped := g.prune()
+
+	if reorged {
+		if !g.inProgress {
+			logrus.WithFields(logrus.Fields{
+				"blockNumber": reorgAt,
+				"depth":       g.depth,
+			}).Warn("🔀 偵測到鏈重組，暫停轉發偵測直到鏈穩定")
+		}
+		g.inProgress = true
+		if resumeAt := number + g.depth; resumeAt > g.resumeAt {
+			g.resumeAt = resumeAt
+		}
+		g.mu.Unlock()
+		releaseExpired(expired, dropped)
+		return
+	}
+
+	if !g.inProgress || number < g.resumeAt {
+		g.mu.Unlock()
+		releaseExpired(expired, dropped)
+		return
+	}
+
+	// 鏈已穩定：釋放仍在 canonical 鏈上的偵測，丟棄孤立區塊中的偵測
+	release := expired
+	discarded := dropped
+	for _, d := range g.held {
+		if g.canonical[d.blockNumber] == d.blockHash {
+			release = append(release, d.forward)
+		} else {
+			discarded++
+		}
+	}
+	g.held = nil
+	g.inProgress = false
+	g.resumeAt = 0
+	g.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"released":  len(release),
+		"discarded": discarded,
+	}).Info("✅ 鏈重組已穩定，恢復轉發偵測")
+	for _, forward := range release {
+		forward()
+	}
+}
+
+// detect 判斷新區塊是否與已知的 canonical 鏈衝突，返回重組發生的最低高度
+// 衝突高度以上的舊 hash 會被移除，呼叫者需持有鎖
+func (g *reorgGuard) detect(number uint64, hash, parentHash string) (uint64, bool) {
+	reorgAt := uint64(0)
+	reorged := false
+
+	if known, exists := g.canonical[number]; exists && known != hash {
+		reorgAt, reorged = number, true
+	}
+	if number > 0 {
+		if parent, exists := g.canonical[number-1]; exists && parent != parentHash {
+			reorgAt, reorged = number-1, true // 父區塊也已被替換，新 hash 尚未得知
+		}
+	}
+
+	if reorged {
+		for n := range g.canonical {
+			if n >= reorgAt {
+				delete(g.canonical, n)
+			}
+		}
+	}
+	return reorgAt, reorged
+}
+
+// prune 只保留最近 reorgHistoryBlocks 個區塊的 hash，呼叫者需持有鎖
+// 區塊移出視窗後就無法再比對 hash，因此先處理所在區塊即將移出的緩衝偵測：這些區塊已夠深而視為確定，
+// 除非已知被孤立，否則不等重組穩定就返回其轉發函式 (release)，被孤立的則計入 discarded 並移除
+func (g *reorgGuard) prune() (release []func(), discarded int) {
+	if g.tip < reorgHistoryBlocks {
+		return nil, 0
+	}
+	cutoff := g.tip - reorgHistoryBlocks
+
+	kept := g.held[:0]
+	for _, d := range g.held {
+		switch known, exists := g.canonical[d.blockNumber]; {
+		case d.blockNumber > cutoff:
+			kept = append(kept, d)
+		case exists && known != d.blockHash:
+			discarded++
+		default:
+			release = append(release, d.forward)
+		}
+	}
+	g.held = kept
+
+	for n := range g.canonical {
+		if n <= cutoff {
+			delete(g.canonical, n)
+		}
+	}
+	return release, discarded
+}
+
+// releaseExpired 轉發所在區塊已移出追蹤視窗的緩衝偵測，呼叫時不可持有鎖
+func releaseExpired(release []func(), discarded int) {
+	if len(release) == 0 && discarded == 0 {
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"released":  len(release),
+		"discarded": discarded,
+	}).Warn("⏰ 緩衝偵測所在區塊已超出重組追蹤範圍，不再等待鏈穩定")
+	for _, forward := range release {
+		forward()
+	}
+}
+
+// hold 返回 true 表示呼叫者不應直接轉發：重組進行中時偵測會被緩衝，
+// 所在區塊已知被孤立時偵測會被丟棄；否則返回 false，呼叫者應直接轉發
+func (g *reorgGuard) hold(blockNumber uint64, blockHash string, forward func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if known, exists := g.canonical[blockNumber]; exists && known != blockHash {
+		return true // 區塊在重組穩定後才被處理，已不在 canonical 鏈上
+	}
+	if !g.inProgress {
+		return false
+	}
+	g.held = append(g.held, heldDetection{blockNumber: blockNumber, blockHash: blockHash, forward: forward})
+	return true
+}
+
+// status 返回重組是否進行中，以及緩衝中的偵測數
+func (g *reorgGuard) status() (bool, int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.inProgress, len(g.held)
+}
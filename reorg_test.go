@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// newChainHeader 創建指定父區塊的區塊頭，fork 用於在同一高度產生不同的 hash
+func newChainHeader(n int64, parent *types.Header, fork byte) *types.Header {
+	h := &types.Header{Number: big.NewInt(n), Difficulty: big.NewInt(1), Extra: []byte{fork}}
+	if parent != nil {
+		h.ParentHash = parent.Hash()
+	}
+	return h
+}
+
+// forwardedTxHashes 取出交易隊列中所有已轉發的交易 hash
+func forwardedTxHashes(t *testing.T) []string {
+	t.Helper()
+	var hashes []string
+	for {
+		msg, _ := messageBroker.Pull(transactionQueueName)
+		if msg == nil {
+			return hashes
+		}
+		var txInfo TransactionInfo
+		unmarshalEvent(msg.Body, &txInfo)
+		hashes = append(hashes, txInfo.Hash)
+	}
+}
+
+// reorgInProgressFromHealth 從 /health 讀取 reorg_in_progress
+func reorgInProgressFromHealth(t *testing.T) bool {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &health)
+	inProgress, _ := health["reorg_in_progress"].(bool)
+	return inProgress
+}
+
+func TestReorgGuardDiscardsOrphanedDetection(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+	reorgs = newReorgGuard(2)
+	defer func() { reorgs = nil }()
+
+	detect := func(header *types.Header, txHash string) {
+		processBlockMessage(BlockMessage{
+			BlockNumber:  header.Number.String(),
+			BlockHash:    header.Hash().Hex(),
+			Transactions: []TransactionInfo{{Hash: txHash, To: targetAddress, Value: "1"}},
+		}, 1)
+	}
+
+	h1 := newChainHeader(1, nil, 0)
+	h2 := newChainHeader(2, h1, 0)
+	reorgs.observe(h1)
+	reorgs.observe(h2)
+
+	// 沒有重組時直接轉發
+	detect(h1, "0xbefore")
+	if got := forwardedTxHashes(t); len(got) != 1 || got[0] != "0xbefore" {
+		t.Fatalf("Expected detection to be forwarded immediately, got %v", got)
+	}
+
+	// 區塊 2 被 2' 取代
+	h2b := newChainHeader(2, h1, 1)
+	reorgs.observe(h2b)
+	if !reorgInProgressFromHealth(t) {
+		t.Error("Expected /health to report reorg_in_progress")
+	}
+
+	detect(h2, "0xorphan")
+	detect(h2b, "0xvalid")
+	if got := forwardedTxHashes(t); len(got) != 0 {
+		t.Errorf("Expected detections to be held during reorg, got %v", got)
+	}
+
+	// 鏈在重組高度後再延伸 2 個區塊後視為穩定
+	h3 := newChainHeader(3, h2b, 0)
+	reorgs.observe(h3)
+	if !reorgInProgressFromHealth(t) {
+		t.Error("Expected reorg to still be in progress before reaching the depth")
+	}
+	reorgs.observe(newChainHeader(4, h3, 0))
+
+	if got := forwardedTxHashes(t); len(got) != 1 || got[0] != "0xvalid" {
+		t.Errorf("Expected only the valid detection to be released, got %v", got)
+	}
+	if reorgInProgressFromHealth(t) {
+		t.Error("Expected reorg_in_progress to be cleared after stabilizing")
+	}
+
+	// 孤立區塊在穩定後才被處理，也不會被轉發
+	detect(h2, "0xlate-orphan")
+	if got := forwardedTxHashes(t); len(got) != 0 {
+		t.Errorf("Expected late orphaned detection to be discarded, got %v", got)
+	}
+}
+
+func TestReorgGuardParentMismatch(t *testing.T) {
+	g := newReorgGuard(1)
+
+	h1 := newChainHeader(1, nil, 0)
+	g.observe(h1)
+	g.observe(newChainHeader(2, h1, 0))
+
+	// 新的區塊 3 的父區塊不是已知的區塊 2，表示區塊 2 已被替換
+	g.observe(&types.Header{Number: big.NewInt(3), Difficulty: big.NewInt(1), ParentHash: common.HexToHash("0x02")})
+	if inProgress, _ := g.status(); !inProgress {
+		t.Fatal("Expected parent hash mismatch to be detected as a reorg")
+	}
+	if _, exists := g.canonical[2]; exists {
+		t.Error("Expected replaced block 2 to be removed from the canonical chain")
+	}
+}
+
+func TestReorgGuardReleasesDetectionsLeavingWindow(t *testing.T) {
+	// depth 大於追蹤視窗，重組在區塊移出視窗前都不會穩定
+	g := newReorgGuard(reorgHistoryBlocks * 2)
+
+	h1 := newChainHeader(1, nil, 0)
+	h2 := newChainHeader(2, h1, 0)
+	g.observe(h1)
+	g.observe(h2)
+	h2b := newChainHeader(2, h1, 1)
+	g.observe(h2b)
+
+	var forwarded []string
+	g.hold(2, h2.Hash().Hex(), func() { forwarded = append(forwarded, "orphan") })
+	g.hold(2, h2b.Hash().Hex(), func() { forwarded = append(forwarded, "valid") })
+
+	// 延伸 canonical 鏈直到區塊 2 移出視窗
+	parent := h2b
+	for n := int64(3); n <= 2+reorgHistoryBlocks; n++ {
+		parent = newChainHeader(n, parent, 0)
+		g.observe(parent)
+	}
+
+	// canonical 鏈上的偵測在 hash 被清除前釋放，孤立的偵測被丟棄
+	if len(forwarded) != 1 || forwarded[0] != "valid" {
+		t.Errorf("Expected only the valid detection to be released, got %v", forwarded)
+	}
+	if inProgress, held := g.status(); !inProgress || held != 0 {
+		t.Errorf("Expected reorg still in progress with nothing held, got %v and %d", inProgress, held)
+	}
+}
+
+func TestReorgGuardFromEnv(t *testing.T) {
+	t.Setenv("REORG_DEPTH", "0")
+	if g := newReorgGuardFromEnv(); g != nil {
+		t.Error("Expected REORG_DEPTH=0 to disable the guard")
+	}
+
+	t.Setenv("REORG_DEPTH", "")
+	if g := newReorgGuardFromEnv(); g == nil || g.depth != defaultReorgDepth {
+		t.Errorf("Expected default depth %d, got %+v", defaultReorgDepth, g)
+	}
+}
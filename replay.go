@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+)
+
+// replayHeader 標記由 /replay/block 手動注入的區塊消息，下游可據此區分
+const replayHeader = "replay"
+
+// replayBlockClient 是重放區塊所需的節點操作 (ethclient.Client 即實作了此介面)
+type replayBlockClient interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+}
+
+// replaySource 保存目前連線的節點與區塊監聽器，每次重新連線時更新
+type replaySource struct {
+	mu      sync.RWMutex
+	client  replayBlockClient
+	watcher *blockWatcher
+}
+
+// replayBlocks 供 /replay/block 使用，尚未連線到節點時為空
+var replayBlocks = &replaySource{}
+
+// set 更新目前連線的節點與區塊監聽器
+func (s *replaySource) set(client replayBlockClient, watcher *blockWatcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.client = client
+	s.watcher = watcher
+}
+
+// get 返回目前連線的節點與區塊監聽器
+func (s *replaySource) get() (replayBlockClient, *blockWatcher) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.client, s.watcher
+}
+
+// handleReplayBlock 處理 POST /replay/block?number=N
+// 抓取歷史區塊 N 並如同新到達的區塊一樣推送到區塊隊列，消息帶有 replay: true 標頭
+func handleReplayBlock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	number, err := strconv.ParseUint(r.URL.Query().Get("number"), 10, 64)
+	if err != nil {
+		http.Error(w, "number parameter must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	client, watcher := replayBlocks.get()
+	if client == nil || watcher == nil {
+		http.Error(w, "not connected to a node", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	latest, err := client.BlockNumber(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get latest block: %v", err), http.StatusBadGateway)
+		return
+	}
+	if number > latest {
+		http.Error(w, fmt.Sprintf("block %d is beyond the latest block %d", number, latest), http.StatusNotFound)
+		return
+	}
+
+	block, err := client.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if errors.Is(err, ethereum.NotFound) {
+		http.Error(w, fmt.Sprintf("block %d not found", number), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to fetch block %d: %v", number, err), http.StatusBadGateway)
+		return
+	}
+
+	// 不設定 ContentID：重放的目的就是重新處理，不應被 effectively-once 去重丟棄
+	msg, err := watcher.blockMessage(ctx, watcher.receiptsFor(client), block)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to scan block %d: %v", number, err), http.StatusBadGateway)
+		return
+	}
+	msg.Headers[replayHeader] = "true"
+	if err := brokerFor(brokerPurposeBlocks).Push(blockQueueName, msg); err != nil {
+		http.Error(w, fmt.Sprintf("failed to enqueue block %d: %v", number, err), http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"blockNumber": number,
+		"messageID":   msg.ID,
+	}).Info("🔁 已手動重放區塊")
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"block_number": number,
+		"block_hash":   block.Hash().Hex(),
+		"message_id":   msg.ID,
+		"queue":        blockQueueName,
+	})
+}
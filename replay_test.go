@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// mockReplayClient 是測試用的節點，只有 blocks 中的區塊存在
+type mockReplayClient struct {
+	latest uint64
+	blocks map[uint64]*types.Block
+}
+
+func (c *mockReplayClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return c.latest, nil
+}
+
+func (c *mockReplayClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	block, ok := c.blocks[number.Uint64()]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return block, nil
+}
+
+// replayRequest 以正確的 API key 發送重放請求
+func replayRequest(t *testing.T, number string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/replay/block?number="+number, nil)
+	req.Header.Set(apiKeyHeader, "secret")
+	rr := httptest.NewRecorder()
+	requireAPIKey(handleReplayBlock)(rr, req)
+	return rr
+}
+
+func TestHTTPReplayBlock(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+	t.Setenv("API_KEY", "secret")
+
+	header := newTestHeader(100)
+	client := &mockReplayClient{
+		latest: 200,
+		blocks: map[uint64]*types.Block{
+			100: types.NewBlockWithHeader(header).WithBody(types.Body{
+				Transactions: []*types.Transaction{newTestTx(1, targetAddress)},
+			}),
+		},
+	}
+	replayBlocks.set(client, &blockWatcher{clock: broker.RealClock{}})
+	defer replayBlocks.set(nil, nil)
+
+	rr := replayRequest(t, "100")
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	msg, err := messageBroker.Pull(blockQueueName)
+	if err != nil || msg == nil {
+		t.Fatalf("Expected replayed block to be enqueued, got %v (err=%v)", msg, err)
+	}
+	if msg.Headers[replayHeader] != "true" {
+		t.Errorf("Expected replay header, got %v", msg.Headers)
+	}
+	var blockMessage BlockMessage
+	json.Unmarshal(msg.Body, &blockMessage)
+	if blockMessage.BlockNumber != "100" || blockMessage.BlockHash != header.Hash().Hex() || len(blockMessage.Transactions) != 1 {
+		t.Errorf("Unexpected replayed block message: %+v", blockMessage)
+	}
+
+	// 格式錯誤、未來區塊與不存在的區塊
+	for number, want := range map[string]int{
+		"abc": http.StatusBadRequest,
+		"":    http.StatusBadRequest,
+		"201": http.StatusNotFound,
+		"150": http.StatusNotFound,
+	} {
+		if rr := replayRequest(t, number); rr.Code != want {
+			t.Errorf("number=%q: expected status %d, got %d", number, want, rr.Code)
+		}
+	}
+	if msg, _ := messageBroker.Pull(blockQueueName); msg != nil {
+		t.Errorf("Expected no extra messages, got %s", msg.ID)
+	}
+}
+
+func TestHTTPReplayBlockRequiresAPIKey(t *testing.T) {
+	replayBlocks.set(&mockReplayClient{}, &blockWatcher{clock: broker.RealClock{}})
+	defer replayBlocks.set(nil, nil)
+
+	// 未設定 API_KEY 時端點關閉
+	t.Setenv("API_KEY", "")
+	rr := httptest.NewRecorder()
+	requireAPIKey(handleReplayBlock)(rr, httptest.NewRequest(http.MethodPost, "/replay/block?number=1", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without API_KEY configured, got %d", rr.Code)
+	}
+
+	// 錯誤的 key
+	t.Setenv("API_KEY", "secret")
+	req := httptest.NewRequest(http.MethodPost, "/replay/block?number=1", nil)
+	req.Header.Set(apiKeyHeader, "wrong")
+	rr = httptest.NewRecorder()
+	requireAPIKey(handleReplayBlock)(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with wrong key, got %d", rr.Code)
+	}
+}
+
+func TestHTTPReplayBlockNotConnected(t *testing.T) {
+	t.Setenv("API_KEY", "secret")
+	replayBlocks.set(nil, nil)
+
+	if rr := replayRequest(t, "1"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 when not connected, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/replay/block?number=1", nil)
+	req.Header.Set(apiKeyHeader, "secret")
+	rr := httptest.NewRecorder()
+	requireAPIKey(handleReplayBlock)(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", rr.Code)
+	}
+}
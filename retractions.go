@@ -0,0 +1,176 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/sirupsen/logrus"
+)
+
+// reorgTopicName 是發布撤回事件的主題，訂閱者會收到已轉發但所在區塊被重組掉的偵測
+const reorgTopicName = "reorgs"
+
+// retractions 追蹤已轉發的偵測，區塊被重組掉時發布撤回事件，未初始化時為 nil
+var retractions *retractionTracker
+
+// revertedDetection 是撤回事件的內容
+type revertedDetection struct {
+	BlockNumber uint64          `json:"block_number"`
+	BlockHash   string          `json:"block_hash"` // 已被孤立的區塊 hash
+	ReorgAt     uint64          `json:"reorg_at"`   // 重組發生的最低高度
+	Transaction TransactionInfo `json:"transaction"`
+}
+
+// reportedBlock 是某個區塊中已轉發的偵測
+type reportedBlock struct {
+	hash string
+	txs  []TransactionInfo
+}
+
+// retractionTracker 記錄最近區塊頭與已轉發的偵測，新區塊頭不延伸已記錄的鏈時撤回受影響的偵測
+type retractionTracker struct {
+	mu       sync.Mutex
+	headers  map[uint64]*types.Header   // 區塊號 -> 目前認定的 canonical 區塊頭
+	reported map[uint64][]reportedBlock // 區塊號 -> 已轉發偵測的區塊
+	tip      uint64                     // 目前看到的最高區塊號
+}
+
+// newRetractionTracker 創建撤回追蹤器
+func newRetractionTracker() *retractionTracker {
+	return &retractionTracker{
+		headers:  make(map[uint64]*types.Header),
+		reported: make(map[uint64][]reportedBlock),
+	}
+}
+
+// detectReorg 判斷新區塊頭是否與先前記錄的區塊頭衝突，返回重組發生的最低高度
+// 同一高度出現不同的區塊，或新區塊的父 hash 不是先前記錄的上一個區塊時視為重組；
+// 兩者高度不相鄰時無法判斷，視為沒有重組
+func detectReorg(prevHeader, newHeader *types.Header) (uint64, bool) {
+	if prevHeader == nil || newHeader == nil {
+		return 0, false
+	}
+
+	prev, next := prevHeader.Number.Uint64(), newHeader.Number.Uint64()
+	switch {
+	case next == prev && newHeader.Hash() != prevHeader.Hash():
+		return next, true
+	case next == prev+1 && newHeader.ParentHash != prevHeader.Hash():
+		return prev, true
+	}
+	return 0, false
+}
+
+// record 記錄已轉發到交易隊列的偵測
+func (r *retractionTracker) record(blockNumber uint64, blockHash string, txs []TransactionInfo) {
+	if blockHash == "" || len(txs) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, block := range r.reported[blockNumber] {
+		if block.hash == blockHash {
+			r.reported[blockNumber][i].txs = append(block.txs, txs...)
+			return
+		}
+	}
+	r.reported[blockNumber] = append(r.reported[blockNumber], reportedBlock{hash: blockHash, txs: append([]TransactionInfo(nil), txs...)})
+}
+
+// observe 記錄新的區塊頭，偵測到重組時發布被孤立區塊中已轉發偵測的撤回事件
+func (r *retractionTracker) observe(header *types.Header) {
+	number := header.Number.Uint64()
+
+	r.mu.Lock()
+	reorgAt, reorged := detectReorg(r.headers[number], header)
+	if !reorged && number > 0 {
+		reorgAt, reorged = detectReorg(r.headers[number-1], header)
+	}
+
+	var reverted []revertedDetection
+	if reorged {
+		reverted = r.retract(reorgAt, header)
+	}
+	r.headers[number] = header
+	if number > r.tip {
+		r.tip = number
+	}
+	r.prune()
+	r.mu.Unlock()
+
+	for _, detection := range reverted {
+		publishReverted(detection)
+	}
+}
+
+// retract 移除重組高度以上的舊區塊頭，返回不在新區塊上的已轉發偵測，呼叫者需持有鎖
+func (r *retractionTracker) retract(reorgAt uint64, header *types.Header) []revertedDetection {
+	newHash := header.Hash().Hex()
+
+	var reverted []revertedDetection
+	for n, blocks := range r.reported {
+		if n < reorgAt {
+			continue
+		}
+		var kept []reportedBlock
+		for _, block := range blocks {
+			if n == header.Number.Uint64() && block.hash == newHash {
+				kept = append(kept, block)
+				continue
+			}
+			for _, tx := range block.txs {
+				reverted = append(reverted, revertedDetection{BlockNumber: n, BlockHash: block.hash, ReorgAt: reorgAt, Transaction: tx})
+			}
+		}
+		if len(kept) == 0 {
+			delete(r.reported, n)
+		} else {
+			r.reported[n] = kept
+		}
+	}
+	for n := range r.headers {
+		if n >= reorgAt {
+			delete(r.headers, n)
+		}
+	}
+	return reverted
+}
+
+// prune 只保留最近 reorgHistoryBlocks 個區塊的記錄，呼叫者需持有鎖
+func (r *retractionTracker) prune() {
+	if r.tip < reorgHistoryBlocks {
+		return
+	}
+	for n := range r.headers {
+		if n <= r.tip-reorgHistoryBlocks {
+			delete(r.headers, n)
+		}
+	}
+	for n := range r.reported {
+		if n <= r.tip-reorgHistoryBlocks {
+			delete(r.reported, n)
+		}
+	}
+}
+
+// publishReverted 在撤回主題上發布偵測已被重組撤回的事件
+func publishReverted(detection revertedDetection) {
+	logrus.WithFields(logrus.Fields{
+		"blockNumber": detection.BlockNumber,
+		"blockHash":   detection.BlockHash,
+		"txHash":      detection.Transaction.Hash,
+	}).Warn("↩️ 已轉發的偵測所在區塊被重組，發布撤回事件")
+
+	data, err := marshalEvent(eventTypeReverted, detection)
+	if err != nil {
+		logrus.WithError(err).Warn("⚠️ 序列化撤回事件失敗")
+		return
+	}
+	msg := broker.NewMessage(generateMessageID(), data, reorgTopicName)
+	if err := brokerFor(brokerPurposeAlerts).Publish(reorgTopicName, msg); err != nil {
+		logrus.WithError(err).Warn("⚠️ 發布撤回事件失敗")
+	}
+}
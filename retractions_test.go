@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectReorg(t *testing.T) {
+	h1 := newChainHeader(1, nil, 0)
+	h2 := newChainHeader(2, h1, 0)
+	h2b := newChainHeader(2, h1, 1)
+	h3b := newChainHeader(3, h2b, 0)
+
+	if _, reorged := detectReorg(h1, h2); reorged {
+		t.Error("Expected a child block not to be a reorg")
+	}
+	if at, reorged := detectReorg(h2, h2b); !reorged || at != 2 {
+		t.Errorf("Expected same-height replacement at 2, got %d, %v", at, reorged)
+	}
+	if at, reorged := detectReorg(h2, h3b); !reorged || at != 2 {
+		t.Errorf("Expected parent mismatch to replace block 2, got %d, %v", at, reorged)
+	}
+	if _, reorged := detectReorg(h1, h3b); reorged {
+		t.Error("Expected non-adjacent headers not to be compared")
+	}
+	if _, reorged := detectReorg(nil, h2); reorged {
+		t.Error("Expected no reorg without a previous header")
+	}
+}
+
+func TestReorgRetractsReportedDetection(t *testing.T) {
+	_, alerts := withBrokerRegistry(t)
+	retractions = newRetractionTracker()
+	defer func() { retractions = nil }()
+
+	reverted, err := alerts.Subscribe(reorgTopicName)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	h1 := newChainHeader(1, nil, 0)
+	h2 := newChainHeader(2, h1, 0)
+	observeHeader(h1)
+	observeHeader(h2)
+
+	// 區塊 1 與 2 中的存款已被轉發
+	processBlockMessage(BlockMessage{
+		BlockNumber:  "1",
+		BlockHash:    h1.Hash().Hex(),
+		Transactions: []TransactionInfo{{Hash: "0xkept", To: targetAddress, Value: "1"}},
+	}, 1)
+	processBlockMessage(BlockMessage{
+		BlockNumber:  "2",
+		BlockHash:    h2.Hash().Hex(),
+		Transactions: []TransactionInfo{{Hash: "0xreverted", To: targetAddress, Value: "1"}},
+	}, 1)
+
+	// 區塊 3' 的父區塊不是已記錄的區塊 2，區塊 2 已被重組掉
+	h2b := newChainHeader(2, h1, 1)
+	observeHeader(newChainHeader(3, h2b, 0))
+
+	select {
+	case msg := <-reverted:
+		var detection revertedDetection
+		envelope, err := unmarshalEvent(msg.Body, &detection)
+		if err != nil {
+			t.Fatalf("Failed to decode reverted event: %v", err)
+		}
+		if envelope.Type != eventTypeReverted {
+			t.Errorf("Expected %q event, got %q", eventTypeReverted, envelope.Type)
+		}
+		if detection.Transaction.Hash != "0xreverted" || detection.BlockHash != h2.Hash().Hex() || detection.ReorgAt != 2 {
+			t.Errorf("Unexpected reverted detection: %+v", detection)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a reverted event for the orphaned deposit")
+	}
+
+	select {
+	case msg := <-reverted:
+		t.Errorf("Expected only one reverted event, got %s", msg.Body)
+	default:
+	}
+
+	// 同一次重組不會重複撤回
+	observeHeader(h2b)
+	select {
+	case msg := <-reverted:
+		t.Errorf("Expected no further retraction, got %s", msg.Body)
+	default:
+	}
+}
+
+func TestReorgKeepsDetectionOnReplacementBlock(t *testing.T) {
+	r := newRetractionTracker()
+
+	h1 := newChainHeader(1, nil, 0)
+	h2 := newChainHeader(2, h1, 0)
+	r.observe(h1)
+	r.observe(h2)
+
+	// 偵測所在的正是取代後的新區塊，不應撤回
+	h2b := newChainHeader(2, h1, 1)
+	r.record(2, h2b.Hash().Hex(), []TransactionInfo{{Hash: "0xnew"}})
+	if got := r.retract(2, h2b); len(got) != 0 {
+		t.Errorf("Expected detection on the new canonical block to be kept, got %+v", got)
+	}
+	if len(r.reported[2]) != 1 {
+		t.Errorf("Expected detection to stay recorded, got %+v", r.reported[2])
+	}
+}
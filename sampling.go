@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// samplingDefaultKey 是套用到所有未個別設定地址的取樣規則
+const samplingDefaultKey = "*"
+
+// samplingRule 定義單一地址的取樣方式
+// 每個區塊中前 Threshold 筆匹配交易全部轉發，超過後每 EveryN 筆只轉發一筆
+type samplingRule struct {
+	Threshold int
+	EveryN    int
+}
+
+// detectionSampler 在單一地址流量過高 (例如粉塵攻擊) 時只轉發部分交易
+type detectionSampler struct {
+	rules map[string]samplingRule // 地址 (小寫) → 規則
+}
+
+// newDetectionSampler 創建取樣器，rules 為空時轉發所有交易
+func newDetectionSampler(rules map[string]samplingRule) *detectionSampler {
+	normalized := make(map[string]samplingRule, len(rules))
+	for address, rule := range rules {
+		normalized[strings.ToLower(address)] = rule
+	}
+	return &detectionSampler{rules: normalized}
+}
+
+// newDetectionSamplerFromEnv 從 SAMPLING_RULES 讀取取樣規則
+func newDetectionSamplerFromEnv() (*detectionSampler, error) {
+	rules, err := parseSamplingRules(os.Getenv("SAMPLING_RULES"))
+	if err != nil {
+		return nil, err
+	}
+	return newDetectionSampler(rules), nil
+}
+
+// parseSamplingRules 解析形如 "0xabc...:100:10,*:500:50" 的取樣規則
+// 每條規則為「地址:門檻:每N筆取一筆」，地址為 * 時作為預設規則
+func parseSamplingRules(spec string) (map[string]samplingRule, error) {
+	rules := make(map[string]samplingRule)
+	if strings.TrimSpace(spec) == "" {
+		return rules, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid sampling rule %q, expected address:threshold:everyN", part)
+		}
+
+		threshold, err := strconv.Atoi(fields[1])
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("invalid sampling threshold in rule %q", part)
+		}
+		everyN, err := strconv.Atoi(fields[2])
+		if err != nil || everyN < 1 {
+			return nil, fmt.Errorf("invalid sampling rate in rule %q", part)
+		}
+
+		rules[strings.ToLower(fields[0])] = samplingRule{Threshold: threshold, EveryN: everyN}
+	}
+	return rules, nil
+}
+
+// shouldForward 判斷某地址在同一區塊中的第 n 筆 (從 1 開始) 匹配交易是否應該轉發
+func (s *detectionSampler) shouldForward(address string, n int) bool {
+	rule, ok := s.rules[strings.ToLower(address)]
+	if !ok {
+		rule, ok = s.rules[samplingDefaultKey]
+	}
+	if !ok || n <= rule.Threshold || rule.EveryN <= 1 {
+		return true
+	}
+	return (n-rule.Threshold)%rule.EveryN == 0
+}
+
+// detectionCounter 記錄各地址匹配、實際轉發與因停用而被抑制的交易數
+type detectionCounter struct {
+	mu         sync.Mutex
+	matched    map[string]int64
+	forwarded  map[string]int64
+	suppressed map[string]int64
+}
+
+// newDetectionCounter 創建新的偵測計數器
+func newDetectionCounter() *detectionCounter {
+	return &detectionCounter{
+		matched:    make(map[string]int64),
+		forwarded:  make(map[string]int64),
+		suppressed: make(map[string]int64),
+	}
+}
+
+// recordMatch 記錄一筆匹配的交易 (不論是否被取樣轉發)
+func (c *detectionCounter) recordMatch(address string) {
+	c.mu.Lock()
+	c.matched[address]++
+	c.mu.Unlock()
+}
+
+// recordForward 記錄一筆實際轉發的交易
+func (c *detectionCounter) recordForward(address string) {
+	c.mu.Lock()
+	c.forwarded[address]++
+	c.mu.Unlock()
+}
+
+// recordSuppressed 記錄一筆因地址停用而未處理的交易
+func (c *detectionCounter) recordSuppressed(address string) {
+	c.mu.Lock()
+	c.suppressed[address]++
+	c.mu.Unlock()
+}
+
+// detectionCount 是單一地址的偵測統計
+type detectionCount struct {
+	Address    string
+	Matched    int64
+	Forwarded  int64
+	Suppressed int64
+}
+
+// snapshot 返回依地址排序的統計副本
+func (c *detectionCounter) snapshot() []detectionCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	addresses := make(map[string]bool, len(c.matched)+len(c.suppressed))
+	for address := range c.matched {
+		addresses[address] = true
+	}
+	for address := range c.suppressed {
+		addresses[address] = true
+	}
+
+	result := make([]detectionCount, 0, len(addresses))
+	for address := range addresses {
+		result = append(result, detectionCount{
+			Address:    address,
+			Matched:    c.matched[address],
+			Forwarded:  c.forwarded[address],
+			Suppressed: c.suppressed[address],
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Address < result[j].Address })
+	return result
+}
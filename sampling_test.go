@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+func TestParseSamplingRules(t *testing.T) {
+	rules, err := parseSamplingRules("0xABC:100:10, *:500:50")
+	if err != nil {
+		t.Fatalf("parseSamplingRules failed: %v", err)
+	}
+	if rules["0xabc"] != (samplingRule{Threshold: 100, EveryN: 10}) {
+		t.Errorf("Unexpected rule for 0xabc: %+v", rules["0xabc"])
+	}
+	if rules["*"] != (samplingRule{Threshold: 500, EveryN: 50}) {
+		t.Errorf("Unexpected default rule: %+v", rules["*"])
+	}
+
+	if rules, err := parseSamplingRules(""); err != nil || len(rules) != 0 {
+		t.Errorf("Expected empty spec to yield no rules, got %v (err=%v)", rules, err)
+	}
+
+	for _, bad := range []string{"0xabc:10", "0xabc:x:10", "0xabc:10:0", "0xabc:-1:5"} {
+		if _, err := parseSamplingRules(bad); err == nil {
+			t.Errorf("Expected error for invalid rule %q", bad)
+		}
+	}
+}
+
+func TestDetectionSamplerShouldForward(t *testing.T) {
+	s := newDetectionSampler(map[string]samplingRule{
+		"0xAAA": {Threshold: 2, EveryN: 3},
+	})
+
+	var forwarded []int
+	for n := 1; n <= 11; n++ {
+		if s.shouldForward("0xaaa", n) {
+			forwarded = append(forwarded, n)
+		}
+	}
+	// 前 2 筆全部轉發，之後每 3 筆轉發一筆
+	if fmt.Sprint(forwarded) != "[1 2 5 8 11]" {
+		t.Errorf("Unexpected forwarded sequence: %v", forwarded)
+	}
+
+	// 沒有規則的地址全部轉發
+	if !s.shouldForward("0xbbb", 1000) {
+		t.Error("Expected addresses without a rule to always be forwarded")
+	}
+}
+
+func TestProcessBlockMessageSamplesBurst(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	sampler = newDetectionSampler(map[string]samplingRule{
+		targetAddress: {Threshold: 10, EveryN: 10},
+	})
+	detectionCounters = newDetectionCounter()
+	defer func() { sampler = newDetectionSampler(nil) }()
+
+	// 單一區塊中對同一地址的 100 筆粉塵轉帳
+	blockMessage := BlockMessage{BlockNumber: "500", TxCount: 100}
+	for i := 0; i < 100; i++ {
+		blockMessage.Transactions = append(blockMessage.Transactions, TransactionInfo{
+			Hash:  fmt.Sprintf("0x%d", i),
+			To:    targetAddress,
+			Value: "1",
+		})
+	}
+	processBlockMessage(blockMessage, 1)
+
+	// 前 10 筆 + 之後 90 筆中每 10 筆一筆 = 19 筆被轉發
+	stats, err := messageBroker.GetQueueStats(transactionQueueName)
+	if err != nil {
+		t.Fatalf("GetQueueStats failed: %v", err)
+	}
+	if stats.EnqueuedTotal != 19 {
+		t.Errorf("Expected 19 sampled transactions forwarded, got %d", stats.EnqueuedTotal)
+	}
+
+	counts := detectionCounters.snapshot()
+	if len(counts) != 1 {
+		t.Fatalf("Expected counts for 1 address, got %d", len(counts))
+	}
+	if counts[0].Address != strings.ToLower(targetAddress) || counts[0].Matched != 100 || counts[0].Forwarded != 19 {
+		t.Errorf("Expected all 100 matches counted and 19 forwarded, got %+v", counts[0])
+	}
+
+	// 指標中包含完整的匹配數
+	startTime = time.Now()
+	rr := httptest.NewRecorder()
+	handleMetrics(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), fmt.Sprintf("detections_matched_total{address=%q} 100", strings.ToLower(targetAddress))) {
+		t.Errorf("Expected matched counter in metrics, got:\n%s", rr.Body.String())
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultShutdownGrace 是收到停止信號後等待進行中請求完成的時間
+const defaultShutdownGrace = 10 * time.Second
+
+// shutdownService 在 grace 時間內停止 HTTP 服務器 (等待進行中的請求完成)，再關閉所有 Broker
+// 即使 HTTP 服務器未能及時停止，Broker 仍會被關閉
+func shutdownService(server *http.Server, grace time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	var errs []error
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shut down http server: %w", err))
+		}
+	}
+	if err := brokers.CloseAll(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close brokers: %w", err))
+	}
+
+	if len(errs) == 0 {
+		logrus.Info("👋 服務已關閉")
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownServiceStopsServerAndBrokers(t *testing.T) {
+	blocks, alerts := withBrokerRegistry(t)
+	startTime = time.Now()
+
+	server, err := startHTTPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("startHTTPServer failed: %v", err)
+	}
+	if resp, err := http.Get("http://" + server.Addr + "/health"); err != nil {
+		t.Fatalf("GET /health failed before shutdown: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	if err := shutdownService(server, time.Second); err != nil {
+		t.Fatalf("shutdownService failed: %v", err)
+	}
+
+	if blocks.IsHealthy() || alerts.IsHealthy() {
+		t.Error("Expected all brokers to be closed after shutdown")
+	}
+	if conn, err := net.DialTimeout("tcp", server.Addr, 100*time.Millisecond); err == nil {
+		conn.Close()
+		t.Error("Expected server to stop accepting connections after shutdown")
+	}
+}
+
+func TestShutdownServiceWaitsForInFlightRequests(t *testing.T) {
+	withBrokerRegistry(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	started := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})}
+	go server.Serve(listener)
+
+	result := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			result <- 0
+			return
+		}
+		resp.Body.Close()
+		result <- resp.StatusCode
+	}()
+
+	<-started
+	if err := shutdownService(server, time.Second); err != nil {
+		t.Fatalf("shutdownService failed: %v", err)
+	}
+	if code := <-result; code != http.StatusOK {
+		t.Errorf("Expected in-flight request to complete with 200, got %d", code)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// defaultStatsDeadline 是 /health 與 /metrics 等待最新統計的時限，超過時改用快取
+const defaultStatsDeadline = 500 * time.Millisecond
+
+// brokerStats 是單一 Broker 的統計快照
+type brokerStats struct {
+	Stats   map[string]interface{}
+	Queues  map[string]*broker.QueueStats
+	Healthy bool
+}
+
+// statsSnapshot 是所有 Broker 的統計快照
+type statsSnapshot struct {
+	Brokers map[string]brokerStats
+	TakenAt time.Time
+}
+
+// collectStats 讀取所有 Broker 的最新統計，Broker 內部鎖競爭激烈時可能阻塞
+func collectStats() *statsSnapshot {
+	snapshot := &statsSnapshot{Brokers: make(map[string]brokerStats)}
+	for name, b := range allBrokers() {
+		snapshot.Brokers[name] = brokerStats{
+			Stats:   b.GetMetrics().GetStats(),
+			Queues:  b.GetAllQueueStats(),
+			Healthy: b.IsHealthy(),
+		}
+	}
+	snapshot.TakenAt = clock.Now()
+	return snapshot
+}
+
+// statsCache 保存最近一次成功計算的統計
+//
+// 讀取最新統計超過 deadline 時 (例如大量清空隊列期間長時間持有鎖)，改為返回上一次的快照並標記為過期，
+// 讓監控端點在最需要觀測的時候仍能及時回應。同一時間最多只有一個計算在進行，
+// 被阻塞的計算完成後會更新快取。
+type statsCache struct {
+	deadline time.Duration
+	collect  func() *statsSnapshot
+
+	mu      sync.Mutex
+	last    *statsSnapshot
+	pending chan struct{} // 進行中的計算，完成時關閉
+}
+
+// newStatsCache 創建統計快取
+func newStatsCache(collect func() *statsSnapshot, deadline time.Duration) *statsCache {
+	return &statsCache{deadline: deadline, collect: collect}
+}
+
+// brokerStatsCache 供 /health 與 /metrics 使用
+var brokerStatsCache = newStatsCache(collectStats, defaultStatsDeadline)
+
+// get 返回最新的統計；在 deadline 內無法取得時返回上一次的快照，並以 stale 標示
+// 從未成功計算過時返回空快照
+func (c *statsCache) get() (snapshot *statsSnapshot, stale bool) {
+	c.mu.Lock()
+	done := c.pending
+	if done == nil {
+		done = make(chan struct{})
+		c.pending = done
+		go c.refresh(done)
+	}
+	c.mu.Unlock()
+
+	timer := clock.NewTimer(c.deadline)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C():
+		stale = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		return &statsSnapshot{Brokers: make(map[string]brokerStats)}, true
+	}
+	return c.last, stale
+}
+
+// refresh 計算最新統計並更新快取
+func (c *statsCache) refresh(done chan struct{}) {
+	snapshot := c.collect()
+
+	c.mu.Lock()
+	c.last = snapshot
+	c.pending = nil
+	c.mu.Unlock()
+	close(done)
+}
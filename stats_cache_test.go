@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// blockingBroker 模擬長時間持有內部鎖的 Broker，release 關閉前讀取統計會阻塞
+type blockingBroker struct {
+	broker.Broker
+	blocked chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBroker) GetAllQueueStats() map[string]*broker.QueueStats {
+	select {
+	case <-b.blocked:
+		<-b.release
+	default:
+	}
+	return b.Broker.GetAllQueueStats()
+}
+
+func TestStatsCacheServesStaleStatsUnderContention(t *testing.T) {
+	inner := broker.NewSimpleBroker()
+	defer inner.Close()
+	inner.Push("test", broker.NewMessage("msg-1", []byte("data"), "test"))
+
+	slow := &blockingBroker{Broker: inner, blocked: make(chan struct{}), release: make(chan struct{})}
+	messageBroker = slow
+
+	original := brokerStatsCache
+	brokerStatsCache = newStatsCache(collectStats, 50*time.Millisecond)
+	defer func() { brokerStatsCache = original }()
+
+	// 第一次讀取成功，建立快取
+	rr := httptest.NewRecorder()
+	handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &health)
+	if _, ok := health["stats_stale"]; ok {
+		t.Errorf("Expected fresh stats on first read, got %v", health)
+	}
+
+	// 模擬長時間持有鎖：讀取最新統計會阻塞，端點應返回快取並標記過期
+	close(slow.blocked)
+	defer func() {
+		// 解除阻塞並等待進行中的計算完成，避免它在之後的測試中讀取全域狀態
+		close(slow.release)
+		for {
+			if _, stale := brokerStatsCache.get(); !stale {
+				return
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		rr := httptest.NewRecorder()
+		handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var health map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &health)
+		if health["stats_stale"] != true {
+			t.Errorf("Expected stats_stale flag, got %v", health)
+		}
+		if health["queues"] != float64(1) {
+			t.Errorf("Expected cached queue count 1, got %v", health["queues"])
+		}
+
+		rr = httptest.NewRecorder()
+		handleMetrics(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body := rr.Body.String()
+		if !strings.Contains(body, "stats_stale 1") {
+			t.Error("Expected stats_stale 1 in metrics")
+		}
+		if !strings.Contains(body, `queue_messages{broker="default",queue="test"} 1`) {
+			t.Error("Expected cached queue depth in metrics")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected handlers to return cached stats instead of hanging")
+	}
+}
+
+func TestStatsCacheRefreshesAfterContention(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int64
+	cache := newStatsCache(func() *statsSnapshot {
+		n := calls.Add(1)
+		if n == 2 {
+			<-release
+		}
+		return &statsSnapshot{Brokers: map[string]brokerStats{}, TakenAt: time.Unix(n, 0)}
+	}, 20*time.Millisecond)
+
+	if s, stale := cache.get(); stale || s.TakenAt.Unix() != 1 {
+		t.Fatalf("Expected fresh first snapshot, got %v stale=%v", s.TakenAt, stale)
+	}
+	if s, stale := cache.get(); !stale || s.TakenAt.Unix() != 1 {
+		t.Errorf("Expected stale first snapshot while blocked, got %v stale=%v", s.TakenAt, stale)
+	}
+	// 阻塞中的計算尚未完成，不會重複啟動新的計算
+	if _, stale := cache.get(); !stale || calls.Load() != 2 {
+		t.Errorf("Expected a single in-flight refresh, got %d calls", calls.Load())
+	}
+
+	// 阻塞的計算完成後更新快取，之後的讀取恢復為最新統計
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s, stale := cache.get()
+		if !stale && s.TakenAt.Unix() == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected fresh snapshot after contention ends, got %v stale=%v", s.TakenAt, stale)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// depositsTopicName 是廣播偵測到的存款的主題，/stream/deposits 的訂閱者會即時收到
+const depositsTopicName = "deposits"
+
+// depositStreamHandler 返回 GET /stream/deposits 的處理函式
+// 以 Server-Sent Events 即時推送偵測到的存款，每個事件的 data 為與交易隊列相同的事件 envelope。
+// 串流在客戶端斷線、Broker 關閉或 done 關閉 (服務器開始關閉) 時結束，避免長連線拖住優雅關閉
+func depositStreamHandler(done <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamDeposits(w, r, done)
+	}
+}
+
+// streamDeposits 訂閱 deposits 主題並將每條消息寫成一個 SSE 事件
+func streamDeposits(w http.ResponseWriter, r *http.Request, done <-chan struct{}) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	b := brokerFor(brokerPurposeAlerts)
+	events, err := b.Subscribe(depositsTopicName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer b.Unsubscribe(depositsTopicName, events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	logrus.Debug("📡 新的存款串流連線")
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-done:
+			return
+
+		case msg, ok := <-events:
+			if !ok {
+				return // Broker 已關閉
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\ndata: %s\n\n", msg.ID, msg.Body); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
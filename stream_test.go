@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSSEEvent 讀取一個 SSE 事件，返回其 data 欄位
+func readSSEEvent(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+	var data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read SSE stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" && data != "" {
+			return data
+		}
+		if strings.HasPrefix(line, "data: ") {
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestDepositStreamDeliversDetections(t *testing.T) {
+	_, alerts := withBrokerRegistry(t)
+
+	server, err := startHTTPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("startHTTPServer failed: %v", err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr + "/stream/deposits")
+	if err != nil {
+		t.Fatalf("GET /stream/deposits failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", ct)
+	}
+
+	forwardDetections("100", "0xblock", []TransactionInfo{{Hash: "0xstreamed", To: targetAddress, Value: "1"}}, 1)
+
+	data := readSSEEvent(t, bufio.NewReader(resp.Body))
+	var txInfo TransactionInfo
+	envelope, err := unmarshalEvent([]byte(data), &txInfo)
+	if err != nil {
+		t.Fatalf("Failed to decode SSE event: %v", err)
+	}
+	if envelope.Type != eventTypeDeposit || txInfo.Hash != "0xstreamed" {
+		t.Errorf("Unexpected event %s: %+v", envelope.Type, txInfo)
+	}
+
+	// 客戶端斷線後訂閱會被清除
+	resp.Body.Close()
+	deadline := time.Now().Add(time.Second)
+	for alerts.GetMetrics().GetStats()["active_consumers"].(int32) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected subscription to be removed after the client disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDepositStreamEndsOnServerShutdown(t *testing.T) {
+	withBrokerRegistry(t)
+
+	server, err := startHTTPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("startHTTPServer failed: %v", err)
+	}
+
+	resp, err := http.Get("http://" + server.Addr + "/stream/deposits")
+	if err != nil {
+		t.Fatalf("GET /stream/deposits failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 開啟中的串流不應讓優雅關閉等到逾時
+	start := time.Now()
+	if err := shutdownService(server, 5*time.Second); err != nil {
+		t.Fatalf("shutdownService failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected open stream to end promptly on shutdown, took %v", elapsed)
+	}
+}
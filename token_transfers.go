@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// transferEventTopic 是 ERC-20 Transfer(address,address,uint256) 事件的 topic0
+var transferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// receiptFetcher 是掃描代幣轉帳所需的節點操作 (ethclient.Client 即實作了此介面)
+type receiptFetcher interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// parseTransferLogs 解析收據中的 ERC-20 Transfer 事件，返回收款地址在監聽清單中的轉帳
+// ERC-721 的 Transfer 事件簽名相同，但 tokenId 位於第 4 個 topic 且 data 為空，不會被匹配
+func parseTransferLogs(receipt *types.Receipt, targets *watchList) []TransactionInfo {
+	var transfers []TransactionInfo
+	for _, log := range receipt.Logs {
+		if len(log.Topics) != 3 || log.Topics[0] != transferEventTopic || len(log.Data) != 32 {
+			continue
+		}
+
+		to := common.BytesToAddress(log.Topics[2].Bytes())
+		if !targets.Contains(to.Hex()) {
+			continue
+		}
+		transfers = append(transfers, TransactionInfo{
+			Hash:     receipt.TxHash.Hex(),
+			To:       to.Hex(),
+			From:     common.BytesToAddress(log.Topics[1].Bytes()).Hex(),
+			Value:    new(big.Int).SetBytes(log.Data).String(),
+			Token:    log.Address.Hex(),
+			LogIndex: log.Index,
+		})
+	}
+	return transfers
+}
+
+// receiptsFor 在開啟代幣轉帳掃描且節點支援時返回收據來源，否則返回 nil
+func (w *blockWatcher) receiptsFor(client interface{}) receiptFetcher {
+	if !w.tokens {
+		return nil
+	}
+	receipts, _ := client.(receiptFetcher)
+	return receipts
+}
+
+// tokenTransfers 抓取合約呼叫的收據並返回發往監聽地址的代幣轉帳
+// 只有帶 calldata 的合約呼叫才可能產生 Transfer 事件，純 ETH 轉帳不需要抓取收據
+func tokenTransfers(ctx context.Context, receipts receiptFetcher, txs types.Transactions) ([]TransactionInfo, error) {
+	var transfers []TransactionInfo
+	for _, tx := range txs {
+		if tx.To() == nil || len(tx.Data()) == 0 {
+			continue
+		}
+		receipt, err := receipts.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch receipt %s: %w", tx.Hash().Hex(), err)
+		}
+		transfers = append(transfers, parseTransferLogs(receipt, watched)...)
+	}
+	return transfers, nil
+}
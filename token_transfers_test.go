@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 測試用的代幣合約與地址
+var (
+	testToken  = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	testSender = common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	testOther  = common.HexToAddress("0x00000000000000000000000000000000000000f2")
+)
+
+// newTransferLog 建立一筆 ERC-20 Transfer 事件
+func newTransferLog(token, from, to common.Address, amount int64, index uint) *types.Log {
+	return &types.Log{
+		Address: token,
+		Topics: []common.Hash{
+			transferEventTopic,
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data:  common.BigToHash(big.NewInt(amount)).Bytes(),
+		Index: index,
+	}
+}
+
+// newTokenTx 建立一筆呼叫代幣合約的交易
+func newTokenTx(nonce uint64) *types.Transaction {
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       &testToken,
+		Gas:      60000,
+		GasPrice: big.NewInt(1),
+		Data:     []byte{0xa9, 0x05, 0x9c, 0xbb}, // transfer(address,uint256)
+	})
+}
+
+// mockReceiptFetcher 以交易 hash 返回預設的收據
+type mockReceiptFetcher struct {
+	receipts map[common.Hash]*types.Receipt
+	fetched  int
+}
+
+func (f *mockReceiptFetcher) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	f.fetched++
+	receipt, ok := f.receipts[hash]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return receipt, nil
+}
+
+func TestParseTransferLogs(t *testing.T) {
+	target := common.HexToAddress(targetAddress)
+	receipt := &types.Receipt{
+		TxHash: common.HexToHash("0x01"),
+		Logs: []*types.Log{
+			newTransferLog(testToken, testSender, target, 2500000, 7),
+			newTransferLog(testToken, testSender, testOther, 1, 8), // 非監聽地址
+			{ // ERC-721 Transfer：tokenId 位於第 4 個 topic
+				Address: testToken,
+				Topics: []common.Hash{
+					transferEventTopic,
+					common.BytesToHash(testSender.Bytes()),
+					common.BytesToHash(target.Bytes()),
+					common.BigToHash(big.NewInt(42)),
+				},
+			},
+			{ // 其他事件
+				Address: testToken,
+				Topics:  []common.Hash{common.HexToHash("0xdead"), common.BytesToHash(target.Bytes())},
+				Data:    make([]byte, 32),
+			},
+		},
+	}
+
+	transfers := parseTransferLogs(receipt, newWatchList(targetAddress))
+	if len(transfers) != 1 {
+		t.Fatalf("Expected 1 matched transfer, got %d: %+v", len(transfers), transfers)
+	}
+
+	got := transfers[0]
+	if got.Hash != receipt.TxHash.Hex() || got.To != target.Hex() || got.From != testSender.Hex() {
+		t.Errorf("Unexpected transfer addresses: %+v", got)
+	}
+	if got.Value != "2500000" || got.Token != testToken.Hex() || got.LogIndex != 7 {
+		t.Errorf("Unexpected transfer details: %+v", got)
+	}
+}
+
+func TestBlockMessageIncludesTokenTransfers(t *testing.T) {
+	watched = newWatchList(targetAddress)
+	defer func() { watched = newWatchList(targetAddress) }()
+
+	tokenTx := newTokenTx(1)
+	ethTx := newTestTx(2, targetAddress)
+	block := types.NewBlockWithHeader(newTestHeader(300)).WithBody(types.Body{
+		Transactions: []*types.Transaction{tokenTx, ethTx},
+	})
+	receipts := &mockReceiptFetcher{receipts: map[common.Hash]*types.Receipt{
+		tokenTx.Hash(): {
+			TxHash: tokenTx.Hash(),
+			Logs:   []*types.Log{newTransferLog(testToken, testSender, common.HexToAddress(targetAddress), 99, 0)},
+		},
+	}}
+
+	w := &blockWatcher{clock: broker.RealClock{}, tokens: true}
+	msg, err := w.blockMessage(context.Background(), w.receiptsFor(receipts), block)
+	if err != nil {
+		t.Fatalf("blockMessage failed: %v", err)
+	}
+	if receipts.fetched != 1 {
+		t.Errorf("Expected only the contract call receipt to be fetched, got %d", receipts.fetched)
+	}
+
+	var blockMessage BlockMessage
+	json.Unmarshal(msg.Body, &blockMessage)
+	if len(blockMessage.Transactions) != 2 {
+		t.Fatalf("Expected ETH and token transfers, got %+v", blockMessage.Transactions)
+	}
+	token := blockMessage.Transactions[1]
+	if token.Token != testToken.Hex() || token.Value != "99" || token.Hash != tokenTx.Hash().Hex() {
+		t.Errorf("Unexpected token transfer: %+v", token)
+	}
+
+	// 收據抓取失敗時整個區塊視為失敗，之後會重試
+	receipts.receipts = nil
+	if _, err := w.blockMessage(context.Background(), receipts, block); err == nil {
+		t.Error("Expected error when a receipt cannot be fetched")
+	}
+
+	// 未開啟時不抓取收據
+	w.tokens = false
+	if w.receiptsFor(receipts) != nil {
+		t.Error("Expected no receipt fetcher when token watching is disabled")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/sirupsen/logrus"
+)
+
+// blockNumberHeader 是交易隊列消息中記錄偵測所在區塊號的標頭
+const blockNumberHeader = "block_number"
+
+// webhookNotifyTimeout 是單筆偵測發送 webhook (含重試) 的時限
+const webhookNotifyTimeout = 15 * time.Second
+
+// runTransactionConsumer 從交易隊列取出偵測，保存到 sink 並發送 webhook 通知，直到 ctx 取消或 Broker 關閉
+// n 為 nil 時不發送通知；發送失敗 (重試耗盡) 的通知會寫入 webhook 死信隊列
+func runTransactionConsumer(ctx context.Context, b broker.Broker, sink DepositSink, n alertNotifier) {
+	_, release := b.RegisterConsumer(transactionQueueName)
+	defer release()
+
+	for ctx.Err() == nil {
+		msg, err := b.PullContext(ctx, transactionQueueName)
+		if errors.Is(err, broker.ErrBrokerClosed) {
+			return
+		}
+		if err != nil {
+			// 交易隊列在第一筆偵測推送前尚未建立，等待一個輪詢週期後再試
+			if !errors.Is(err, broker.ErrQueueNotFound) && ctx.Err() == nil {
+				logrus.WithError(err).Warn("⚠️ 拉取交易消息失敗")
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(workerPollTimeout):
+			}
+			continue
+		}
+		handleTransactionMessage(ctx, sink, n, msg)
+	}
+}
+
+// handleTransactionMessage 保存一條交易隊列中的存款並發送 webhook 通知
+func handleTransactionMessage(ctx context.Context, sink DepositSink, n alertNotifier, msg *broker.Message) {
+	var txInfo TransactionInfo
+	envelope, err := unmarshalEvent(msg.Body, &txInfo)
+	if err != nil {
+		logrus.WithError(err).Warn("⚠️ 解析交易消息失敗，略過")
+		return
+	}
+	if envelope.Type != eventTypeDeposit {
+		return
+	}
+
+	// 保存失敗不影響通知，只記錄日誌
+	if err := sink.Save(ctx, txInfo); err != nil {
+		logrus.WithField("txHash", txInfo.Hash).WithError(err).Warn("⚠️ 保存存款紀錄失敗")
+	}
+	if n == nil {
+		return
+	}
+
+	payload := webhookPayload{Event: eventTypeDeposit, BlockNumber: msg.Headers[blockNumberHeader], Transaction: txInfo}
+	notifyCtx, cancel := context.WithTimeout(ctx, webhookNotifyTimeout)
+	defer cancel()
+	if err := n.Notify(notifyCtx, payload); err != nil {
+		logrus.WithError(err).Warn("⚠️ Webhook 通知發送失敗")
+		deadLetterWebhook(payload, err)
+	}
+}
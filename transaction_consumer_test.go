@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingWebhookServer 記錄收到的 webhook 內容，前 failFirst 次請求返回 500
+type recordingWebhookServer struct {
+	*httptest.Server
+	hits     atomic.Int64
+	mu       sync.Mutex
+	payloads []webhookPayload
+}
+
+func newRecordingWebhookServer(t *testing.T, failFirst int64) *recordingWebhookServer {
+	s := &recordingWebhookServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.hits.Add(1) <= failFirst {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var payload webhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		s.mu.Lock()
+		s.payloads = append(s.payloads, payload)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// received 返回已成功接收的 webhook 內容
+func (s *recordingWebhookServer) received() []webhookPayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webhookPayload(nil), s.payloads...)
+}
+
+// startWebhookConsumer 以指向 url 的分發器啟動 webhook 消費者，測試結束時停止
+func startWebhookConsumer(t *testing.T, url string, retries int) {
+	t.Helper()
+	router, err := newWebhookRouter(webhookModeMirror, []string{url}, nil, "")
+	if err != nil {
+		t.Fatalf("newWebhookRouter failed: %v", err)
+	}
+	router.retries = retries
+	router.retryBackoff = time.Millisecond
+	router.circuitFailures = 0
+
+	previous := notifier
+	notifier = router
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runTransactionConsumer(ctx, brokerFor(brokerPurposeAlerts), noopDepositSink{}, router)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		notifier = previous
+	})
+}
+
+// waitFor 等待 cond 成立，逾時則測試失敗
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookConsumerRetriesAndDeliversPayload(t *testing.T) {
+	withBrokerRegistry(t)
+	server := newRecordingWebhookServer(t, 2)
+	startWebhookConsumer(t, server.URL, 2)
+
+	tx := TransactionInfo{Hash: "0xhooked", To: targetAddress, Value: "1000"}
+	forwardDetections("123", "0xblock", []TransactionInfo{tx}, 1)
+
+	// 前兩次返回 500，第三次 (最後一次重試) 成功
+	waitFor(t, "webhook delivery", func() bool { return len(server.received()) == 1 })
+	if got := server.hits.Load(); got != 3 {
+		t.Errorf("Expected 3 webhook attempts, got %d", got)
+	}
+	payload := server.received()[0]
+	if payload.Event != eventTypeDeposit || payload.BlockNumber != "123" || payload.Transaction.Hash != "0xhooked" || payload.Transaction.Value != "1000" {
+		t.Errorf("Unexpected webhook payload: %+v", payload)
+	}
+	if dlq := brokerFor(brokerPurposeAlerts).GetDLQ(webhookQueueName); len(dlq) != 0 {
+		t.Errorf("Expected no dead-lettered webhooks, got %d", len(dlq))
+	}
+}
+
+func TestWebhookConsumerDeadLettersAfterMaxAttempts(t *testing.T) {
+	withBrokerRegistry(t)
+	server := newRecordingWebhookServer(t, 1000)
+	startWebhookConsumer(t, server.URL, 2)
+
+	forwardDetections("124", "0xblock", []TransactionInfo{{Hash: "0xfailing", To: targetAddress, Value: "1"}}, 1)
+
+	alerts := brokerFor(brokerPurposeAlerts)
+	waitFor(t, "webhook dead letter", func() bool { return len(alerts.GetDLQ(webhookQueueName)) == 1 })
+	if got := server.hits.Load(); got != 3 {
+		t.Errorf("Expected 3 attempts before dead-lettering, got %d", got)
+	}
+
+	var payload webhookPayload
+	json.Unmarshal(alerts.GetDLQ(webhookQueueName)[0].Body, &payload)
+	if payload.BlockNumber != "124" || payload.Transaction.Hash != "0xfailing" {
+		t.Errorf("Expected the failed notification in the DLQ, got %+v", payload)
+	}
+}
+
+func TestTransactionConsumerSavesToSinkWithoutWebhook(t *testing.T) {
+	withBrokerRegistry(t)
+	path := filepath.Join(t.TempDir(), "deposits.jsonl")
+	sink, err := newJSONLDepositSink(path)
+	if err != nil {
+		t.Fatalf("newJSONLDepositSink failed: %v", err)
+	}
+	defer sink.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runTransactionConsumer(ctx, brokerFor(brokerPurposeAlerts), sink, nil)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	forwardDetections("125", "0xblock", []TransactionInfo{
+		{Hash: "0xsaved1", To: targetAddress, Value: "1"},
+		{Hash: "0xsaved2", To: targetAddress, Value: "2"},
+	}, 1)
+
+	waitFor(t, "deposits saved", func() bool {
+		got, _ := readJSONLDeposits(path)
+		return len(got) == 2
+	})
+	got, _ := readJSONLDeposits(path)
+	if got[0].Hash != "0xsaved1" || got[1].Hash != "0xsaved2" {
+		t.Errorf("Expected deposits saved in order, got %+v", got)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sirupsen/logrus"
+)
+
+// watchedAddress 是一個監聽中的地址，停用時仍保留在清單中
+type watchedAddress struct {
+	Address string `json:"address"`
+	Enabled bool   `json:"enabled"`
+}
+
+// watchList 是監聽地址清單，地址以小寫為鍵
+// 停用的地址仍會被區塊掃描匹配，但偵測流程只計入 suppressed 指標、不轉發告警
+type watchList struct {
+	mu        sync.RWMutex
+	addresses map[string]*watchedAddress
+}
+
+// newWatchList 以指定地址創建監聽清單，所有地址預設啟用
+func newWatchList(addresses ...string) *watchList {
+	l := &watchList{addresses: make(map[string]*watchedAddress)}
+	for _, address := range addresses {
+		l.addresses[strings.ToLower(address)] = &watchedAddress{Address: address, Enabled: true}
+	}
+	return l
+}
+
+// newWatchListFromEnv 以 targetAddress 加上 WATCHED_ADDRESSES (逗號分隔) 創建監聽清單
+func newWatchListFromEnv() *watchList {
+	return newWatchList(append([]string{targetAddress}, splitList(os.Getenv("WATCHED_ADDRESSES"))...)...)
+}
+
+// watched 是全域的監聽地址清單
+var watched = newWatchList(targetAddress)
+
+// Contains 判斷地址是否在監聽清單中 (不論是否啟用)
+func (l *watchList) Contains(address string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, exists := l.addresses[strings.ToLower(address)]
+	return exists
+}
+
+// Enabled 判斷地址是否在監聽清單中且已啟用
+func (l *watchList) Enabled(address string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	entry, exists := l.addresses[strings.ToLower(address)]
+	return exists && entry.Enabled
+}
+
+// SetEnabled 啟用或停用地址，地址不在清單中時返回錯誤
+func (l *watchList) SetEnabled(address string, enabled bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, exists := l.addresses[strings.ToLower(address)]
+	if !exists {
+		return fmt.Errorf("address %s is not watched", address)
+	}
+	entry.Enabled = enabled
+	return nil
+}
+
+// Add 加入一個啟用的地址，返回 false 表示地址已在清單中 (狀態不變)
+func (l *watchList) Add(address string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := strings.ToLower(address)
+	if _, exists := l.addresses[key]; exists {
+		return false
+	}
+	l.addresses[key] = &watchedAddress{Address: address, Enabled: true}
+	return true
+}
+
+// Remove 從清單中移除地址，返回 false 表示地址不在清單中
+func (l *watchList) Remove(address string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := strings.ToLower(address)
+	if _, exists := l.addresses[key]; !exists {
+		return false
+	}
+	delete(l.addresses, key)
+	return true
+}
+
+// List 返回依地址排序的清單副本
+func (l *watchList) List() []watchedAddress {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	result := make([]watchedAddress, 0, len(l.addresses))
+	for _, entry := range l.addresses {
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Address) < strings.ToLower(result[j].Address)
+	})
+	return result
+}
+
+// handleWatched 處理 /watched 端點，列出所有監聽地址與啟用狀態
+func handleWatched(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	addresses := watched.List()
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"addresses": addresses,
+		"count":     len(addresses),
+	})
+}
+
+// handleWatchedToggle 處理 POST /watched/toggle?address=X&enabled=false
+// 暫時停用或重新啟用地址的告警，地址設定仍保留在清單中
+func handleWatchedToggle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "address parameter is required", http.StatusBadRequest)
+		return
+	}
+	enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+	if err != nil {
+		http.Error(w, "enabled parameter must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	if err := watched.SetEnabled(address, enabled); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"address": address,
+		"enabled": enabled,
+	}).Info("🔧 已切換監聽地址的告警狀態")
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"address": address,
+		"enabled": enabled,
+	})
+}
+
+// watchRequest 是 /watch 端點的請求內容
+type watchRequest struct {
+	Address string `json:"address"`
+}
+
+// isWatchableAddress 判斷是否為 0x 開頭的 20 bytes 十六進位地址
+func isWatchableAddress(address string) bool {
+	return strings.HasPrefix(address, "0x") && common.IsHexAddress(address)
+}
+
+// handleWatch 處理 POST /watch 與 DELETE /watch，請求內容為 {"address": "0x..."}
+// 在執行期間加入或移除監聽地址，不需要重啟服務
+func handleWatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req watchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !isWatchableAddress(req.Address) {
+		http.Error(w, "address must be a 0x-prefixed 20-byte hex string", http.StatusBadRequest)
+		return
+	}
+	address := common.HexToAddress(req.Address).Hex()
+
+	if r.Method == http.MethodDelete {
+		if !watched.Remove(address) {
+			http.Error(w, fmt.Sprintf("address %s is not watched", address), http.StatusNotFound)
+			return
+		}
+		logrus.WithField("address", address).Info("➖ 已移除監聽地址")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"address": address,
+			"removed": true,
+		})
+		return
+	}
+
+	added := watched.Add(address)
+	if added {
+		logrus.WithField("address", address).Info("➕ 已加入監聽地址")
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"address": address,
+		"added":   added,
+	})
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// toggleWatched 以正確的 API key 發送切換請求
+func toggleWatched(t *testing.T, address, enabled string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/watched/toggle?address="+address+"&enabled="+enabled, nil)
+	req.Header.Set(apiKeyHeader, "secret")
+	rr := httptest.NewRecorder()
+	requireAPIKey(handleWatchedToggle)(rr, req)
+	return rr
+}
+
+// suppressedCount 返回地址的 suppressed 計數
+func suppressedCount(address string) int64 {
+	for _, c := range detectionCounters.snapshot() {
+		if c.Address == strings.ToLower(address) {
+			return c.Suppressed
+		}
+	}
+	return 0
+}
+
+func TestWatchedAddressToggle(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+	t.Setenv("API_KEY", "secret")
+
+	watched = newWatchList(targetAddress)
+	detectionCounters = newDetectionCounter()
+	defer func() {
+		watched = newWatchList(targetAddress)
+		detectionCounters = newDetectionCounter()
+	}()
+
+	deposit := func(hash string) {
+		processBlockMessage(BlockMessage{
+			BlockNumber:  "900",
+			Transactions: []TransactionInfo{{Hash: hash, To: targetAddress, Value: "1"}},
+		}, 1)
+	}
+
+	// 停用後交易不轉發，但計入 suppressed
+	if rr := toggleWatched(t, strings.ToLower(targetAddress), "false"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	deposit("0xmuted")
+	if got := forwardedTxHashes(t); len(got) != 0 {
+		t.Errorf("Expected no forwarded transactions while disabled, got %v", got)
+	}
+	if got := suppressedCount(targetAddress); got != 1 {
+		t.Errorf("Expected 1 suppressed transaction, got %d", got)
+	}
+	if !watched.Contains(targetAddress) {
+		t.Error("Expected disabled address to remain in the watch list")
+	}
+
+	// 重新啟用後恢復告警
+	toggleWatched(t, targetAddress, "true")
+	deposit("0xalert")
+	if got := forwardedTxHashes(t); len(got) != 1 || got[0] != "0xalert" {
+		t.Errorf("Expected alert to be forwarded after re-enabling, got %v", got)
+	}
+	if got := suppressedCount(targetAddress); got != 1 {
+		t.Errorf("Expected suppressed count to stay 1, got %d", got)
+	}
+
+	rr := httptest.NewRecorder()
+	handleMetrics(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), "detections_suppressed_total{address=\""+strings.ToLower(targetAddress)+"\"} 1") {
+		t.Error("Expected suppressed metric in /metrics")
+	}
+}
+
+func TestWatchedToggleValidation(t *testing.T) {
+	t.Setenv("API_KEY", "secret")
+	watched = newWatchList(targetAddress)
+	defer func() { watched = newWatchList(targetAddress) }()
+
+	if rr := toggleWatched(t, "0x0000000000000000000000000000000000000001", "false"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown address, got %d", rr.Code)
+	}
+	if rr := toggleWatched(t, targetAddress, "maybe"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid enabled value, got %d", rr.Code)
+	}
+	if rr := toggleWatched(t, "", "false"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for missing address, got %d", rr.Code)
+	}
+
+	rr := httptest.NewRecorder()
+	handleWatched(rr, httptest.NewRequest(http.MethodGet, "/watched", nil))
+	var listing struct {
+		Addresses []watchedAddress `json:"addresses"`
+		Count     int              `json:"count"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &listing)
+	if listing.Count != 1 || !listing.Addresses[0].Enabled {
+		t.Errorf("Unexpected watch list: %+v", listing)
+	}
+}
+
+func TestWatchListFromEnv(t *testing.T) {
+	t.Setenv("WATCHED_ADDRESSES", "0xAbC, 0xdef")
+	l := newWatchListFromEnv()
+	if !l.Contains(targetAddress) || !l.Contains("0xabc") || !l.Contains("0xDEF") {
+		t.Errorf("Expected target and env addresses to be watched, got %+v", l.List())
+	}
+}
+
+// watchAddress 以正確的 API key 發送 /watch 請求
+func watchAddress(t *testing.T, method, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/watch", strings.NewReader(body))
+	req.Header.Set(apiKeyHeader, "secret")
+	rr := httptest.NewRecorder()
+	requireAPIKey(handleWatch)(rr, req)
+	return rr
+}
+
+func TestWatchAddRemove(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+	t.Setenv("API_KEY", "secret")
+
+	watched = newWatchList(targetAddress)
+	defer func() { watched = newWatchList(targetAddress) }()
+
+	const added = "0x00000000000000000000000000000000000000ab"
+	deposit := func(hash string) {
+		processBlockMessage(BlockMessage{
+			BlockNumber:  "901",
+			Transactions: []TransactionInfo{{Hash: hash, To: added, Value: "1"}},
+		}, 1)
+	}
+
+	// 未加入前不匹配
+	deposit("0xbefore")
+	if got := forwardedTxHashes(t); len(got) != 0 {
+		t.Fatalf("Expected no match before adding, got %v", got)
+	}
+
+	rr := watchAddress(t, http.MethodPost, `{"address":"`+added+`"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !watched.Enabled(added) {
+		t.Error("Expected added address to be enabled")
+	}
+	deposit("0xwatched")
+	if got := forwardedTxHashes(t); len(got) != 1 || got[0] != "0xwatched" {
+		t.Errorf("Expected added address to be matched, got %v", got)
+	}
+
+	// 重複加入不改變狀態
+	if rr := watchAddress(t, http.MethodPost, `{"address":"`+added+`"}`); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for duplicate add, got %d", rr.Code)
+	}
+
+	if rr := watchAddress(t, http.MethodDelete, `{"address":"`+added+`"}`); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	deposit("0xafter")
+	if got := forwardedTxHashes(t); len(got) != 0 {
+		t.Errorf("Expected no match after removing, got %v", got)
+	}
+	if rr := watchAddress(t, http.MethodDelete, `{"address":"`+added+`"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 removing unknown address, got %d", rr.Code)
+	}
+}
+
+func TestWatchValidation(t *testing.T) {
+	t.Setenv("API_KEY", "secret")
+
+	watched = newWatchList(targetAddress)
+	defer func() { watched = newWatchList(targetAddress) }()
+
+	for _, body := range []string{
+		`{"address":"0x1234"}`,
+		`{"address":"00000000000000000000000000000000000000ab"}`,
+		`{"address":"0xzz000000000000000000000000000000000000ab"}`,
+		`{"address":""}`,
+		`not json`,
+	} {
+		if rr := watchAddress(t, http.MethodPost, body); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, rr.Code)
+		}
+	}
+	if rr := watchAddress(t, http.MethodGet, ""); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rr.Code)
+	}
+	if len(watched.List()) != 1 {
+		t.Errorf("Expected watch list to be unchanged, got %v", watched.List())
+	}
+}
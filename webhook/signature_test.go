@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "test-secret"
+	const nonce = "0123456789abcdef"
+	body := []byte(`{"event":"deposit","block_number":"12345"}`)
+
+	// 以標準字串獨立計算的已知簽章
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("1700000000." + nonce + "." + string(body)))
+	known := hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign(secret, 1700000000, nonce, body); got != known {
+		t.Errorf("Expected signature %s, got %s", known, got)
+	}
+	if !VerifySignature(secret, 1700000000, nonce, body, known) {
+		t.Error("Expected known signature to verify")
+	}
+
+	tampered := []byte(`{"event":"deposit","block_number":"99999"}`)
+	if VerifySignature(secret, 1700000000, nonce, tampered, known) {
+		t.Error("Expected tampered body to fail verification")
+	}
+	if VerifySignature("other-secret", 1700000000, nonce, body, known) {
+		t.Error("Expected wrong secret to fail verification")
+	}
+	if VerifySignature(secret, 1700000001, nonce, body, known) {
+		t.Error("Expected changed timestamp to fail verification")
+	}
+	if VerifySignature(secret, 1700000000, nonce, body, "not-hex") {
+		t.Error("Expected malformed signature to fail verification")
+	}
+}
+
+func TestVerifyRoundTrip(t *testing.T) {
+	const secret = "test-secret"
+	body := []byte(`{"event":"deposit"}`)
+
+	header := http.Header{}
+	SetHeaders(header, secret, 1700000000, "nonce-1", body)
+	if header.Get(TimestampHeader) != "1700000000" || header.Get(NonceHeader) != "nonce-1" {
+		t.Errorf("Unexpected signature headers %v", header)
+	}
+	if err := Verify(header, secret, body); err != nil {
+		t.Errorf("Expected signed headers to verify, got %v", err)
+	}
+	if err := Verify(header, secret, append(body, ' ')); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a tampered body, got %v", err)
+	}
+
+	// 缺少簽章標頭
+	if err := Verify(http.Header{}, secret, body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for an unsigned request, got %v", err)
+	}
+}
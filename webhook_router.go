@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/sirupsen/logrus"
+)
+
+// webhookQueueName 是 webhook 全部發送失敗時寫入死信隊列所使用的隊列名稱
+const webhookQueueName = "webhooks"
+
+// webhook 多端點的分發模式
+const (
+	webhookModeMirror     = "mirror"     // 發送到所有端點
+	webhookModeRoundRobin = "roundrobin" // 輪流發送到單一端點
+	webhookModeWeighted   = "weighted"   // 依權重分配到單一端點
+)
+
+// webhook 重試與熔斷的預設值
+const (
+	defaultWebhookRetries         = 2
+	defaultWebhookRetryBackoff    = 500 * time.Millisecond
+	maxWebhookRetryBackoff        = 10 * time.Second
+	defaultWebhookCircuitFailures = 3
+	defaultWebhookCircuitCooldown = 30 * time.Second
+)
+
+// errNoWebhookEndpoint 表示所有端點都處於熔斷狀態
+var errNoWebhookEndpoint = errors.New("no available webhook endpoint")
+
+// webhookEndpoint 是單一 webhook 端點及其健康狀態
+type webhookEndpoint struct {
+	notifier *webhookNotifier
+	weight   int
+
+	// 以下欄位由 webhookRouter.mu 保護
+	currentWeight       int // 平滑加權輪詢的當前權重
+	consecutiveFailures int
+	openUntil           time.Time // 熔斷結束時間
+	successes           int64
+	failures            int64
+}
+
+// webhookEndpointStats 是單一端點的統計快照
+type webhookEndpointStats struct {
+	URL       string
+	Available bool
+	Successes int64
+	Failures  int64
+}
+
+// webhookRouter 將通知分發到多個 webhook 端點，並對持續失敗的端點熔斷
+type webhookRouter struct {
+	mode      string
+	endpoints []*webhookEndpoint
+
+	retries         int           // 每個端點的重試次數 (不含第一次)
+	retryBackoff    time.Duration // 第一次重試前的等待時間，之後每次加倍 (上限 maxWebhookRetryBackoff)
+	circuitFailures int           // 連續失敗幾次後熔斷
+	circuitCooldown time.Duration // 熔斷多久後恢復
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// newWebhookRouter 以指定模式創建多端點 webhook 分發器
+// weights 只在 weighted 模式下使用，長度需與 urls 相同
+func newWebhookRouter(mode string, urls []string, weights []int, secret string) (*webhookRouter, error) {
+	switch mode {
+	case webhookModeMirror, webhookModeRoundRobin, webhookModeWeighted:
+	default:
+		return nil, fmt.Errorf("unknown webhook mode %q", mode)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("at least one webhook url is required")
+	}
+	if weights != nil && len(weights) != len(urls) {
+		return nil, fmt.Errorf("got %d webhook weights for %d urls", len(weights), len(urls))
+	}
+
+	r := &webhookRouter{
+		mode:            mode,
+		retries:         defaultWebhookRetries,
+		retryBackoff:    defaultWebhookRetryBackoff,
+		circuitFailures: defaultWebhookCircuitFailures,
+		circuitCooldown: defaultWebhookCircuitCooldown,
+		now:             time.Now,
+	}
+	for i, url := range urls {
+		weight := 1
+		if weights != nil {
+			weight = weights[i]
+		}
+		if weight < 1 {
+			return nil, fmt.Errorf("invalid weight %d for webhook %s", weight, url)
+		}
+		r.endpoints = append(r.endpoints, &webhookEndpoint{
+			notifier: newWebhookNotifier(url, secret),
+			weight:   weight,
+		})
+	}
+	return r, nil
+}
+
+// newWebhookRouterFromEnv 從環境變數建立 webhook 分發器，未設定任何 URL 時返回 nil
+//
+// WEBHOOK_URLS 為逗號分隔的多個端點 (未設定時退回單一的 WEBHOOK_URL)，
+// WEBHOOK_MODE 為 mirror (預設) / roundrobin / weighted，
+// WEBHOOK_WEIGHTS 為與 WEBHOOK_URLS 對應的逗號分隔權重。
+func newWebhookRouterFromEnv() (*webhookRouter, error) {
+	urls := splitList(os.Getenv("WEBHOOK_URLS"))
+	if len(urls) == 0 {
+		if url := os.Getenv("WEBHOOK_URL"); url != "" {
+			urls = []string{url}
+		}
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	mode := strings.ToLower(os.Getenv("WEBHOOK_MODE"))
+	if mode == "" {
+		mode = webhookModeMirror
+	}
+
+	var weights []int
+	if raw := splitList(os.Getenv("WEBHOOK_WEIGHTS")); len(raw) > 0 {
+		for _, w := range raw {
+			weight, err := strconv.Atoi(w)
+			if err != nil {
+				return nil, fmt.Errorf("invalid webhook weight %q", w)
+			}
+			weights = append(weights, weight)
+		}
+	}
+
+	r, err := newWebhookRouter(mode, urls, weights, os.Getenv("WEBHOOK_SECRET"))
+	if err != nil {
+		return nil, err
+	}
+	r.retries = envInt("WEBHOOK_RETRIES", defaultWebhookRetries)
+	r.circuitFailures = envInt("WEBHOOK_CIRCUIT_FAILURES", defaultWebhookCircuitFailures)
+	r.circuitCooldown = envDuration("WEBHOOK_CIRCUIT_COOLDOWN", defaultWebhookCircuitCooldown)
+	return r, nil
+}
+
+// splitList 解析逗號分隔的清單，忽略空白項目
+func splitList(s string) []string {
+	var result []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// Notify 依分發模式發送通知
+// mirror 模式下只有所有端點都失敗才返回錯誤；其他模式下選中的端點重試耗盡即返回錯誤
+func (r *webhookRouter) Notify(ctx context.Context, payload interface{}) error {
+	if r.mode == webhookModeMirror {
+		return r.notifyAll(ctx, payload)
+	}
+
+	endpoint := r.pick()
+	if endpoint == nil {
+		return errNoWebhookEndpoint
+	}
+	return r.send(ctx, endpoint, payload)
+}
+
+// notifyAll 並行發送到所有可用端點，任一成功即視為成功
+func (r *webhookRouter) notifyAll(ctx context.Context, payload interface{}) error {
+	available := r.available()
+	if len(available) == 0 {
+		return errNoWebhookEndpoint
+	}
+
+	errs := make([]error, len(available))
+	var wg sync.WaitGroup
+	for i, endpoint := range available {
+		wg.Add(1)
+		go func(i int, endpoint *webhookEndpoint) {
+			defer wg.Done()
+			errs[i] = r.send(ctx, endpoint, payload)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("all %d webhook endpoints failed: %w", len(available), errors.Join(errs...))
+}
+
+// send 發送到單一端點並在失敗時重試，同時更新端點的熔斷狀態
+func (r *webhookRouter) send(ctx context.Context, endpoint *webhookEndpoint, payload interface{}) error {
+	var err error
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		if delay := r.retryDelay(attempt); delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		err = endpoint.notifier.Notify(ctx, payload)
+		r.record(endpoint, err)
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook %s failed after %d attempts: %w", endpoint.notifier.url, r.retries+1, err)
+}
+
+// retryDelay 返回第 attempt 次發送 (從 0 開始) 前的等待時間，第一次發送不等待
+func (r *webhookRouter) retryDelay(attempt int) time.Duration {
+	if attempt == 0 || r.retryBackoff <= 0 {
+		return 0
+	}
+	delay := maxWebhookRetryBackoff
+	if attempt <= 32 && r.retryBackoff<<(attempt-1) < maxWebhookRetryBackoff {
+		delay = r.retryBackoff << (attempt - 1)
+	}
+	return delay
+}
+
+// record 記錄一次發送結果，連續失敗達到門檻時熔斷端點
+func (r *webhookRouter) record(endpoint *webhookEndpoint, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil {
+		endpoint.successes++
+		endpoint.consecutiveFailures = 0
+		return
+	}
+
+	endpoint.failures++
+	endpoint.consecutiveFailures++
+	if r.circuitFailures > 0 && endpoint.consecutiveFailures >= r.circuitFailures {
+		endpoint.openUntil = r.now().Add(r.circuitCooldown)
+	}
+}
+
+// available 返回目前未熔斷的端點
+func (r *webhookRouter) available() []*webhookEndpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var result []*webhookEndpoint
+	for _, endpoint := range r.endpoints {
+		if !now.Before(endpoint.openUntil) {
+			result = append(result, endpoint)
+		}
+	}
+	return result
+}
+
+// pick 以平滑加權輪詢選出一個未熔斷的端點，roundrobin 模式下所有權重視為 1
+func (r *webhookRouter) pick() *webhookEndpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	var best *webhookEndpoint
+	total := 0
+	for _, endpoint := range r.endpoints {
+		if now.Before(endpoint.openUntil) {
+			continue // 熔斷中
+		}
+		weight := 1
+		if r.mode == webhookModeWeighted {
+			weight = endpoint.weight
+		}
+		endpoint.currentWeight += weight
+		total += weight
+		if best == nil || endpoint.currentWeight > best.currentWeight {
+			best = endpoint
+		}
+	}
+	if best != nil {
+		best.currentWeight -= total
+	}
+	return best
+}
+
+// stats 返回各端點的統計快照
+func (r *webhookRouter) stats() []webhookEndpointStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	result := make([]webhookEndpointStats, 0, len(r.endpoints))
+	for _, endpoint := range r.endpoints {
+		result = append(result, webhookEndpointStats{
+			URL:       endpoint.notifier.url,
+			Available: !now.Before(endpoint.openUntil),
+			Successes: endpoint.successes,
+			Failures:  endpoint.failures,
+		})
+	}
+	return result
+}
+
+// deadLetterWebhook 將發送失敗的通知寫入死信隊列，之後可透過 /dlq 查看或重新處理
+func deadLetterWebhook(payload webhookPayload, cause error) {
+	body, _ := json.Marshal(payload)
+	msg := broker.NewMessage(generateMessageID(), body, webhookQueueName)
+	msg.Headers["error"] = cause.Error()
+
+	// 發送時已做過的重試也計入全域重試預算，之後從 DLQ 重新處理時一併受限
+	alerts := brokerFor(brokerPurposeAlerts)
+	if notifier != nil {
+		for i := 0; i < notifier.retries; i++ {
+			alerts.ChargeRetry(&msg, "webhook", cause)
+		}
+	}
+
+	if err := alerts.MoveToDLQ(webhookQueueName, msg); err != nil {
+		logrus.WithError(err).Error("❌ 寫入 webhook 死信隊列失敗")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// countingServer 是記錄請求數的測試 webhook 端點，healthy 為 false 時返回 500
+type countingServer struct {
+	*httptest.Server
+	hits    atomic.Int64
+	healthy atomic.Bool
+}
+
+func newCountingServer(t *testing.T) *countingServer {
+	s := &countingServer{}
+	s.healthy.Store(true)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if !s.healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestRouter(t *testing.T, mode string, weights []int, servers ...*countingServer) *webhookRouter {
+	var urls []string
+	for _, s := range servers {
+		urls = append(urls, s.URL)
+	}
+	r, err := newWebhookRouter(mode, urls, weights, "")
+	if err != nil {
+		t.Fatalf("newWebhookRouter failed: %v", err)
+	}
+	r.retryBackoff = 0
+	return r
+}
+
+func TestWebhookRouterMirror(t *testing.T) {
+	a, b := newCountingServer(t), newCountingServer(t)
+	r := newTestRouter(t, webhookModeMirror, nil, a, b)
+
+	for i := 0; i < 3; i++ {
+		if err := r.Notify(context.Background(), webhookPayload{Event: "deposit"}); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+	if a.hits.Load() != 3 || b.hits.Load() != 3 {
+		t.Errorf("Expected every endpoint to receive 3 requests, got %d and %d", a.hits.Load(), b.hits.Load())
+	}
+
+	// 只要有一個端點成功就不算失敗
+	a.healthy.Store(false)
+	if err := r.Notify(context.Background(), webhookPayload{Event: "deposit"}); err != nil {
+		t.Errorf("Expected mirror to succeed while one endpoint is healthy, got %v", err)
+	}
+
+	// 全部失敗才返回錯誤
+	b.healthy.Store(false)
+	if err := r.Notify(context.Background(), webhookPayload{Event: "deposit"}); err == nil {
+		t.Error("Expected error when all mirror endpoints fail")
+	}
+}
+
+func TestWebhookRouterRoundRobin(t *testing.T) {
+	a, b, c := newCountingServer(t), newCountingServer(t), newCountingServer(t)
+	r := newTestRouter(t, webhookModeRoundRobin, nil, a, b, c)
+
+	for i := 0; i < 9; i++ {
+		if err := r.Notify(context.Background(), webhookPayload{Event: "deposit"}); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+	for i, s := range []*countingServer{a, b, c} {
+		if s.hits.Load() != 3 {
+			t.Errorf("Expected endpoint %d to receive 3 requests, got %d", i, s.hits.Load())
+		}
+	}
+}
+
+func TestWebhookRouterWeighted(t *testing.T) {
+	a, b := newCountingServer(t), newCountingServer(t)
+	r := newTestRouter(t, webhookModeWeighted, []int{3, 1}, a, b)
+
+	for i := 0; i < 40; i++ {
+		if err := r.Notify(context.Background(), webhookPayload{Event: "deposit"}); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+	if a.hits.Load() != 30 || b.hits.Load() != 10 {
+		t.Errorf("Expected a 3:1 split (30/10), got %d/%d", a.hits.Load(), b.hits.Load())
+	}
+}
+
+func TestWebhookRouterCircuitBreakerFailover(t *testing.T) {
+	a, b := newCountingServer(t), newCountingServer(t)
+	r := newTestRouter(t, webhookModeRoundRobin, nil, a, b)
+	r.retries = 1
+	r.circuitFailures = 2
+	r.circuitCooldown = time.Minute
+
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	// a 持續失敗：第一次選到 a 時重試耗盡並熔斷
+	a.healthy.Store(false)
+	if err := r.Notify(context.Background(), webhookPayload{Event: "deposit"}); err == nil {
+		t.Fatal("Expected error when the selected endpoint exhausts retries")
+	}
+	if a.hits.Load() != 2 {
+		t.Errorf("Expected 2 attempts against failing endpoint, got %d", a.hits.Load())
+	}
+
+	// 熔斷期間所有流量轉到 b
+	for i := 0; i < 4; i++ {
+		if err := r.Notify(context.Background(), webhookPayload{Event: "deposit"}); err != nil {
+			t.Fatalf("Expected failover to healthy endpoint, got %v", err)
+		}
+	}
+	if a.hits.Load() != 2 || b.hits.Load() != 4 {
+		t.Errorf("Expected circuit-broken endpoint to be skipped, got a=%d b=%d", a.hits.Load(), b.hits.Load())
+	}
+	stats := r.stats()
+	if stats[0].Available || !stats[1].Available || stats[0].Failures != 2 || stats[1].Successes != 4 {
+		t.Errorf("Unexpected endpoint stats: %+v", stats)
+	}
+
+	// 冷卻後恢復
+	a.healthy.Store(true)
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 2; i++ {
+		r.Notify(context.Background(), webhookPayload{Event: "deposit"})
+	}
+	if a.hits.Load() != 3 {
+		t.Errorf("Expected endpoint to be restored after cooldown, got %d hits", a.hits.Load())
+	}
+}
+
+func TestDeadLetterWebhook(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	deadLetterWebhook(webhookPayload{Event: "deposit", BlockNumber: "1"}, errNoWebhookEndpoint)
+
+	dlq := messageBroker.GetDLQ(webhookQueueName)
+	if len(dlq) != 1 {
+		t.Fatalf("Expected 1 dead-lettered webhook, got %d", len(dlq))
+	}
+	if dlq[0].Headers["error"] != errNoWebhookEndpoint.Error() {
+		t.Errorf("Expected failure reason in headers, got %v", dlq[0].Headers)
+	}
+}
+
+func TestWebhookRouterRetryDelayBacksOffExponentially(t *testing.T) {
+	r := &webhookRouter{retryBackoff: 500 * time.Millisecond}
+
+	want := []time.Duration{0, 500 * time.Millisecond, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, maxWebhookRetryBackoff, maxWebhookRetryBackoff}
+	for attempt, expected := range want {
+		if got := r.retryDelay(attempt); got != expected {
+			t.Errorf("Expected delay %v before attempt %d, got %v", expected, attempt, got)
+		}
+	}
+	if got := r.retryDelay(100); got != maxWebhookRetryBackoff {
+		t.Errorf("Expected large attempts to be capped, got %v", got)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/sirupsen/logrus"
+)
+
+// Worker 輪詢的時間參數
+const (
+	workerPollTimeout = 1 * time.Second        // 每次 PullBatch 等待第一條消息的基礎時間
+	workerPollJitter  = 250 * time.Millisecond // 每次輪詢額外加上的隨機抖動上限
+	workerStartSpread = 1 * time.Second        // 各 worker 啟動時間的分散區間
+	workerBatchSize   = 16                     // 每次最多拉取的區塊消息數
+)
+
+// workerStartDelay 計算第 workerID 個 worker (從 1 開始) 的啟動延遲
+// 將 numWorkers 個 worker 平均分散在 spread 區間內，避免同時喚醒
+func workerStartDelay(workerID, numWorkers int, spread time.Duration) time.Duration {
+	if numWorkers <= 0 || workerID <= 1 {
+		return 0
+	}
+	return spread * time.Duration(workerID-1) / time.Duration(numWorkers)
+}
+
+// jitteredTimeout 在 base 之上加入 [0, jitter) 的隨機抖動
+// rng 為 nil 時使用全域亂數來源
+func jitteredTimeout(base, jitter time.Duration, rng *rand.Rand) time.Duration {
+	if jitter <= 0 {
+		return base
+	}
+	if rng == nil {
+		return base + rand.N(jitter)
+	}
+	return base + time.Duration(rng.Int64N(int64(jitter)))
+}
+
+// workerCountFromEnv 從 WORKER_COUNT 讀取區塊 worker 數，未設定或不是正數時使用 CPU 核心數
+func workerCountFromEnv() int {
+	n := envInt("WORKER_COUNT", runtime.NumCPU())
+	if n <= 0 {
+		logrus.WithField("workers", n).Warn("⚠️ WORKER_COUNT 必須為正數，改用 CPU 核心數")
+		return runtime.NumCPU()
+	}
+	return n
+}
+
+// startWorkerPool 啟動 numWorkers 個 worker 從區塊隊列消費消息
+// 返回的 stop 停止所有 worker 並等待它們結束 (正在處理的區塊會先完成)；ctx 取消時 worker 同樣停止
+func startWorkerPool(ctx context.Context, numWorkers int) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for i := 1; i <= numWorkers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+
+			// 錯開各 worker 的啟動時間，並在每次輪詢加入抖動，避免空隊列時同步喚醒
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(workerStartDelay(workerID, numWorkers, workerStartSpread)):
+			}
+
+			// 登記為區塊隊列的消費者，讓隊列統計反映活躍的 worker 數
+			_, release := brokerFor(brokerPurposeBlocks).RegisterConsumer(blockQueueName)
+			defer release()
+
+			for ctx.Err() == nil {
+				// 一次拉取一批區塊消息，減少逐條輪詢的開銷
+				// 以 ctx 等待，stop 時不必等到輪詢逾時
+				batch, err := brokerFor(brokerPurposeBlocks).PullBatchContext(ctx, blockQueueName, workerBatchSize, jitteredTimeout(workerPollTimeout, workerPollJitter, nil))
+				if errors.Is(err, broker.ErrBrokerClosed) || ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					// 區塊隊列在第一個區塊推送前尚未建立，其餘錯誤記錄後重試；兩者都等待一個輪詢週期，避免空轉
+					if !errors.Is(err, broker.ErrQueueNotFound) && !errors.Is(err, broker.ErrQueueEmpty) {
+						logrus.WithError(err).Warn("⚠️ 拉取區塊消息失敗")
+					}
+					select {
+					case <-ctx.Done():
+					case <-time.After(workerPollTimeout):
+					}
+					continue
+				}
+				for _, blockMsg := range batch {
+					handleBlockDelivery(blockMsg, workerID)
+				}
+			}
+		}(i)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// handleBlockDelivery 解析並處理一條區塊消息，處理完後向 Broker 確認
+func handleBlockDelivery(blockMsg *broker.Message, workerID int) {
+	var blockMessage BlockMessage
+	if err := decodeMessage(*blockMsg, &blockMessage); err != nil {
+		logrus.WithError(err).Warn("⚠️ 解析區塊消息失敗")
+		// 格式錯誤的消息重試也不會成功，直接移入死信隊列
+		if acksEnabled {
+			brokerFor(brokerPurposeBlocks).Nack(blockQueueName, blockMsg.DeliveryTag, false)
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"workerID":    workerID,
+		"blockNumber": blockMessage.BlockNumber,
+		"txCount":     blockMessage.TxCount,
+		"replay":      blockMsg.Headers[replayHeader] == "true",
+	}).Debug("🛠️ 工人開始處理區塊")
+
+	processBlockMessage(blockMessage, workerID)
+
+	// 確認已處理：at-least-once 模式下 Ack 會同時記錄 effectively-once 的已處理 ID，
+	// 重新推送的同一區塊會被丟棄
+	if acksEnabled {
+		if err := brokerFor(brokerPurposeBlocks).Ack(blockQueueName, blockMsg.DeliveryTag); err != nil {
+			logrus.WithError(err).Warn("⚠️ 確認區塊消息失敗")
+		}
+	} else if effectivelyOnceEnabled {
+		if err := brokerFor(brokerPurposeBlocks).MarkProcessed(blockQueueName, *blockMsg); err != nil {
+			logrus.WithError(err).Warn("⚠️ 確認區塊消息失敗")
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+)
+
+// maxWakeupsPerBucket 模擬 numWorkers 個 worker 在空隊列上輪詢 duration 時間，
+// 返回同一個 bucket 時間窗內最多有多少個 worker 同時喚醒
+func maxWakeupsPerBucket(numWorkers int, duration, bucket, spread, jitter time.Duration, rng *rand.Rand) int {
+	counts := make(map[int64]int)
+	for id := 1; id <= numWorkers; id++ {
+		at := workerStartDelay(id, numWorkers, spread)
+		for at < duration {
+			at += jitteredTimeout(workerPollTimeout, jitter, rng)
+			counts[int64(at/bucket)]++
+		}
+	}
+
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+	return max
+}
+
+func TestWorkerStartDelay(t *testing.T) {
+	if d := workerStartDelay(1, 4, time.Second); d != 0 {
+		t.Errorf("Expected first worker to start immediately, got %v", d)
+	}
+	if d := workerStartDelay(3, 4, time.Second); d != 500*time.Millisecond {
+		t.Errorf("Expected 500ms delay for worker 3 of 4, got %v", d)
+	}
+	if d := workerStartDelay(2, 0, time.Second); d != 0 {
+		t.Errorf("Expected no delay for an empty pool, got %v", d)
+	}
+}
+
+func TestJitteredTimeoutBounds(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 1000; i++ {
+		d := jitteredTimeout(time.Second, 100*time.Millisecond, rng)
+		if d < time.Second || d >= time.Second+100*time.Millisecond {
+			t.Fatalf("Jittered timeout %v out of range", d)
+		}
+	}
+
+	if d := jitteredTimeout(time.Second, 0, rng); d != time.Second {
+		t.Errorf("Expected no jitter when jitter is 0, got %v", d)
+	}
+}
+
+func TestStaggeredWorkersReduceSynchronizedWakeups(t *testing.T) {
+	const numWorkers = 8
+	const duration = 60 * time.Second
+	const bucket = 10 * time.Millisecond
+
+	// 沒有錯開與抖動時，所有 worker 每秒同時喚醒
+	lockstep := maxWakeupsPerBucket(numWorkers, duration, bucket, 0, 0, nil)
+	if lockstep != numWorkers {
+		t.Fatalf("Expected all %d workers to wake together without jitter, got %d", numWorkers, lockstep)
+	}
+
+	rng := rand.New(rand.NewPCG(42, 7))
+	spread := maxWakeupsPerBucket(numWorkers, duration, bucket, workerStartSpread, workerPollJitter, rng)
+	if spread >= lockstep/2 {
+		t.Errorf("Expected staggered workers to wake far less synchronously, got %d concurrent wakeups (lockstep %d)", spread, lockstep)
+	}
+	t.Logf("Max concurrent wakeups per %v: lockstep=%d, staggered=%d", bucket, lockstep, spread)
+}
+
+func TestHandleBlockDeliveryBatch(t *testing.T) {
+	messageBroker = broker.NewSimpleBroker()
+	defer messageBroker.Close()
+
+	for i, hash := range []string{"0xa1", "0xa2", "0xa3"} {
+		body, _ := json.Marshal(BlockMessage{
+			BlockNumber:  strconv.Itoa(700 + i),
+			Transactions: []TransactionInfo{{Hash: hash, To: targetAddress, Value: "1"}},
+		})
+		messageBroker.Push(blockQueueName, broker.NewMessage(hash, body, blockQueueName))
+	}
+	messageBroker.Push(blockQueueName, broker.NewMessage("bad", []byte("not json"), blockQueueName))
+
+	batch, err := messageBroker.PullBatch(blockQueueName, workerBatchSize, 0)
+	if err != nil || len(batch) != 4 {
+		t.Fatalf("Expected batch of 4, got %d (err=%v)", len(batch), err)
+	}
+	for _, msg := range batch {
+		handleBlockDelivery(msg, 1)
+	}
+
+	if got := forwardedTxHashes(t); len(got) != 3 {
+		t.Errorf("Expected 3 forwarded transactions, got %v", got)
+	}
+}
+
+func TestStartWorkerPoolLaunchesConfiguredWorkers(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+	t.Setenv("WORKER_COUNT", "3")
+
+	n := workerCountFromEnv()
+	if n != 3 {
+		t.Fatalf("Expected 3 workers from WORKER_COUNT, got %d", n)
+	}
+	stop := startWorkerPool(context.Background(), n)
+
+	// worker 在啟動分散區間內陸續登記為區塊隊列的消費者
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		stats, _ := blocks.GetQueueStats(blockQueueName)
+		if stats != nil && stats.ConsumerCount == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 3 registered workers, got %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// stop 返回時所有 worker 都已結束並釋放登記
+	stop()
+	if stats, _ := blocks.GetQueueStats(blockQueueName); stats.ConsumerCount != 0 {
+		t.Errorf("Expected no registered workers after stop, got %d", stats.ConsumerCount)
+	}
+}
+
+func TestStartWorkerPoolStopsDuringPoll(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+	blocks.DeclareQueue(blockQueueName, 10)
+
+	stop := startWorkerPool(context.Background(), 1)
+
+	// 等 worker 登記後再稍等，讓它進入空隊列的輪詢等待
+	deadline := time.Now().Add(time.Second)
+	for {
+		if stats, _ := blocks.GetQueueStats(blockQueueName); stats.ConsumerCount == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the worker to register")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// stop 應立即中斷輪詢，而不是等滿 workerPollTimeout
+	start := time.Now()
+	stop()
+	if elapsed := time.Since(start); elapsed > workerPollTimeout/4 {
+		t.Errorf("Expected stop to return well under the poll timeout, took %v", elapsed)
+	}
+}
+
+func TestWorkerCountFromEnvRejectsNonPositive(t *testing.T) {
+	for _, value := range []string{"0", "-2", "abc", ""} {
+		t.Setenv("WORKER_COUNT", value)
+		if got := workerCountFromEnv(); got != runtime.NumCPU() {
+			t.Errorf("WORKER_COUNT=%q: expected fallback to %d workers, got %d", value, runtime.NumCPU(), got)
+		}
+	}
+}
+
+func TestWatchSessionStopsWorkersOnReconnect(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+	baseline := runtime.NumGoroutine()
+
+	// 模擬多次連線：每次訂閱在 worker 都啟動後中斷，watchSession 返回時 worker 應已全部結束
+	for session := 1; session <= 3; session++ {
+		err := watchSession(context.Background(), 4, func(ctx context.Context) error {
+			deadline := time.Now().Add(3 * time.Second)
+			for {
+				if stats, _ := blocks.GetQueueStats(blockQueueName); stats != nil && stats.ConsumerCount == 4 {
+					return errors.New("subscription dropped")
+				}
+				if time.Now().After(deadline) {
+					return errors.New("workers did not start")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+		if err == nil || err.Error() != "subscription dropped" {
+			t.Fatalf("Session %d: expected subscription error, got %v", session, err)
+		}
+
+		// 允許執行時期回收剛結束的 goroutine
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := runtime.NumGoroutine(); got > baseline {
+			t.Errorf("Session %d: expected goroutines to return to baseline %d, got %d", session, baseline, got)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// blocksTopicName 是廣播新區塊摘要的主題，/ws/blocks 的連線會即時收到
+const blocksTopicName = "blocks"
+
+// blockSocketWriteTimeout 是寫入單條 WebSocket 消息的時限，超過時視為客戶端已失去回應並斷開
+const blockSocketWriteTimeout = 5 * time.Second
+
+// blockSocketUpgrader 將 HTTP 連線升級為 WebSocket，使用預設的同源檢查
+var blockSocketUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// blockSocketHandler 返回 GET /ws/blocks 的處理函式
+// 升級為 WebSocket 後轉發 blocks 主題上的區塊摘要 (BlockMessage JSON)，每條區塊一個文字訊框。
+// 慢速的客戶端不會阻塞 Broker：訂閱緩衝已滿時 Publish 直接丟棄該客戶端的消息，
+// 單條訊息寫入超過 blockSocketWriteTimeout 時斷開連線。done 關閉 (服務器開始關閉) 時也會斷開
+func blockSocketHandler(done <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamBlocks(w, r, done)
+	}
+}
+
+// streamBlocks 訂閱 blocks 主題並將每條消息寫入 WebSocket 連線
+func streamBlocks(w http.ResponseWriter, r *http.Request, done <-chan struct{}) {
+	b := brokerFor(brokerPurposeBlocks)
+	if !b.IsHealthy() {
+		http.Error(w, "broker is unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := blockSocketUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade 已回覆錯誤
+	}
+	defer conn.Close()
+
+	blocks, err := b.Subscribe(blocksTopicName)
+	if err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()))
+		return
+	}
+	defer b.Unsubscribe(blocksTopicName, blocks)
+
+	// 客戶端不會送資料過來，但仍需讀取才能處理控制訊框並得知連線已關閉
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	logrus.Debug("🔌 新的區塊 WebSocket 連線")
+	for {
+		select {
+		case <-closed:
+			return
+
+		case <-done:
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(time.Second))
+			return
+
+		case msg, ok := <-blocks:
+			if !ok {
+				return // Broker 已關閉
+			}
+			frame, err := blockFrame(msg)
+			if err != nil {
+				logrus.WithError(err).Debug("⚠️ 轉換區塊摘要失敗，略過")
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(blockSocketWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				logrus.WithError(err).Debug("⚠️ 區塊 WebSocket 寫入失敗，斷開連線")
+				return
+			}
+		}
+	}
+}
+
+// blockFrame 返回區塊摘要的 JSON 文字訊框，區塊消息使用其他編碼時先轉換為 JSON
+func blockFrame(msg broker.Message) ([]byte, error) {
+	if codec, err := codecFor(msg); err == nil && codec.Name() == codecJSON {
+		return msg.Body, nil
+	}
+	var blockMessage BlockMessage
+	if err := decodeMessage(msg, &blockMessage); err != nil {
+		return nil, err
+	}
+	return json.Marshal(blockMessage)
+}
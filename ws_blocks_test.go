@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/gorilla/websocket"
+)
+
+func TestBlockSocketReceivesBlockSummary(t *testing.T) {
+	blocks, _ := withBrokerRegistry(t)
+
+	server, err := startHTTPServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("startHTTPServer failed: %v", err)
+	}
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+server.Addr+"/ws/blocks", nil)
+	if err != nil {
+		t.Fatalf("Dial /ws/blocks failed: %v", err)
+	}
+	defer conn.Close()
+
+	// 等待訂閱建立後再廣播
+	deadline := time.Now().Add(time.Second)
+	for blocks.GetMetrics().GetStats()["active_consumers"].(int32) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the WebSocket connection to subscribe to the blocks topic")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	h := newTestHeader(42)
+	w := &blockWatcher{clock: broker.RealClock{}, grace: time.Second, fetchTimeout: time.Second}
+	w.handle(context.Background(), newMockBlockFetcher(h), h)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read block summary: %v", err)
+	}
+	var summary BlockMessage
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("Failed to decode block summary: %v", err)
+	}
+	if summary.BlockNumber != "42" || summary.BlockHash != h.Hash().Hex() {
+		t.Errorf("Unexpected block summary: %+v", summary)
+	}
+
+	// 客戶端斷線後訂閱會被清除
+	conn.Close()
+	deadline = time.Now().Add(time.Second)
+	for blocks.GetMetrics().GetStats()["active_consumers"].(int32) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected subscription to be removed after the client disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
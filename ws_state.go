@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// wsState 記錄上游 WebSocket 訂閱的連線狀態，與 Broker 無關，供 /ready 與 /metrics 使用
+type wsState struct {
+	connects   atomic.Int64 // 訂閱成功的次數
+	reconnects atomic.Int64 // 第一次之後的訂閱成功次數
+	lastBlock  atomic.Int64 // 最後一次收到新區塊的時間 (UnixNano)，0 表示尚未收到
+}
+
+// upstream 是監聽器目前的連線狀態
+var upstream wsState
+
+// connected 在訂閱成功時呼叫，除第一次外都計為一次重新連線
+func (s *wsState) connected() {
+	if s.connects.Add(1) > 1 {
+		s.reconnects.Add(1)
+	}
+	upstreamSubscribed.Store(true)
+}
+
+// disconnected 在訂閱中斷或連線結束時呼叫
+func (s *wsState) disconnected() {
+	upstreamSubscribed.Store(false)
+}
+
+// blockReceived 記錄收到新區塊的時間
+func (s *wsState) blockReceived(at time.Time) {
+	s.lastBlock.Store(at.UnixNano())
+}
+
+// lastBlockTimestamp 返回最後一次收到新區塊的 Unix 時間 (秒)，尚未收到時為 0
+func (s *wsState) lastBlockTimestamp() float64 {
+	nanos := s.lastBlock.Load()
+	if nanos == 0 {
+		return 0
+	}
+	return float64(nanos) / float64(time.Second)
+}
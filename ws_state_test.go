@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/YCLstock/transaction-watcher/broker"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// resetUpstream 重置監聽器連線狀態，並在測試結束後再次重置
+func resetUpstream(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		upstream = wsState{}
+		upstreamSubscribed.Store(false)
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// scrapeGauge 返回 /metrics 中無標籤指標的數值
+func scrapeGauge(t *testing.T, name string) float64 {
+	t.Helper()
+	family, exists := scrapeMetrics(t)[name]
+	if !exists || len(family.GetMetric()) != 1 {
+		t.Fatalf("Expected a single %s series", name)
+	}
+	m := family.GetMetric()[0]
+	if m.GetCounter() != nil {
+		return m.GetCounter().GetValue()
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestWSStateConnectTransitions(t *testing.T) {
+	withBrokerRegistry(t)
+	resetUpstream(t)
+
+	if scrapeGauge(t, "ws_connected") != 0 || scrapeGauge(t, "ws_reconnects_total") != 0 {
+		t.Error("Expected disconnected with no reconnects before the first connection")
+	}
+
+	// 第一次連線不計為重新連線
+	upstream.connected()
+	if scrapeGauge(t, "ws_connected") != 1 || scrapeGauge(t, "ws_reconnects_total") != 0 {
+		t.Error("Expected connected with no reconnects after the first connection")
+	}
+
+	// 斷線後再連上兩次
+	for i := 0; i < 2; i++ {
+		upstream.disconnected()
+		if scrapeGauge(t, "ws_connected") != 0 {
+			t.Error("Expected ws_connected 0 after disconnect")
+		}
+		upstream.connected()
+	}
+	if got := scrapeGauge(t, "ws_reconnects_total"); got != 2 {
+		t.Errorf("Expected 2 reconnects, got %v", got)
+	}
+	if !upstreamSubscribed.Load() {
+		t.Error("Expected readiness flag to follow the connection state")
+	}
+}
+
+func TestWSStateRecordsLastBlockTimestamp(t *testing.T) {
+	withBrokerRegistry(t)
+	resetUpstream(t)
+
+	if got := scrapeGauge(t, "last_block_timestamp"); got != 0 {
+		t.Errorf("Expected last_block_timestamp 0 before any block, got %v", got)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	h1 := newTestHeader(1)
+	w := &blockWatcher{clock: broker.NewFakeClock(now), grace: time.Second, fetchTimeout: time.Second}
+	headers := make(chan *types.Header, 1)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(context.Background(), newMockBlockFetcher(h1), headers, errs)
+	}()
+
+	// 送出一個區塊，等待監聽器記錄後再中斷訂閱
+	headers <- h1
+	deadline := time.Now().Add(2 * time.Second)
+	for upstream.lastBlock.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	errs <- errors.New("subscription dropped")
+	<-done
+
+	if got := scrapeGauge(t, "last_block_timestamp"); got != float64(now.Unix()) {
+		t.Errorf("Expected last_block_timestamp %d, got %v", now.Unix(), got)
+	}
+}